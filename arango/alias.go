@@ -0,0 +1,85 @@
+package arango
+
+import (
+	"fmt"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/resolve"
+)
+
+// the ArangoGraph finds the nodes by their aliases
+var _ handler.AliasGraph = (*ArangoGraph)(nil)
+
+// resolveName returns the name of the node owning the alias, names are returned as they are
+func (ag *ArangoGraph) resolveName(name string) string {
+	if _, ok := ag.nodeNameToIDMap.Get(name); ok {
+		return name
+	}
+	if owner, ok := ag.aliases.Resolve(name); ok {
+		return owner
+	}
+	return name
+}
+
+// checkAliases returns an error if a name is the name or an alias of another node
+// id is the ID of the node, blank for a new one
+func (ag *ArangoGraph) checkAliases(id string, names ...string) error {
+	for _, a := range names {
+		if other, ok := ag.nodeNameToIDMap.Get(a); ok && idString(other) != id {
+			return fmt.Errorf("alias %s is the name of another node", a)
+		}
+	}
+	return ag.aliases.Check(id, names...)
+}
+
+// idString reads the ids of the bidimap, put by createBidimap or AddNode
+func idString(id interface{}) string {
+	s, _ := toIDString(id)
+	return s
+}
+
+// aliasNode returns the node of the name or alias
+func (ag *ArangoGraph) aliasNode(name interface{}) (*Node, error) {
+	n, err := ag.GetNode(name)
+	if err != nil {
+		return nil, err
+	}
+	return n.(*Node), nil
+}
+
+// AddAliases(name interface{}, aliases ...string) error
+func (ag *ArangoGraph) AddAliases(name interface{}, aliases ...string) error {
+	n, err := ag.aliasNode(name)
+	if err != nil {
+		return err
+	}
+	if err := ag.checkAliases(n.ID, aliases...); err != nil {
+		return err
+	}
+	for _, a := range aliases {
+		if a == n.Name {
+			return fmt.Errorf("alias %s is the name of the node", a)
+		}
+	}
+	n.Data = resolve.AddAliases(n.Data, handler.AliasesKey, aliases...)
+	return ag.ReplaceNode(n)
+}
+
+// RemoveAliases(name interface{}, aliases ...string) error
+func (ag *ArangoGraph) RemoveAliases(name interface{}, aliases ...string) error {
+	n, err := ag.aliasNode(name)
+	if err != nil {
+		return err
+	}
+	n.Data = resolve.RemoveAliases(n.Data, handler.AliasesKey, aliases...)
+	return ag.ReplaceNode(n)
+}
+
+// GetAliases(name interface{}) ([]string, error)
+func (ag *ArangoGraph) GetAliases(name interface{}) ([]string, error) {
+	n, err := ag.aliasNode(name)
+	if err != nil {
+		return nil, err
+	}
+	return resolve.Aliases(n.Data, handler.AliasesKey), nil
+}
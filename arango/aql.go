@@ -0,0 +1,262 @@
+package arango
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/arangodb/go-driver"
+	"github.com/wonderstone/chainstorm/filter"
+)
+
+// # the typed AQL builder, the safe replacement of QueryGenerator:
+// # the collections and the fields are checked identifiers, every value is a bind variable
+
+var (
+	// collectionPattern are the names arango accepts for the user collections
+	collectionPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_\-]{0,255}$`)
+	// fieldPattern are the dotted attribute paths the builder accepts
+	fieldPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+)
+
+// docVar is the loop variable of the built queries
+const docVar = "doc"
+
+// checkField returns the quoted AQL path of the field of the document
+func checkField(field string) (string, error) {
+	if !fieldPattern.MatchString(field) {
+		return "", fmt.Errorf("invalid field %q", field)
+	}
+	return docVar + ".`" + strings.ReplaceAll(field, ".", "`.`") + "`", nil
+}
+
+// bind adds the value to the bind variables and returns its reference
+func bind(bindVars map[string]interface{}, value interface{}) string {
+	name := fmt.Sprintf("v%d", len(bindVars))
+	bindVars[name] = value
+	return "@" + name
+}
+
+// Cond is a FILTER condition of a built query
+type Cond interface {
+	aql(bindVars map[string]interface{}) (string, error)
+}
+
+type condFunc func(bindVars map[string]interface{}) (string, error)
+
+func (f condFunc) aql(bindVars map[string]interface{}) (string, error) { return f(bindVars) }
+
+// Regex matches the documents whose field matches the regular expression
+func Regex(field, pattern string, caseInsensitive bool) Cond {
+	return condFunc(func(bindVars map[string]interface{}) (string, error) {
+		path, err := checkField(field)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("REGEX_MATCHES(%s, %s, %t)", path, bind(bindVars, pattern), caseInsensitive), nil
+	})
+}
+
+// RegexAny matches the documents with an element of the array field matching the regular expression
+// a single value counts as an array of one, like the aliases
+func RegexAny(field, pattern string, caseInsensitive bool) Cond {
+	return condFunc(func(bindVars map[string]interface{}) (string, error) {
+		path, err := checkField(field)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("LENGTH(TO_ARRAY(%s)[* FILTER REGEX_MATCHES(CURRENT, %s, %t)]) > 0",
+			path, bind(bindVars, pattern), caseInsensitive), nil
+	})
+}
+
+// compareOps are the operators of Compare
+var compareOps = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true, "IN": true, "NOT IN": true}
+
+// Compare compares the field of the documents with the value
+func Compare(field, op string, value interface{}) Cond {
+	return condFunc(func(bindVars map[string]interface{}) (string, error) {
+		path, err := checkField(field)
+		if err != nil {
+			return "", err
+		}
+		if !compareOps[op] {
+			return "", fmt.Errorf("invalid operator %q", op)
+		}
+		return fmt.Sprintf("%s %s %s", path, op, bind(bindVars, value)), nil
+	})
+}
+
+// Data matches the documents whose Data match the filter
+func Data(f filter.Expr) Cond {
+	return condFunc(func(bindVars map[string]interface{}) (string, error) {
+		if err := f.Validate(); err != nil {
+			return "", err
+		}
+		return aqlFilter(f, docVar, bindVars), nil
+	})
+}
+
+// join joins the conditions with the operator, the empty value if none
+func join(op, empty string, conds []Cond) Cond {
+	return condFunc(func(bindVars map[string]interface{}) (string, error) {
+		if len(conds) == 0 {
+			return empty, nil
+		}
+		parts := make([]string, len(conds))
+		for i, c := range conds {
+			s, err := c.aql(bindVars)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return "(" + strings.Join(parts, " "+op+" ") + ")", nil
+	})
+}
+
+// And matches the documents matching all the conditions
+func And(conds ...Cond) Cond { return join("AND", "true", conds) }
+
+// Or matches the documents matching one of the conditions
+func Or(conds ...Cond) Cond { return join("OR", "false", conds) }
+
+// Not matches the documents not matching the condition
+func Not(c Cond) Cond {
+	return condFunc(func(bindVars map[string]interface{}) (string, error) {
+		s, err := c.aql(bindVars)
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + s + ")", nil
+	})
+}
+
+// sortKey is a SORT attribute
+type sortKey struct {
+	field string
+	desc  bool
+}
+
+// Query builds a query over the documents of one or more collections, the union of them
+//
+//	q := NewQuery("company", "fund").Filter(Regex("name", "^600", false)).Sort("name", false).Limit(0, 10)
+//	nodes, err := Fetch[Node](ag, q)
+type Query struct {
+	collections   []string
+	filters       []Cond
+	sorts         []sortKey
+	offset, count int
+}
+
+// NewQuery returns the query of the collections
+func NewQuery(collections ...string) *Query {
+	return &Query{collections: collections}
+}
+
+// Filter adds the conditions, all of them have to match
+func (q *Query) Filter(conds ...Cond) *Query {
+	q.filters = append(q.filters, conds...)
+	return q
+}
+
+// Sort adds a sort attribute
+func (q *Query) Sort(field string, desc bool) *Query {
+	q.sorts = append(q.sorts, sortKey{field, desc})
+	return q
+}
+
+// Limit keeps count documents after offset, a count of 0 keeps them all
+func (q *Query) Limit(offset, count int) *Query {
+	q.offset, q.count = offset, count
+	return q
+}
+
+// Build returns the AQL and its bind variables
+// % the filter goes in every collection, SORT and LIMIT apply to the union
+func (q *Query) Build() (string, map[string]interface{}, error) {
+	if len(q.collections) == 0 {
+		return "", nil, fmt.Errorf("no collection to query")
+	}
+	bindVars := map[string]interface{}{}
+	cols := make([]string, len(q.collections))
+	for i, c := range q.collections {
+		if !collectionPattern.MatchString(c) {
+			return "", nil, fmt.Errorf("invalid collection %q", c)
+		}
+		cols[i] = fmt.Sprintf("@@c%d", i)
+		bindVars[cols[i][1:]] = c
+	}
+	filterLine := ""
+	if len(q.filters) > 0 {
+		cond, err := And(q.filters...).aql(bindVars)
+		if err != nil {
+			return "", nil, err
+		}
+		filterLine = "FILTER " + cond
+	}
+
+	var lines []string
+	if len(cols) == 1 {
+		lines = append(lines, fmt.Sprintf("FOR %s IN %s", docVar, cols[0]))
+		if filterLine != "" {
+			lines = append(lines, filterLine)
+		}
+	} else {
+		subs := make([]string, len(cols))
+		for i, c := range cols {
+			subs[i] = fmt.Sprintf("(FOR %s IN %s %s RETURN %s)", docVar, c, filterLine, docVar)
+		}
+		lines = append(lines, fmt.Sprintf("FOR %s IN UNION(%s)", docVar, strings.Join(subs, ", ")))
+	}
+	if len(q.sorts) > 0 {
+		keys := make([]string, len(q.sorts))
+		for i, s := range q.sorts {
+			path, err := checkField(s.field)
+			if err != nil {
+				return "", nil, err
+			}
+			keys[i] = path
+			if s.desc {
+				keys[i] += " DESC"
+			}
+		}
+		lines = append(lines, "SORT "+strings.Join(keys, ", "))
+	}
+	if q.count > 0 {
+		lines = append(lines, fmt.Sprintf("LIMIT %s, %s", bind(bindVars, q.offset), bind(bindVars, q.count)))
+	}
+	lines = append(lines, "RETURN "+docVar)
+	return strings.Join(lines, "\n"), bindVars, nil
+}
+
+// Fetch runs the query and decodes the documents into T, such as Node or Edge
+// a query without collection finds nothing
+func Fetch[T any](ag *ArangoGraph, q *Query) ([]T, error) {
+	res := []T{}
+	if len(q.collections) == 0 {
+		return res, nil
+	}
+	aql, bindVars, err := q.Build()
+	if err != nil {
+		return nil, err
+	}
+	ctx := ag.context()
+	cursor, err := ag.db.Query(ctx, aql, bindVars)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to execute query: %v", err)
+		return nil, err
+	}
+	defer cursor.Close()
+	for {
+		var doc T
+		_, err := cursor.ReadDocument(ctx, &doc)
+		if driver.IsNoMoreDocuments(err) {
+			return res, nil
+		} else if err != nil {
+			ag.logger.Info().Msgf("Failed to read document: %v", err)
+			return nil, err
+		}
+		res = append(res, doc)
+	}
+}
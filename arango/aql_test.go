@@ -0,0 +1,49 @@
+package arango
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/filter"
+)
+
+func TestBuilder(t *testing.T) {
+	// a single collection needs no UNION
+	aql, bindVars, err := NewQuery("company").Filter(Regex("name", "^600", true)).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "FOR doc IN @@c0\nFILTER (REGEX_MATCHES(doc.`name`, @v1, true))\nRETURN doc", aql)
+	assert.Equal(t, map[string]interface{}{"@c0": "company", "v1": "^600"}, bindVars)
+
+	// several collections share the filter, SORT and LIMIT apply to the union
+	aql, bindVars, err = NewQuery("company", "fund").
+		Filter(Or(Regex("name", "x", false), RegexAny("data.aliases", "x", false)), Data(filter.Gt("size", 10))).
+		Sort("name", true).
+		Limit(5, 10).
+		Build()
+	assert.NoError(t, err)
+	cond := "FILTER ((REGEX_MATCHES(doc.`name`, @v2, false) OR LENGTH(TO_ARRAY(doc.`data`.`aliases`)[* FILTER REGEX_MATCHES(CURRENT, @v3, false)]) > 0) AND " +
+		"(IS_NUMBER(doc.data.`size`) AND doc.data.`size` > @f4))"
+	assert.Equal(t, "FOR doc IN UNION((FOR doc IN @@c0 "+cond+" RETURN doc), (FOR doc IN @@c1 "+cond+" RETURN doc))\n"+
+		"SORT doc.`name` DESC\nLIMIT @v5, @v6\nRETURN doc", aql)
+	assert.Equal(t, map[string]interface{}{"@c0": "company", "@c1": "fund", "v2": "x", "v3": "x", "f4": 10, "v5": 5, "v6": 10}, bindVars)
+
+	// the identifiers are checked, the values never reach the AQL
+	_, _, err = NewQuery("company` REMOVE doc IN company //").Build()
+	assert.Error(t, err)
+	_, _, err = NewQuery("company").Sort("name DESC, doc._key", false).Build()
+	assert.Error(t, err)
+	_, _, err = NewQuery("company").Filter(Compare("name", "LIKE", "%")).Build()
+	assert.Error(t, err)
+	_, _, err = NewQuery().Build()
+	assert.Error(t, err)
+	aql, bindVars, err = NewQuery("company").Filter(Compare("name", "==", `" OR true OR "`)).Build()
+	assert.NoError(t, err)
+	assert.NotContains(t, aql, "OR true")
+	assert.Equal(t, `" OR true OR "`, bindVars["v1"])
+}
+
+func TestQueryGeneratorShape(t *testing.T) {
+	// the deprecated generator reports a wrong shape instead of panicking
+	_, _, err := QueryGenerator([]string{"company"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,294 @@
+package arango
+
+import (
+	"context"
+	"time"
+
+	"github.com/arangodb/go-driver"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+)
+
+// the ArangoGraph has a change feed polling the changes collection
+var _ handler.ChangeGraph = (*ArangoGraph)(nil)
+
+// # record inserts a change document next to the versions, in the same transaction if any.
+// # The padded key generator gives increasing keys, the feed polls the keys after its cursor.
+// # A key is taken at the write and seen at the commit, so a transaction committing late leaves
+// # a gap behind the cursor: the feed polls the keys of the last changesLookback for the ones
+// # it has not seen and sends them late. A transaction committing more than changesLookback
+// # after its write, or a gap behind the cursor of a resumed feed, is not filled.
+// # A late change keeps its own key as cursor, below the keys sent before it: a feed resumed from it
+// # sends these again. The feed is at-least-once, a change sent twice has the same cursor
+
+const (
+	// keyGeneratorPadded makes the keys sortable as strings
+	keyGeneratorPadded = driver.KeyGeneratorType("padded")
+	// changesRetention is how long the change documents are kept
+	changesRetention = 7 * 24 * time.Hour
+	// changesPoll is the interval of the polls, changesLookback how long the gaps wait for their commits
+	changesPoll     = time.Second
+	changesLookback = time.Minute
+	// changesBatch is how many changes a poll reads at most
+	changesBatch = 1000
+)
+
+// changeDoc is the stored handler.ChangeEvent
+// ~ time is an ISO date for the TTL index
+type changeDoc struct {
+	Key        string       `json:"_key,omitempty"`
+	Op         history.Op   `json:"op"`
+	Kind       history.Kind `json:"kind"`
+	Item       string       `json:"item"`
+	Collection string       `json:"collection"`
+	Before     *versionDoc  `json:"before,omitempty"`
+	After      *versionDoc  `json:"after,omitempty"`
+	Time       time.Time    `json:"time"`
+}
+
+func toChangeDoc(e handler.ChangeEvent) changeDoc {
+	d := changeDoc{Op: e.Op, Kind: e.Kind, Item: e.ID, Collection: e.Collection, Time: e.Time}
+	if e.Before != nil {
+		v := toVersionDoc(*e.Before)
+		d.Before = &v
+	}
+	if e.After != nil {
+		v := toVersionDoc(*e.After)
+		d.After = &v
+	}
+	return d
+}
+
+func (d changeDoc) event() handler.ChangeEvent {
+	e := handler.ChangeEvent{Cursor: d.Key, Op: d.Op, Kind: d.Kind, ID: d.Item, Collection: d.Collection, Time: d.Time.UTC()}
+	if d.Before != nil {
+		v := d.Before.version()
+		e.Before = &v
+	}
+	if d.After != nil {
+		v := d.After.version()
+		e.After = &v
+	}
+	return e
+}
+
+// ensureChangesCollection creates the changes collection with its key generator and TTL index
+// it runs once, ag.changes keeps the collection
+func (ag *ArangoGraph) ensureChangesCollection() error {
+	if ag.changes != nil {
+		return nil
+	}
+	// # DDL is not transactional, it runs outside the transactions
+	ctx := context.Background()
+	exists, err := ag.db.CollectionExists(ctx, handler.ChangesCollection)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to check for collection: %v", err)
+		return err
+	}
+	var col driver.Collection
+	if exists {
+		col, err = ag.db.Collection(ctx, handler.ChangesCollection)
+	} else {
+		col, err = ag.db.CreateCollection(ctx, handler.ChangesCollection, &driver.CreateCollectionOptions{
+			KeyOptions: &driver.CollectionKeyOptions{Type: keyGeneratorPadded},
+		})
+	}
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to open changes collection: %v", err)
+		return err
+	}
+	if _, _, err := col.EnsureTTLIndex(ctx, "time", int(changesRetention.Seconds()), nil); err != nil {
+		ag.logger.Info().Msgf("Failed to create index: %v", err)
+		return err
+	}
+	ag.changes = col
+	return nil
+}
+
+// publish stores the event of a write
+func (ag *ArangoGraph) publish(ctx context.Context, e handler.ChangeEvent) error {
+	if err := ag.ensureChangesCollection(); err != nil {
+		return err
+	}
+	if _, err := ag.changes.CreateDocument(ctx, toChangeDoc(e)); err != nil {
+		ag.logger.Info().Msgf("Failed to insert change: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Subscribe(ctx context.Context, f handler.ChangeFilter) (<-chan handler.ChangeEvent, error)
+// the cursor is the key of the change document, resuming from a late change sends the later keys again
+func (ag *ArangoGraph) Subscribe(ctx context.Context, f handler.ChangeFilter) (<-chan handler.ChangeEvent, error) {
+	if err := ag.ensureChangesCollection(); err != nil {
+		return nil, err
+	}
+	after := f.Cursor
+	if after != "" {
+		// ~ the change of the cursor expired, the ones after it may have too
+		exists, err := ag.changes.DocumentExists(ctx, after)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, handler.ErrCursorExpired
+		}
+	} else {
+		last, err := ag.readChanges(ctx, `
+			FOR c IN @@col
+			SORT c._key DESC
+			LIMIT 1
+			RETURN c
+		`, map[string]interface{}{"@col": handler.ChangesCollection})
+		if err != nil {
+			return nil, err
+		}
+		if len(last) > 0 {
+			after = last[0].Key
+		}
+	}
+
+	ch := make(chan handler.ChangeEvent)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(changesPoll)
+		defer ticker.Stop()
+		gaps := newChangeGaps(after)
+		for {
+			docs, err := ag.readChanges(ctx, `
+				FOR c IN @@col
+				FILTER c._key > @after
+				SORT c._key
+				LIMIT @batch
+				RETURN c
+			`, map[string]interface{}{"@col": handler.ChangesCollection, "after": gaps.after, "batch": changesBatch})
+			if err != nil {
+				return
+			}
+			// a full batch is read on without waiting
+			more := len(docs) == changesBatch
+			late, err := ag.lateChanges(ctx, gaps)
+			if err != nil {
+				return
+			}
+			for _, d := range append(late, docs...) {
+				gaps.seen(d.Key, d.Time)
+				e := d.event()
+				if !f.Match(e) {
+					continue
+				}
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+			gaps.expire(time.Now())
+			if more {
+				continue
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// lateChanges returns the changes committed behind the cursor since the last poll
+func (ag *ArangoGraph) lateChanges(ctx context.Context, gaps *changeGaps) ([]changeDoc, error) {
+	if gaps.after <= gaps.floor {
+		return nil, nil
+	}
+	keys, err := ag.readChanges(ctx, `
+		FOR c IN @@col
+		FILTER c._key > @floor AND c._key <= @after
+		RETURN {_key: c._key}
+	`, map[string]interface{}{"@col": handler.ChangesCollection, "floor": gaps.floor, "after": gaps.after})
+	if err != nil {
+		return nil, err
+	}
+	missing := gaps.missing(keys)
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	return ag.readChanges(ctx, `
+		FOR c IN @@col
+		FILTER c._key IN @keys
+		SORT c._key
+		RETURN c
+	`, map[string]interface{}{"@col": handler.ChangesCollection, "keys": missing})
+}
+
+// changeGaps tells the changes a feed has not seen behind its cursor
+// ~ the padded keys are increasing, not consecutive: a gap is a key read in the window and not seen
+type changeGaps struct {
+	// after is the greatest key seen, floor the one the window starts after
+	after string
+	floor string
+	// sent holds the keys of the window with the time of their change
+	sent map[string]time.Time
+}
+
+func newChangeGaps(after string) *changeGaps {
+	return &changeGaps{after: after, floor: after, sent: make(map[string]time.Time)}
+}
+
+// seen marks the change as sent
+func (g *changeGaps) seen(key string, at time.Time) {
+	g.sent[key] = at
+	if key > g.after {
+		g.after = key
+	}
+}
+
+// missing returns the keys of the window not seen yet
+func (g *changeGaps) missing(docs []changeDoc) []string {
+	var res []string
+	for _, d := range docs {
+		if _, ok := g.sent[d.Key]; !ok && d.Key > g.floor && d.Key <= g.after {
+			res = append(res, d.Key)
+		}
+	}
+	return res
+}
+
+// expire moves the window past the changes older than changesLookback, their gaps are not filled anymore
+func (g *changeGaps) expire(now time.Time) {
+	limit := now.Add(-changesLookback)
+	for key, at := range g.sent {
+		if at.Before(limit) {
+			delete(g.sent, key)
+			if key > g.floor {
+				g.floor = key
+			}
+		}
+	}
+}
+
+// readChanges runs the query and reads all the change documents
+func (ag *ArangoGraph) readChanges(ctx context.Context, query string, bindVars map[string]interface{}) ([]changeDoc, error) {
+	cursor, err := ag.db.Query(ctx, query, bindVars)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to execute query: %v", err)
+		return nil, err
+	}
+	defer cursor.Close()
+
+	docs := []changeDoc{}
+	for {
+		var doc changeDoc
+		_, err := cursor.ReadDocument(ctx, &doc)
+		if driver.IsNoMoreDocuments(err) {
+			break
+		}
+		if err != nil {
+			ag.logger.Info().Msgf("Failed to read document: %v", err)
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
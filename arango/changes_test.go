@@ -0,0 +1,101 @@
+package arango
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+)
+
+func TestChangeDoc(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	before := history.Version{ID: "v1", ItemID: "company/a", Kind: history.NodeKind, Op: history.Create, Collection: "company",
+		Name: "600001", ValidFrom: at, ValidTo: history.EndOfTime, TxFrom: at, TxTo: history.EndOfTime}
+	e := handler.NewChangeEvent("company/a", []history.Version{before}, nil, at)
+
+	raw, err := json.Marshal(toChangeDoc(e))
+	assert.NoError(t, err)
+	// the TTL index reads an ISO date
+	assert.Contains(t, string(raw), `"time":"2024-01-02T03:04:05Z"`)
+	assert.NotContains(t, string(raw), "after")
+
+	var doc changeDoc
+	assert.NoError(t, json.Unmarshal(raw, &doc))
+	doc.Key = "0000000000000001"
+	got := doc.event()
+	assert.Equal(t, "0000000000000001", got.Cursor)
+	assert.Equal(t, history.Delete, got.Op)
+	assert.Equal(t, "company", got.Collection)
+	assert.Equal(t, before, *got.Before)
+	assert.Nil(t, got.After)
+	assert.Equal(t, at, got.Time)
+}
+
+func TestChangeGaps(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	keys := func(ks ...string) []changeDoc {
+		docs := make([]changeDoc, len(ks))
+		for i, k := range ks {
+			docs[i] = changeDoc{Key: k}
+		}
+		return docs
+	}
+	g := newChangeGaps("0000000000000001")
+
+	// 3 committed before 2, the poll sends 3 and leaves a gap at 2
+	g.seen("0000000000000003", at)
+	assert.Equal(t, "0000000000000003", g.after)
+	assert.Equal(t, []string{"0000000000000002"}, g.missing(keys("0000000000000002", "0000000000000003")))
+
+	// 2 is filled by the next poll, it is not sent twice
+	g.seen("0000000000000002", at)
+	assert.Equal(t, "0000000000000003", g.after)
+	assert.Empty(t, g.missing(keys("0000000000000002", "0000000000000003")))
+
+	// the window keeps the changes of changesLookback
+	g.seen("0000000000000005", at.Add(changesLookback))
+	g.expire(at.Add(changesLookback))
+	assert.Equal(t, "0000000000000001", g.floor)
+	assert.Equal(t, []string{"0000000000000004"}, g.missing(keys("0000000000000004", "0000000000000005")))
+
+	// past it the window moves on, a later commit of 4 is never sent
+	g.expire(at.Add(changesLookback + time.Second))
+	assert.Equal(t, "0000000000000003", g.floor)
+	assert.Equal(t, []string{"0000000000000004"}, g.missing(keys("0000000000000004", "0000000000000005")))
+	g.expire(at.Add(2*changesLookback + time.Second))
+	assert.Equal(t, "0000000000000005", g.floor)
+	assert.Empty(t, g.missing(keys("0000000000000004", "0000000000000005")))
+	assert.Empty(t, g.sent)
+}
+
+func TestChangeGapsResume(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	stored := []string{"0000000000000002", "0000000000000003"}
+	// poll returns the keys the feed reads after its cursor
+	poll := func(g *changeGaps) []string {
+		var res []string
+		for _, k := range stored {
+			if k > g.after {
+				res = append(res, k)
+			}
+		}
+		return res
+	}
+	g := newChangeGaps("0000000000000001")
+
+	// 3 is sent first, then 2 late with its own key as cursor
+	var cursors []string
+	for _, k := range []string{"0000000000000003", "0000000000000002"} {
+		g.seen(k, at)
+		cursors = append(cursors, changeDoc{Key: k}.event().Cursor)
+	}
+	assert.Equal(t, []string{"0000000000000003", "0000000000000002"}, cursors)
+	assert.Empty(t, poll(g))
+
+	// resuming from the last cursor received sends 3 again, with the same cursor
+	r := newChangeGaps(cursors[len(cursors)-1])
+	assert.Equal(t, []string{"0000000000000003"}, poll(r))
+}
@@ -0,0 +1,34 @@
+package arango
+
+import (
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+// the ArangoGraph tells its size
+var _ handler.CountingGraph = (*ArangoGraph)(nil)
+
+// CountItems() (nodes, edges int64, err error)
+func (ag *ArangoGraph) CountItems() (nodes, edges int64, err error) {
+	ctx := ag.context()
+	nodeCols, edgeCols, err := ag.graphCollections(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	counts := []*int64{&nodes, &edges}
+	for i, cols := range [][]string{nodeCols, edgeCols} {
+		for _, name := range cols {
+			col, err := ag.db.Collection(ctx, name)
+			if err != nil {
+				ag.logger.Info().Msgf("Failed to open collection: %v", err)
+				return 0, 0, err
+			}
+			n, err := col.Count(ctx)
+			if err != nil {
+				ag.logger.Info().Msgf("Failed to count collection: %v", err)
+				return 0, 0, err
+			}
+			*counts[i] += n
+		}
+	}
+	return nodes, edges, nil
+}
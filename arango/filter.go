@@ -0,0 +1,139 @@
+package arango
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/arangodb/go-driver"
+	"github.com/wonderstone/chainstorm/filter"
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+// the ArangoGraph translates the filters to AQL
+var _ handler.FilterGraph = (*ArangoGraph)(nil)
+
+// aqlPath returns the AQL path of the Data field, the keys are quoted
+func aqlPath(doc, path string) string {
+	return doc + ".data.`" + strings.Join(filter.Segments(path), "`.`") + "`"
+}
+
+// aqlFilter translates a valid filter on the variable doc, the values are added to bindVars
+// # the ranges check the type first, AQL would order a string after any number
+func aqlFilter(f filter.Expr, doc string, bindVars map[string]interface{}) string {
+	switch f.Op {
+	case filter.OpAnd, filter.OpOr:
+		if len(f.Exprs) == 0 {
+			return fmt.Sprint(f.Op == filter.OpAnd)
+		}
+		parts := make([]string, len(f.Exprs))
+		for i, sub := range f.Exprs {
+			parts[i] = aqlFilter(sub, doc, bindVars)
+		}
+		return "(" + strings.Join(parts, " "+strings.ToUpper(string(f.Op))+" ") + ")"
+	case filter.OpNot:
+		return "NOT (" + aqlFilter(f.Exprs[0], doc, bindVars) + ")"
+	}
+
+	return aqlCompare(aqlPath(doc, f.Path), f, bindVars)
+}
+
+// aqlCompare translates the comparison of a valid filter on the AQL path
+func aqlCompare(path string, f filter.Expr, bindVars map[string]interface{}) string {
+	if f.Op == filter.OpExists {
+		return path + " != null"
+	}
+	name := fmt.Sprintf("f%d", len(bindVars))
+	bindVars[name] = f.Value
+	switch f.Op {
+	case filter.OpEq:
+		return fmt.Sprintf("%s == @%s", path, name)
+	case filter.OpNe:
+		return fmt.Sprintf("%s != @%s", path, name)
+	case filter.OpIn:
+		bindVars[name] = filter.Values(f.Value)
+		return fmt.Sprintf("%s IN @%s", path, name)
+	}
+	check := "IS_NUMBER"
+	if _, ok := f.Value.(string); ok {
+		check = "IS_STRING"
+	}
+	symbol := map[filter.Op]string{filter.OpGt: ">", filter.OpGte: ">=", filter.OpLt: "<", filter.OpLte: "<="}[f.Op]
+	return fmt.Sprintf("(%s(%s) AND %s %s @%s)", check, path, path, symbol, name)
+}
+
+// itemCollections returns the node or edge collections, only the given one if not blank
+func (ag *ArangoGraph) itemCollections(ctx context.Context, collection string, kind driver.CollectionType) ([]string, error) {
+	collections, err := ag.db.Collections(ctx)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to list collections: %v", err)
+		return nil, err
+	}
+	var res []string
+	for _, col := range collections {
+		if collection != "" && col.Name() != collection {
+			continue
+		}
+		props, err := col.Properties(ctx)
+		if err != nil {
+			ag.logger.Info().Msgf("Failed to get collection properties: %v", err)
+			return nil, err
+		}
+		// Skip system and chainstorm collections
+		if props.IsSystem || handler.IsReserved(col.Name()) || props.Type != kind {
+			continue
+		}
+		res = append(res, col.Name())
+	}
+	return res, nil
+}
+
+// FindNodes(collection string, f filter.Expr) ([]handler.Node, error)
+// the nodes are sorted by name
+func (ag *ArangoGraph) FindNodes(collection string, f filter.Expr) ([]handler.Node, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	ctx := ag.context()
+	collections, err := ag.itemCollections(ctx, collection, driver.CollectionTypeDocument)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := Fetch[Node](ag, NewQuery(collections...).Filter(Data(f)))
+	if err != nil {
+		return nil, err
+	}
+	// % sorted here, AQL compares the strings with the collation of the server
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	result := make([]handler.Node, len(nodes))
+	for i := range nodes {
+		result[i] = &nodes[i]
+	}
+	return result, nil
+}
+
+// FindEdges(collection string, f filter.Expr) ([]handler.Edge, error)
+// the edges are sorted by ID
+func (ag *ArangoGraph) FindEdges(collection string, f filter.Expr) ([]handler.Edge, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	ctx := ag.context()
+	collections, err := ag.itemCollections(ctx, collection, driver.CollectionTypeEdge)
+	if err != nil {
+		return nil, err
+	}
+	edges, err := Fetch[Edge](ag, NewQuery(collections...).Filter(Data(f)))
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(edges, func(i, j int) bool { return edges[i].ID < edges[j].ID })
+
+	result := make([]handler.Edge, len(edges))
+	for i := range edges {
+		result[i] = &edges[i]
+	}
+	return result, nil
+}
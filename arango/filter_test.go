@@ -0,0 +1,31 @@
+package arango
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/filter"
+	"github.com/wonderstone/chainstorm/query"
+)
+
+func TestAqlFilter(t *testing.T) {
+	bindVars := map[string]interface{}{}
+	aql := aqlFilter(filter.And(
+		filter.Gt("companyRevenue", 1e6),
+		filter.Eq("address.city", "Boston"),
+		filter.Not(filter.In("companyCountry", "CN", "RU")),
+		filter.Exists("ceo"),
+	), "doc", bindVars)
+	assert.Equal(t, "((IS_NUMBER(doc.data.`companyRevenue`) AND doc.data.`companyRevenue` > @f0) AND "+
+		"doc.data.`address`.`city` == @f1 AND NOT (doc.data.`companyCountry` IN @f2) AND doc.data.`ceo` != null)", aql)
+	assert.Equal(t, map[string]interface{}{"f0": 1e6, "f1": "Boston", "f2": []interface{}{"CN", "RU"}}, bindVars)
+}
+
+func TestAqlCond(t *testing.T) {
+	q, err := query.Parse(`MATCH (a)-[]->(b) WHERE a.Name = "600001" OR NOT EXISTS(b.Data.ceo.name) RETURN b`)
+	assert.NoError(t, err)
+	bindVars := map[string]interface{}{}
+	aql := aqlCond(*q.Where, map[string]string{"a": "n0", "b": "n1"}, bindVars)
+	assert.Equal(t, "(n0.name == @f0 OR NOT (n1.data.`ceo`.`name` != null))", aql)
+	assert.Equal(t, map[string]interface{}{"f0": "600001"}, bindVars)
+}
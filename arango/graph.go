@@ -0,0 +1,191 @@
+package arango
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/arangodb/go-driver"
+	"github.com/emirpasic/gods/maps/hashbidimap"
+)
+
+// # the named graph: every edge collection links all the node collections,
+// # AddNode and AddEdge sync it when they create a collection, the traversals run on it
+
+// DefaultGraphName is the name of the graph when the config gives none
+const DefaultGraphName = "chainstorm"
+
+// graphCollections returns the node and the edge collections the graph should hold
+func (ag *ArangoGraph) graphCollections(ctx context.Context) ([]string, []string, error) {
+	nodeCols, err := ag.itemCollections(ctx, "", driver.CollectionTypeDocument)
+	if err != nil {
+		return nil, nil, err
+	}
+	edgeCols, err := ag.itemCollections(ctx, "", driver.CollectionTypeEdge)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(nodeCols)
+	sort.Strings(edgeCols)
+	return nodeCols, edgeCols, nil
+}
+
+// sameCollections tells whether the two lists hold the same collections
+func sameCollections(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sorted := append([]string(nil), a...)
+	sort.Strings(sorted)
+	for i := range sorted {
+		if sorted[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateGraph() error
+// creates the named graph over the current collections, it fails if the graph exists
+func (ag *ArangoGraph) CreateGraph() error {
+	ctx := context.Background()
+	nodeCols, edgeCols, err := ag.graphCollections(ctx)
+	if err != nil {
+		return err
+	}
+
+	// create the []driver.EdgeDefinition slice
+	options := driver.CreateGraphOptions{}
+	if len(nodeCols) > 0 {
+		for _, col := range edgeCols {
+			options.EdgeDefinitions = append(options.EdgeDefinitions, driver.EdgeDefinition{
+				Collection: col,
+				From:       nodeCols,
+				To:         nodeCols,
+			})
+		}
+	}
+	// % without edges the node collections are orphans
+	if len(options.EdgeDefinitions) == 0 {
+		options.OrphanVertexCollections = nodeCols
+	}
+
+	ag.graph, err = ag.db.CreateGraphV2(ctx, ag.graphname, &options)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to create graph: %v", err)
+		ag.graph = nil
+		return err
+	}
+	ag.logger.Info().Msgf("Graph %s created", ag.graphname)
+	return nil
+}
+
+// DeleteGraph(dropCollections bool) error
+// removes the named graph, with its nodes and edges if dropCollections
+func (ag *ArangoGraph) DeleteGraph(dropCollections bool) error {
+	ctx := context.Background()
+	graph, err := ag.Graph()
+	if err != nil {
+		return err
+	}
+	err = graph.RemoveWithOpts(ctx, &driver.RemoveGraphOptions{DropCollections: dropCollections})
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to remove graph: %v", err)
+		return err
+	}
+	ag.graph = nil
+	if !dropCollections {
+		return nil
+	}
+	// rebuild the bidimap
+	ag.nodeNameToIDMap = hashbidimap.New()
+	return ag.createBidimap()
+}
+
+// SyncGraph() error
+// creates the named graph if missing, otherwise adds the new collections to its edge definitions
+func (ag *ArangoGraph) SyncGraph() error {
+	ctx := context.Background()
+	exists, err := ag.db.GraphExists(ctx, ag.graphname)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to check graph: %v", err)
+		return err
+	}
+	if !exists {
+		return ag.CreateGraph()
+	}
+	graph, err := ag.db.Graph(ctx, ag.graphname)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to open graph: %v", err)
+		return err
+	}
+	ag.graph = graph
+
+	nodeCols, edgeCols, err := ag.graphCollections(ctx)
+	if err != nil {
+		return err
+	}
+	cols, constraints, err := graph.EdgeCollections(ctx)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to list edge definitions: %v", err)
+		return err
+	}
+	defined := make(map[string]driver.VertexConstraints, len(cols))
+	for i, col := range cols {
+		defined[col.Name()] = constraints[i]
+	}
+
+	all := driver.VertexConstraints{From: nodeCols, To: nodeCols}
+	for _, col := range edgeCols {
+		if len(nodeCols) == 0 {
+			break
+		}
+		c, ok := defined[col]
+		switch {
+		case !ok:
+			if _, err := graph.CreateEdgeCollection(ctx, col, all); err != nil {
+				ag.logger.Info().Msgf("Failed to add edge collection %s: %v", col, err)
+				return err
+			}
+		case !sameCollections(c.From, nodeCols) || !sameCollections(c.To, nodeCols):
+			if err := graph.SetVertexConstraints(ctx, col, all); err != nil {
+				ag.logger.Info().Msgf("Failed to update edge collection %s: %v", col, err)
+				return err
+			}
+		}
+	}
+
+	// % the node collections out of the edge definitions stay orphans
+	if len(edgeCols) == 0 {
+		for _, col := range nodeCols {
+			ok, err := graph.VertexCollectionExists(ctx, col)
+			if err != nil {
+				ag.logger.Info().Msgf("Failed to check vertex collection %s: %v", col, err)
+				return err
+			}
+			if ok {
+				continue
+			}
+			if _, err := graph.CreateVertexCollection(ctx, col); err != nil {
+				ag.logger.Info().Msgf("Failed to add vertex collection %s: %v", col, err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Graph() (driver.Graph, error)
+// returns the named graph, synced first if not open yet
+func (ag *ArangoGraph) Graph() (driver.Graph, error) {
+	if ag.graph != nil {
+		return ag.graph, nil
+	}
+	if err := ag.SyncGraph(); err != nil {
+		return nil, err
+	}
+	if ag.graph == nil {
+		return nil, fmt.Errorf("graph %s not open", ag.graphname)
+	}
+	return ag.graph, nil
+}
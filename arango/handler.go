@@ -4,51 +4,74 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 
 	"github.com/arangodb/go-driver"
 	"github.com/arangodb/go-driver/http"
 	"github.com/emirpasic/gods/maps/hashbidimap"
+	"github.com/wonderstone/chainstorm/config"
 	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"github.com/wonderstone/chainstorm/merge"
+	"github.com/wonderstone/chainstorm/provenance"
+	"github.com/wonderstone/chainstorm/resolve"
+	"github.com/wonderstone/chainstorm/search"
 	"github.com/wonderstone/chainstorm/tools"
-	"gopkg.in/yaml.v3"
 )
 
 // - Init operations
 // Init(yamlPath string) error
 func (ag *ArangoGraph) Init(yamlPath string) error {
-	// read the yaml file
-	yamlData, err := os.ReadFile(yamlPath)
-
+	// read the yaml file into the typed config, the environment overrides it
+	c, err := config.LoadArango(yamlPath)
 	if err != nil {
 		return err
 	}
+	return ag.Configure(c)
+}
 
-	// unmarshal the yaml data into a map
-	var data map[string]interface{}
-	err = yaml.Unmarshal(yamlData, &data)
+// Configure(c *config.Arango) error
+// + Init reads the config from a file, Configure takes it as it is
+func (ag *ArangoGraph) Configure(c *config.Arango) error {
+	var err error
+	// get the config data
+	ag.username = c.Username
+	ag.password = c.Password
+	ag.server = c.Server
+	ag.port = c.Port
+	ag.dbname = c.DBName
+	// graph part, the traversals run on the named graph
+	ag.graphname = c.GraphName
+	if ag.graphname == "" {
+		ag.graphname = DefaultGraphName
+	}
+	// bidiMap
+	ag.nodeNameToIDMap = hashbidimap.New()
+	ag.aliases = &resolve.AliasIndex{}
 
+	// logger, the section is optional
+	if ag.logger != nil {
+		ag.logger.Close()
+	}
+	ag.logger, err = tools.NewLogger(c.Logger)
 	if err != nil {
 		return err
 	}
 
-	// get the yaml data
-	ag.username = data["username"].(string)
-	ag.password = data["password"].(string)
-	ag.server = data["server"].(string)
-	ag.port = data["port"].(int)
-	ag.dbname = data["dbname"].(string)
-	// graph part
-	ag.graphname = data["graphname"].(string)
-	// bidiMap
-	ag.nodeNameToIDMap = hashbidimap.New()
+	// merge strategies, the section is optional
+	ag.merger, err = merge.Parse(c.Merge)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to parse merge config: %v", err)
+		return err
+	}
 
-	// logger
-	loggerConfig := data["logger"].(map[string]interface{})
-	logger := tools.NewLogger(loggerConfig)
-	ag.logger = &logger
+	// full-text search fields, the section is optional
+	ag.searcher, err = search.Parse(c.Search)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to parse search config: %v", err)
+		return err
+	}
 
 	// log out: say init success
 	ag.logger.Info().Msgf("ArangoGraph initialized")
@@ -67,6 +90,8 @@ func (ag *ArangoGraph) Connect() error {
 	})
 	if err != nil {
 		ag.logger.Info().Msgf("Failed to create connection: %v", err)
+	} else {
+		conn = traceConnection(conn)
 	}
 
 	ag.Client, err = driver.NewClient(driver.ClientConfig{
@@ -89,8 +114,9 @@ func (ag *ArangoGraph) Connect() error {
 	if err != nil {
 		return err
 	}
-	
-	return nil
+
+	// the writes publish their changes, the collection is ensured once here
+	return ag.ensureChangesCollection()
 }
 
 // - bidiMap creation
@@ -112,8 +138,8 @@ func (ag *ArangoGraph) createBidimap() error {
 			return err
 		}
 
-		// Skip system collections
-		if props.IsSystem {
+		// Skip system and chainstorm collections
+		if props.IsSystem || handler.IsReserved(col.Name()) {
 			continue
 		}
 
@@ -158,6 +184,8 @@ func (ag *ArangoGraph) createBidimap() error {
 			}
 			// add the node name to the bidimap
 			ag.nodeNameToIDMap.Put(doc.Name, doc.ID)
+			// index the aliases of the node
+			ag.aliases.Track(doc.ID, history.NodeVersion(history.Create, doc.ID, doc.Collection, doc.Name, doc.Data), handler.AliasesKey)
 		}
 
 	}
@@ -171,8 +199,8 @@ func (ag *ArangoGraph) createBidimap() error {
 			return err
 		}
 
-		// Skip system collections
-		if props.IsSystem {
+		// Skip system and chainstorm collections
+		if props.IsSystem || handler.IsReserved(col.Name()) {
 			continue
 		}
 
@@ -231,84 +259,8 @@ func (ag *ArangoGraph) Disconnect() error {
 	// ! If clients do not wish to use the keep-alive feature,
 	// ! they should explicitly indicate that by sending a Connection: Close HTTP header in the request.
 	// https://docs.arangodb.com/3.10/develop/http-api/general-request-handling/
-	return nil
-}
-
-// % createGraph
-func (ag *ArangoGraph) createGraph() error {
-	// get all the collections
-
-	ctx := context.Background()
-	collections, err := ag.db.Collections(ctx)
-	if err != nil {
-		ag.logger.Info().Msgf("Failed to list collections: %v", err)
-		return err
-	}
-
-	// iter all the collections and output the non-system collections to nodeCollections and edgeCollections
-	var nodeCollections []string
-	var edgeCollections []string
-	for _, col := range collections {
-		props, err := col.Properties(ctx)
-		if err != nil {
-			ag.logger.Info().Msgf("Failed to get collection properties: %v", err)
-			return err
-		}
-
-		// Skip system collections
-		if props.IsSystem {
-			continue
-		}
-
-		// Skip edge collections
-		if props.Type == driver.CollectionTypeEdge {
-			edgeCollections = append(edgeCollections, col.Name())
-			continue
-		}
-
-		nodeCollections = append(nodeCollections, col.Name())
-	}
-
-	// create the []driver.EdgeDefinition slice
-	var edgeDefinitions []driver.EdgeDefinition
-	for _, col := range edgeCollections {
-		edgeDefinitions = append(edgeDefinitions, driver.EdgeDefinition{
-			Collection: col,
-			From:       nodeCollections,
-			To:         nodeCollections,
-		})
-	}
-
-	options := driver.CreateGraphOptions{
-		EdgeDefinitions: edgeDefinitions,
-	}
-
-	// create the graph
-	ag.graph, err = ag.db.CreateGraphV2(context.TODO(), ag.graphname, &options)
-	// ag.graph, err = ag.db.CreateGraphV2(context.TODO(), ag.graphname, nil)
-
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-// % deleteGraph
-func (ag *ArangoGraph) deleteGraph() error{
-	ctx := context.Background()
-	options := driver.RemoveGraphOptions{
-		DropCollections: true,
-	}
-
-	err := ag.graph.RemoveWithOpts(ctx, &options)
-	if err != nil {
-		return err
-	}
-	// rebuild the bidimap
-	ag.nodeNameToIDMap = hashbidimap.New()
-	err = ag.createBidimap()
-	return err
-
+	// the log files are released, a later message opens them again
+	return ag.logger.Close()
 }
 
 // - CRUD operations
@@ -316,7 +268,7 @@ func (ag *ArangoGraph) deleteGraph() error{
 
 // checkItemExists checks if the node exists
 func (ag *ArangoGraph) checkItemExists(id string) (bool, error) {
-	ctx := context.Background()
+	ctx := ag.context()
 	// split the id into collection and name
 	infos := strings.Split(id, "/")
 	if len(infos) != 2 {
@@ -365,8 +317,20 @@ func (ag *ArangoGraph) AddNode(ni handler.Node) (interface{}, error) {
 	default:
 		return nil, fmt.Errorf("invalid input")
 	}
+	// # split the provenance out of the data
+	data, src, err := provenance.Extract(n.Data)
+	if err != nil {
+		ag.logger.Info().Msgf("Invalid provenance: %v", err)
+		return nil, err
+	}
+	n.Data = data
+	// # the names and the aliases are unique together
+	if err := ag.checkAliases("", append([]string{n.Name}, resolve.Aliases(n.Data, handler.AliasesKey)...)...); err != nil {
+		ag.logger.Info().Msgf("Invalid alias: %v", err)
+		return nil, err
+	}
 	// add node to the arangodb
-	ctx := context.Background()
+	ctx := ag.context()
 	// # Open a database
 	db, err := ag.Client.Database(ctx, ag.dbname)
 	if err != nil {
@@ -379,6 +343,10 @@ func (ag *ArangoGraph) AddNode(ni handler.Node) (interface{}, error) {
 		return nil, err
 	} else {
 		if !exists {
+			// the collections of a transaction are declared when it begins
+			if ag.txID != "" {
+				return nil, fmt.Errorf("collection %s does not exist, create it before the transaction", n.Collection)
+			}
 			// create a collection
 			col, err := db.CreateCollection(ctx, n.Collection, nil)
 			if err != nil {
@@ -394,6 +362,10 @@ func (ag *ArangoGraph) AddNode(ni handler.Node) (interface{}, error) {
 				ag.logger.Info().Msgf("Failed to create index: %v", err)
 				return nil, err
 			}
+			// the edge definitions take the new node collection
+			if err := ag.SyncGraph(); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -433,12 +405,18 @@ func (ag *ArangoGraph) AddNode(ni handler.Node) (interface{}, error) {
 	// # it is the arangodb's former operations that check if the document already exists
 	// # bidiMap actually will replace the old value with the new value
 	ag.nodeNameToIDMap.Put(n.Name, meta.ID)
+	if err := ag.record(history.Create, meta.ID, n.Data); err != nil {
+		return nil, err
+	}
+	if err := ag.assert(history.Create, meta.ID, history.NodeKind, n.Collection, n.Data, src); err != nil {
+		return nil, err
+	}
 	return meta, nil
 }
 
 // AddEdge(e Edge) (interface{}, error)
 func (ag *ArangoGraph) AddEdge(ei handler.Edge) (interface{}, error) {
-	ctx := context.Background()
+	ctx := ag.context()
 	// convert the handler.Edge to Edge
 	var e Edge
 	switch v := ei.(type) {
@@ -448,6 +426,13 @@ func (ag *ArangoGraph) AddEdge(ei handler.Edge) (interface{}, error) {
 		ag.logger.Info().Msgf("Invalid input")
 		return nil, fmt.Errorf("invalid input")
 	}
+	// # split the provenance out of the data
+	data, src, err := provenance.Extract(e.Data)
+	if err != nil {
+		ag.logger.Info().Msgf("Invalid provenance: %v", err)
+		return nil, err
+	}
+	e.Data = data
 
 	// # check if the collection exists
 	if exists, err := ag.db.CollectionExists(ctx, e.Collection); err != nil {
@@ -455,6 +440,10 @@ func (ag *ArangoGraph) AddEdge(ei handler.Edge) (interface{}, error) {
 		return nil, err
 	} else {
 		if !exists {
+			// the collections of a transaction are declared when it begins
+			if ag.txID != "" {
+				return nil, fmt.Errorf("collection %s does not exist, create it before the transaction", e.Collection)
+			}
 			// create an edge collection
 			col, err := ag.db.CreateCollection(ctx, e.Collection, &driver.CreateCollectionOptions{
 				Type: driver.CollectionTypeEdge,
@@ -464,6 +453,10 @@ func (ag *ArangoGraph) AddEdge(ei handler.Edge) (interface{}, error) {
 				return nil, err
 			}
 			ag.logger.Info().Msgf("Collection %s created", col.Name())
+			// the graph takes the new edge collection
+			if err := ag.SyncGraph(); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -516,6 +509,12 @@ func (ag *ArangoGraph) AddEdge(ei handler.Edge) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := ag.record(history.Create, meta.ID, e.Data); err != nil {
+		return nil, err
+	}
+	if err := ag.assert(history.Create, meta.ID, history.EdgeKind, e.Collection, e.Data, src); err != nil {
+		return nil, err
+	}
 
 	return meta, nil
 }
@@ -531,6 +530,13 @@ func (ag *ArangoGraph) ReplaceNode(ni handler.Node) error {
 	default:
 		return fmt.Errorf("invalid input")
 	}
+	// # split the provenance out of the data
+	data, src, err := provenance.Extract(n.Data)
+	if err != nil {
+		ag.logger.Info().Msgf("Invalid provenance: %v", err)
+		return err
+	}
+	n.Data = data
 
 	// # get the id from the bidimap
 	// % if the id is blank, assign the id from the bidimap
@@ -550,6 +556,12 @@ func (ag *ArangoGraph) ReplaceNode(ni handler.Node) error {
 		n.ID = id.(driver.DocumentID).String()
 	}
 
+	// # the aliases of the write are unique with the names and the other aliases
+	if err := ag.checkAliases(n.ID, resolve.Aliases(n.Data, handler.AliasesKey)...); err != nil {
+		ag.logger.Info().Msgf("Invalid alias: %v", err)
+		return err
+	}
+
 	// # get the collection and key from the id
 	infos := strings.Split(n.ID, "/")
 	if len(infos) != 2 {
@@ -569,7 +581,7 @@ func (ag *ArangoGraph) ReplaceNode(ni handler.Node) error {
 	}
 
 	// # replace the node
-	ctx := context.Background()
+	ctx := ag.context()
 	db, err := ag.Client.Database(ctx, ag.dbname)
 	if err != nil {
 		ag.logger.Fatal().Msgf("Failed to open database: %v", err)
@@ -587,13 +599,19 @@ func (ag *ArangoGraph) ReplaceNode(ni handler.Node) error {
 	doc["_id"] = n.ID
 	doc["name"] = n.Name
 	doc["collection"] = n.Collection
-	// % replace the document
-	_, err = col.ReplaceDocument(ctx, infos[1], doc)
+	// % replace the document, if it still has the revision given
+	_, err = col.ReplaceDocument(ifMatch(ctx, n.Rev), infos[1], doc)
+	if driver.IsPreconditionFailed(err) {
+		return ag.conflict(ctx, col, infos[1], n.ID, n.Rev)
+	}
 	if err != nil {
 		ag.logger.Fatal().Msgf("Failed to replace document: %v", err)
 		return err
 	}
-	return nil
+	if err := ag.record(history.Replace, n.ID, n.Data); err != nil {
+		return err
+	}
+	return ag.assert(history.Replace, n.ID, history.NodeKind, n.Collection, n.Data, src)
 }
 
 // ReplaceEdge(e Edge) error
@@ -607,6 +625,13 @@ func (ag *ArangoGraph) ReplaceEdge(ei handler.Edge) error {
 		ag.logger.Fatal().Msgf("Invalid input")
 		return fmt.Errorf("invalid input")
 	}
+	// # split the provenance out of the data
+	data, src, err := provenance.Extract(e.Data)
+	if err != nil {
+		ag.logger.Info().Msgf("Invalid provenance: %v", err)
+		return err
+	}
+	e.Data = data
 	// = For edge, must have the id
 	// = if the id is blank, return an error
 	// todo: get it from the GetEdgesByRegex method
@@ -635,7 +660,7 @@ func (ag *ArangoGraph) ReplaceEdge(ei handler.Edge) error {
 	}
 
 	// % replace the edge
-	ctx := context.Background()
+	ctx := ag.context()
 	db, err := ag.Client.Database(ctx, ag.dbname)
 	if err != nil {
 		ag.logger.Fatal().Msgf("Failed to open database: %v", err)
@@ -656,14 +681,20 @@ func (ag *ArangoGraph) ReplaceEdge(ei handler.Edge) error {
 	doc["_from"] = e.From
 	doc["_to"] = e.To
 
-	// % replace the document
-	_, err = col.ReplaceDocument(ctx, infos[1], doc)
+	// % replace the document, if it still has the revision given
+	_, err = col.ReplaceDocument(ifMatch(ctx, e.Rev), infos[1], doc)
+	if driver.IsPreconditionFailed(err) {
+		return ag.conflict(ctx, col, infos[1], e.ID, e.Rev)
+	}
 	if err != nil {
 		ag.logger.Fatal().Msgf("Failed to replace document: %v", err)
 		return err
 	}
 
-	return nil
+	if err := ag.record(history.Replace, e.ID, e.Data); err != nil {
+		return err
+	}
+	return ag.assert(history.Replace, e.ID, history.EdgeKind, e.Collection, e.Data, src)
 }
 
 // UpdateNode(n Node) error
@@ -679,6 +710,13 @@ func (ag *ArangoGraph) UpdateNode(ni handler.Node) error {
 	default:
 		return fmt.Errorf("invalid input")
 	}
+	// # split the provenance out of the data
+	data, src, err := provenance.Extract(n.Data)
+	if err != nil {
+		ag.logger.Info().Msgf("Invalid provenance: %v", err)
+		return err
+	}
+	n.Data = data
 
 	// # get the id from the bidimap
 	// % if the id is blank, assign the id from the bidimap
@@ -698,6 +736,12 @@ func (ag *ArangoGraph) UpdateNode(ni handler.Node) error {
 		n.ID = id.(driver.DocumentID).String()
 	}
 
+	// # the aliases of the write are unique with the names and the other aliases
+	if err := ag.checkAliases(n.ID, resolve.Aliases(n.Data, handler.AliasesKey)...); err != nil {
+		ag.logger.Info().Msgf("Invalid alias: %v", err)
+		return err
+	}
+
 	// # get the collection and key from the id
 	infos := strings.Split(n.ID, "/")
 	if len(infos) != 2 {
@@ -717,7 +761,7 @@ func (ag *ArangoGraph) UpdateNode(ni handler.Node) error {
 	}
 
 	// # update the node
-	ctx := context.Background()
+	ctx := ag.context()
 	db, err := ag.Client.Database(ctx, ag.dbname)
 	if err != nil {
 		ag.logger.Fatal().Msgf("Failed to open database: %v", err)
@@ -735,13 +779,19 @@ func (ag *ArangoGraph) UpdateNode(ni handler.Node) error {
 	doc["_id"] = n.ID
 	doc["name"] = n.Name
 	doc["collection"] = n.Collection
-	// % update the document
-	_, err = col.UpdateDocument(ctx, infos[1], doc)
+	// % update the document, if it still has the revision given
+	_, err = col.UpdateDocument(ifMatch(ctx, n.Rev), infos[1], doc)
+	if driver.IsPreconditionFailed(err) {
+		return ag.conflict(ctx, col, infos[1], n.ID, n.Rev)
+	}
 	if err != nil {
 		ag.logger.Fatal().Msgf("Failed to update document: %v", err)
 		return err
 	}
-	return nil
+	if err := ag.record(history.Update, n.ID, n.Data); err != nil {
+		return err
+	}
+	return ag.assert(history.Update, n.ID, history.NodeKind, n.Collection, n.Data, src)
 }
 
 // UpdateEdge(e Edge) error
@@ -755,6 +805,13 @@ func (ag *ArangoGraph) UpdateEdge(ei handler.Edge) error {
 		ag.logger.Fatal().Msgf("Invalid input")
 		return fmt.Errorf("invalid input")
 	}
+	// # split the provenance out of the data
+	data, src, err := provenance.Extract(e.Data)
+	if err != nil {
+		ag.logger.Info().Msgf("Invalid provenance: %v", err)
+		return err
+	}
+	e.Data = data
 
 	// = For edge, must have the id
 	// = if the id is blank, return an error
@@ -784,7 +841,7 @@ func (ag *ArangoGraph) UpdateEdge(ei handler.Edge) error {
 	}
 
 	// % update the edge
-	ctx := context.Background()
+	ctx := ag.context()
 	db, err := ag.Client.Database(ctx, ag.dbname)
 	if err != nil {
 		ag.logger.Fatal().Msgf("Failed to open database: %v", err)
@@ -804,17 +861,23 @@ func (ag *ArangoGraph) UpdateEdge(ei handler.Edge) error {
 	doc["relationship"] = e.Relationship
 	doc["_from"] = e.From
 	doc["_to"] = e.To
-	// % update the document
-	_, err = col.UpdateDocument(ctx, infos[1], doc)
+	// % update the document, if it still has the revision given
+	_, err = col.UpdateDocument(ifMatch(ctx, e.Rev), infos[1], doc)
+	if driver.IsPreconditionFailed(err) {
+		return ag.conflict(ctx, col, infos[1], e.ID, e.Rev)
+	}
 	if err != nil {
 		ag.logger.Fatal().Msgf("Failed to update document: %v", err)
 		return err
 	}
-	return nil
+	if err := ag.record(history.Update, e.ID, e.Data); err != nil {
+		return err
+	}
+	return ag.assert(history.Update, e.ID, history.EdgeKind, e.Collection, e.Data, src)
 }
 
 // MergeNode(n Node) error
-// - The same fields in the document are added together
+// - The same fields in the document are combined by the merge strategies
 
 func (ag *ArangoGraph) MergeNode(ni handler.Node) error {
 	// convert ni to Node
@@ -825,6 +888,13 @@ func (ag *ArangoGraph) MergeNode(ni handler.Node) error {
 	default:
 		return fmt.Errorf("invalid input")
 	}
+	// # split the provenance out of the data
+	data, src, err := provenance.Extract(n.Data)
+	if err != nil {
+		ag.logger.Info().Msgf("Invalid provenance: %v", err)
+		return err
+	}
+	n.Data = data
 
 	// # get the id from the bidimap
 	// % if the id is blank, assign the id from the bidimap
@@ -844,6 +914,12 @@ func (ag *ArangoGraph) MergeNode(ni handler.Node) error {
 		n.ID = id.(driver.DocumentID).String()
 	}
 
+	// # the aliases of the write are unique with the names and the other aliases
+	if err := ag.checkAliases(n.ID, resolve.Aliases(n.Data, handler.AliasesKey)...); err != nil {
+		ag.logger.Info().Msgf("Invalid alias: %v", err)
+		return err
+	}
+
 	// # get the collection and key from the id
 	infos := strings.Split(n.ID, "/")
 	if len(infos) != 2 {
@@ -863,7 +939,7 @@ func (ag *ArangoGraph) MergeNode(ni handler.Node) error {
 	}
 
 	// # replace the node
-	ctx := context.Background()
+	ctx := ag.context()
 	db, err := ag.Client.Database(ctx, ag.dbname)
 	if err != nil {
 		ag.logger.Fatal().Msgf("Failed to open database: %v", err)
@@ -876,53 +952,54 @@ func (ag *ArangoGraph) MergeNode(ni handler.Node) error {
 		return err
 	}
 
-	// # get the old data
-	var oldNode Node
-	_, err = col.ReadDocument(ctx, infos[1], &oldNode)
-	if err != nil {
-		ag.logger.Fatal().Msgf("Failed to read document: %v", err)
-		return err
-	}
+	// # read, merge and write back if nobody wrote in between
+	// % the write is conditional on the revision read, a merge without revision reads again after a conflict
+	for attempt := 0; ; attempt++ {
+		// # get the old data
+		var oldNode Node
+		_, err = col.ReadDocument(ctx, infos[1], &oldNode)
+		if err != nil {
+			ag.logger.Fatal().Msgf("Failed to read document: %v", err)
+			return err
+		}
+		if n.Rev != "" && n.Rev != oldNode.Rev {
+			return &handler.ConflictError{ID: n.ID, Expected: n.Rev, Actual: oldNode.Rev}
+		}
 
-	// # merge the data
-	// $ if the data is new, add it to the oldNode
-	// $ if the data is not new, oldNode same fields are added together
-	for k, v := range n.Data {
-		if _, ok := oldNode.Data[k]; !ok {
-			oldNode.Data[k] = v
-		} else {
-			if v != "" {
-				// type assertion
-				switch v.(type) {
-				case string:
-					oldNode.Data[k] = oldNode.Data[k].(string) + v.(string)
-				case int:
-					oldNode.Data[k] = oldNode.Data[k].(int) + v.(int)
-				case float64:
-					oldNode.Data[k] = oldNode.Data[k].(float64) + v.(float64)
-				case bool:
-					oldNode.Data[k] = oldNode.Data[k].(bool) || v.(bool)
-				default:
-					ag.logger.Fatal().Msgf("Invalid data type")
-				}
+		// # merge the data with the configured strategies
+		// $ if the data is new, add it to the oldNode
+		// $ if the data is not new, the field strategy decides
+		oldNode.Data, err = ag.mergeConfig().Merge(infos[0], oldNode.Data, n.Data)
+		if err != nil {
+			ag.logger.Info().Msgf("Failed to merge data: %v", err)
+			return err
+		}
+
+		// update the node
+		doc := make(map[string]interface{})
+		doc["data"] = oldNode.Data
+		doc["_id"] = n.ID
+		doc["name"] = n.Name
+		doc["collection"] = n.Collection
+
+		_, err = col.UpdateDocument(ifMatch(ctx, oldNode.Rev), infos[1], doc)
+		if driver.IsPreconditionFailed(err) {
+			if n.Rev == "" && attempt < handler.MergeRetries {
+				continue
 			}
+			return ag.conflict(ctx, col, infos[1], n.ID, oldNode.Rev)
+		}
+		if err != nil {
+			ag.logger.Fatal().Msgf("Failed to update document: %v", err)
+			return err
 		}
+		break
 	}
 
-	// update the node
-	doc := make(map[string]interface{})
-	doc["data"] = oldNode.Data
-	doc["_id"] = n.ID
-	doc["name"] = n.Name
-	doc["collection"] = n.Collection
-
-	_, err = col.UpdateDocument(ctx, infos[1], doc)
-	if err != nil {
-		ag.logger.Fatal().Msgf("Failed to update document: %v", err)
+	if err := ag.record(history.Merge, n.ID, n.Data); err != nil {
 		return err
 	}
-
-	return nil
+	return ag.assert(history.Merge, n.ID, history.NodeKind, n.Collection, n.Data, src)
 
 }
 
@@ -937,6 +1014,13 @@ func (ag *ArangoGraph) MergeEdge(ei handler.Edge) error {
 		ag.logger.Fatal().Msgf("Invalid input")
 		return fmt.Errorf("invalid input")
 	}
+	// # split the provenance out of the data
+	data, src, err := provenance.Extract(e.Data)
+	if err != nil {
+		ag.logger.Info().Msgf("Invalid provenance: %v", err)
+		return err
+	}
+	e.Data = data
 
 	// = For edge, must have the id
 	// = if the id is blank, return an error
@@ -964,7 +1048,7 @@ func (ag *ArangoGraph) MergeEdge(ei handler.Edge) error {
 	}
 
 	// # update the edge
-	ctx := context.Background()
+	ctx := ag.context()
 	db, err := ag.Client.Database(ctx, ag.dbname)
 	if err != nil {
 		ag.logger.Fatal().Msgf("Failed to open database: %v", err)
@@ -978,55 +1062,56 @@ func (ag *ArangoGraph) MergeEdge(ei handler.Edge) error {
 		return err
 	}
 
-	// # get the old data
-	var oldEdge Edge
-	_, err = col.ReadDocument(ctx, infos[1], &oldEdge)
-	if err != nil {
-		ag.logger.Fatal().Msgf("Failed to read document: %v", err)
-		return err
-	}
+	// # read, merge and write back if nobody wrote in between
+	// % the write is conditional on the revision read, a merge without revision reads again after a conflict
+	for attempt := 0; ; attempt++ {
+		// # get the old data
+		var oldEdge Edge
+		_, err = col.ReadDocument(ctx, infos[1], &oldEdge)
+		if err != nil {
+			ag.logger.Fatal().Msgf("Failed to read document: %v", err)
+			return err
+		}
+		if e.Rev != "" && e.Rev != oldEdge.Rev {
+			return &handler.ConflictError{ID: e.ID, Expected: e.Rev, Actual: oldEdge.Rev}
+		}
 
-	// # merge the data
-	// $ if the data is new, add it to the oldEdge
-	// $ if the data is not new, oldEdge same fields are added together
-	for k, v := range e.Data {
-		if _, ok := oldEdge.Data[k]; !ok {
-			oldEdge.Data[k] = v
-		} else {
-			if v != "" {
-				// type assertion
-				switch v.(type) {
-				case string:
-					oldEdge.Data[k] = oldEdge.Data[k].(string) + v.(string)
-				case int:
-					oldEdge.Data[k] = oldEdge.Data[k].(int) + v.(int)
-				case float64:
-					oldEdge.Data[k] = oldEdge.Data[k].(float64) + v.(float64)
-				case bool:
-					oldEdge.Data[k] = oldEdge.Data[k].(bool) || v.(bool)
-				default:
-					ag.logger.Fatal().Msgf("Invalid data type")
-				}
+		// # merge the data with the configured strategies
+		// $ if the data is new, add it to the oldEdge
+		// $ if the data is not new, the field strategy decides
+		oldEdge.Data, err = ag.mergeConfig().Merge(infos[0], oldEdge.Data, e.Data)
+		if err != nil {
+			ag.logger.Info().Msgf("Failed to merge data: %v", err)
+			return err
+		}
+
+		// update the edge
+		doc := make(map[string]interface{})
+		doc["data"] = oldEdge.Data
+		doc["_id"] = e.ID
+		doc["collection"] = e.Collection
+		doc["relationship"] = e.Relationship
+		doc["_from"] = e.From
+		doc["_to"] = e.To
+
+		_, err = col.UpdateDocument(ifMatch(ctx, oldEdge.Rev), infos[1], doc)
+		if driver.IsPreconditionFailed(err) {
+			if e.Rev == "" && attempt < handler.MergeRetries {
+				continue
 			}
+			return ag.conflict(ctx, col, infos[1], e.ID, oldEdge.Rev)
 		}
+		if err != nil {
+			ag.logger.Fatal().Msgf("Failed to update document: %v", err)
+			return err
+		}
+		break
 	}
 
-	// update the edge
-	doc := make(map[string]interface{})
-	doc["data"] = oldEdge.Data
-	doc["_id"] = e.ID
-	doc["collection"] = e.Collection
-	doc["relationship"] = e.Relationship
-	doc["_from"] = e.From
-	doc["_to"] = e.To
-
-	_, err = col.UpdateDocument(ctx, infos[1], doc)
-	if err != nil {
-		ag.logger.Fatal().Msgf("Failed to update document: %v", err)
+	if err := ag.record(history.Merge, e.ID, e.Data); err != nil {
 		return err
 	}
-
-	return nil
+	return ag.assert(history.Merge, e.ID, history.EdgeKind, e.Collection, e.Data, src)
 
 }
 
@@ -1053,7 +1138,7 @@ func (ag *ArangoGraph) DeleteNode(name interface{}) error {
 
 // DeleteItemByID(id interface{}) error
 func (ag *ArangoGraph) DeleteItemByID(id interface{}) error {
-	ctx := context.Background()
+	ctx := ag.context()
 	var idStr string
 	// type assertion to check if the id is a string
 	switch id := id.(type) {
@@ -1095,6 +1180,17 @@ func (ag *ArangoGraph) DeleteItemByID(id interface{}) error {
 			ag.logger.Fatal().Msgf("Failed to delete document: %v", err)
 			return err
 		}
+		// the time series of the item go with it
+		if err := ag.removeSeries(idStr, ""); err != nil {
+			return err
+		}
+		// close the versions of the item
+		if err := ag.record(history.Delete, idStr, nil); err != nil {
+			return err
+		}
+		if err := ag.removeFacts(idStr); err != nil {
+			return err
+		}
 
 		// remove the node from the bidimap
 		// bidimap name is the name, value is the id
@@ -1137,6 +1233,17 @@ func (ag *ArangoGraph) DeleteItemByID(id interface{}) error {
 			ag.logger.Fatal().Msgf("Failed to delete document: %v", err)
 			return err
 		}
+		// the time series of the item go with it
+		if err := ag.removeSeries(idStr, ""); err != nil {
+			return err
+		}
+		// close the versions of the item
+		if err := ag.record(history.Delete, idStr, nil); err != nil {
+			return err
+		}
+		if err := ag.removeFacts(idStr); err != nil {
+			return err
+		}
 
 		return nil
 	default:
@@ -1149,7 +1256,7 @@ func (ag *ArangoGraph) DeleteItemByID(id interface{}) error {
 // + Query operations
 // GetItemByID(id interface{}) (interface{}, error)
 func (ag *ArangoGraph) GetItemByID(id interface{}) (interface{}, error) {
-	ctx := context.Background()
+	ctx := ag.context()
 	// type assertion to check if the id is a string
 	var idStr string
 	switch id := id.(type) {
@@ -1217,6 +1324,9 @@ func (ag *ArangoGraph) GetNode(name interface{}) (handler.Node, error) {
 		return nil, fmt.Errorf("invalid name")
 	}
 
+	// an alias leads to the name of its node
+	nameStr = ag.resolveName(nameStr)
+
 	// get the id from the bidimap with the name
 	id, ok := ag.nodeNameToIDMap.Get(nameStr)
 	if !ok {
@@ -1243,7 +1353,7 @@ func (ag *ArangoGraph) GetNode(name interface{}) (handler.Node, error) {
 // Query method returns []interface{}, error
 // interface{} is in the map[string]interface{} format
 func (ag *ArangoGraph) Query(query string, bindVars map[string]interface{}) ([]interface{}, error) {
-	ctx := context.Background()
+	ctx := ag.context()
 	db, err := ag.Client.Database(ctx, ag.dbname)
 	if err != nil {
 		ag.logger.Fatal().Msgf("Failed to open database: %v", err)
@@ -1274,17 +1384,23 @@ func (ag *ArangoGraph) Query(query string, bindVars map[string]interface{}) ([]i
 }
 
 // give a flexible query generator
+//
+// Deprecated: the collections and the filter are pasted into the AQL, use NewQuery and Fetch
 func QueryGenerator(q interface{}) (string, map[string]interface{}, error) {
 	var subqueries []string
+	m, ok := q.(map[string]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("query must be a map, got %T", q)
+	}
 	// # get collection
-	cols, ok := q.(map[string]interface{})["collections"].([]string)
+	cols, ok := m["collections"].([]string)
 	if !ok {
 		return "", nil, fmt.Errorf("collection not found")
 	}
 
 	// # get regexPatterns
 	bindVars := make(map[string]interface{})
-	regexPatterns, ok := q.(map[string]interface{})["regexPatterns"].([]string)
+	regexPatterns, ok := m["regexPatterns"].([]string)
 	if !ok {
 		return "", nil, fmt.Errorf("regex not found")
 	}
@@ -1294,13 +1410,13 @@ func QueryGenerator(q interface{}) (string, map[string]interface{}, error) {
 	}
 
 	// # get filter
-	filter, ok := q.(map[string]interface{})["filter"].(string)
+	filter, ok := m["filter"].(string)
 	if !ok {
 		return "", nil, fmt.Errorf("filter not found")
 	}
 
 	// # get base query
-	query_base, ok := q.(map[string]interface{})["query"].(string)
+	query_base, ok := m["query"].(string)
 	if !ok {
 		return "", nil, fmt.Errorf("query not found")
 	}
@@ -1332,87 +1448,26 @@ func mapToStruct(m map[string]interface{}, result interface{}) error {
 // GetNodesByRegex(regex string) ([]Node, error)
 // Search by regex in the node name fields
 func (ag *ArangoGraph) GetNodesByRegex(regex string) ([]handler.Node, error) {
-	ctx := context.Background()
-	// list all the node collections
-	collections, err := ag.db.Collections(ctx)
-	if err != nil {
-		ag.logger.Fatal().Msgf("Failed to list collections: %v", err)
-		return nil, err
-	}
-
-	// get all node collections
-	var nodeCollections []string
-	for _, col := range collections {
-		props, err := col.Properties(ctx)
-		if err != nil {
-			ag.logger.Info().Msgf("Failed to get collection properties: %v", err)
-			return nil, err
-		}
-
-		// Skip system collections
-		if props.IsSystem {
-			continue
-		}
-
-		// Skip edge collections
-		if props.Type == driver.CollectionTypeEdge {
-			continue
-		}
-
-		nodeCollections = append(nodeCollections, col.Name())
-	}
-
-	q := map[string]interface{}{
-		// #
-		"collections":   nodeCollections,
-		"regexPatterns": []string{regex},
-		// "filter": "REGEX_MATCHES(node.name, @regex0, true)",
-		"filter": `
-            REGEX_MATCHES(node.name, @regex0, true) 
-        `,
-		"query": `
-			FOR node IN %s
-			FILTER %s
-			RETURN node
-		`,
-	}
-
-	query, bindVars, err := QueryGenerator(q)
-
+	ctx := ag.context()
+	nodeCollections, err := ag.itemCollections(ctx, "", driver.CollectionTypeDocument)
 	if err != nil {
 		return nil, err
 	}
 
-	db, err := ag.Client.Database(ctx, ag.dbname)
+	// % the aliases match too, RegexAny accepts a single alias
+	q := NewQuery(nodeCollections...).Filter(Or(
+		Regex("name", regex, true),
+		RegexAny("data."+handler.AliasesKey, regex, true),
+	))
+	nodes, err := Fetch[Node](ag, q)
 	if err != nil {
-		ag.logger.Fatal().Msgf("Failed to open database: %v", err)
 		return nil, err
 	}
 
-	cursor, err := db.Query(ctx, query, bindVars)
-	if err != nil {
-		ag.logger.Fatal().Msgf("Failed to execute query: %v", err)
-		return nil, err
-	}
-	defer cursor.Close()
-
-	var nodes []*Node
-	for {
-		var node Node
-		_, err := cursor.ReadDocument(ctx, &node)
-		if driver.IsNoMoreDocuments(err) {
-			break
-		} else if err != nil {
-			ag.logger.Fatal().Msgf("Failed to read document: %v", err)
-			return nil, err
-		}
-		nodes = append(nodes, &node)
-	}
-
-	// Convert []*Node to []handler.Node
+	// Convert []Node to []handler.Node
 	handlerNodes := make([]handler.Node, len(nodes))
-	for i, n := range nodes {
-		handlerNodes[i] = n
+	for i := range nodes {
+		handlerNodes[i] = &nodes[i]
 	}
 
 	return handlerNodes, nil
@@ -1420,93 +1475,29 @@ func (ag *ArangoGraph) GetNodesByRegex(regex string) ([]handler.Node, error) {
 
 // GetEdgesByRegex(regex string) ([]Edge, error)
 func (ag *ArangoGraph) GetEdgesByRegex(regex string) ([]handler.Edge, error) {
-	ctx := context.Background()
-	// list all the edge collections
-	collections, err := ag.db.Collections(ctx)
-	if err != nil {
-		ag.logger.Fatal().Msgf("Failed to list collections: %v", err)
-		return nil, err
-	}
-
-	// get all edge collections
-	var edgeCollections []string
-	for _, col := range collections {
-		props, err := col.Properties(ctx)
-		if err != nil {
-			ag.logger.Info().Msgf("Failed to get collection properties: %v", err)
-			return nil, err
-		}
-
-		// Skip system collections
-		if props.IsSystem {
-			continue
-		}
-
-		// Skip node collections
-		if props.Type == driver.CollectionTypeDocument {
-			continue
-		}
-
-		edgeCollections = append(edgeCollections, col.Name())
-	}
-
-	q := map[string]interface{}{
-		// #
-		"collections":   edgeCollections,
-		"regexPatterns": []string{regex},
-		// "filter": "REGEX_MATCHES(node.name, @regex0, true)",
-		"filter": `
-			LENGTH(edge.relationship) > 0 AND 
-			REGEX_MATCHES(edge.relationship, @regex0, true) 
-		`,
-		"query": `
-			FOR edge IN %s
-			FILTER %s
-			RETURN edge
-		`,
-	}
-
-	query, bindVars, err := QueryGenerator(q)
-
+	ctx := ag.context()
+	edgeCollections, err := ag.itemCollections(ctx, "", driver.CollectionTypeEdge)
 	if err != nil {
 		return nil, err
 	}
 
-	db, err := ag.Client.Database(ctx, ag.dbname)
+	q := NewQuery(edgeCollections...).Filter(
+		// % null and the empty string sort before any relationship
+		Compare("relationship", ">", ""),
+		Regex("relationship", regex, true),
+	)
+	edges, err := Fetch[Edge](ag, q)
 	if err != nil {
-		ag.logger.Fatal().Msgf("Failed to open database: %v", err)
 		return nil, err
 	}
 
-	cursor, err := db.Query(ctx, query, bindVars)
-	if err != nil {
-		ag.logger.Fatal().Msgf("Failed to execute query: %v", err)
-		return nil, err
-	}
-
-	defer cursor.Close()
-
-	var edges []*Edge
-	for {
-		var edge Edge
-		_, err := cursor.ReadDocument(ctx, &edge)
-		if driver.IsNoMoreDocuments(err) {
-			break
-		} else if err != nil {
-			ag.logger.Fatal().Msgf("Failed to read document: %v", err)
-			return nil, err
-		}
-		edges = append(edges, &edge)
-	}
-
-	// Convert []*Edge to []handler.Edge
+	// Convert []Edge to []handler.Edge
 	handlerEdges := make([]handler.Edge, len(edges))
-	for i, e := range edges {
-		handlerEdges[i] = e
+	for i := range edges {
+		handlerEdges[i] = &edges[i]
 	}
 
 	return handlerEdges, nil
-
 }
 
 // GetFromNodes(name interface{}) ([]Node, error)
@@ -1518,6 +1509,9 @@ func (ag *ArangoGraph) GetFromNodes(name interface{}) ([]handler.Node, error) {
 		return nil, fmt.Errorf("invalid name")
 	}
 
+	// an alias leads to the name of its node
+	nameStr = ag.resolveName(nameStr)
+
 	// get the id from the bidimap with the name
 	id, ok := ag.nodeNameToIDMap.Get(nameStr)
 	if !ok {
@@ -1526,7 +1520,7 @@ func (ag *ArangoGraph) GetFromNodes(name interface{}) ([]handler.Node, error) {
 	}
 
 	// get the edges from the edge collection
-	ctx := context.Background()
+	ctx := ag.context()
 	// Retrieve the list of collections
 	collections, err := ag.db.Collections(ctx)
 	if err != nil {
@@ -1605,6 +1599,9 @@ func (ag *ArangoGraph) GetToNodes(name interface{}) ([]handler.Node, error) {
 		return nil, fmt.Errorf("invalid name")
 	}
 
+	// an alias leads to the name of its node
+	nameStr = ag.resolveName(nameStr)
+
 	// get the id from the bidimap with the name
 	id, ok := ag.nodeNameToIDMap.Get(nameStr)
 	if !ok {
@@ -1613,7 +1610,7 @@ func (ag *ArangoGraph) GetToNodes(name interface{}) ([]handler.Node, error) {
 	}
 
 	// get the edges from the edge collection
-	ctx := context.Background()
+	ctx := ag.context()
 	// Retrieve the list of collections
 	collections, err := ag.db.Collections(ctx)
 	if err != nil {
@@ -1693,6 +1690,9 @@ func (ag *ArangoGraph) GetInEdges(name interface{}) ([]handler.Edge, error) {
 		return nil, fmt.Errorf("invalid name")
 	}
 
+	// an alias leads to the name of its node
+	nameStr = ag.resolveName(nameStr)
+
 	// get the id from the bidimap with the name
 	id, ok := ag.nodeNameToIDMap.Get(nameStr)
 	if !ok {
@@ -1701,7 +1701,7 @@ func (ag *ArangoGraph) GetInEdges(name interface{}) ([]handler.Edge, error) {
 	}
 
 	// get the edges from the edge collection
-	ctx := context.Background()
+	ctx := ag.context()
 	// Retrieve the list of collections
 	collections, err := ag.db.Collections(ctx)
 	if err != nil {
@@ -1770,6 +1770,9 @@ func (ag *ArangoGraph) GetOutEdges(name interface{}) ([]handler.Edge, error) {
 		return nil, fmt.Errorf("invalid name")
 	}
 
+	// an alias leads to the name of its node
+	nameStr = ag.resolveName(nameStr)
+
 	// get the id from the bidimap with the name
 	id, ok := ag.nodeNameToIDMap.Get(nameStr)
 	if !ok {
@@ -1778,7 +1781,7 @@ func (ag *ArangoGraph) GetOutEdges(name interface{}) ([]handler.Edge, error) {
 	}
 
 	// get the edges from the edge collection
-	ctx := context.Background()
+	ctx := ag.context()
 	// Retrieve the list of collections
 	collections, err := ag.db.Collections(ctx)
 	if err != nil {
@@ -1840,131 +1843,6 @@ func (ag *ArangoGraph) GetOutEdges(name interface{}) ([]handler.Edge, error) {
 
 // + Graph operations
 // - Traversal operations
-// GetAllRelatedNodes(name interface{}) ([][]Node, error)
-func (ag *ArangoGraph) GetAllRelatedNodes(name interface{}) ([][]handler.Node, error) {
-	// Convert the name into a string
-	nameStr, ok := name.(string)
-	if !ok {
-		ag.logger.Fatal().Msgf("Invalid name: %v", name)
-		return nil, fmt.Errorf("invalid name")
-	}
-
-	// Get the ID from the bidimap with the name
-	id, ok := ag.nodeNameToIDMap.Get(nameStr)
-	if !ok {
-		ag.logger.Fatal().Msgf("Node %s does not exist", nameStr)
-		return nil, fmt.Errorf("node does not exist")
-	}
-
-	// Initialize BFS structures
-	queue := []string{id.(driver.DocumentID).String()}
-	visited := make(map[string]bool)
-	visited[id.(driver.DocumentID).String()] = true
-
-	// Create a result slice
-	var result [][]handler.Node
-
-	// Get the database
-	ctx := context.Background()
-	db, err := ag.Client.Database(ctx, ag.dbname)
-	if err != nil {
-		ag.logger.Fatal().Msgf("Failed to open database: %v", err)
-		return nil, err
-	}
-
-	// Get the list of edge collections
-	collections, err := db.Collections(ctx)
-	if err != nil {
-		ag.logger.Fatal().Msgf("Failed to list collections: %v", err)
-		return nil, err
-	}
-
-	var edgeCollections []string
-	for _, col := range collections {
-		props, err := col.Properties(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get collection properties: %v", err)
-		}
-
-		if props.Type == driver.CollectionTypeEdge && !props.IsSystem {
-			edgeCollections = append(edgeCollections, col.Name())
-		}
-
-	}
-
-	// Iterate over the queue
-	for len(queue) > 0 {
-		var level []handler.Node
-		// Get the length of the queue
-		l := len(queue)
-		// Iterate over the length
-		for i := 0; i < l; i++ {
-			// Get the node ID from the queue
-			nodeID := queue[0]
-			// Remove the node ID from the queue
-			queue = queue[1:]
-
-			// Get the node
-			node, err := ag.GetItemByID(nodeID)
-			if err != nil {
-				ag.logger.Fatal().Msgf("Failed to get node: %v", err)
-				return nil, err
-			}
-
-			n, ok := node.(Node)
-			if !ok {
-				ag.logger.Fatal().Msgf("Invalid node: %v", node)
-				return nil, fmt.Errorf("invalid node")
-			}
-			// Add the node to the level
-			level = append(level, &n)
-
-			// Get the edges from all edge collections
-			for _, edgeCol := range edgeCollections {
-				query := fmt.Sprintf(`
-				 FOR edge IN %s
-				 FILTER edge._from == @id
-				 RETURN edge
-				 `, edgeCol)
-				bindVars := map[string]interface{}{
-					"id": nodeID,
-				}
-
-				cursor, err := db.Query(ctx, query, bindVars)
-				if err != nil {
-					ag.logger.Fatal().Msgf("Failed to execute query: %v", err)
-					return nil, err
-				}
-				defer cursor.Close()
-
-				// Iterate over the edges
-				for {
-					var edge Edge
-					_, err := cursor.ReadDocument(ctx, &edge)
-					if driver.IsNoMoreDocuments(err) {
-						break
-					} else if err != nil {
-						ag.logger.Fatal().Msgf("Failed to read document: %v", err)
-						return nil, err
-					}
-
-					// Check if the node has been visited
-					if _, ok := visited[edge.To]; !ok {
-						// Add the node ID to the queue
-						queue = append(queue, edge.To)
-						// Mark the node as visited
-						visited[edge.To] = true
-					}
-				}
-			}
-		}
-		// Add the level to the result
-		result = append(result, level)
-	}
-
-	return result, nil
-}
-
 // GetAllRelatedNodesInEdgeSlice(name interface{}, EdgeSlice ...Edge) ([][]Node, error)
 func (ag *ArangoGraph) GetAllRelatedNodesInEdgeSlice(name interface{}, EdgeSlice ...handler.Edge) ([][]handler.Node, error) {
 	// Convert the name into a string
@@ -1974,6 +1852,9 @@ func (ag *ArangoGraph) GetAllRelatedNodesInEdgeSlice(name interface{}, EdgeSlice
 		return nil, fmt.Errorf("invalid name")
 	}
 
+	// an alias leads to the name of its node
+	nameStr = ag.resolveName(nameStr)
+
 	// Get the ID from the bidimap with the name
 	id, ok := ag.nodeNameToIDMap.Get(nameStr)
 	if !ok {
@@ -2043,133 +1924,3 @@ func (ag *ArangoGraph) GetAllRelatedNodesInEdgeSlice(name interface{}, EdgeSlice
 
 }
 
-// GetAllRelatedNodesInRange(name interface{}, max int) ([][]Node, error)
-func (ag *ArangoGraph) GetAllRelatedNodesInRange(name interface{}, max int) ([][]handler.Node, error) {
-	// Convert the name into a string
-	nameStr, ok := name.(string)
-	if !ok {
-		ag.logger.Fatal().Msgf("Invalid name: %v", name)
-		return nil, fmt.Errorf("invalid name")
-	}
-
-	// Get the ID from the bidimap with the name
-	id, ok := ag.nodeNameToIDMap.Get(nameStr)
-	if !ok {
-		ag.logger.Fatal().Msgf("Node %s does not exist", nameStr)
-		return nil, fmt.Errorf("node does not exist")
-	}
-
-	// Initialize BFS structures
-	queue := []string{id.(driver.DocumentID).String()}
-	visited := make(map[string]bool)
-	visited[id.(driver.DocumentID).String()] = true
-
-	// Create a result slice
-	var result [][]handler.Node
-
-	// Get the database
-	ctx := context.Background()
-	db, err := ag.Client.Database(ctx, ag.dbname)
-	if err != nil {
-		ag.logger.Fatal().Msgf("Failed to open database: %v", err)
-		return nil, err
-	}
-
-	// Get the list of edge collections
-	collections, err := db.Collections(ctx)
-	if err != nil {
-		ag.logger.Fatal().Msgf("Failed to list collections: %v", err)
-		return nil, err
-	}
-
-	var edgeCollections []string
-	for _, col := range collections {
-		props, err := col.Properties(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get collection properties: %v", err)
-		}
-
-		if props.Type == driver.CollectionTypeEdge && !props.IsSystem {
-			edgeCollections = append(edgeCollections, col.Name())
-		}
-
-	}
-
-	// Iterate over the queue
-	for len(queue) > 0 {
-		var level []handler.Node
-		// Get the length of the queue
-		l := len(queue)
-		// Iterate over the length
-		for i := 0; i < l; i++ {
-			// Get the node ID from the queue
-			nodeID := queue[0]
-			// Remove the node ID from the queue
-			queue = queue[1:]
-
-			// Get the node
-			node, err := ag.GetItemByID(nodeID)
-			if err != nil {
-				ag.logger.Fatal().Msgf("Failed to get node: %v", err)
-				return nil, err
-			}
-
-			n, ok := node.(Node)
-			if !ok {
-				ag.logger.Fatal().Msgf("Invalid node: %v", node)
-				return nil, fmt.Errorf("invalid node")
-			}
-			// Add the node to the level
-			level = append(level, &n)
-
-			// Get the edges from all edge collections
-			for _, edgeCol := range edgeCollections {
-				query := fmt.Sprintf(`
-				FOR edge IN %s
-				FILTER edge._from == @id
-				RETURN edge
-				`, edgeCol)
-				bindVars := map[string]interface{}{
-					"id": nodeID,
-				}
-
-				cursor, err := db.Query(ctx, query, bindVars)
-				if err != nil {
-					ag.logger.Fatal().Msgf("Failed to execute query: %v", err)
-					return nil, err
-				}
-				defer cursor.Close()
-
-				// Iterate over the edges
-				for {
-					var edge Edge
-					_, err := cursor.ReadDocument(ctx, &edge)
-					if driver.IsNoMoreDocuments(err) {
-						break
-					} else if err != nil {
-						ag.logger.Fatal().Msgf("Failed to read document: %v", err)
-						return nil, err
-					}
-
-					// Check if the node has been visited
-					if _, ok := visited[edge.To]; !ok {
-						// Add the node ID to the queue
-						queue = append(queue, edge.To)
-						// Mark the node as visited
-						visited[edge.To] = true
-					}
-				}
-			}
-
-		}
-		// Add the level to the result
-		result = append(result, level)
-
-		// Check if the level is greater than the max
-		if len(result) >= max {
-			break
-		}
-	}
-
-	return result, nil
-}
@@ -148,8 +148,8 @@ func TestArangoGraph_CRUD(t *testing.T) {
 		})
 	}
 
-	// $ Create graph
-	err = ag.createGraph()
+	// $ Sync graph, AddNode and AddEdge keep it up to date already
+	err = ag.SyncGraph()
 	if err != nil {
 		t.Errorf("Test failed, expected nil, got %v", err)
 	}
@@ -318,7 +318,7 @@ func TestArangoGraph_CRUD(t *testing.T) {
 
 
 	// ~ delete the graph and all its nodes and edges
-	err = ag.deleteGraph()
+	err = ag.DeleteGraph(true)
 	if err != nil {
 		t.Errorf("Test failed, expected nil, got %v", err)
 	}
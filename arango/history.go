@@ -0,0 +1,315 @@
+package arango
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/arangodb/go-driver"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+)
+
+// the ArangoGraph keeps the bitemporal history
+var _ handler.TemporalGraph = (*ArangoGraph)(nil)
+
+// versionDoc is one version in the history collection
+// the times are stored as unix milliseconds so they can be indexed and compared in AQL
+// _key is the version ID
+type versionDoc struct {
+	Key          string                 `json:"_key"`
+	Item         string                 `json:"item"`
+	Kind         history.Kind           `json:"kind"`
+	Op           history.Op             `json:"op"`
+	Collection   string                 `json:"collection"`
+	Name         string                 `json:"name,omitempty"`
+	Relationship string                 `json:"relationship,omitempty"`
+	From         string                 `json:"from,omitempty"`
+	To           string                 `json:"to,omitempty"`
+	Data         map[string]interface{} `json:"data,omitempty"`
+	ValidFrom    int64                  `json:"validFrom"`
+	ValidTo      int64                  `json:"validTo"`
+	TxFrom       int64                  `json:"txFrom"`
+	TxTo         int64                  `json:"txTo"`
+}
+
+func toVersionDoc(v history.Version) versionDoc {
+	return versionDoc{
+		Key: v.ID, Item: v.ItemID, Kind: v.Kind, Op: v.Op, Collection: v.Collection,
+		Name: v.Name, Relationship: v.Relationship, From: v.From, To: v.To, Data: v.Data,
+		ValidFrom: v.ValidFrom.UnixMilli(), ValidTo: v.ValidTo.UnixMilli(),
+		TxFrom: v.TxFrom.UnixMilli(), TxTo: v.TxTo.UnixMilli(),
+	}
+}
+
+func (d versionDoc) version() history.Version {
+	return history.Version{
+		ID: d.Key, ItemID: d.Item, Kind: d.Kind, Op: d.Op, Collection: d.Collection,
+		Name: d.Name, Relationship: d.Relationship, From: d.From, To: d.To, Data: d.Data,
+		ValidFrom: time.UnixMilli(d.ValidFrom).UTC(), ValidTo: time.UnixMilli(d.ValidTo).UTC(),
+		TxFrom: time.UnixMilli(d.TxFrom).UTC(), TxTo: time.UnixMilli(d.TxTo).UTC(),
+	}
+}
+
+// ensureHistoryCollection creates the history collection and its indexes if needed
+func (ag *ArangoGraph) ensureHistoryCollection() error {
+	// # DDL is not transactional, it runs outside the transactions
+	ctx := context.Background()
+	exists, err := ag.db.CollectionExists(ctx, handler.HistoryCollection)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to check for collection: %v", err)
+		return err
+	}
+	var col driver.Collection
+	if exists {
+		col, err = ag.db.Collection(ctx, handler.HistoryCollection)
+	} else {
+		col, err = ag.db.CreateCollection(ctx, handler.HistoryCollection, nil)
+	}
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to open history collection: %v", err)
+		return err
+	}
+	// # the versions of one item, and the node versions by name
+	for _, fields := range [][]string{{"item", "txTo"}, {"name", "txFrom"}} {
+		if _, _, err := col.EnsurePersistentIndex(ctx, fields, nil); err != nil {
+			ag.logger.Info().Msgf("Failed to create index: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// readVersions runs the query and reads all the version documents
+func (ag *ArangoGraph) readVersions(ctx context.Context, query string, bindVars map[string]interface{}) ([]history.Version, error) {
+	cursor, err := ag.db.Query(ctx, query, bindVars)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to execute query: %v", err)
+		return nil, err
+	}
+	defer cursor.Close()
+
+	versions := []history.Version{}
+	for {
+		var doc versionDoc
+		_, err := cursor.ReadDocument(ctx, &doc)
+		if driver.IsNoMoreDocuments(err) {
+			break
+		} else if err != nil {
+			ag.logger.Info().Msgf("Failed to read document: %v", err)
+			return nil, err
+		}
+		versions = append(versions, doc.version())
+	}
+	return versions, nil
+}
+
+// currentVersion reads the stored item back as the next version, nil if it is gone
+func (ag *ArangoGraph) currentVersion(ctx context.Context, op history.Op, idStr string) (*history.Version, error) {
+	cursor, err := ag.db.Query(ctx, "RETURN DOCUMENT(@id)", map[string]interface{}{"id": idStr})
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to execute query: %v", err)
+		return nil, err
+	}
+	defer cursor.Close()
+
+	var doc map[string]interface{}
+	if _, err := cursor.ReadDocument(ctx, &doc); err != nil {
+		ag.logger.Info().Msgf("Failed to read document: %v", err)
+		return nil, err
+	}
+	if doc == nil {
+		return nil, nil
+	}
+	data, _ := doc["data"].(map[string]interface{})
+	col, _ := doc["collection"].(string)
+	if from, ok := doc["_from"].(string); ok {
+		rel, _ := doc["relationship"].(string)
+		to, _ := doc["_to"].(string)
+		return history.EdgeVersion(op, idStr, col, rel, from, to, data), nil
+	}
+	name, _ := doc["name"].(string)
+	return history.NodeVersion(op, idStr, col, name, data), nil
+}
+
+// record stores the version changes of a write on the item
+// written is the data of the write, its history.ValidFromKey gives the valid time
+func (ag *ArangoGraph) record(op history.Op, id interface{}, written map[string]interface{}) error {
+	ctx := ag.context()
+	idStr, err := toIDString(id)
+	if err != nil {
+		return err
+	}
+	if err := ag.ensureHistoryCollection(); err != nil {
+		return err
+	}
+
+	var next *history.Version
+	if op != history.Delete {
+		if next, err = ag.currentVersion(ctx, op, idStr); err != nil {
+			return err
+		}
+	}
+	// the alias index follows the current version
+	ag.aliases.Track(idStr, next, handler.AliasesKey)
+	current, err := ag.readVersions(ctx, `
+		FOR v IN @@col
+		FILTER v.item == @item AND v.txTo == @end
+		RETURN v
+	`, map[string]interface{}{
+		"@col": handler.HistoryCollection,
+		"item": idStr,
+		"end":  history.EndOfTime.UnixMilli(),
+	})
+	if err != nil {
+		return err
+	}
+
+	txTime := history.Now()
+	closed, inserted := history.Record(current, next, history.ValidTime(written, txTime), txTime)
+	keys := make([]string, len(closed))
+	for i, c := range closed {
+		keys[i] = c.ID
+	}
+	docs := make([]versionDoc, len(inserted))
+	for i, v := range inserted {
+		docs[i] = toVersionDoc(v)
+	}
+	// # AQL modifies a collection once per query, close first then insert
+	cursor, err := ag.db.Query(ctx, `
+		FOR k IN @keys
+		UPDATE k WITH { txTo: @tx } IN @@col
+	`, map[string]interface{}{
+		"@col": handler.HistoryCollection,
+		"keys": keys,
+		"tx":   txTime.UnixMilli(),
+	})
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to close versions: %v", err)
+		return err
+	}
+	cursor.Close()
+	cursor, err = ag.db.Query(ctx, `
+		FOR v IN @docs
+		INSERT v INTO @@col
+	`, map[string]interface{}{
+		"@col": handler.HistoryCollection,
+		"docs": docs,
+	})
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to insert versions: %v", err)
+		return err
+	}
+	cursor.Close()
+	return ag.publish(ctx, handler.NewChangeEvent(idStr, current, next, txTime))
+}
+
+// versionToNode rebuilds the node of a version
+func versionToNode(v history.Version) *Node {
+	return &Node{ID: v.ItemID, Collection: v.Collection, Name: v.Name, Data: v.Data}
+}
+
+// atFilter is the AQL filter of the versions valid at @valid as believed at @known
+const atFilter = "v.txFrom <= @known AND v.txTo > @known AND v.validFrom <= @valid AND v.validTo > @valid"
+
+// GetNodeAsOf(name interface{}, t time.Time) (handler.Node, error)
+func (ag *ArangoGraph) GetNodeAsOf(name interface{}, t time.Time) (handler.Node, error) {
+	return ag.GetNodeAt(name, t, t)
+}
+
+// GetNodeAt(name interface{}, validAt, knownAt time.Time) (handler.Node, error)
+func (ag *ArangoGraph) GetNodeAt(name interface{}, validAt, knownAt time.Time) (handler.Node, error) {
+	ctx := ag.context()
+	nameStr, ok := name.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid name")
+	}
+	if err := ag.ensureHistoryCollection(); err != nil {
+		return nil, err
+	}
+	versions, err := ag.readVersions(ctx, fmt.Sprintf(`
+		FOR v IN @@col
+		FILTER v.kind == "node" AND v.name == @name AND %s
+		SORT v.txFrom DESC
+		LIMIT 1
+		RETURN v
+	`, atFilter), map[string]interface{}{
+		"@col":  handler.HistoryCollection,
+		"name":  nameStr,
+		"valid": validAt.UnixMilli(),
+		"known": knownAt.UnixMilli(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("node with name %s does not exist at %v", nameStr, validAt)
+	}
+	return versionToNode(versions[0]), nil
+}
+
+// TraverseAsOf(t time.Time, name interface{}) ([][]handler.Node, error)
+// the edges are followed from _from to _to like GetAllRelatedNodes
+func (ag *ArangoGraph) TraverseAsOf(t time.Time, name interface{}) ([][]handler.Node, error) {
+	ctx := ag.context()
+	nameStr, ok := name.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid name")
+	}
+	if err := ag.ensureHistoryCollection(); err != nil {
+		return nil, err
+	}
+	// # the whole graph as of t, the BFS runs on the snapshot
+	versions, err := ag.readVersions(ctx, fmt.Sprintf(`
+		FOR v IN @@col
+		FILTER %s
+		RETURN v
+	`, atFilter), map[string]interface{}{
+		"@col":  handler.HistoryCollection,
+		"valid": t.UnixMilli(),
+		"known": t.UnixMilli(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	start, ok := history.FindNode(versions, nameStr, t, t)
+	if !ok {
+		return nil, fmt.Errorf("node with name %s does not exist at %v", nameStr, t)
+	}
+
+	snapshot := history.Snapshot(versions, t, t)
+	var result [][]handler.Node
+	for _, level := range history.Traverse(start.ItemID, snapshot, true) {
+		nodes := make([]handler.Node, len(level))
+		for i, id := range level {
+			nodes[i] = versionToNode(snapshot[id])
+		}
+		result = append(result, nodes)
+	}
+	return result, nil
+}
+
+// GetHistory(id interface{}) ([]history.Version, error)
+func (ag *ArangoGraph) GetHistory(id interface{}) ([]history.Version, error) {
+	ctx := ag.context()
+	idStr, err := toIDString(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := ag.ensureHistoryCollection(); err != nil {
+		return nil, err
+	}
+	versions, err := ag.readVersions(ctx, `
+		FOR v IN @@col
+		FILTER v.item == @item
+		SORT v.txFrom
+		RETURN v
+	`, map[string]interface{}{"@col": handler.HistoryCollection, "item": idStr})
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("item with ID %s has no history", idStr)
+	}
+	return versions, nil
+}
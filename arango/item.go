@@ -1,13 +1,17 @@
 package arango
 
 import (
+	"context"
 	"encoding/json"
 	"strings"
 
 	driver "github.com/arangodb/go-driver"
-	"github.com/rs/zerolog"
 
 	"github.com/emirpasic/gods/maps/hashbidimap"
+	"github.com/wonderstone/chainstorm/merge"
+	"github.com/wonderstone/chainstorm/resolve"
+	"github.com/wonderstone/chainstorm/search"
+	"github.com/wonderstone/chainstorm/tools"
 )
 
 // - ArangoDB 的 _id 由 collection/key 组成
@@ -20,6 +24,8 @@ type Node struct {
 	Collection string                 `json:"collection"`
 	Name       string                 `json:"name"`
 	Data       map[string]interface{} `json:"data,omitempty"`
+	// Rev is the _rev of the document, a write given it applies only if the node still has it
+	Rev string `json:"_rev,omitempty"`
 }
 
 
@@ -68,6 +74,8 @@ type Edge struct {
 	From         string                 `json:"_from"`
 	To           string                 `json:"_to"`
 	Data         map[string]interface{} `json:"data,omitempty"`
+	// Rev is the _rev of the document, a write given it applies only if the edge still has it
+	Rev string `json:"_rev,omitempty"`
 }
 
 // implement the handler Edge interface
@@ -126,5 +134,38 @@ type ArangoGraph struct {
 	// bidimap section for the node name and id
 	nodeNameToIDMap *hashbidimap.Map
 
-	logger *zerolog.Logger
+	// merge strategies for MergeNode and MergeEdge
+	merger *merge.Config
+
+	// aliases maps the aliases in the Data to the node names
+	// a pointer, the transactions share it
+	aliases *resolve.AliasIndex
+
+	// searcher tells the Data fields of the search view
+	searcher *search.Config
+
+	// logger is created by Init from the "logger" section, Disconnect closes its files
+	logger *tools.Logger
+
+	// changes is the changes collection, ensured once by Connect or by the first write
+	changes driver.Collection
+
+	// txID is the stream transaction of the operations, empty outside the transactions
+	txID driver.TransactionID
+
+	// base is the caller context of a WithContext view, nil for the graph itself
+	base context.Context
+}
+
+// SetMergeConfig replaces the merge strategies used by MergeNode and MergeEdge
+func (ag *ArangoGraph) SetMergeConfig(c *merge.Config) {
+	ag.merger = c
+}
+
+// mergeConfig returns the merge strategies, the default ones if not initialized
+func (ag *ArangoGraph) mergeConfig() *merge.Config {
+	if ag.merger == nil {
+		return merge.Default()
+	}
+	return ag.merger
 }
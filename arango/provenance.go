@@ -0,0 +1,324 @@
+package arango
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/arangodb/go-driver"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"github.com/wonderstone/chainstorm/provenance"
+)
+
+// the ArangoGraph keeps the provenance of the facts
+var _ handler.ProvenanceGraph = (*ArangoGraph)(nil)
+
+// factDoc is one fact in the provenance collection
+// the timestamp is stored as unix milliseconds like the series and history documents
+type factDoc struct {
+	Item       string       `json:"item"`
+	Kind       history.Kind `json:"kind"`
+	Collection string       `json:"collection"`
+	Field      string       `json:"field"`
+	Source     string       `json:"source"`
+	Extractor  string       `json:"extractor,omitempty"`
+	Version    string       `json:"version,omitempty"`
+	Confidence float64      `json:"confidence"`
+	Timestamp  int64        `json:"timestamp"`
+}
+
+func toFactDoc(f provenance.Fact) factDoc {
+	return factDoc{
+		Item: f.Item, Kind: f.Kind, Collection: f.Collection, Field: f.Field,
+		Source: f.Source, Extractor: f.Extractor, Version: f.Version,
+		Confidence: f.Confidence, Timestamp: f.Timestamp.UnixMilli(),
+	}
+}
+
+func (d factDoc) fact() provenance.Fact {
+	return provenance.Fact{
+		Item: d.Item, Kind: d.Kind, Collection: d.Collection, Field: d.Field,
+		Record: provenance.Record{
+			Source: d.Source, Extractor: d.Extractor, Version: d.Version,
+			Confidence: d.Confidence, Timestamp: time.UnixMilli(d.Timestamp).UTC(),
+		},
+	}
+}
+
+// ensureProvenanceCollection creates the provenance collection and its indexes if needed
+func (ag *ArangoGraph) ensureProvenanceCollection() error {
+	// # DDL is not transactional, it runs outside the transactions
+	ctx := context.Background()
+	exists, err := ag.db.CollectionExists(ctx, handler.ProvenanceCollection)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to check for collection: %v", err)
+		return err
+	}
+	var col driver.Collection
+	if exists {
+		col, err = ag.db.Collection(ctx, handler.ProvenanceCollection)
+	} else {
+		col, err = ag.db.CreateCollection(ctx, handler.ProvenanceCollection, nil)
+	}
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to open provenance collection: %v", err)
+		return err
+	}
+	// # one fact per item, field and source, and the facts by source
+	_, _, err = col.EnsurePersistentIndex(ctx, []string{"item", "field", "source"}, &driver.EnsurePersistentIndexOptions{
+		Unique: true,
+	})
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to create index: %v", err)
+		return err
+	}
+	if _, _, err := col.EnsurePersistentIndex(ctx, []string{"source"}, nil); err != nil {
+		ag.logger.Info().Msgf("Failed to create index: %v", err)
+		return err
+	}
+	return nil
+}
+
+// readFacts runs the query and reads all the fact documents
+func (ag *ArangoGraph) readFacts(ctx context.Context, query string, bindVars map[string]interface{}) ([]provenance.Fact, error) {
+	cursor, err := ag.db.Query(ctx, query, bindVars)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to execute query: %v", err)
+		return nil, err
+	}
+	defer cursor.Close()
+
+	facts := []provenance.Fact{}
+	for {
+		var doc factDoc
+		_, err := cursor.ReadDocument(ctx, &doc)
+		if driver.IsNoMoreDocuments(err) {
+			break
+		} else if err != nil {
+			ag.logger.Info().Msgf("Failed to read document: %v", err)
+			return nil, err
+		}
+		facts = append(facts, doc.fact())
+	}
+	return facts, nil
+}
+
+// assert stores the facts of a write on the item
+// written is the data of the write without the provenance key, rec is nil without provenance
+// a replace also drops the facts of the fields it removed
+func (ag *ArangoGraph) assert(op history.Op, id interface{}, kind history.Kind, collection string, written map[string]interface{}, rec *provenance.Record) error {
+	if op != history.Replace && rec == nil {
+		return nil
+	}
+	ctx := ag.context()
+	idStr, err := toIDString(id)
+	if err != nil {
+		return err
+	}
+	if err := ag.ensureProvenanceCollection(); err != nil {
+		return err
+	}
+
+	if op == history.Replace {
+		fields := []string{provenance.ItemField}
+		for k := range written {
+			fields = append(fields, k)
+		}
+		cursor, err := ag.db.Query(ctx, `
+			FOR f IN @@col
+			FILTER f.item == @item AND f.field NOT IN @fields
+			REMOVE f IN @@col
+		`, map[string]interface{}{
+			"@col":   handler.ProvenanceCollection,
+			"item":   idStr,
+			"fields": fields,
+		})
+		if err != nil {
+			ag.logger.Info().Msgf("Failed to prune facts: %v", err)
+			return err
+		}
+		cursor.Close()
+	}
+	if rec == nil {
+		return nil
+	}
+
+	return ag.upsertFacts(ctx, provenance.Facts(op, *rec, idStr, kind, collection, written))
+}
+
+// upsertFacts stores the facts, the provenance collection exists
+func (ag *ArangoGraph) upsertFacts(ctx context.Context, facts []provenance.Fact) error {
+	docs := make([]factDoc, len(facts))
+	for i, f := range facts {
+		docs[i] = toFactDoc(f)
+	}
+	// # the same source replaces its fact, another source adds one
+	cursor, err := ag.db.Query(ctx, `
+		FOR f IN @facts
+		UPSERT { item: f.item, field: f.field, source: f.source }
+		INSERT f
+		REPLACE f
+		IN @@col
+	`, map[string]interface{}{
+		"@col":  handler.ProvenanceCollection,
+		"facts": docs,
+	})
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to store facts: %v", err)
+		return err
+	}
+	return cursor.Close()
+}
+
+// removeFacts removes the facts of the item
+func (ag *ArangoGraph) removeFacts(idStr string) error {
+	ctx := ag.context()
+	exists, err := ag.db.CollectionExists(ctx, handler.ProvenanceCollection)
+	if err != nil || !exists {
+		return err
+	}
+	cursor, err := ag.db.Query(ctx, `
+		FOR f IN @@col
+		FILTER f.item == @item
+		REMOVE f IN @@col
+	`, map[string]interface{}{"@col": handler.ProvenanceCollection, "item": idStr})
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to remove facts: %v", err)
+		return err
+	}
+	return cursor.Close()
+}
+
+// GetProvenance(id interface{}) ([]provenance.Fact, error)
+func (ag *ArangoGraph) GetProvenance(id interface{}) ([]provenance.Fact, error) {
+	ctx := ag.context()
+	idStr, err := toIDString(id)
+	if err != nil {
+		return nil, err
+	}
+	if exists, err := ag.checkItemExists(idStr); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, fmt.Errorf("item %s does not exist", idStr)
+	}
+	if err := ag.ensureProvenanceCollection(); err != nil {
+		return nil, err
+	}
+	return ag.readFacts(ctx, `
+		FOR f IN @@col
+		FILTER f.item == @item
+		SORT f.field, f.source
+		RETURN f
+	`, map[string]interface{}{"@col": handler.ProvenanceCollection, "item": idStr})
+}
+
+// GetFactsBySource(source string) ([]provenance.Fact, error)
+func (ag *ArangoGraph) GetFactsBySource(source string) ([]provenance.Fact, error) {
+	ctx := ag.context()
+	if source == "" {
+		return nil, fmt.Errorf("source is required")
+	}
+	if err := ag.ensureProvenanceCollection(); err != nil {
+		return nil, err
+	}
+	return ag.readFacts(ctx, `
+		FOR f IN @@col
+		FILTER f.source == @source
+		SORT f.item, f.field
+		RETURN f
+	`, map[string]interface{}{"@col": handler.ProvenanceCollection, "source": source})
+}
+
+// RetractSource(source string, dryRun bool) (provenance.Report, error)
+func (ag *ArangoGraph) RetractSource(source string, dryRun bool) (provenance.Report, error) {
+	ctx := ag.context()
+	mine, err := ag.GetFactsBySource(source)
+	if err != nil {
+		return provenance.Report{}, err
+	}
+	items := []string{}
+	seen := make(map[string]bool)
+	for _, f := range mine {
+		if !seen[f.Item] {
+			seen[f.Item] = true
+			items = append(items, f.Item)
+		}
+	}
+	// # all the facts of the items the source touched
+	facts, err := ag.readFacts(ctx, `
+		FOR f IN @@col
+		FILTER f.item IN @items
+		RETURN f
+	`, map[string]interface{}{"@col": handler.ProvenanceCollection, "items": items})
+	if err != nil {
+		return provenance.Report{}, err
+	}
+	report := provenance.Plan(source, facts)
+	report.DryRun = dryRun
+
+	// # the edges of the deleted nodes go with them
+	for _, it := range report.Deleted {
+		if it.Kind != history.NodeKind {
+			continue
+		}
+		name, ok := ag.nodeNameToIDMap.GetKey(driver.DocumentID(it.ID))
+		if !ok {
+			continue
+		}
+		in, err := ag.GetInEdges(name)
+		if err != nil {
+			return report, err
+		}
+		out, err := ag.GetOutEdges(name)
+		if err != nil {
+			return report, err
+		}
+		for _, he := range append(in, out...) {
+			e := he.(*Edge)
+			if !report.Delete(e.ID) {
+				report.Cascaded = append(report.Cascaded, provenance.Item{ID: e.ID, Kind: history.EdgeKind, Collection: e.Collection})
+			}
+		}
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	for id, fields := range report.Removed {
+		infos := strings.Split(id, "/")
+		if len(infos) != 2 {
+			return report, fmt.Errorf("invalid id %s", id)
+		}
+		// % mergeObjects false so the data is replaced, not merged with the old one
+		cursor, err := ag.db.Query(ctx, `
+			LET d = DOCUMENT(@id)
+			UPDATE d WITH { data: UNSET(d.data, @fields) } IN @@col
+			OPTIONS { mergeObjects: false }
+		`, map[string]interface{}{"@col": infos[0], "id": id, "fields": fields})
+		if err != nil {
+			ag.logger.Info().Msgf("Failed to remove fields: %v", err)
+			return report, err
+		}
+		cursor.Close()
+		if err := ag.record(history.Retract, id, nil); err != nil {
+			return report, err
+		}
+	}
+	for _, it := range report.DeleteOrder() {
+		if err := ag.DeleteItemByID(it.ID); err != nil {
+			return report, err
+		}
+	}
+	cursor, err := ag.db.Query(ctx, `
+		FOR f IN @@col
+		FILTER f.source == @source
+		REMOVE f IN @@col
+	`, map[string]interface{}{"@col": handler.ProvenanceCollection, "source": source})
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to remove facts: %v", err)
+		return report, err
+	}
+	return report, cursor.Close()
+}
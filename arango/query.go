@@ -0,0 +1,140 @@
+package arango
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arangodb/go-driver"
+	"github.com/wonderstone/chainstorm/filter"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/query"
+)
+
+// the ArangoGraph runs the query plans as one AQL traversal
+var _ query.Native = (*ArangoGraph)(nil)
+
+// aqlCond translates a query condition, vars maps the query variables to the AQL ones
+func aqlCond(c query.Cond, vars map[string]string, bindVars map[string]interface{}) string {
+	switch c.Op {
+	case filter.OpAnd, filter.OpOr:
+		parts := make([]string, len(c.Conds))
+		for i, sub := range c.Conds {
+			parts[i] = aqlCond(sub, vars, bindVars)
+		}
+		return "(" + strings.Join(parts, " "+strings.ToUpper(string(c.Op))+" ") + ")"
+	case filter.OpNot:
+		return "NOT (" + aqlCond(c.Conds[0], vars, bindVars) + ")"
+	}
+	doc := vars[c.Var]
+	var path string
+	switch c.Path {
+	case "Name":
+		path = doc + ".name"
+	case "Collection":
+		path = doc + ".collection"
+	default:
+		path = aqlPath(doc, strings.TrimPrefix(c.Path, "Data."))
+	}
+	return aqlCompare(path, filter.Expr{Op: c.Op, Value: c.Value}, bindVars)
+}
+
+// aqlDirections are the traversal directions of the relationship patterns
+var aqlDirections = map[query.Direction]string{query.Out: "OUTBOUND", query.In: "INBOUND", query.Both: "ANY"}
+
+// RunPlan(p *query.Plan) (*query.Result, error)
+// the start needs a name or a collection, the scan of all the collections is left to query.Generic
+func (ag *ArangoGraph) RunPlan(p *query.Plan) (*query.Result, error) {
+	ctx := ag.context()
+	empty := &query.Result{Columns: p.Return, Rows: [][]handler.Node{}}
+	vars := map[string]string{}
+	for i, s := range p.Steps {
+		vars[s.Var] = fmt.Sprintf("n%d", i)
+	}
+
+	bindVars := map[string]interface{}{}
+	var lines []string
+	switch first := p.Steps[0]; {
+	case first.Name != "":
+		id, ok := ag.nodeNameToIDMap.Get(first.Name)
+		if !ok {
+			return empty, nil
+		}
+		bindVars["start"] = id
+		lines = append(lines, "FOR n0 IN TO_ARRAY(DOCUMENT(@start))")
+	case first.Collection != "":
+		bindVars["@start"] = first.Collection
+		lines = append(lines, "FOR n0 IN @@start")
+	default:
+		return query.Generic(ag, p)
+	}
+	if c := p.Steps[0].Cond; c != nil {
+		lines = append(lines, "FILTER "+aqlCond(*c, vars, bindVars))
+	}
+
+	if len(p.Hops) > 0 {
+		// the hops run on the named graph
+		graph, err := ag.Graph()
+		if err != nil {
+			return nil, err
+		}
+		bindVars["graph"] = graph.Name()
+		for i, h := range p.Hops {
+			v, e, path := fmt.Sprintf("n%d", i+1), fmt.Sprintf("e%d", i+1), fmt.Sprintf("p%d", i+1)
+			lines = append(lines, fmt.Sprintf("FOR %s, %s, %s IN %d..%d %s n%d GRAPH @graph", v, e, path, h.Min, h.Max, aqlDirections[h.Direction], i))
+			if len(h.Types) > 0 {
+				// # the paths leaving the relationships are pruned, then the ones reaching v checked whole
+				types := fmt.Sprintf("types%d", i+1)
+				bindVars[types] = h.Types
+				lines = append(lines,
+					fmt.Sprintf("PRUNE %s != null AND %s.relationship NOT IN @%s", e, e, types),
+					`OPTIONS { uniqueVertices: "path", order: "bfs" }`,
+					fmt.Sprintf("FILTER %s.edges[*].relationship ALL IN @%s", path, types))
+			} else {
+				lines = append(lines, `OPTIONS { uniqueVertices: "path", order: "bfs" }`)
+			}
+			if c := p.Steps[i+1].Cond; c != nil {
+				lines = append(lines, "FILTER "+aqlCond(*c, vars, bindVars))
+			}
+		}
+	}
+	if p.Residual != nil {
+		lines = append(lines, "FILTER "+aqlCond(*p.Residual, vars, bindVars))
+	}
+
+	returned := make([]string, len(p.Return))
+	for i, v := range p.Return {
+		returned[i] = vars[v]
+	}
+	// % COLLECT makes the rows distinct
+	lines = append(lines, "COLLECT row = ["+strings.Join(returned, ", ")+"]")
+	if p.Limit > 0 {
+		bindVars["limit"] = p.Limit
+		lines = append(lines, "LIMIT @limit")
+	}
+	lines = append(lines, "RETURN row")
+
+	cursor, err := ag.db.Query(ctx, strings.Join(lines, "\n"), bindVars)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to execute query: %v", err)
+		return nil, err
+	}
+	defer cursor.Close()
+
+	res := empty
+	for {
+		var row []Node
+		_, err := cursor.ReadDocument(ctx, &row)
+		if driver.IsNoMoreDocuments(err) {
+			break
+		} else if err != nil {
+			ag.logger.Info().Msgf("Failed to read document: %v", err)
+			return nil, err
+		}
+		nodes := make([]handler.Node, len(row))
+		for i := range row {
+			nodes[i] = &row[i]
+		}
+		res.Rows = append(res.Rows, nodes)
+	}
+	return res, nil
+}
@@ -0,0 +1,124 @@
+package arango
+
+import (
+	"fmt"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/provenance"
+	"github.com/wonderstone/chainstorm/resolve"
+)
+
+// the ArangoGraph merges duplicate nodes
+var _ handler.ResolvingGraph = (*ArangoGraph)(nil)
+
+// MergeNodesInto(survivor interface{}, duplicates ...interface{}) error
+func (ag *ArangoGraph) MergeNodesInto(survivor interface{}, duplicates ...interface{}) error {
+	sn, err := ag.GetNode(survivor)
+	if err != nil {
+		return err
+	}
+	s := sn.(*Node)
+	// check everything before changing anything
+	dups := make([]*Node, 0, len(duplicates))
+	for _, name := range duplicates {
+		dn, err := ag.GetNode(name)
+		if err != nil {
+			return err
+		}
+		d := dn.(*Node)
+		if d.ID == s.ID {
+			return fmt.Errorf("node %s can not be merged into itself", d.Name)
+		}
+		dups = append(dups, d)
+	}
+
+	ctx := ag.context()
+	// # the data of each duplicate, its name and aliases become aliases of the survivor
+	// the merges run before the first write, a failed one changes nothing
+	datas := make([]map[string]interface{}, len(dups))
+	sData := s.Data
+	for i, d := range dups {
+		data := make(map[string]interface{}, len(d.Data))
+		for k, v := range d.Data {
+			if k != handler.AliasesKey {
+				data[k] = v
+			}
+		}
+		merged, err := ag.mergeConfig().Merge(s.Collection, sData, data)
+		if err != nil {
+			return err
+		}
+		sData = resolve.AddAliases(merged, handler.AliasesKey, append([]string{d.Name}, resolve.Aliases(d.Data, handler.AliasesKey)...)...)
+		datas[i] = sData
+	}
+
+	for i, d := range dups {
+		if err := ag.repointEdges(d, s); err != nil {
+			return err
+		}
+
+		s.Data = datas[i]
+		if err := ag.ReplaceNode(s); err != nil {
+			return err
+		}
+
+		// # the facts of the duplicate now hold for the survivor, before they go with it
+		if err := ag.ensureProvenanceCollection(); err != nil {
+			return err
+		}
+		facts, err := ag.readFacts(ctx, `
+			FOR f IN @@col
+			FILTER f.item == @item
+			RETURN f
+		`, map[string]interface{}{"@col": handler.ProvenanceCollection, "item": d.ID})
+		if err != nil {
+			return err
+		}
+		if len(facts) > 0 {
+			if err := ag.upsertFacts(ctx, provenance.Move(facts, s.ID, s.Collection)); err != nil {
+				return err
+			}
+		}
+		if err := ag.DeleteNode(d.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// repointEdges moves the edges of the duplicate to the survivor
+// the ones between the two nodes would become loops and are deleted
+func (ag *ArangoGraph) repointEdges(d, s *Node) error {
+	in, err := ag.GetInEdges(d.Name)
+	if err != nil {
+		return err
+	}
+	out, err := ag.GetOutEdges(d.Name)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, ei := range append(in, out...) {
+		e := ei.(*Edge)
+		if seen[e.ID] {
+			continue
+		}
+		seen[e.ID] = true
+		if e.From == d.ID {
+			e.From = s.ID
+		}
+		if e.To == d.ID {
+			e.To = s.ID
+		}
+		if e.From == s.ID && e.To == s.ID {
+			if err := ag.DeleteItemByID(e.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := ag.ReplaceEdge(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package arango
+
+import (
+	"context"
+
+	"github.com/arangodb/go-driver"
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+// # the revision of a node or an edge is its _rev,
+// # a write given it sends it as If-Match and arango refuses it once the document changed
+
+// ifMatch returns the context of a write conditional on the revision, the same context without one
+func ifMatch(ctx context.Context, rev string) context.Context {
+	if rev == "" {
+		return ctx
+	}
+	return driver.WithRevision(ctx, rev)
+}
+
+// conflict returns the handler.ConflictError of a refused write with the revision the document has now
+func (ag *ArangoGraph) conflict(ctx context.Context, col driver.Collection, key, id, expected string) error {
+	ag.logger.Info().Msgf("Revision conflict on %s", id)
+	var doc map[string]interface{}
+	meta, err := col.ReadDocument(ctx, key, &doc)
+	actual := ""
+	if err == nil {
+		actual = meta.Rev
+	}
+	return &handler.ConflictError{ID: id, Expected: expected, Actual: actual}
+}
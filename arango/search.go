@@ -0,0 +1,212 @@
+package arango
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/arangodb/go-driver"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/search"
+)
+
+// the ArangoGraph searches the nodes with an ArangoSearch view
+var _ handler.SearchGraph = (*ArangoGraph)(nil)
+
+// searchAnalyzer is the built-in analyzer segmenting the Chinese text
+const searchAnalyzer = "text_zh"
+
+// searchConfig returns the indexed fields, the default ones if not initialized
+func (ag *ArangoGraph) searchConfig() *search.Config {
+	if ag.searcher == nil {
+		return search.Default()
+	}
+	return ag.searcher
+}
+
+// searchLinks returns the links of the view: the name, the aliases and the fields of every node collection
+func (ag *ArangoGraph) searchLinks(ctx context.Context) (driver.ArangoSearchLinks, error) {
+	collections, err := ag.db.Collections(ctx)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to list collections: %v", err)
+		return nil, err
+	}
+	analyzed := driver.ArangoSearchElementProperties{Analyzers: []string{searchAnalyzer}}
+	data := driver.ArangoSearchFields{handler.AliasesKey: analyzed}
+	for _, f := range ag.searchConfig().Fields {
+		data[f] = analyzed
+	}
+	links := driver.ArangoSearchLinks{}
+	for _, col := range collections {
+		props, err := col.Properties(ctx)
+		if err != nil {
+			ag.logger.Info().Msgf("Failed to get collection properties: %v", err)
+			return nil, err
+		}
+		// # only the node collections
+		if props.IsSystem || handler.IsReserved(col.Name()) || props.Type == driver.CollectionTypeEdge {
+			continue
+		}
+		links[col.Name()] = driver.ArangoSearchElementProperties{
+			Fields: driver.ArangoSearchFields{
+				"name":       analyzed,
+				"collection": {Analyzers: []string{"identity"}},
+				"data":       {Fields: data},
+			},
+		}
+	}
+	return links, nil
+}
+
+// ensureSearchView creates the view, or links it to the node collections created since
+// force relinks all the collections, after the fields changed
+func (ag *ArangoGraph) ensureSearchView(ctx context.Context, force bool) error {
+	links, err := ag.searchLinks(ctx)
+	if err != nil {
+		return err
+	}
+	exists, err := ag.db.ViewExists(ctx, handler.SearchCollection)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to check for view: %v", err)
+		return err
+	}
+	if !exists {
+		_, err = ag.db.CreateArangoSearchView(ctx, handler.SearchCollection, &driver.ArangoSearchViewProperties{Links: links})
+		if err != nil {
+			ag.logger.Info().Msgf("Failed to create view: %v", err)
+		}
+		return err
+	}
+
+	v, err := ag.db.View(ctx, handler.SearchCollection)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to open view: %v", err)
+		return err
+	}
+	view, err := v.ArangoSearchView()
+	if err != nil {
+		return err
+	}
+	if !force {
+		props, err := view.Properties(ctx)
+		if err != nil {
+			ag.logger.Info().Msgf("Failed to get view properties: %v", err)
+			return err
+		}
+		// % the links are updated only when a collection is missing
+		missing := false
+		for col := range links {
+			if _, ok := props.Links[col]; !ok {
+				missing = true
+				break
+			}
+		}
+		if !missing {
+			return nil
+		}
+	}
+	if err := view.SetProperties(ctx, driver.ArangoSearchViewProperties{Links: links}); err != nil {
+		ag.logger.Info().Msgf("Failed to update view: %v", err)
+		return err
+	}
+	return nil
+}
+
+// SetSearchConfig(c *search.Config) error
+func (ag *ArangoGraph) SetSearchConfig(c *search.Config) error {
+	ag.searcher = c
+	return ag.ensureSearchView(context.Background(), true)
+}
+
+// searchField is the AQL path of an indexed text with its weight
+type searchField struct {
+	path   string
+	weight float64
+}
+
+// searchFields are the indexed texts, weighted like the in-process index
+func (ag *ArangoGraph) searchFields() []searchField {
+	fields := []searchField{
+		{"doc.name", search.NameWeight},
+		{"doc.data.`" + handler.AliasesKey + "`", search.AliasWeight},
+	}
+	sorted := append([]string{}, ag.searchConfig().Fields...)
+	sort.Strings(sorted)
+	for _, f := range sorted {
+		fields = append(fields, searchField{"doc.data.`" + f + "`", search.FieldWeight})
+	}
+	return fields
+}
+
+// Search(query string, opts search.Options) ([]handler.SearchHit, error)
+func (ag *ArangoGraph) Search(query string, opts search.Options) ([]handler.SearchHit, error) {
+	ctx := context.Background()
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("query is empty")
+	}
+	if err := ag.ensureSearchView(ctx, false); err != nil {
+		return nil, err
+	}
+
+	// # any token in any field, the prefix search completes the tokens
+	var conditions []string
+	for _, f := range ag.searchFields() {
+		cond := fmt.Sprintf("%s IN TOKENS(@query, @analyzer)", f.path)
+		if opts.Prefix {
+			cond = fmt.Sprintf("STARTS_WITH(%s, TOKENS(@query, @analyzer), 1)", f.path)
+		}
+		conditions = append(conditions, fmt.Sprintf("BOOST(%s, %g)", cond, f.weight))
+	}
+	bindVars := map[string]interface{}{
+		"@view":    handler.SearchCollection,
+		"query":    query,
+		"analyzer": searchAnalyzer,
+	}
+	filter := ""
+	if len(opts.Collections) > 0 {
+		filter = "FILTER doc.collection IN @collections"
+		bindVars["collections"] = opts.Collections
+	}
+	limit := ""
+	if opts.Limit > 0 {
+		limit = "LIMIT @limit"
+		bindVars["limit"] = opts.Limit
+	}
+	// % waitForSync makes the latest writes visible to the search
+	aql := fmt.Sprintf(`
+		FOR doc IN @@view
+		SEARCH ANALYZER(%s, @analyzer)
+		OPTIONS { waitForSync: true }
+		%s
+		LET score = BM25(doc)
+		SORT score DESC, doc._id
+		%s
+		RETURN { node: doc, score: score }
+	`, strings.Join(conditions, " OR "), filter, limit)
+
+	cursor, err := ag.db.Query(ctx, aql, bindVars)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to execute query: %v", err)
+		return nil, err
+	}
+	defer cursor.Close()
+
+	hits := []handler.SearchHit{}
+	for {
+		var doc struct {
+			Node  Node    `json:"node"`
+			Score float64 `json:"score"`
+		}
+		_, err := cursor.ReadDocument(ctx, &doc)
+		if driver.IsNoMoreDocuments(err) {
+			break
+		} else if err != nil {
+			ag.logger.Info().Msgf("Failed to read document: %v", err)
+			return nil, err
+		}
+		node := doc.Node
+		hits = append(hits, handler.SearchHit{Node: &node, Score: doc.Score})
+	}
+	return hits, nil
+}
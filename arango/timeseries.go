@@ -0,0 +1,309 @@
+package arango
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/arangodb/go-driver"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/timeseries"
+)
+
+// the ArangoGraph keeps the time series properties
+var _ handler.TimeSeriesGraph = (*ArangoGraph)(nil)
+
+// seriesDoc is one point in the series collection
+// t is stored as unix milliseconds so it can be indexed and compared in AQL
+type seriesDoc struct {
+	Item   string  `json:"item"`
+	Metric string  `json:"metric"`
+	T      int64   `json:"t"`
+	V      float64 `json:"v"`
+}
+
+func (d seriesDoc) point() timeseries.Point {
+	return timeseries.Point{Time: time.UnixMilli(d.T).UTC(), Value: d.V}
+}
+
+// toIDString converts the string or driver.DocumentID id into collection/key string
+func toIDString(id interface{}) (string, error) {
+	switch id := id.(type) {
+	case string:
+		return id, nil
+	case driver.DocumentID:
+		return id.String(), nil
+	default:
+		return "", fmt.Errorf("invalid id")
+	}
+}
+
+// ensureSeriesCollection creates the series collection and its index if needed
+func (ag *ArangoGraph) ensureSeriesCollection() error {
+	// # DDL is not transactional, it runs outside the transactions
+	ctx := context.Background()
+	exists, err := ag.db.CollectionExists(ctx, handler.SeriesCollection)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to check for collection: %v", err)
+		return err
+	}
+	var col driver.Collection
+	if exists {
+		col, err = ag.db.Collection(ctx, handler.SeriesCollection)
+	} else {
+		col, err = ag.db.CreateCollection(ctx, handler.SeriesCollection, nil)
+	}
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to open series collection: %v", err)
+		return err
+	}
+	// one point per item, metric and timestamp, the index also serves the range queries
+	_, _, err = col.EnsurePersistentIndex(ctx, []string{"item", "metric", "t"}, &driver.EnsurePersistentIndexOptions{
+		Unique: true,
+	})
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to create index: %v", err)
+		return err
+	}
+	return nil
+}
+
+// seriesFilter returns the AQL filter and bind vars for a point range
+func seriesFilter(item, metric string, from, to time.Time) (string, map[string]interface{}) {
+	filter := "p.item == @item AND p.metric == @metric"
+	bindVars := map[string]interface{}{
+		"@col":   handler.SeriesCollection,
+		"item":   item,
+		"metric": metric,
+	}
+	if !from.IsZero() {
+		filter += " AND p.t >= @from"
+		bindVars["from"] = from.UnixMilli()
+	}
+	if !to.IsZero() {
+		filter += " AND p.t <= @to"
+		bindVars["to"] = to.UnixMilli()
+	}
+	return filter, bindVars
+}
+
+// readSeriesDocs runs the query and reads all the point documents
+func (ag *ArangoGraph) readSeriesDocs(ctx context.Context, query string, bindVars map[string]interface{}) ([]timeseries.Point, error) {
+	cursor, err := ag.db.Query(ctx, query, bindVars)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to execute query: %v", err)
+		return nil, err
+	}
+	defer cursor.Close()
+
+	points := []timeseries.Point{}
+	for {
+		var doc seriesDoc
+		_, err := cursor.ReadDocument(ctx, &doc)
+		if driver.IsNoMoreDocuments(err) {
+			break
+		} else if err != nil {
+			ag.logger.Info().Msgf("Failed to read document: %v", err)
+			return nil, err
+		}
+		points = append(points, doc.point())
+	}
+	return points, nil
+}
+
+// seriesItem checks the id, the metric and if the item exists
+func (ag *ArangoGraph) seriesItem(id interface{}, metric string) (string, error) {
+	if err := timeseries.ValidMetric(metric); err != nil {
+		return "", err
+	}
+	idStr, err := toIDString(id)
+	if err != nil {
+		ag.logger.Info().Msgf("Invalid id: %v", id)
+		return "", err
+	}
+	exists, err := ag.checkItemExists(idStr)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", fmt.Errorf("item %s does not exist", idStr)
+	}
+	return idStr, nil
+}
+
+// AppendPoints(id interface{}, metric string, points ...timeseries.Point) error
+func (ag *ArangoGraph) AppendPoints(id interface{}, metric string, points ...timeseries.Point) error {
+	ctx := ag.context()
+	idStr, err := ag.seriesItem(id, metric)
+	if err != nil {
+		return err
+	}
+	if err := ag.ensureSeriesCollection(); err != nil {
+		return err
+	}
+
+	docs := make([]seriesDoc, len(points))
+	for i, p := range points {
+		docs[i] = seriesDoc{Item: idStr, Metric: metric, T: p.Time.UnixMilli(), V: p.Value}
+	}
+	// # upsert so the same timestamp replaces the value
+	query := `
+		FOR p IN @points
+		UPSERT { item: p.item, metric: p.metric, t: p.t }
+		INSERT p
+		UPDATE { v: p.v }
+		IN @@col
+	`
+	cursor, err := ag.db.Query(ctx, query, map[string]interface{}{
+		"@col":   handler.SeriesCollection,
+		"points": docs,
+	})
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to append points: %v", err)
+		return err
+	}
+	return cursor.Close()
+}
+
+// GetSeries(id interface{}, metric string, from, to time.Time) ([]timeseries.Point, error)
+func (ag *ArangoGraph) GetSeries(id interface{}, metric string, from, to time.Time) ([]timeseries.Point, error) {
+	ctx := ag.context()
+	idStr, err := ag.seriesItem(id, metric)
+	if err != nil {
+		return nil, err
+	}
+	if err := ag.ensureSeriesCollection(); err != nil {
+		return nil, err
+	}
+
+	filter, bindVars := seriesFilter(idStr, metric, from, to)
+	query := fmt.Sprintf(`
+		FOR p IN @@col
+		FILTER %s
+		SORT p.t
+		RETURN p
+	`, filter)
+	return ag.readSeriesDocs(ctx, query, bindVars)
+}
+
+// GetValueAsOf(id interface{}, metric string, t time.Time) (timeseries.Point, error)
+func (ag *ArangoGraph) GetValueAsOf(id interface{}, metric string, t time.Time) (timeseries.Point, error) {
+	ctx := ag.context()
+	idStr, err := ag.seriesItem(id, metric)
+	if err != nil {
+		return timeseries.Point{}, err
+	}
+	if err := ag.ensureSeriesCollection(); err != nil {
+		return timeseries.Point{}, err
+	}
+
+	filter, bindVars := seriesFilter(idStr, metric, time.Time{}, t)
+	query := fmt.Sprintf(`
+		FOR p IN @@col
+		FILTER %s
+		SORT p.t DESC
+		LIMIT 1
+		RETURN p
+	`, filter)
+	points, err := ag.readSeriesDocs(ctx, query, bindVars)
+	if err != nil {
+		return timeseries.Point{}, err
+	}
+	if len(points) == 0 {
+		return timeseries.Point{}, timeseries.ErrNoPoints
+	}
+	return points[0], nil
+}
+
+// AggregateSeries(id interface{}, metric string, from, to time.Time, agg timeseries.Aggregation) (float64, error)
+// the aggregate is computed by AQL, only the result comes back
+func (ag *ArangoGraph) AggregateSeries(id interface{}, metric string, from, to time.Time, agg timeseries.Aggregation) (float64, error) {
+	ctx := ag.context()
+	if !agg.Valid() {
+		return 0, fmt.Errorf("unknown aggregation %q", agg)
+	}
+	idStr, err := ag.seriesItem(id, metric)
+	if err != nil {
+		return 0, err
+	}
+	if err := ag.ensureSeriesCollection(); err != nil {
+		return 0, err
+	}
+
+	filter, bindVars := seriesFilter(idStr, metric, from, to)
+	query := fmt.Sprintf(`
+		LET vs = (
+			FOR p IN @@col
+			FILTER %s
+			SORT p.t
+			RETURN p.v
+		)
+		RETURN {
+			count: LENGTH(vs),
+			sum: SUM(vs),
+			avg: AVERAGE(vs),
+			min: MIN(vs),
+			max: MAX(vs),
+			first: FIRST(vs),
+			last: LAST(vs)
+		}
+	`, filter)
+	cursor, err := ag.db.Query(ctx, query, bindVars)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to execute query: %v", err)
+		return 0, err
+	}
+	defer cursor.Close()
+
+	var res map[string]interface{}
+	if _, err := cursor.ReadDocument(ctx, &res); err != nil {
+		ag.logger.Info().Msgf("Failed to read document: %v", err)
+		return 0, err
+	}
+	count, _ := res[string(timeseries.Count)].(float64)
+	if agg == timeseries.Count {
+		return count, nil
+	}
+	if count == 0 {
+		return 0, timeseries.ErrNoPoints
+	}
+	v, ok := res[string(agg)].(float64)
+	if !ok {
+		return 0, fmt.Errorf("invalid aggregate result %v", res[string(agg)])
+	}
+	return v, nil
+}
+
+// DeleteSeries(id interface{}, metric string) error
+func (ag *ArangoGraph) DeleteSeries(id interface{}, metric string) error {
+	idStr, err := ag.seriesItem(id, metric)
+	if err != nil {
+		return err
+	}
+	return ag.removeSeries(idStr, metric)
+}
+
+// removeSeries removes the points of the item, all the metrics if metric is blank
+func (ag *ArangoGraph) removeSeries(idStr, metric string) error {
+	ctx := ag.context()
+	exists, err := ag.db.CollectionExists(ctx, handler.SeriesCollection)
+	if err != nil || !exists {
+		return err
+	}
+	filter := "p.item == @item"
+	bindVars := map[string]interface{}{"@col": handler.SeriesCollection, "item": idStr}
+	if metric != "" {
+		filter += " AND p.metric == @metric"
+		bindVars["metric"] = metric
+	}
+	cursor, err := ag.db.Query(ctx, fmt.Sprintf(`
+		FOR p IN @@col
+		FILTER %s
+		REMOVE p IN @@col
+	`, filter), bindVars)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to remove points: %v", err)
+		return err
+	}
+	return cursor.Close()
+}
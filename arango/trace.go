@@ -0,0 +1,53 @@
+package arango
+
+import (
+	"context"
+
+	"github.com/arangodb/go-driver"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/trace"
+)
+
+// the ArangoGraph calls the database in the context of its caller
+var _ handler.ContextGraph = (*ArangoGraph)(nil)
+
+// WithContext(ctx context.Context) handler.GraphDB
+// the view is a copy sharing the maps, like the one of a transaction
+func (ag *ArangoGraph) WithContext(ctx context.Context) handler.GraphDB {
+	view := *ag
+	view.base = ctx
+	return &view
+}
+
+// tracedConnection starts a span for each request, the child of the span of its context
+type tracedConnection struct {
+	driver.Connection
+}
+
+func traceConnection(conn driver.Connection) driver.Connection {
+	return &tracedConnection{Connection: conn}
+}
+
+// Do(ctx context.Context, req driver.Request) (driver.Response, error)
+func (c *tracedConnection) Do(ctx context.Context, req driver.Request) (driver.Response, error) {
+	ctx, span := trace.Start(ctx, "arango.request")
+	defer span.End()
+	span.Set("method", req.Method())
+	span.Set("path", req.Path())
+	resp, err := c.Connection.Do(ctx, req)
+	if resp != nil {
+		span.Set("status", resp.StatusCode())
+	}
+	span.Fail(err)
+	return resp, err
+}
+
+// SetAuthentication(a driver.Authentication) (driver.Connection, error)
+// the client calls it with the connection it was given, the authenticated one is traced too
+func (c *tracedConnection) SetAuthentication(a driver.Authentication) (driver.Connection, error) {
+	conn, err := c.Connection.SetAuthentication(a)
+	if err != nil {
+		return nil, err
+	}
+	return traceConnection(conn), nil
+}
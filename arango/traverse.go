@@ -0,0 +1,133 @@
+package arango
+
+import (
+	"fmt"
+
+	"github.com/arangodb/go-driver"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/query"
+)
+
+// # the traversals run in the server: one AQL graph traversal over the named graph,
+// # instead of a query per node and per edge collection
+
+// TraversalPath is the path from the start node to a node of a traversal
+type TraversalPath struct {
+	Vertices []Node `json:"vertices"`
+	Edges    []Edge `json:"edges"`
+}
+
+// Traversal holds what a traversal reached
+type Traversal struct {
+	// Levels[i] holds the nodes first reached at depth Min+i
+	Levels [][]handler.Node
+	// Edges are the edges the nodes were reached by, the start node has none
+	Edges []Edge
+	// Paths are the shortest paths to the nodes, in the order of the nodes
+	Paths []TraversalPath
+}
+
+// traversalRow is a node of the traversal as the query returns it
+type traversalRow struct {
+	Depth  int           `json:"depth"`
+	Vertex Node          `json:"vertex"`
+	Edge   *Edge         `json:"edge"`
+	Path   TraversalPath `json:"path"`
+}
+
+// Traverse(name string, min, max int, direction query.Direction) (*Traversal, error)
+// the nodes min to max hops away, breadth first, each node once at the depth it is first reached
+func (ag *ArangoGraph) Traverse(name string, min, max int, direction query.Direction) (*Traversal, error) {
+	dir, ok := aqlDirections[direction]
+	if !ok {
+		return nil, fmt.Errorf("invalid direction %d", direction)
+	}
+	if min < 0 || max < min {
+		return nil, fmt.Errorf("invalid depth range %d..%d", min, max)
+	}
+	// an alias leads to the name of its node
+	name = ag.resolveName(name)
+	id, ok := ag.nodeNameToIDMap.Get(name)
+	if !ok {
+		ag.logger.Info().Msgf("Node %s does not exist", name)
+		return nil, fmt.Errorf("node does not exist")
+	}
+
+	ctx := ag.context()
+	graph, err := ag.Graph()
+	if err != nil {
+		return nil, err
+	}
+	// % global uniqueness needs the bfs order, a node comes at its shortest depth
+	aql := fmt.Sprintf(`
+		FOR v, e, p IN @min..@max %s @start GRAPH @graph
+		OPTIONS { order: "bfs", uniqueVertices: "global" }
+		RETURN { depth: LENGTH(p.edges), vertex: v, edge: e, path: p }
+	`, dir)
+	bindVars := map[string]interface{}{
+		"min":   min,
+		"max":   max,
+		"start": fmt.Sprint(id),
+		"graph": graph.Name(),
+	}
+	cursor, err := ag.db.Query(ctx, aql, bindVars)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to execute query: %v", err)
+		return nil, err
+	}
+	defer cursor.Close()
+
+	res := &Traversal{Levels: [][]handler.Node{}, Edges: []Edge{}, Paths: []TraversalPath{}}
+	for {
+		var row traversalRow
+		_, err := cursor.ReadDocument(ctx, &row)
+		if driver.IsNoMoreDocuments(err) {
+			break
+		} else if err != nil {
+			ag.logger.Info().Msgf("Failed to read document: %v", err)
+			return nil, err
+		}
+		// the bfs order gives the depths in order
+		for len(res.Levels) <= row.Depth-min {
+			res.Levels = append(res.Levels, []handler.Node{})
+		}
+		node := row.Vertex
+		res.Levels[row.Depth-min] = append(res.Levels[row.Depth-min], &node)
+		if row.Edge != nil {
+			res.Edges = append(res.Edges, *row.Edge)
+		}
+		res.Paths = append(res.Paths, row.Path)
+	}
+	return res, nil
+}
+
+// relatedLevels returns the levels of the out traversal, at most levels of them
+func (ag *ArangoGraph) relatedLevels(name interface{}, levels int) ([][]handler.Node, error) {
+	// Convert the name into a string
+	nameStr, ok := name.(string)
+	if !ok {
+		ag.logger.Info().Msgf("Invalid name: %v", name)
+		return nil, fmt.Errorf("invalid name")
+	}
+	if levels < 1 {
+		levels = 1
+	}
+	t, err := ag.Traverse(nameStr, 0, levels-1, query.Out)
+	if err != nil {
+		return nil, err
+	}
+	return t.Levels, nil
+}
+
+// GetAllRelatedNodes(name interface{}) ([][]Node, error)
+// the levels of the nodes reachable by the out edges, the first one holds the node
+func (ag *ArangoGraph) GetAllRelatedNodes(name interface{}) ([][]handler.Node, error) {
+	// % a level per node at most, the depth needs a bound
+	return ag.relatedLevels(name, ag.nodeNameToIDMap.Size())
+}
+
+// GetAllRelatedNodesInRange(name interface{}, max int) ([][]Node, error)
+// like GetAllRelatedNodes, max levels at most
+func (ag *ArangoGraph) GetAllRelatedNodesInRange(name interface{}, max int) ([][]handler.Node, error) {
+	return ag.relatedLevels(name, max)
+}
@@ -0,0 +1,180 @@
+package arango
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/arangodb/go-driver"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/query"
+)
+
+// benchGraph fills a binary tree of the given depth, bench0 is the root
+// AddNode and AddEdge add the bench collections to the named graph
+func benchGraph(b *testing.B, depth int) *ArangoGraph {
+	b.Helper()
+	ag := &ArangoGraph{}
+	if err := ag.Init("config/config.yaml"); err != nil {
+		b.Fatal(err)
+	}
+	if err := ag.Connect(); err != nil {
+		b.Fatal(err)
+	}
+	ids := []string{}
+	for i := 0; i < 1<<depth-1; i++ {
+		meta, err := ag.AddNode(&Node{Collection: "bench_nodes", Name: fmt.Sprintf("bench%d", i), Data: map[string]interface{}{"i": i}})
+		if err != nil {
+			b.Fatal(err)
+		}
+		ids = append(ids, meta.(driver.DocumentMeta).ID.String())
+		if i > 0 {
+			_, err = ag.AddEdge(&Edge{Collection: "bench_edges", From: ids[(i-1)/2], To: ids[i], Relationship: "child"})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	// % only the bench collections go, the graph and the other collections stay
+	b.Cleanup(func() {
+		if err := removeCollections(ag, "bench_edges", "bench_nodes"); err != nil {
+			b.Error(err)
+		}
+	})
+	return ag
+}
+
+// removeCollections takes the collections out of the named graph and drops them
+func removeCollections(ag *ArangoGraph, names ...string) error {
+	ctx := context.Background()
+	graph, err := ag.Graph()
+	if err != nil {
+		return err
+	}
+	drop := make(map[string]bool, len(names))
+	for _, name := range names {
+		drop[name] = true
+	}
+	keep := func(cols []string) []string {
+		var res []string
+		for _, col := range cols {
+			if !drop[col] {
+				res = append(res, col)
+			}
+		}
+		return res
+	}
+
+	// the edge definitions lose the dropped edge collections and node collections
+	cols, constraints, err := graph.EdgeCollections(ctx)
+	if err != nil {
+		return err
+	}
+	for i, col := range cols {
+		from, to := keep(constraints[i].From), keep(constraints[i].To)
+		switch {
+		case drop[col.Name()] || len(from) == 0 || len(to) == 0:
+			// the collection leaves the graph, not the database
+			if err := col.Remove(ctx); err != nil {
+				return err
+			}
+		case len(from) != len(constraints[i].From) || len(to) != len(constraints[i].To):
+			if err := graph.SetVertexConstraints(ctx, col.Name(), driver.VertexConstraints{From: from, To: to}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range names {
+		if ok, err := graph.VertexCollectionExists(ctx, name); err != nil {
+			return err
+		} else if ok {
+			col, err := graph.VertexCollection(ctx, name)
+			if err != nil {
+				return err
+			}
+			if err := col.Remove(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range names {
+		ok, err := ag.db.CollectionExists(ctx, name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		col, err := ag.db.Collection(ctx, name)
+		if err != nil {
+			return err
+		}
+		if err := col.Remove(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clientBFS is the former client-side traversal, a round trip per node
+func clientBFS(ag *ArangoGraph, name string) ([][]handler.Node, error) {
+	visited := map[string]bool{name: true}
+	level := []string{name}
+	var res [][]handler.Node
+	for len(level) > 0 {
+		var nodes []handler.Node
+		var next []string
+		for _, n := range level {
+			node, err := ag.GetNode(n)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, node)
+			to, err := ag.GetToNodes(n)
+			if err != nil {
+				return nil, err
+			}
+			for _, t := range to {
+				if tn := t.(*Node).Name; !visited[tn] {
+					visited[tn] = true
+					next = append(next, tn)
+				}
+			}
+		}
+		res = append(res, nodes)
+		level = next
+	}
+	return res, nil
+}
+
+func BenchmarkTraverse(b *testing.B) {
+	const depth = 7
+	ag := benchGraph(b, depth)
+
+	b.Run("native", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			levels, err := ag.GetAllRelatedNodes("bench0")
+			if err != nil || len(levels) != depth {
+				b.Fatalf("got %d levels, %v", len(levels), err)
+			}
+		}
+	})
+	b.Run("native-paths", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			t, err := ag.Traverse("bench0", 1, depth, query.Out)
+			if err != nil || len(t.Paths) != 1<<depth-2 {
+				b.Fatalf("got %d paths, %v", len(t.Paths), err)
+			}
+		}
+	})
+	b.Run("client-bfs", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			levels, err := clientBFS(ag, "bench0")
+			if err != nil || len(levels) != depth {
+				b.Fatalf("got %d levels, %v", len(levels), err)
+			}
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package arango
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/arangodb/go-driver"
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+// the ArangoGraph groups writes in transactions
+var _ handler.TxGraph = (*ArangoGraph)(nil)
+
+// # a transaction is a stream transaction: a copy of the graph carries its ID in the context of the operations,
+// # the collections are declared when it begins, a rollback reloads the name map from the committed documents
+
+// context returns the context of the operations, the one of the stream transaction inside a transaction
+func (ag *ArangoGraph) context() context.Context {
+	ctx := context.Background()
+	if ag.base != nil {
+		ctx = ag.base
+	}
+	if ag.txID != "" {
+		return driver.WithTransactionID(ctx, ag.txID)
+	}
+	return ctx
+}
+
+// txCollections returns the collections a transaction writes: the node, edge and chainstorm ones
+// the chainstorm collections are created first, DDL is not transactional
+func (ag *ArangoGraph) txCollections(ctx context.Context) ([]string, error) {
+	for _, ensure := range []func() error{ag.ensureHistoryCollection, ag.ensureProvenanceCollection, ag.ensureSeriesCollection, ag.ensureChangesCollection} {
+		if err := ensure(); err != nil {
+			return nil, err
+		}
+	}
+	collections, err := ag.db.Collections(ctx)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to list collections: %v", err)
+		return nil, err
+	}
+	var names []string
+	for _, col := range collections {
+		props, err := col.Properties(ctx)
+		if err != nil {
+			ag.logger.Info().Msgf("Failed to get collection properties: %v", err)
+			return nil, err
+		}
+		if !props.IsSystem {
+			names = append(names, col.Name())
+		}
+	}
+	return names, nil
+}
+
+// Update(fn func(tx handler.Tx) error) error
+// fn writes to the existing collections only, and must not call ag itself
+func (ag *ArangoGraph) Update(fn func(tx handler.Tx) error) (err error) {
+	if ag.txID != "" {
+		return fmt.Errorf("transactions do not nest")
+	}
+	ctx := ag.context()
+	names, err := ag.txCollections(ctx)
+	if err != nil {
+		return err
+	}
+	txID, err := ag.db.BeginTransaction(ctx, driver.TransactionCollections{Write: names}, nil)
+	if err != nil {
+		ag.logger.Info().Msgf("Failed to begin transaction: %v", err)
+		return err
+	}
+	tx := *ag
+	tx.txID = txID
+
+	// the name map and the aliases may hold the writes of the transaction
+	abort := func() error {
+		if err := ag.db.AbortTransaction(ctx, txID, nil); err != nil {
+			ag.logger.Info().Msgf("Failed to abort transaction: %v", err)
+			return err
+		}
+		ag.nodeNameToIDMap.Clear()
+		ag.aliases.Reset()
+		return ag.createBidimap()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			_ = abort()
+			panic(r)
+		}
+	}()
+	if err := fn(&tx); err != nil {
+		if abortErr := abort(); abortErr != nil {
+			return fmt.Errorf("%v, rollback failed: %v", err, abortErr)
+		}
+		return err
+	}
+	if err := ag.db.CommitTransaction(ctx, txID, nil); err != nil {
+		ag.logger.Info().Msgf("Failed to commit transaction: %v", err)
+		if abortErr := abort(); abortErr != nil {
+			return fmt.Errorf("%v, rollback failed: %v", err, abortErr)
+		}
+		return err
+	}
+	return nil
+}
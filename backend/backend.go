@@ -0,0 +1,50 @@
+// Package backend creates the graph a config.Config selects, so that a program
+// moves between the local, mongo and arango backends by its config file alone.
+//
+// It stands apart from config, the backends import config to read their files.
+package backend
+
+import (
+	"fmt"
+
+	"github.com/wonderstone/chainstorm/arango"
+	"github.com/wonderstone/chainstorm/config"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/local"
+	"github.com/wonderstone/chainstorm/mongo"
+)
+
+// New returns the graph of the backend configured, not connected yet
+func New(c *config.Config) (handler.GraphDB, error) {
+	switch c.Backend {
+	case config.BackendLocal:
+		db, err := local.NewInMemoryDB()
+		if err != nil {
+			return nil, err
+		}
+		return db, db.Configure(c.Local)
+	case config.BackendMongo:
+		mg := &mongo.MongoGraph{}
+		return mg, mg.Configure(c.Mongo)
+	case config.BackendArango:
+		ag := &arango.ArangoGraph{}
+		return ag, ag.Configure(c.Arango)
+	}
+	return nil, fmt.Errorf("unknown backend %q", c.Backend)
+}
+
+// Open loads the config file, creates its graph and connects it
+func Open(path string) (handler.GraphDB, error) {
+	c, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	g, err := New(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.Connect(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
@@ -0,0 +1,31 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/config"
+	"github.com/wonderstone/chainstorm/local"
+)
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "data"), 0755))
+	path := filepath.Join(dir, "chainstorm.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("backend: local\nlocal:\n  dataPath: ${GRAPH_DIR}/data\n"), 0644))
+	t.Setenv("GRAPH_DIR", dir)
+
+	g, err := Open(path)
+	assert.NoError(t, err)
+	assert.IsType(t, &local.InMemoryDB{}, g)
+	n, _ := local.NewNode(local.WithNID("a"), local.WithNCollection("company"), local.WithNName("600001"))
+	_, err = g.AddNode(n)
+	assert.NoError(t, err)
+	assert.NoError(t, g.Disconnect())
+	assert.FileExists(t, filepath.Join(dir, "data", "company", "600001.json"))
+
+	_, err = New(&config.Config{Backend: "neo4j"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,251 @@
+// Package cache is a handler.GraphDB keeping the results of another one.
+//
+// Wrap keeps a bounded LRU of GetNode, GetItemByID and the adjacency reads
+// (GetFromNodes, GetToNodes, GetInEdges, GetOutEdges). Each result is tagged with
+// the IDs and names of its items; a write through the cache, or an event of the
+// change feed once Watch runs, drops the results tagged with the items it touched,
+// and the edge writes and the deletes drop every adjacency result.
+// The cached items are shared by the callers, which must not change them.
+package cache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+)
+
+// DefaultSize is the number of results kept by a cache of size 0
+const DefaultSize = 10000
+
+// adjacency is the tag of the adjacency results
+const adjacency = "adj"
+
+// Stats counts the lookups of a cache
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Len is the number of results kept
+	Len int
+}
+
+// Graph is a handler.GraphDB caching the reads of the wrapped one
+type Graph struct {
+	writes
+	base
+
+	mu  sync.Mutex
+	lru *lru
+	// gen changes with each invalidation, a read started before it is not kept
+	gen   uint64
+	stats Stats
+}
+
+var _ handler.GraphDB = (*Graph)(nil)
+var _ handler.TxGraph = (*Graph)(nil)
+
+// Wrap returns g with a cache of size results, DefaultSize if size is not positive
+func Wrap(g handler.GraphDB, size int) *Graph {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	c := &Graph{base: base{g}, lru: newLRU(size)}
+	c.writes = writes{target: g, done: c.invalidate}
+	return c
+}
+
+// Unwrap returns the backend, for its other interfaces
+func (g *Graph) Unwrap() handler.GraphDB {
+	return g.GraphDB
+}
+
+// Stats returns the counters since Wrap
+func (g *Graph) Stats() Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s := g.stats
+	s.Len = g.lru.len()
+	return s
+}
+
+// Purge drops every result
+func (g *Graph) Purge() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.gen++
+	g.lru = newLRU(g.lru.size)
+}
+
+// invalidate drops the results with one of the tags
+func (g *Graph) invalidate(tags ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.gen++
+	g.lru.drop(tags...)
+}
+
+// cached returns the result kept under the key, or loads it with its tags and keeps it
+func (g *Graph) cached(key string, load func() (interface{}, []string, error)) (interface{}, error) {
+	g.mu.Lock()
+	if v, ok := g.lru.get(key); ok {
+		g.stats.Hits++
+		g.mu.Unlock()
+		return v, nil
+	}
+	g.stats.Misses++
+	gen := g.gen
+	g.mu.Unlock()
+
+	v, tags, err := load()
+	if err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	// ~ a write invalidated while loading, the result may be older than it
+	if g.gen == gen {
+		g.stats.Evictions += uint64(g.lru.put(key, v, tags))
+	}
+	g.mu.Unlock()
+	return v, nil
+}
+
+// # the tags are "id:" with the ID of an item and "name:" with the name of a node, read by reflection
+// # from the Node and Edge structs of every backend; an ID with a Hex method (mongo) is tagged by it
+
+func idTag(id interface{}) string {
+	if h, ok := id.(interface{ Hex() string }); ok {
+		return "id:" + h.Hex()
+	}
+	return "id:" + fmt.Sprint(id)
+}
+
+func nameTag(name interface{}) string {
+	return "name:" + fmt.Sprint(name)
+}
+
+// tagsOf returns the tags of a node or an edge
+func tagsOf(item interface{}) []string {
+	rv := reflect.ValueOf(item)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var tags []string
+	if f := rv.FieldByName("ID"); f.IsValid() {
+		tags = append(tags, idTag(f.Interface()))
+	}
+	if f := rv.FieldByName("Name"); f.IsValid() && f.Kind() == reflect.String && f.String() != "" {
+		tags = append(tags, nameTag(f.String()))
+	}
+	return tags
+}
+
+// eventTags returns the tags a change event invalidates
+func eventTags(e handler.ChangeEvent) []string {
+	tags := []string{"id:" + e.ID}
+	for _, v := range []*history.Version{e.Before, e.After} {
+		if v != nil && v.Name != "" {
+			tags = append(tags, nameTag(v.Name))
+		}
+	}
+	if e.Kind != history.NodeKind || e.Op == history.Delete {
+		tags = append(tags, adjacency)
+	}
+	return tags
+}
+
+// Watch invalidates the results on the events of the change feed of the backend until ctx is done,
+// the writes of other processes included. When the feed closes the cache is purged and Watch must run again
+func (g *Graph) Watch(ctx context.Context) error {
+	cg, ok := g.GraphDB.(handler.ChangeGraph)
+	if !ok {
+		return fmt.Errorf("%T has no change feed", g.GraphDB)
+	}
+	feed, err := cg.Subscribe(ctx, handler.ChangeFilter{})
+	if err != nil {
+		return err
+	}
+	go func() {
+		for e := range feed {
+			g.invalidate(eventTags(e)...)
+		}
+		g.Purge()
+	}()
+	return nil
+}
+
+// + Query operations
+func (g *Graph) GetItemByID(id interface{}) (interface{}, error) {
+	return g.cached("item:"+idTag(id), func() (interface{}, []string, error) {
+		item, err := g.GraphDB.GetItemByID(id)
+		return item, append(tagsOf(item), idTag(id)), err
+	})
+}
+
+func (g *Graph) GetNode(name interface{}) (handler.Node, error) {
+	v, err := g.cached("node:"+fmt.Sprint(name), func() (interface{}, []string, error) {
+		n, err := g.GraphDB.GetNode(name)
+		return n, append(tagsOf(n), nameTag(name)), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(handler.Node), nil
+}
+
+func (g *Graph) GetFromNodes(name interface{}) ([]handler.Node, error) {
+	return g.nodes("from:", name, g.GraphDB.GetFromNodes)
+}
+
+func (g *Graph) GetToNodes(name interface{}) ([]handler.Node, error) {
+	return g.nodes("to:", name, g.GraphDB.GetToNodes)
+}
+
+func (g *Graph) GetInEdges(name interface{}) ([]handler.Edge, error) {
+	return g.edges("in:", name, g.GraphDB.GetInEdges)
+}
+
+func (g *Graph) GetOutEdges(name interface{}) ([]handler.Edge, error) {
+	return g.edges("out:", name, g.GraphDB.GetOutEdges)
+}
+
+// nodes caches an adjacency read returning nodes
+func (g *Graph) nodes(prefix string, name interface{}, read func(interface{}) ([]handler.Node, error)) ([]handler.Node, error) {
+	v, err := g.cached(prefix+fmt.Sprint(name), func() (interface{}, []string, error) {
+		nodes, err := read(name)
+		tags := []string{adjacency, nameTag(name)}
+		for _, n := range nodes {
+			tags = append(tags, tagsOf(n)...)
+		}
+		return nodes, tags, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]handler.Node), nil
+}
+
+// edges caches an adjacency read returning edges
+func (g *Graph) edges(prefix string, name interface{}, read func(interface{}) ([]handler.Edge, error)) ([]handler.Edge, error) {
+	v, err := g.cached(prefix+fmt.Sprint(name), func() (interface{}, []string, error) {
+		edges, err := read(name)
+		tags := []string{adjacency, nameTag(name)}
+		for _, e := range edges {
+			tags = append(tags, tagsOf(e)...)
+		}
+		return edges, tags, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]handler.Edge), nil
+}
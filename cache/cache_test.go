@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/local"
+)
+
+func testGraph(t *testing.T, size int) (*local.InMemoryDB, *Graph) {
+	db, err := local.NewInMemoryDB()
+	assert.NoError(t, err)
+	a, _ := local.NewNode(local.WithNID("a"), local.WithNCollection("company"), local.WithNName("600001"))
+	b, _ := local.NewNode(local.WithNID("b"), local.WithNCollection("person"), local.WithNName("holder"))
+	e, _ := local.NewEdge(local.WithEID("e"), local.WithECollection("invest"), local.WithEName("invest"), local.WithEFrom(b), local.WithETo(a))
+	for _, n := range []*local.Node{a, b} {
+		_, err := db.AddNode(n)
+		assert.NoError(t, err)
+	}
+	_, err = db.AddEdge(e)
+	assert.NoError(t, err)
+	return db, Wrap(db, size)
+}
+
+func TestCache(t *testing.T) {
+	db, g := testGraph(t, 0)
+	for i := 0; i < 2; i++ {
+		_, err := g.GetNode("600001")
+		assert.NoError(t, err)
+		out, err := g.GetOutEdges("holder")
+		assert.NoError(t, err)
+		assert.Len(t, out, 1)
+	}
+	assert.Equal(t, Stats{Hits: 2, Misses: 2, Len: 2}, g.Stats())
+	// the errors are not kept
+	_, err := g.GetNode("missing")
+	assert.Error(t, err)
+	assert.Equal(t, 2, g.Stats().Len)
+
+	// a node write drops the results holding the node
+	assert.NoError(t, g.UpdateNode(&local.Node{ID: "a", Data: map[string]interface{}{"revenue": 1.0}}))
+	assert.Equal(t, 1, g.Stats().Len)
+	// an edge write drops the adjacency
+	c, _ := local.NewNode(local.WithNID("c"), local.WithNCollection("company"), local.WithNName("600002"))
+	_, err = g.AddNode(c)
+	assert.NoError(t, err)
+	holder, _ := db.GetNode("holder")
+	e, _ := local.NewEdge(local.WithEID("e2"), local.WithECollection("invest"), local.WithEName("invest"), local.WithEFrom(holder.(*local.Node)), local.WithETo(c))
+	_, err = g.AddEdge(e)
+	assert.NoError(t, err)
+	out, err := g.GetOutEdges("holder")
+	assert.NoError(t, err)
+	assert.Len(t, out, 2)
+
+	// so does a transaction once it ended
+	from, err := g.GetFromNodes("holder")
+	assert.NoError(t, err)
+	assert.Len(t, from, 2)
+	assert.NoError(t, g.Update(func(tx handler.Tx) error {
+		return tx.DeleteItemByID("e2")
+	}))
+	from, err = g.GetFromNodes("holder")
+	assert.NoError(t, err)
+	assert.Len(t, from, 1)
+}
+
+func TestCacheEviction(t *testing.T) {
+	_, g := testGraph(t, 2)
+	for _, name := range []string{"600001", "holder", "600001", "holder"} {
+		_, err := g.GetNode(name)
+		assert.NoError(t, err)
+	}
+	_, err := g.GetItemByID("e")
+	assert.NoError(t, err)
+	assert.Equal(t, Stats{Hits: 2, Misses: 3, Evictions: 1, Len: 2}, g.Stats())
+	// the least recently used went
+	_, err = g.GetNode("holder")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), g.Stats().Hits)
+}
+
+func TestCacheWatch(t *testing.T) {
+	db, g := testGraph(t, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, g.Watch(ctx))
+
+	to, err := g.GetToNodes("600001")
+	assert.NoError(t, err)
+	assert.Len(t, to, 1)
+	// a write bypassing the cache comes through the change feed
+	assert.NoError(t, db.DeleteItemByID("e"))
+	assert.Eventually(t, func() bool { return g.Stats().Len == 0 }, time.Second, 10*time.Millisecond)
+	to, err = g.GetToNodes("600001")
+	assert.NoError(t, err)
+	assert.Empty(t, to)
+}
@@ -0,0 +1,78 @@
+package cache
+
+import "container/list"
+
+// entry is a cached result with the tags that invalidate it
+type entry struct {
+	key   string
+	value interface{}
+	tags  []string
+}
+
+// lru is a bounded map dropping the least recently used entry, the caller locks it
+type lru struct {
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+	// tags maps a tag to the keys of its entries
+	tags map[string]map[string]struct{}
+}
+
+func newLRU(size int) *lru {
+	return &lru{size: size, ll: list.New(), items: make(map[string]*list.Element), tags: make(map[string]map[string]struct{})}
+}
+
+func (c *lru) get(key string) (interface{}, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*entry).value, true
+}
+
+// put stores the entry and returns how many entries it evicted
+func (c *lru) put(key string, value interface{}, tags []string) int {
+	c.remove(key)
+	c.items[key] = c.ll.PushFront(&entry{key: key, value: value, tags: tags})
+	for _, t := range tags {
+		if c.tags[t] == nil {
+			c.tags[t] = make(map[string]struct{})
+		}
+		c.tags[t][key] = struct{}{}
+	}
+	evicted := 0
+	for c.ll.Len() > c.size {
+		c.remove(c.ll.Back().Value.(*entry).key)
+		evicted++
+	}
+	return evicted
+}
+
+// drop removes the entries with one of the tags
+func (c *lru) drop(tags ...string) {
+	for _, t := range tags {
+		for key := range c.tags[t] {
+			c.remove(key)
+		}
+	}
+}
+
+func (c *lru) remove(key string) {
+	el, ok := c.items[key]
+	if !ok {
+		return
+	}
+	e := c.ll.Remove(el).(*entry)
+	delete(c.items, key)
+	for _, t := range e.tags {
+		delete(c.tags[t], key)
+		if len(c.tags[t]) == 0 {
+			delete(c.tags, t)
+		}
+	}
+}
+
+func (c *lru) len() int {
+	return c.ll.Len()
+}
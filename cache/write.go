@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"fmt"
+
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+// writer is the write half of handler.GraphDB and handler.Tx
+type writer interface {
+	AddNode(n handler.Node) (interface{}, error)
+	AddEdge(e handler.Edge) (interface{}, error)
+	ReplaceNode(n handler.Node) error
+	ReplaceEdge(e handler.Edge) error
+	UpdateNode(n handler.Node) error
+	UpdateEdge(e handler.Edge) error
+	MergeNode(n handler.Node) error
+	MergeEdge(e handler.Edge) error
+	DeleteNode(name interface{}) error
+	DeleteItemByID(id interface{}) error
+}
+
+// writes applies the writes to the target and gives done the tags they invalidate,
+// once the write returned, failed or not
+type writes struct {
+	target writer
+	done   func(tags ...string)
+}
+
+func edgeTags(e handler.Edge) []string {
+	return append(tagsOf(e), adjacency)
+}
+
+func (ws writes) AddNode(n handler.Node) (interface{}, error) {
+	id, err := ws.target.AddNode(n)
+	ws.done(tagsOf(n)...)
+	return id, err
+}
+
+func (ws writes) AddEdge(e handler.Edge) (interface{}, error) {
+	id, err := ws.target.AddEdge(e)
+	ws.done(edgeTags(e)...)
+	return id, err
+}
+
+func (ws writes) ReplaceNode(n handler.Node) error {
+	defer ws.done(tagsOf(n)...)
+	return ws.target.ReplaceNode(n)
+}
+
+func (ws writes) ReplaceEdge(e handler.Edge) error {
+	defer ws.done(edgeTags(e)...)
+	return ws.target.ReplaceEdge(e)
+}
+
+func (ws writes) UpdateNode(n handler.Node) error {
+	defer ws.done(tagsOf(n)...)
+	return ws.target.UpdateNode(n)
+}
+
+func (ws writes) UpdateEdge(e handler.Edge) error {
+	defer ws.done(edgeTags(e)...)
+	return ws.target.UpdateEdge(e)
+}
+
+func (ws writes) MergeNode(n handler.Node) error {
+	defer ws.done(tagsOf(n)...)
+	return ws.target.MergeNode(n)
+}
+
+func (ws writes) MergeEdge(e handler.Edge) error {
+	defer ws.done(edgeTags(e)...)
+	return ws.target.MergeEdge(e)
+}
+
+// the deletes take the edges of the node with them
+func (ws writes) DeleteNode(name interface{}) error {
+	defer ws.done(nameTag(name), adjacency)
+	return ws.target.DeleteNode(name)
+}
+
+func (ws writes) DeleteItemByID(id interface{}) error {
+	defer ws.done(idTag(id), adjacency)
+	return ws.target.DeleteItemByID(id)
+}
+
+// # the Graph and the tx embed their writes and the backend one level deeper,
+// # so the write methods of writes win over the ones of the backend
+
+type base struct{ handler.GraphDB }
+
+// tx is the handler.Tx of the Graph, it reads the transaction and collects the tags of its writes
+type tx struct {
+	writes
+	txBase
+	tags []string
+}
+
+type txBase struct{ handler.Tx }
+
+var _ handler.Tx = (*tx)(nil)
+
+// Update(fn func(tx handler.Tx) error) error
+// the results are invalidated once the transaction ended, the reads of tx are not cached
+func (g *Graph) Update(fn func(tx handler.Tx) error) error {
+	txg, ok := g.GraphDB.(handler.TxGraph)
+	if !ok {
+		return fmt.Errorf("%T has no transactions", g.GraphDB)
+	}
+	t := &tx{}
+	t.done = func(tags ...string) { t.tags = append(t.tags, tags...) }
+	defer func() { g.invalidate(t.tags...) }()
+	return txg.Update(func(inner handler.Tx) error {
+		t.target, t.Tx = inner, inner
+		return fn(t)
+	})
+}
@@ -0,0 +1,123 @@
+// Package changes is the in-process change feed: the writes publish their events to a Bus,
+// the subscribers read them in order from a bounded log, so a feed can resume from a cursor
+// as long as its events are still kept.
+package changes
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+// DefaultSize is how many events a Bus keeps for the feeds to resume from
+const DefaultSize = 4096
+
+var _ handler.ChangeGraph = (*Bus)(nil)
+
+// Bus keeps the last events published and wakes the subscribers
+type Bus struct {
+	mu   sync.Mutex
+	size int
+	// log holds the events first to last, the sequence of log[i] is first+i
+	log   []handler.ChangeEvent
+	first uint64
+	// wake is closed by the next Publish
+	wake chan struct{}
+}
+
+// NewBus returns a Bus keeping size events, DefaultSize if size is not positive
+func NewBus(size int) *Bus {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Bus{size: size, first: 1, wake: make(chan struct{})}
+}
+
+// Publish appends the event and gives it its cursor, it never waits for the subscribers
+func (b *Bus) Publish(e handler.ChangeEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e.Cursor = strconv.FormatUint(b.first+uint64(len(b.log)), 10)
+	b.log = append(b.log, e)
+	if len(b.log) > b.size {
+		// # copy to release the old array instead of slicing it for ever
+		b.log = append([]handler.ChangeEvent(nil), b.log[len(b.log)-b.size:]...)
+		b.first = parseSeq(b.log[0].Cursor)
+	}
+	close(b.wake)
+	b.wake = make(chan struct{})
+}
+
+// Subscribe(ctx context.Context, f handler.ChangeFilter) (<-chan handler.ChangeEvent, error)
+func (b *Bus) Subscribe(ctx context.Context, f handler.ChangeFilter) (<-chan handler.ChangeEvent, error) {
+	b.mu.Lock()
+	next := b.first + uint64(len(b.log))
+	if f.Cursor != "" {
+		seq, err := strconv.ParseUint(f.Cursor, 10, 64)
+		if err != nil {
+			b.mu.Unlock()
+			return nil, fmt.Errorf("invalid cursor %s", f.Cursor)
+		}
+		// # a cursor past the end was taken from another Bus, e.g. before a restart
+		if seq+1 < b.first || seq >= next {
+			b.mu.Unlock()
+			return nil, handler.ErrCursorExpired
+		}
+		next = seq + 1
+	}
+	b.mu.Unlock()
+
+	ch := make(chan handler.ChangeEvent)
+	go func() {
+		defer close(ch)
+		for {
+			events, wake, ok := b.since(next)
+			if !ok {
+				// ~ fell behind the log, the subscriber resumes and learns it from ErrCursorExpired
+				return
+			}
+			for _, e := range events {
+				next++
+				if !f.Match(e) {
+					continue
+				}
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if len(events) > 0 {
+				continue
+			}
+			select {
+			case <-wake:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// since returns the events from the sequence on and the channel of the next Publish
+// ok is false once the events are no longer kept
+func (b *Bus) since(seq uint64) (events []handler.ChangeEvent, wake chan struct{}, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if seq < b.first {
+		return nil, nil, false
+	}
+	if i := seq - b.first; i < uint64(len(b.log)) {
+		events = append(events, b.log[i:]...)
+	}
+	return events, b.wake, true
+}
+
+func parseSeq(cursor string) uint64 {
+	seq, _ := strconv.ParseUint(cursor, 10, 64)
+	return seq
+}
@@ -0,0 +1,79 @@
+package changes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+)
+
+func receive(t *testing.T, ch <-chan handler.ChangeEvent) handler.ChangeEvent {
+	t.Helper()
+	select {
+	case e, ok := <-ch:
+		assert.True(t, ok)
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+	return handler.ChangeEvent{}
+}
+
+func TestBus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := NewBus(3)
+	b.Publish(handler.ChangeEvent{ID: "a", Op: history.Create, Kind: history.NodeKind})
+
+	// a feed starts with the next write
+	nodes, err := b.Subscribe(ctx, handler.ChangeFilter{Kinds: []history.Kind{history.NodeKind}})
+	assert.NoError(t, err)
+	b.Publish(handler.ChangeEvent{ID: "e", Op: history.Create, Kind: history.EdgeKind})
+	b.Publish(handler.ChangeEvent{ID: "a", Op: history.Update, Kind: history.NodeKind})
+	e := receive(t, nodes)
+	assert.Equal(t, "3", e.Cursor)
+	assert.Equal(t, history.Update, e.Op)
+
+	// and resumes after a cursor
+	all, err := b.Subscribe(ctx, handler.ChangeFilter{Cursor: "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "e", receive(t, all).ID)
+	assert.Equal(t, "a", receive(t, all).ID)
+
+	// the log keeps the last 3 events
+	b.Publish(handler.ChangeEvent{ID: "a", Op: history.Delete, Kind: history.NodeKind})
+	b.Publish(handler.ChangeEvent{ID: "b", Op: history.Create, Kind: history.NodeKind})
+	_, err = b.Subscribe(ctx, handler.ChangeFilter{Cursor: "1"})
+	assert.ErrorIs(t, err, handler.ErrCursorExpired)
+	_, err = b.Subscribe(ctx, handler.ChangeFilter{Cursor: "9"})
+	assert.ErrorIs(t, err, handler.ErrCursorExpired)
+	feed, err := b.Subscribe(ctx, handler.ChangeFilter{Cursor: "2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "3", receive(t, feed).Cursor)
+
+	// the channel closes with the context
+	cancel()
+	for range nodes {
+	}
+}
+
+func TestNewChangeEvent(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	current := []history.Version{
+		{ItemID: "a", Kind: history.NodeKind, Collection: "company", Name: "old", ValidFrom: day},
+		{ItemID: "a", Kind: history.NodeKind, Collection: "company", Name: "new", ValidFrom: day.AddDate(0, 1, 0)},
+	}
+	e := handler.NewChangeEvent("a", current, nil, day)
+	assert.Equal(t, history.Delete, e.Op)
+	assert.Equal(t, "company", e.Collection)
+	assert.Equal(t, "new", e.Before.Name)
+	assert.Nil(t, e.After)
+
+	e = handler.NewChangeEvent("a", nil, history.NodeVersion(history.Create, "a", "company", "new", nil), day)
+	assert.Equal(t, history.Create, e.Op)
+	assert.Equal(t, history.NodeKind, e.Kind)
+	assert.Nil(t, e.Before)
+}
@@ -0,0 +1,334 @@
+// Package config reads the backend yaml files into typed configs.
+//
+// A config is read in this order: the yaml file, where ${NAME} and ${NAME:-default}
+// take the value of an environment variable; then the CHAINSTORM_* environment
+// variables, which override single keys (CHAINSTORM_MONGO_PORT sets mongo.port and
+// CHAINSTORM_MONGO_LOGGER_LEVEL sets mongo.logger.level); then the defaults of the
+// keys left empty; then the secrets, a passwordFile key reads the password from a
+// file such as a docker or kubernetes secret. The result is validated, every
+// invalid key is reported at once instead of a panic on the first.
+//
+// LoadLocal, LoadMongo and LoadArango read the file of a single backend, the one
+// its Init reads. Load reads a file selecting the backend:
+//
+//	backend: mongo
+//	mongo:
+//	  server: localhost
+//	  database: chainstorm
+//	  username: admin
+//	  passwordFile: /run/secrets/mongo_password
+//	  logger:
+//	    enabled: true
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wonderstone/chainstorm/merge"
+	"github.com/wonderstone/chainstorm/search"
+	"github.com/wonderstone/chainstorm/tools"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variables overriding the keys
+const EnvPrefix = "CHAINSTORM"
+
+// the backends a Config selects
+const (
+	BackendLocal  = "local"
+	BackendMongo  = "mongo"
+	BackendArango = "arango"
+)
+
+// Common holds the optional sections of every backend
+type Common struct {
+	// Merge is the "merge" section, read by merge.Parse
+	Merge interface{} `yaml:"merge"`
+	// Search is the "search" section, read by search.Parse
+	Search interface{} `yaml:"search"`
+	// Logger is the "logger" section, a missing one logs nothing
+	Logger tools.LogConfig `yaml:"logger"`
+}
+
+// Local is the config of the local.InMemoryDB
+type Local struct {
+	// DataPath is the directory of the json files, ./data by default
+	DataPath string `yaml:"dataPath"`
+
+	Common `yaml:",inline"`
+}
+
+// Mongo is the config of the mongo.MongoGraph
+type Mongo struct {
+	// Username and Password authenticate, both empty connects without credentials
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// PasswordFile is the file holding the password, instead of Password
+	PasswordFile string `yaml:"passwordFile"`
+	// Server is the host, localhost by default
+	Server string `yaml:"server"`
+	// Port is 27017 by default
+	Port     int    `yaml:"port"`
+	Database string `yaml:"database"`
+
+	Common `yaml:",inline"`
+}
+
+// Arango is the config of the arango.ArangoGraph
+type Arango struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// PasswordFile is the file holding the password, instead of Password
+	PasswordFile string `yaml:"passwordFile"`
+	// Server is the http or https endpoint without port, http://localhost by default
+	Server string `yaml:"server"`
+	// Port is 8529 by default
+	Port   int    `yaml:"port"`
+	DBName string `yaml:"dbname"`
+	// GraphName is the graph of the traversals, arango.DefaultGraphName if empty
+	GraphName string `yaml:"graphname"`
+
+	Common `yaml:",inline"`
+}
+
+// Config selects a backend and holds its config, the sections of the other backends are ignored
+type Config struct {
+	Backend string  `yaml:"backend"`
+	Local   *Local  `yaml:"local"`
+	Mongo   *Mongo  `yaml:"mongo"`
+	Arango  *Arango `yaml:"arango"`
+}
+
+// Load reads a Config, CHAINSTORM_BACKEND overrides the backend
+// and CHAINSTORM_<BACKEND>_<KEY> the keys of its section
+func Load(path string) (*Config, error) {
+	c := &Config{}
+	if err := read(path, c); err != nil {
+		return nil, err
+	}
+	if err := override(c, EnvPrefix); err != nil {
+		return nil, err
+	}
+	// % a missing section is all defaults
+	var s section
+	switch c.Backend {
+	case BackendLocal:
+		if c.Local == nil {
+			c.Local = &Local{}
+		}
+		s = c.Local
+	case BackendMongo:
+		if c.Mongo == nil {
+			c.Mongo = &Mongo{}
+		}
+		s = c.Mongo
+	case BackendArango:
+		if c.Arango == nil {
+			c.Arango = &Arango{}
+		}
+		s = c.Arango
+	case "":
+		return nil, fmt.Errorf("%s: backend is missing, want %s, %s or %s", path, BackendLocal, BackendMongo, BackendArango)
+	default:
+		return nil, fmt.Errorf("%s: unknown backend %q, want %s, %s or %s", path, c.Backend, BackendLocal, BackendMongo, BackendArango)
+	}
+	if err := complete(s, c.Backend); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// LoadLocal reads the file of a local.InMemoryDB, CHAINSTORM_LOCAL_<KEY> overrides the keys
+func LoadLocal(path string) (*Local, error) {
+	c := &Local{}
+	if err := loadSection(path, BackendLocal, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadMongo reads the file of a mongo.MongoGraph, CHAINSTORM_MONGO_<KEY> overrides the keys
+func LoadMongo(path string) (*Mongo, error) {
+	c := &Mongo{}
+	if err := loadSection(path, BackendMongo, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadArango reads the file of an arango.ArangoGraph, CHAINSTORM_ARANGO_<KEY> overrides the keys
+func LoadArango(path string) (*Arango, error) {
+	c := &Arango{}
+	if err := loadSection(path, BackendArango, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// section is the config of a backend
+type section interface {
+	defaults()
+	secrets() error
+	validate() []error
+}
+
+func loadSection(path, backend string, c section) error {
+	if err := read(path, c); err != nil {
+		return err
+	}
+	if err := override(c, EnvPrefix+"_"+strings.ToUpper(backend)); err != nil {
+		return err
+	}
+	if err := complete(c, backend); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// read decodes the yaml file into c, after the ${NAME} interpolation
+// the unknown keys are errors, they are mostly typos
+func read(path string, c interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if err := interpolate(&doc); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if doc.Kind == 0 {
+		// ~ an empty file is all defaults
+		return nil
+	}
+	// # yaml.Node.Decode has no KnownFields, the interpolated document is decoded again
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(out))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// complete fills the defaults, reads the secrets and validates, the errors are prefixed with the backend
+func complete(c section, backend string) error {
+	c.defaults()
+	if err := c.secrets(); err != nil {
+		return fmt.Errorf("%s.%v", backend, err)
+	}
+	var errs []error
+	for _, err := range c.validate() {
+		errs = append(errs, fmt.Errorf("%s.%v", backend, err))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Local) defaults() {
+	if c.DataPath == "" {
+		c.DataPath = "./data"
+	}
+}
+
+func (c *Local) secrets() error { return nil }
+
+func (c *Local) validate() []error {
+	return c.Common.validate()
+}
+
+func (c *Mongo) defaults() {
+	if c.Server == "" {
+		c.Server = "localhost"
+	}
+	if c.Port == 0 {
+		c.Port = 27017
+	}
+}
+
+func (c *Mongo) secrets() error {
+	return readSecret(&c.Password, c.PasswordFile)
+}
+
+func (c *Mongo) validate() []error {
+	var errs []error
+	if c.Database == "" {
+		errs = append(errs, fmt.Errorf("database is missing"))
+	}
+	if c.Password != "" && c.Username == "" {
+		errs = append(errs, fmt.Errorf("username is missing, a password is given"))
+	}
+	errs = append(errs, validatePort(c.Port)...)
+	return append(errs, c.Common.validate()...)
+}
+
+func (c *Arango) defaults() {
+	if c.Server == "" {
+		c.Server = "http://localhost"
+	}
+	if c.Port == 0 {
+		c.Port = 8529
+	}
+}
+
+func (c *Arango) secrets() error {
+	return readSecret(&c.Password, c.PasswordFile)
+}
+
+func (c *Arango) validate() []error {
+	var errs []error
+	if c.DBName == "" {
+		errs = append(errs, fmt.Errorf("dbname is missing"))
+	}
+	if !strings.HasPrefix(c.Server, "http://") && !strings.HasPrefix(c.Server, "https://") {
+		errs = append(errs, fmt.Errorf("server %q must start with http:// or https://", c.Server))
+	}
+	errs = append(errs, validatePort(c.Port)...)
+	return append(errs, c.Common.validate()...)
+}
+
+func validatePort(port int) []error {
+	if port < 1 || port > 65535 {
+		return []error{fmt.Errorf("port must be between 1 and 65535, got %d", port)}
+	}
+	return nil
+}
+
+// validate parses the optional sections, the backends parse them again
+func (c *Common) validate() []error {
+	var errs []error
+	if _, err := merge.Parse(c.Merge); err != nil {
+		errs = append(errs, fmt.Errorf("merge: %v", err))
+	}
+	if _, err := search.Parse(c.Search); err != nil {
+		errs = append(errs, fmt.Errorf("search: %v", err))
+	}
+	if err := c.Logger.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("logger: %v", err))
+	}
+	return errs
+}
+
+// readSecret reads the password from the file, the trailing newline is dropped
+func readSecret(password *string, file string) error {
+	if file == "" {
+		return nil
+	}
+	if *password != "" {
+		return fmt.Errorf("password and passwordFile are both given")
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("passwordFile: %v", err)
+	}
+	*password = strings.TrimRight(string(b), "\r\n")
+	return nil
+}
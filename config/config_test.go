@@ -0,0 +1,159 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func write(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoadMongo(t *testing.T) {
+	secret := write(t, "password", "s3cret:#1\n")
+	t.Setenv("MONGO_HOST", "db.internal")
+	t.Setenv("MONGO_PORT", "27018")
+	t.Setenv("CHAINSTORM_MONGO_LOGGER_LEVEL", "debug")
+	t.Setenv("CHAINSTORM_MONGO_LOGGER_MAX_AGE", "12h")
+	path := write(t, "mongo.yaml", `
+username: admin
+passwordFile: `+secret+`
+server: ${MONGO_HOST}
+port: ${MONGO_PORT}
+database: ${MONGO_DB:-chainstorm}
+logger:
+  enabled: true
+  output: stdout
+`)
+
+	c, err := LoadMongo(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", c.Username)
+	assert.Equal(t, "s3cret:#1", c.Password)
+	assert.Equal(t, "db.internal", c.Server)
+	assert.Equal(t, 27018, c.Port)
+	assert.Equal(t, "chainstorm", c.Database)
+	assert.Equal(t, "debug", c.Logger.Level)
+	assert.Equal(t, 12*time.Hour, c.Logger.MaxAge)
+
+	// the variables override the file
+	t.Setenv("CHAINSTORM_MONGO_PORT", "27019")
+	c, err = LoadMongo(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 27019, c.Port)
+}
+
+func TestLoadDefaults(t *testing.T) {
+	c, err := LoadArango(write(t, "arango.yaml", "dbname: mydb\npassword: \"123\"\nusername: root\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost", c.Server)
+	assert.Equal(t, 8529, c.Port)
+	assert.Equal(t, "123", c.Password)
+
+	l, err := LoadLocal(write(t, "local.yaml", ""))
+	assert.NoError(t, err)
+	assert.Equal(t, "./data", l.DataPath)
+	assert.False(t, l.Logger.Enabled)
+}
+
+func TestLoadErrors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		content string
+		env     map[string]string
+		want    []string
+	}{
+		"all invalid keys at once": {
+			content: "server: localhost\nport: 70000\nlogger:\n  level: loud\n",
+			want:    []string{"arango.dbname is missing", "arango.server \"localhost\" must start with http:// or https://", "arango.port must be between 1 and 65535, got 70000", "arango.logger: invalid logger level \"loud\""},
+		},
+		"typo": {
+			content: "dbname: mydb\ndbnmae: mydb\n",
+			want:    []string{"field dbnmae not found"},
+		},
+		"mistyped": {
+			content: "dbname: mydb\nport: [8529]\n",
+			want:    []string{"cannot unmarshal"},
+		},
+		"unset variable": {
+			content: "dbname: ${CHAINSTORM_TEST_UNSET}\n",
+			want:    []string{"dbname: environment variable CHAINSTORM_TEST_UNSET is not set"},
+		},
+		"bad override": {
+			content: "dbname: mydb\n",
+			env:     map[string]string{"CHAINSTORM_ARANGO_PORT": "http"},
+			want:    []string{"CHAINSTORM_ARANGO_PORT: invalid integer \"http\""},
+		},
+		"both passwords": {
+			content: "dbname: mydb\npassword: a\npasswordFile: /nowhere\n",
+			want:    []string{"arango.password and passwordFile are both given"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := LoadArango(write(t, "arango.yaml", tt.content))
+			if assert.Error(t, err) {
+				for _, w := range tt.want {
+					assert.Contains(t, err.Error(), w)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := write(t, "chainstorm.yaml", `
+backend: local
+local:
+  dataPath: /srv/graph
+mongo:
+  port: 70000 # not checked, mongo is not selected
+`)
+	c, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, BackendLocal, c.Backend)
+	assert.Equal(t, "/srv/graph", c.Local.DataPath)
+
+	// the backend and a missing section come from the environment
+	t.Setenv("CHAINSTORM_BACKEND", "mongo")
+	_, err = Load(path)
+	assert.ErrorContains(t, err, "mongo.port")
+	path = write(t, "chainstorm.yaml", "backend: local\n")
+	t.Setenv("CHAINSTORM_MONGO_DATABASE", "graph")
+	c, err = Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "graph", c.Mongo.Database)
+	assert.Equal(t, 27017, c.Mongo.Port)
+
+	t.Setenv("CHAINSTORM_BACKEND", "neo4j")
+	_, err = Load(path)
+	assert.ErrorContains(t, err, `unknown backend "neo4j"`)
+}
+
+func TestExpand(t *testing.T) {
+	t.Setenv("CHAINSTORM_TEST_A", "x")
+	for in, want := range map[string]string{
+		"${CHAINSTORM_TEST_A}":            "x",
+		"a-${CHAINSTORM_TEST_A}-b":        "a-x-b",
+		"${CHAINSTORM_TEST_UNSET:-d:e}":   "d:e",
+		"$$${CHAINSTORM_TEST_A}$":         "$x$",
+		"cost $5":                         "cost $5",
+		"${CHAINSTORM_TEST_A:-default}{}": "x{}",
+	} {
+		got, err := expand(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	_, err := expand("${CHAINSTORM_TEST_A")
+	assert.Error(t, err)
+	_, err = expand("${}")
+	assert.Error(t, err)
+}
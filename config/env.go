@@ -0,0 +1,197 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// interpolate replaces ${NAME} and ${NAME:-default} in the values of the document, $$ is a $
+// the keys are left as they are
+func interpolate(n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if !strings.Contains(n.Value, "$") {
+			return nil
+		}
+		v, err := expand(n.Value)
+		if err != nil {
+			return err
+		}
+		n.Value = v
+		// ~ a plain value is resolved again, port: ${PORT} is an int
+		if n.Style == 0 {
+			n.Tag = ""
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			if err := interpolate(n.Content[i]); err != nil {
+				return fmt.Errorf("%s: %v", n.Content[i-1].Value, err)
+			}
+		}
+	default:
+		for _, c := range n.Content {
+			if err := interpolate(c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expand replaces the variables of s
+func expand(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unclosed ${ in %q", s)
+			}
+			name, def, hasDef := strings.Cut(s[i+2:i+end], ":-")
+			if name == "" {
+				return "", fmt.Errorf("empty variable name in %q", s)
+			}
+			v, ok := os.LookupEnv(name)
+			switch {
+			case ok:
+			case hasDef:
+				v = def
+			default:
+				return "", fmt.Errorf("environment variable %s is not set", name)
+			}
+			b.WriteString(v)
+			i += end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// override sets the fields of the struct v points to from the environment variables,
+// named by the prefix and the yaml keys in upper snake case: PREFIX_LOGGER_MAX_SIZE for logger.maxSize
+func override(v interface{}, prefix string) error {
+	return overrideStruct(reflect.ValueOf(v).Elem(), prefix)
+}
+
+func overrideStruct(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		if opts == "inline" {
+			if err := overrideStruct(fv, prefix); err != nil {
+				return err
+			}
+			continue
+		}
+		key := prefix + "_" + envName(name)
+		switch {
+		case fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct:
+			// # a missing section is created when a variable sets one of its keys
+			if !envHasPrefix(key + "_") {
+				continue
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			if err := overrideStruct(fv.Elem(), key); err != nil {
+				return err
+			}
+		case fv.Kind() == reflect.Struct:
+			if err := overrideStruct(fv, key); err != nil {
+				return err
+			}
+		case fv.Kind() == reflect.Interface:
+			// ~ the merge and search sections are too free for single variables
+		default:
+			s, ok := os.LookupEnv(key)
+			if !ok {
+				continue
+			}
+			if err := setValue(fv, s); err != nil {
+				return fmt.Errorf("%s: %v", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setValue parses s into the field
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", s)
+		}
+		v.SetUint(n)
+	default:
+		return fmt.Errorf("cannot set a %s from the environment", v.Type())
+	}
+	return nil
+}
+
+// envName turns a yaml key into upper snake case, dataPath is DATA_PATH
+func envName(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+func envHasPrefix(prefix string) bool {
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+// Package filter is the backend-neutral filter expression on the Data fields of nodes and edges.
+//
+// An Expr compares the value at a dotted Data path, e.g. "address.city", or combines other Exprs:
+//
+//	f := filter.And(
+//		filter.Gt("companyRevenue", 1e6),
+//		filter.Eq("companyCountry", "USA"),
+//	)
+//
+// The backends translate it to AQL (arango) or BSON (mongo), the local one evaluates it with Match.
+// The comparisons hold between numbers or between strings only, a field of another type never
+// matches a range; Eq and Ne compare the scalar values, a missing field equals nil.
+package filter
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Op is the operator of an Expr
+type Op string
+
+const (
+	OpEq     Op = "eq"
+	OpNe     Op = "ne"
+	OpGt     Op = "gt"
+	OpGte    Op = "gte"
+	OpLt     Op = "lt"
+	OpLte    Op = "lte"
+	OpIn     Op = "in"
+	OpExists Op = "exists"
+	OpAnd    Op = "and"
+	OpOr     Op = "or"
+	OpNot    Op = "not"
+)
+
+// Expr is a filter expression, a comparison on Path or a combination of Exprs
+type Expr struct {
+	Op    Op          `json:"op" yaml:"op"`
+	Path  string      `json:"path,omitempty" yaml:"path,omitempty"`
+	Value interface{} `json:"value,omitempty" yaml:"value,omitempty"`
+	Exprs []Expr      `json:"exprs,omitempty" yaml:"exprs,omitempty"`
+}
+
+// Eq matches the items whose field equals the value
+func Eq(path string, value interface{}) Expr { return Expr{Op: OpEq, Path: path, Value: value} }
+
+// Ne matches the items whose field is missing or differs from the value
+func Ne(path string, value interface{}) Expr { return Expr{Op: OpNe, Path: path, Value: value} }
+
+// Gt matches the items whose field is greater than the value
+func Gt(path string, value interface{}) Expr { return Expr{Op: OpGt, Path: path, Value: value} }
+
+// Gte matches the items whose field is greater than or equal to the value
+func Gte(path string, value interface{}) Expr { return Expr{Op: OpGte, Path: path, Value: value} }
+
+// Lt matches the items whose field is less than the value
+func Lt(path string, value interface{}) Expr { return Expr{Op: OpLt, Path: path, Value: value} }
+
+// Lte matches the items whose field is less than or equal to the value
+func Lte(path string, value interface{}) Expr { return Expr{Op: OpLte, Path: path, Value: value} }
+
+// In matches the items whose field equals one of the values
+func In(path string, values ...interface{}) Expr { return Expr{Op: OpIn, Path: path, Value: values} }
+
+// Exists matches the items having the field, with a value other than nil
+func Exists(path string) Expr { return Expr{Op: OpExists, Path: path} }
+
+// And matches the items matching all the exprs, all the items if none
+func And(exprs ...Expr) Expr { return Expr{Op: OpAnd, Exprs: exprs} }
+
+// Or matches the items matching one of the exprs, none if none
+func Or(exprs ...Expr) Expr { return Expr{Op: OpOr, Exprs: exprs} }
+
+// Not matches the items not matching the expr
+func Not(e Expr) Expr { return Expr{Op: OpNot, Exprs: []Expr{e}} }
+
+// Segments returns the keys of the path
+func Segments(path string) []string {
+	return strings.Split(path, ".")
+}
+
+// isScalar tells the values Eq, Ne and In compare
+func isScalar(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	switch v.(type) {
+	case string, bool:
+		return true
+	}
+	_, ok := Number(v)
+	return ok
+}
+
+// Values returns the values of an In, a single value is a list of one
+func Values(v interface{}) []interface{} {
+	if v == nil {
+		return nil
+	}
+	if vs, ok := v.([]interface{}); ok {
+		return vs
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return []interface{}{v}
+	}
+	vs := make([]interface{}, rv.Len())
+	for i := range vs {
+		vs[i] = rv.Index(i).Interface()
+	}
+	return vs
+}
+
+// Validate checks the paths, the operators and the types of the values
+// the backends validate a filter before translating it
+func (e Expr) Validate() error {
+	switch e.Op {
+	case OpAnd, OpOr:
+		for _, sub := range e.Exprs {
+			if err := sub.Validate(); err != nil {
+				return err
+			}
+		}
+		return nil
+	case OpNot:
+		if len(e.Exprs) != 1 {
+			return fmt.Errorf("not takes one expression, got %d", len(e.Exprs))
+		}
+		return e.Exprs[0].Validate()
+	}
+
+	for _, s := range Segments(e.Path) {
+		if s == "" || strings.ContainsAny(s, "$`") {
+			return fmt.Errorf("invalid path %q", e.Path)
+		}
+	}
+	switch e.Op {
+	case OpExists:
+	case OpEq, OpNe:
+		if !isScalar(e.Value) {
+			return fmt.Errorf("%s %s: invalid value %v", e.Path, e.Op, e.Value)
+		}
+	case OpGt, OpGte, OpLt, OpLte:
+		if _, ok := e.Value.(string); !ok {
+			if _, ok := Number(e.Value); !ok {
+				return fmt.Errorf("%s %s: only numbers and strings are ordered, got %v", e.Path, e.Op, e.Value)
+			}
+		}
+	case OpIn:
+		for _, v := range Values(e.Value) {
+			if !isScalar(v) {
+				return fmt.Errorf("%s in: invalid value %v", e.Path, v)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown operator %q", e.Op)
+	}
+	return nil
+}
+
+var symbols = map[Op]string{OpEq: "==", OpNe: "!=", OpGt: ">", OpGte: ">=", OpLt: "<", OpLte: "<="}
+
+// String returns the expression in a readable form, for the logs and the errors
+func (e Expr) String() string {
+	switch e.Op {
+	case OpAnd, OpOr:
+		if len(e.Exprs) == 0 {
+			return fmt.Sprint(e.Op == OpAnd)
+		}
+		parts := make([]string, len(e.Exprs))
+		for i, sub := range e.Exprs {
+			parts[i] = sub.String()
+		}
+		return "(" + strings.Join(parts, " "+strings.ToUpper(string(e.Op))+" ") + ")"
+	case OpNot:
+		if len(e.Exprs) == 1 {
+			return "NOT " + e.Exprs[0].String()
+		}
+	case OpExists:
+		return e.Path + " EXISTS"
+	case OpIn:
+		return fmt.Sprintf("%s IN %v", e.Path, Values(e.Value))
+	}
+	if s, ok := symbols[e.Op]; ok {
+		return fmt.Sprintf("%s %s %#v", e.Path, s, e.Value)
+	}
+	return fmt.Sprintf("%s %s %v", e.Path, e.Op, e.Value)
+}
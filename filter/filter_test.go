@@ -0,0 +1,55 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	data := map[string]interface{}{
+		"companyRevenue": 2e6,
+		"companyCountry": "USA",
+		"employees":      120,
+		"listed":         true,
+		"address":        map[string]interface{}{"city": "Boston"},
+	}
+	cases := []struct {
+		f    Expr
+		want bool
+	}{
+		{And(Gt("companyRevenue", 1e6), Eq("companyCountry", "USA")), true},
+		{Gte("employees", 120.0), true},
+		{Lt("employees", 100), false},
+		{Gt("companyCountry", 1), false},
+		{Lte("companyCountry", "USB"), true},
+		{Eq("listed", true), true},
+		{Eq("address.city", "Boston"), true},
+		{Eq("address.zip", nil), true},
+		{Ne("address.zip", "02108"), true},
+		{In("companyCountry", "CN", "USA"), true},
+		{Exists("address.city"), true},
+		{Exists("address.city.name"), false},
+		{Or(Eq("companyCountry", "CN"), Not(Exists("ceo"))), true},
+		{Or(), false},
+		{And(), true},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, c.f.Match(data), c.f.String())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, And(Gt("a.b", 1), In("c", 1, "x", nil), Not(Exists("d"))).Validate())
+	assert.Error(t, Eq("a..b", 1).Validate())
+	assert.Error(t, Eq("$where", 1).Validate())
+	assert.Error(t, Gt("a", true).Validate())
+	assert.Error(t, Eq("a", []int{1}).Validate())
+	assert.Error(t, Expr{Op: OpNot}.Validate())
+	assert.Error(t, Expr{Op: "like", Path: "a"}.Validate())
+}
+
+func TestString(t *testing.T) {
+	f := And(Gt("companyRevenue", 1e6), Eq("companyCountry", "USA"))
+	assert.Equal(t, `(companyRevenue > 1e+06 AND companyCountry == "USA")`, f.String())
+}
@@ -0,0 +1,150 @@
+package filter
+
+import (
+	"reflect"
+)
+
+// Number returns the value of a number of any Go type
+func Number(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// Get returns the value at the path of the data
+// ~ the nested maps may be map[string]interface{} or any map with string keys (bson.M)
+func Get(data map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = data
+	for _, s := range Segments(path) {
+		if cur == nil {
+			return nil, false
+		}
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			rv := reflect.ValueOf(cur)
+			if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			v := rv.MapIndex(reflect.ValueOf(s).Convert(rv.Type().Key()))
+			if !v.IsValid() {
+				return nil, false
+			}
+			cur = v.Interface()
+			continue
+		}
+		if cur, ok = m[s]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// Compare orders a and b, both numbers or both strings
+// ok is false for the values of other or different types
+func Compare(a, b interface{}) (c int, ok bool) {
+	if fa, ok := Number(a); ok {
+		fb, ok := Number(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	sa, ok := a.(string)
+	if !ok {
+		return 0, false
+	}
+	sb, ok := b.(string)
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case sa < sb:
+		return -1, true
+	case sa > sb:
+		return 1, true
+	}
+	return 0, true
+}
+
+// Equal tells whether two scalar values are equal, the numbers whatever their Go type
+func Equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if c, ok := Compare(a, b); ok {
+		return c == 0
+	}
+	ba, ok := a.(bool)
+	if !ok {
+		return false
+	}
+	bb, ok := b.(bool)
+	return ok && ba == bb
+}
+
+// Match evaluates the expression on the data
+func (e Expr) Match(data map[string]interface{}) bool {
+	switch e.Op {
+	case OpAnd:
+		for _, sub := range e.Exprs {
+			if !sub.Match(data) {
+				return false
+			}
+		}
+		return true
+	case OpOr:
+		for _, sub := range e.Exprs {
+			if sub.Match(data) {
+				return true
+			}
+		}
+		return false
+	case OpNot:
+		return len(e.Exprs) == 1 && !e.Exprs[0].Match(data)
+	}
+
+	v, _ := Get(data, e.Path)
+	switch e.Op {
+	case OpExists:
+		return v != nil
+	case OpEq:
+		return Equal(v, e.Value)
+	case OpNe:
+		return !Equal(v, e.Value)
+	case OpIn:
+		for _, want := range Values(e.Value) {
+			if Equal(v, want) {
+				return true
+			}
+		}
+		return false
+	}
+	c, ok := Compare(v, e.Value)
+	if !ok {
+		return false
+	}
+	switch e.Op {
+	case OpGt:
+		return c > 0
+	case OpGte:
+		return c >= 0
+	case OpLt:
+		return c < 0
+	case OpLte:
+		return c <= 0
+	}
+	return false
+}
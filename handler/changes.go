@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/wonderstone/chainstorm/history"
+)
+
+// ChangesCollection is the collection where mongo and arango write the change events of the writes
+const ChangesCollection = ReservedPrefix + "changes"
+
+// ErrCursorExpired is returned by Subscribe when the events after the cursor are no longer kept
+var ErrCursorExpired = errors.New("change cursor expired")
+
+// ChangeEvent is a committed write on a node or an edge
+type ChangeEvent struct {
+	// Cursor resumes a feed after this event
+	Cursor string
+	// Op is history.Create, Replace, Update, Merge, Delete or Retract
+	Op         history.Op
+	Kind       history.Kind
+	ID         string
+	Collection string
+	// Before is the item before the write, nil for a create; After the item after it, nil for a delete
+	Before *history.Version
+	After  *history.Version
+	Time   time.Time
+}
+
+// ChangeFilter selects the events of a feed, an empty field matches everything
+type ChangeFilter struct {
+	Ops         []history.Op
+	Kinds       []history.Kind
+	Collections []string
+	// Cursor is the Cursor of the last event received, the feed starts after it
+	// empty starts with the next write
+	Cursor string
+}
+
+// Match checks the event against the filter, the Cursor aside
+func (f ChangeFilter) Match(e ChangeEvent) bool {
+	return matchAny(f.Ops, e.Op) && matchAny(f.Kinds, e.Kind) && matchAny(f.Collections, e.Collection)
+}
+
+func matchAny[T comparable](values []T, v T) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangeGraph is implemented by the backends with a change feed
+type ChangeGraph interface {
+	// Subscribe emits the events matching the filter in commit order until ctx is done, then closes the channel
+	// the channel is closed early too if the feed fails or falls behind, resume with the Cursor of the last event
+	// a resumed feed may send again the events received before the cursor, with the same Cursor
+	Subscribe(ctx context.Context, f ChangeFilter) (<-chan ChangeEvent, error)
+}
+
+// NewChangeEvent builds the event of a write from the current versions before it and the next one,
+// next is nil for a delete
+func NewChangeEvent(itemID string, current []history.Version, next *history.Version, at time.Time) ChangeEvent {
+	e := ChangeEvent{ID: itemID, Op: history.Delete, After: next, Time: at}
+	// # the latest valid version is the item as it was read before the write
+	for i := range current {
+		if e.Before == nil || current[i].ValidFrom.After(e.Before.ValidFrom) {
+			v := current[i]
+			e.Before = &v
+		}
+	}
+	switch {
+	case next != nil:
+		e.Op, e.Kind, e.Collection = next.Op, next.Kind, next.Collection
+	case e.Before != nil:
+		e.Kind, e.Collection = e.Before.Kind, e.Before.Collection
+	}
+	return e
+}
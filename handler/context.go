@@ -0,0 +1,11 @@
+package handler
+
+import "context"
+
+// ContextGraph is implemented by the backends running their round trips in a caller context,
+// which carries its cancellation and its trace span
+type ContextGraph interface {
+	// WithContext returns a view of the graph calling the database with ctx,
+	// Init, Connect and Disconnect belong to the graph itself
+	WithContext(ctx context.Context) GraphDB
+}
@@ -0,0 +1,7 @@
+package handler
+
+// CountingGraph is implemented by the backends telling their size, for the metrics
+type CountingGraph interface {
+	// CountItems returns the number of nodes and edges
+	CountItems() (nodes, edges int64, err error)
+}
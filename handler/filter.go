@@ -0,0 +1,14 @@
+package handler
+
+import (
+	"github.com/wonderstone/chainstorm/filter"
+)
+
+// FilterGraph is implemented by the backends querying the nodes and edges by their Data fields
+// the collection restricts the query, all the node (or edge) collections if blank
+type FilterGraph interface {
+	// FindNodes returns the nodes whose Data match the filter
+	FindNodes(collection string, f filter.Expr) ([]Node, error)
+	// FindEdges returns the edges whose Data match the filter
+	FindEdges(collection string, f filter.Expr) ([]Edge, error)
+}
@@ -1,5 +1,16 @@
 package handler
 
+import "strings"
+
+// ReservedPrefix marks the collections chainstorm keeps for itself, like the time series store
+// backends skip them when they look for node and edge collections
+const ReservedPrefix = "chainstorm_"
+
+// IsReserved checks if the collection is one of the chainstorm collections
+func IsReserved(collection string) bool {
+	return strings.HasPrefix(collection, ReservedPrefix)
+}
+
 type Node interface {
 	Export() map[string]interface{}
 }
@@ -35,7 +46,8 @@ type GraphDB interface {
 	UpdateNode(n Node) error
 	UpdateEdge(e Edge) error
 	// - Merge: data field will be merged
-	// - The same fields in the document are added together
+	// - The same fields in the document are combined by the merge package strategies,
+	// - by default the incoming value overwrites the existing one
 	MergeNode(n Node) error
 	MergeEdge(e Edge) error
 	// + Delete operations
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/wonderstone/chainstorm/history"
+)
+
+// HistoryCollection is the collection (or data directory) where the backends keep the versions
+const HistoryCollection = ReservedPrefix + "history"
+
+// TemporalGraph is implemented by the backends keeping the bitemporal history
+// of every Add, Replace, Update, Merge and Delete
+// valid time is when a fact is true, known time is when the graph believed it
+type TemporalGraph interface {
+	// GetNodeAsOf returns the node valid at t as believed at t
+	// i.e. what did we believe about the node on date t
+	GetNodeAsOf(name interface{}, t time.Time) (Node, error)
+	// GetNodeAt returns the node valid at validAt as believed at knownAt
+	GetNodeAt(name interface{}, validAt, knownAt time.Time) (Node, error)
+	// TraverseAsOf works like GetAllRelatedNodes over the graph valid and believed at t
+	TraverseAsOf(t time.Time, name interface{}) ([][]Node, error)
+	// GetHistory returns all the versions of an item by ID sorted by transaction time
+	GetHistory(id interface{}) ([]history.Version, error)
+}
@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"github.com/wonderstone/chainstorm/provenance"
+)
+
+// ProvenanceCollection is the collection (or data directory) where the backends keep the facts
+const ProvenanceCollection = ReservedPrefix + "provenance"
+
+// ProvenanceGraph is implemented by the backends keeping the provenance of every fact
+// a write gives its provenance.Record in the provenance.Key of its Data,
+// the key is not stored with the Data
+type ProvenanceGraph interface {
+	// GetProvenance returns the facts of an item by ID, sorted by field and source
+	GetProvenance(id interface{}) ([]provenance.Fact, error)
+	// GetFactsBySource returns all the facts derived from the source, sorted by item and field
+	GetFactsBySource(source string) ([]provenance.Fact, error)
+	// RetractSource removes everything the source contributed:
+	// the items only it asserted with their edges, and the Data keys only it asserted on the others
+	// the facts of the source are dropped, with dryRun nothing changes and the report tells what would
+	RetractSource(source string, dryRun bool) (provenance.Report, error)
+}
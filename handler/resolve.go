@@ -0,0 +1,26 @@
+package handler
+
+// AliasesKey is the Data key holding the other names of a node,
+// MergeNodesInto records there the names of the merged duplicates
+const AliasesKey = "aliases"
+
+// ResolvingGraph is implemented by the backends able to merge duplicate nodes
+type ResolvingGraph interface {
+	// MergeNodesInto merges the duplicates into the survivor, all given by name:
+	// every incident edge is repointed to the survivor (edges between them are dropped),
+	// the Data are merged with the merge strategies, the names and aliases of the duplicates
+	// are added to the AliasesKey of the survivor, then the duplicates are deleted
+	MergeNodesInto(survivor interface{}, duplicates ...interface{}) error
+}
+
+// AliasGraph is implemented by the backends finding the nodes by their aliases too
+// the aliases are kept in the AliasesKey of the Data and are unique across the graph,
+// GetNode, the traversals and GetNodesByRegex accept an alias where they take a name
+type AliasGraph interface {
+	// AddAliases adds the aliases to the node, an alias can not be a name or an alias of another node
+	AddAliases(name interface{}, aliases ...string) error
+	// RemoveAliases removes the aliases from the node
+	RemoveAliases(name interface{}, aliases ...string) error
+	// GetAliases returns the aliases of the node
+	GetAliases(name interface{}) ([]string, error)
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+)
+
+// # every node and edge carries a revision, changed by each write:
+// # a Replace, Update or Merge given the revision it read applies only if the item still has it (if-match),
+// # without a revision it applies anyway
+
+// ErrConflict is matched by errors.Is for every ConflictError
+var ErrConflict = errors.New("revision conflict")
+
+// MergeRetries is how many times a merge without revision reads the item again after a concurrent write
+const MergeRetries = 5
+
+// ConflictError is returned by a conditional write when the item changed since its revision was read
+type ConflictError struct {
+	// ID is the ID of the item
+	ID string
+	// Expected is the revision of the write, Actual the one of the item, empty if unknown
+	Expected string
+	Actual   string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("revision conflict on %s: expected %s, got %s", e.ID, e.Expected, e.Actual)
+}
+
+// Is makes errors.Is(err, ErrConflict) true
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
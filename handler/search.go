@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"github.com/wonderstone/chainstorm/search"
+)
+
+// SearchCollection is the ArangoSearch view (arango) or the collection of the tokens (mongo) of the full-text search
+const SearchCollection = ReservedPrefix + "search"
+
+// SearchHit is a node found by a search with its relevance
+type SearchHit struct {
+	Node  Node
+	Score float64
+}
+
+// SearchGraph is implemented by the backends with a full-text search over the node names,
+// the aliases and the Data fields of the search.Config
+type SearchGraph interface {
+	// Search returns the nodes matching the query, the most relevant first
+	Search(query string, opts search.Options) ([]SearchHit, error)
+	// SetSearchConfig changes the indexed Data fields and rebuilds the index
+	SetSearchConfig(c *search.Config) error
+}
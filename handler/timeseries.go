@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/wonderstone/chainstorm/timeseries"
+)
+
+// SeriesCollection is the collection (or data directory) where the backends keep the points
+const SeriesCollection = ReservedPrefix + "series"
+
+// TimeSeriesGraph is implemented by the backends storing time series properties
+// on nodes and edges, e.g. companyRevenue by quarter, instead of Data keys with timestamp suffix
+// id is the item ID of a node or an edge, the same as GetItemByID
+// a zero from or to leaves the range open on that side
+type TimeSeriesGraph interface {
+	// AppendPoints adds points to the metric, a point with an existing timestamp replaces the value
+	AppendPoints(id interface{}, metric string, points ...timeseries.Point) error
+	// GetSeries returns the points in [from, to] sorted by time
+	GetSeries(id interface{}, metric string, from, to time.Time) ([]timeseries.Point, error)
+	// GetValueAsOf returns the last point at or before t
+	GetValueAsOf(id interface{}, metric string, t time.Time) (timeseries.Point, error)
+	// AggregateSeries computes the aggregate over the points in [from, to]
+	AggregateSeries(id interface{}, metric string, from, to time.Time, agg timeseries.Aggregation) (float64, error)
+	// DeleteSeries removes all the points of the metric
+	DeleteSeries(id interface{}, metric string) error
+}
@@ -0,0 +1,38 @@
+package handler
+
+// Tx is the graph inside a transaction, its reads see its own writes
+type Tx interface {
+	// + Create operations
+	AddNode(n Node) (interface{}, error)
+	AddEdge(e Edge) (interface{}, error)
+	// + Update operations
+	ReplaceNode(n Node) error
+	ReplaceEdge(e Edge) error
+	UpdateNode(n Node) error
+	UpdateEdge(e Edge) error
+	MergeNode(n Node) error
+	MergeEdge(e Edge) error
+	// + Delete operations
+	DeleteNode(name interface{}) error
+	DeleteItemByID(id interface{}) error
+
+	// + Query operations
+	GetItemByID(id interface{}) (interface{}, error)
+	GetNode(name interface{}) (Node, error)
+	GetNodesByRegex(regex string) ([]Node, error)
+	GetEdgesByRegex(regex string) ([]Edge, error)
+
+	GetFromNodes(name interface{}) ([]Node, error)
+	GetToNodes(name interface{}) ([]Node, error)
+	GetInEdges(name interface{}) ([]Edge, error)
+	GetOutEdges(name interface{}) ([]Edge, error)
+}
+
+// TxGraph is implemented by the backends grouping compound writes atomically,
+// such as a node with its edges
+type TxGraph interface {
+	// Update runs fn in a transaction, committed if fn returns nil
+	// and rolled back if it returns an error or panics, the error is returned
+	// fn writes and reads through tx only
+	Update(fn func(tx Tx) error) error
+}
@@ -0,0 +1,219 @@
+// Package history keeps the bitemporal version history of nodes and edges.
+//
+// Every version has two time ranges, both closed at the start and open at the end:
+//   - valid time: when the fact is true in the real world (ValidFrom, ValidTo)
+//   - transaction time: when the graph believed it (TxFrom, TxTo)
+//
+// An open end is EndOfTime. The backends store the versions in their own
+// history collection, this package decides what a write changes and which
+// version answers "what did we believe about X on date D".
+package history
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wonderstone/chainstorm/merge"
+)
+
+// EndOfTime closes the open ranges, it is a real date so every backend can index and compare it
+var EndOfTime = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// Now is the transaction clock, replaced in tests
+var Now = func() time.Time { return time.Now().UTC() }
+
+// ValidFromKey is the Data key a write can use to give its valid time,
+// RFC3339 string, date or unix seconds. Without it the fact is valid from the write time
+const ValidFromKey = "validFrom"
+
+// Op is the write operation that produced a version
+type Op string
+
+const (
+	Create  Op = "create"
+	Replace Op = "replace"
+	Update  Op = "update"
+	Merge   Op = "merge"
+	Delete  Op = "delete"
+	// Retract removes the fields of a retracted source
+	Retract Op = "retract"
+)
+
+// Kind tells nodes from edges
+type Kind string
+
+const (
+	NodeKind Kind = "node"
+	EdgeKind Kind = "edge"
+)
+
+// Version is the state of an item over a valid time range, as believed over a transaction time range
+type Version struct {
+	ID           string                 `json:"id" bson:"vid"`
+	ItemID       string                 `json:"item" bson:"item"`
+	Kind         Kind                   `json:"kind" bson:"kind"`
+	Op           Op                     `json:"op" bson:"op"`
+	Collection   string                 `json:"collection" bson:"collection"`
+	Name         string                 `json:"name,omitempty" bson:"name,omitempty"`
+	Relationship string                 `json:"relationship,omitempty" bson:"relationship,omitempty"`
+	From         string                 `json:"from,omitempty" bson:"from,omitempty"`
+	To           string                 `json:"to,omitempty" bson:"to,omitempty"`
+	Data         map[string]interface{} `json:"data,omitempty" bson:"data,omitempty"`
+	ValidFrom    time.Time              `json:"validFrom" bson:"validFrom"`
+	ValidTo      time.Time              `json:"validTo" bson:"validTo"`
+	TxFrom       time.Time              `json:"txFrom" bson:"txFrom"`
+	TxTo         time.Time              `json:"txTo" bson:"txTo"`
+}
+
+// NodeVersion builds the state of a node for Record
+func NodeVersion(op Op, id, collection, name string, data map[string]interface{}) *Version {
+	return &Version{ItemID: id, Kind: NodeKind, Op: op, Collection: collection, Name: name, Data: copyData(data)}
+}
+
+// EdgeVersion builds the state of an edge for Record
+func EdgeVersion(op Op, id, collection, relationship, from, to string, data map[string]interface{}) *Version {
+	return &Version{ItemID: id, Kind: EdgeKind, Op: op, Collection: collection,
+		Relationship: relationship, From: from, To: to, Data: copyData(data)}
+}
+
+func copyData(data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		return nil
+	}
+	res := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		res[k] = v
+	}
+	return res
+}
+
+// ValidTime returns the valid time of a write, the ValidFromKey of the data or the transaction time
+func ValidTime(data map[string]interface{}, txTime time.Time) time.Time {
+	if t, ok := merge.ParseTime(data[ValidFromKey]); ok {
+		return t.UTC()
+	}
+	return txTime
+}
+
+// Current reports if the version is still believed
+func (v Version) Current() bool {
+	return !v.TxTo.Before(EndOfTime)
+}
+
+// At reports if the version was valid at validAt as believed at knownAt
+func (v Version) At(validAt, knownAt time.Time) bool {
+	return !knownAt.Before(v.TxFrom) && knownAt.Before(v.TxTo) &&
+		!validAt.Before(v.ValidFrom) && validAt.Before(v.ValidTo)
+}
+
+// Record computes what a write changes in the history of one item
+// current are the versions of the item still believed, next is the new state or nil for a delete
+// closed are the current versions to be stored again with TxTo set to txTime,
+// inserted are the new versions to be stored
+func Record(current []Version, next *Version, validFrom, txTime time.Time) (closed, inserted []Version) {
+	for _, c := range current {
+		// the version ends before the write is valid, nothing changes
+		if !c.ValidTo.After(validFrom) {
+			continue
+		}
+		c.TxTo = txTime
+		closed = append(closed, c)
+		// the part before the write is still believed
+		if c.ValidFrom.Before(validFrom) {
+			kept := c
+			kept.ID = uuid.New().String()
+			kept.ValidTo = validFrom
+			kept.TxFrom = txTime
+			kept.TxTo = EndOfTime
+			inserted = append(inserted, kept)
+		}
+	}
+	if next != nil {
+		v := *next
+		v.ID = uuid.New().String()
+		v.ValidFrom = validFrom
+		v.ValidTo = EndOfTime
+		v.TxFrom = txTime
+		v.TxTo = EndOfTime
+		inserted = append(inserted, v)
+	}
+	return closed, inserted
+}
+
+// AsOf returns the version valid at validAt as believed at knownAt
+func AsOf(versions []Version, validAt, knownAt time.Time) (Version, bool) {
+	var res Version
+	found := false
+	for _, v := range versions {
+		if v.At(validAt, knownAt) && (!found || v.TxFrom.After(res.TxFrom)) {
+			res = v
+			found = true
+		}
+	}
+	return res, found
+}
+
+// Snapshot returns the versions of every item valid at validAt as believed at knownAt, by item ID
+func Snapshot(versions []Version, validAt, knownAt time.Time) map[string]Version {
+	res := make(map[string]Version)
+	for _, v := range versions {
+		if !v.At(validAt, knownAt) {
+			continue
+		}
+		if old, ok := res[v.ItemID]; !ok || v.TxFrom.After(old.TxFrom) {
+			res[v.ItemID] = v
+		}
+	}
+	return res
+}
+
+// Traverse runs a BFS from start over the edges of a snapshot and returns the node IDs by level
+// the first level is the start node, directed follows From to To only
+func Traverse(start string, snapshot map[string]Version, directed bool) [][]string {
+	adj := make(map[string][]string)
+	for _, v := range snapshot {
+		if v.Kind != EdgeKind {
+			continue
+		}
+		// edges to nodes missing in the snapshot are skipped
+		if _, ok := snapshot[v.From]; !ok {
+			continue
+		}
+		if _, ok := snapshot[v.To]; !ok {
+			continue
+		}
+		adj[v.From] = append(adj[v.From], v.To)
+		if !directed {
+			adj[v.To] = append(adj[v.To], v.From)
+		}
+	}
+
+	visited := map[string]bool{start: true}
+	level := []string{start}
+	var result [][]string
+	for len(level) > 0 {
+		result = append(result, level)
+		var next []string
+		for _, id := range level {
+			for _, to := range adj[id] {
+				if !visited[to] {
+					visited[to] = true
+					next = append(next, to)
+				}
+			}
+		}
+		level = next
+	}
+	return result
+}
+
+// FindNode returns the node version with the name valid at validAt as believed at knownAt
+func FindNode(versions []Version, name string, validAt, knownAt time.Time) (Version, bool) {
+	var named []Version
+	for _, v := range versions {
+		if v.Kind == NodeKind && v.Name == name {
+			named = append(named, v)
+		}
+	}
+	return AsOf(named, validAt, knownAt)
+}
@@ -0,0 +1,81 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func day(d int) time.Time {
+	return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+// apply runs Record against the current versions of a log and returns the new log
+func apply(log []Version, next *Version, validFrom, txTime time.Time) []Version {
+	var current []Version
+	for _, v := range log {
+		if v.Current() {
+			current = append(current, v)
+		}
+	}
+	closed, inserted := Record(current, next, validFrom, txTime)
+	for _, c := range closed {
+		for i := range log {
+			if log[i].ID == c.ID {
+				log[i] = c
+			}
+		}
+	}
+	return append(log, inserted...)
+}
+
+func TestRecordAndAsOf(t *testing.T) {
+	var log []Version
+	// day 1: we learn revenue 100, valid from day 1
+	log = apply(log, NodeVersion(Create, "n1", "company", "600001", map[string]interface{}{"revenue": 100.0}), day(1), day(1))
+	// day 5: we learn revenue 120, valid from day 5
+	log = apply(log, NodeVersion(Update, "n1", "company", "600001", map[string]interface{}{"revenue": 120.0}), day(5), day(5))
+	// day 10: a correction, revenue was 110 since day 3
+	log = apply(log, NodeVersion(Update, "n1", "company", "600001", map[string]interface{}{"revenue": 110.0}), day(3), day(10))
+
+	revenue := func(validAt, knownAt time.Time) interface{} {
+		v, ok := FindNode(log, "600001", validAt, knownAt)
+		if !ok {
+			return nil
+		}
+		return v.Data["revenue"]
+	}
+
+	// what we believed on day 6 about day 4
+	assert.Equal(t, 100.0, revenue(day(4), day(6)))
+	// what we believe on day 11 about day 4, after the correction
+	assert.Equal(t, 110.0, revenue(day(4), day(11)))
+	// day 2 is not touched by the correction
+	assert.Equal(t, 100.0, revenue(day(2), day(11)))
+	// before the first write nothing is known
+	assert.Nil(t, revenue(day(4), day(0)))
+
+	// day 12: deleted
+	log = apply(log, nil, day(12), day(12))
+	assert.Nil(t, revenue(day(13), day(13)))
+	assert.Equal(t, 110.0, revenue(day(11), day(13)))
+}
+
+func TestTraverse(t *testing.T) {
+	snapshot := map[string]Version{
+		"a":  {ItemID: "a", Kind: NodeKind},
+		"b":  {ItemID: "b", Kind: NodeKind},
+		"c":  {ItemID: "c", Kind: NodeKind},
+		"ab": {ItemID: "ab", Kind: EdgeKind, From: "a", To: "b"},
+		"cb": {ItemID: "cb", Kind: EdgeKind, From: "c", To: "b"},
+		"bx": {ItemID: "bx", Kind: EdgeKind, From: "b", To: "x"},
+	}
+	assert.Equal(t, [][]string{{"a"}, {"b"}}, Traverse("a", snapshot, true))
+	assert.Equal(t, [][]string{{"a"}, {"b"}, {"c"}}, Traverse("a", snapshot, false))
+}
+
+func TestValidTime(t *testing.T) {
+	assert.Equal(t, day(3), ValidTime(map[string]interface{}{ValidFromKey: "2024-01-03"}, day(9)))
+	assert.Equal(t, day(9), ValidTime(nil, day(9)))
+}
@@ -0,0 +1,173 @@
+package hooks
+
+import (
+	"fmt"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+)
+
+// writer is the write half of handler.GraphDB and handler.Tx
+type writer interface {
+	AddNode(n handler.Node) (interface{}, error)
+	AddEdge(e handler.Edge) (interface{}, error)
+	ReplaceNode(n handler.Node) error
+	ReplaceEdge(e handler.Edge) error
+	UpdateNode(n handler.Node) error
+	UpdateEdge(e handler.Edge) error
+	MergeNode(n handler.Node) error
+	MergeEdge(e handler.Edge) error
+	DeleteNode(name interface{}) error
+	DeleteItemByID(id interface{}) error
+}
+
+// writes runs the writes through the middlewares to the target
+type writes struct {
+	target writer
+	mws    *[]Middleware
+}
+
+// run builds the chain, the first middleware outermost, and applies the write
+func (ws writes) run(w *Write) error {
+	h := ws.apply
+	for i := len(*ws.mws) - 1; i >= 0; i-- {
+		h = (*ws.mws)[i](h)
+	}
+	return h(w)
+}
+
+// apply is the end of the chain, the write as the hooks left it
+func (ws writes) apply(w *Write) (err error) {
+	node := w.Kind == history.NodeKind
+	switch w.Op {
+	case history.Create:
+		if node {
+			w.Result, err = ws.target.AddNode(w.Node)
+		} else {
+			w.Result, err = ws.target.AddEdge(w.Edge)
+		}
+	case history.Replace:
+		if node {
+			err = ws.target.ReplaceNode(w.Node)
+		} else {
+			err = ws.target.ReplaceEdge(w.Edge)
+		}
+	case history.Update:
+		if node {
+			err = ws.target.UpdateNode(w.Node)
+		} else {
+			err = ws.target.UpdateEdge(w.Edge)
+		}
+	case history.Merge:
+		if node {
+			err = ws.target.MergeNode(w.Node)
+		} else {
+			err = ws.target.MergeEdge(w.Edge)
+		}
+	case history.Delete:
+		if node {
+			err = ws.target.DeleteNode(w.Key)
+		} else {
+			err = ws.target.DeleteItemByID(w.Key)
+		}
+	default:
+		err = fmt.Errorf("invalid write op %s", w.Op)
+	}
+	return err
+}
+
+func (ws writes) AddNode(n handler.Node) (interface{}, error) {
+	w := &Write{Op: history.Create, Kind: history.NodeKind, Node: n}
+	err := ws.run(w)
+	return w.Result, err
+}
+
+func (ws writes) AddEdge(e handler.Edge) (interface{}, error) {
+	w := &Write{Op: history.Create, Kind: history.EdgeKind, Edge: e}
+	err := ws.run(w)
+	return w.Result, err
+}
+
+func (ws writes) ReplaceNode(n handler.Node) error {
+	return ws.run(&Write{Op: history.Replace, Kind: history.NodeKind, Node: n})
+}
+
+func (ws writes) ReplaceEdge(e handler.Edge) error {
+	return ws.run(&Write{Op: history.Replace, Kind: history.EdgeKind, Edge: e})
+}
+
+func (ws writes) UpdateNode(n handler.Node) error {
+	return ws.run(&Write{Op: history.Update, Kind: history.NodeKind, Node: n})
+}
+
+func (ws writes) UpdateEdge(e handler.Edge) error {
+	return ws.run(&Write{Op: history.Update, Kind: history.EdgeKind, Edge: e})
+}
+
+func (ws writes) MergeNode(n handler.Node) error {
+	return ws.run(&Write{Op: history.Merge, Kind: history.NodeKind, Node: n})
+}
+
+func (ws writes) MergeEdge(e handler.Edge) error {
+	return ws.run(&Write{Op: history.Merge, Kind: history.EdgeKind, Edge: e})
+}
+
+func (ws writes) DeleteNode(name interface{}) error {
+	return ws.run(&Write{Op: history.Delete, Kind: history.NodeKind, Key: name})
+}
+
+func (ws writes) DeleteItemByID(id interface{}) error {
+	return ws.run(&Write{Op: history.Delete, Key: id})
+}
+
+// # Graph embeds its writes and the backend one level deeper, so the write methods
+// # of writes win over the ones of the backend and everything else is the backend's
+
+// Graph is a handler.GraphDB running its writes through the middlewares
+type Graph struct {
+	writes
+	base
+}
+
+type base struct{ handler.GraphDB }
+
+var _ handler.GraphDB = (*Graph)(nil)
+var _ handler.TxGraph = (*Graph)(nil)
+
+// Wrap returns g with the middlewares, the first one runs first
+func Wrap(g handler.GraphDB, mws ...Middleware) *Graph {
+	chain := append([]Middleware{}, mws...)
+	return &Graph{writes: writes{target: g, mws: &chain}, base: base{g}}
+}
+
+// Use appends middlewares to the chain, before the graph is shared
+func (g *Graph) Use(mws ...Middleware) {
+	*g.mws = append(*g.mws, mws...)
+}
+
+// Unwrap returns the backend, for its other interfaces
+func (g *Graph) Unwrap() handler.GraphDB {
+	return g.GraphDB
+}
+
+// tx is the handler.Tx of the Graph, its writes go through the same middlewares
+type tx struct {
+	writes
+	txBase
+}
+
+type txBase struct{ handler.Tx }
+
+var _ handler.Tx = (*tx)(nil)
+
+// Update(fn func(tx handler.Tx) error) error
+// a veto returned from fn rolls the transaction back like any error
+func (g *Graph) Update(fn func(tx handler.Tx) error) error {
+	txg, ok := g.GraphDB.(handler.TxGraph)
+	if !ok {
+		return fmt.Errorf("%T has no transactions", g.GraphDB)
+	}
+	return txg.Update(func(t handler.Tx) error {
+		return fn(&tx{writes: writes{target: t, mws: g.mws}, txBase: txBase{t}})
+	})
+}
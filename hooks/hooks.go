@@ -0,0 +1,148 @@
+// Package hooks runs synchronous hooks around the writes of any handler.GraphDB.
+//
+// Wrap puts a chain of middlewares in front of AddNode, AddEdge, Replace*, Update*,
+// Merge*, DeleteNode and DeleteItemByID. A middleware sees the Write before the
+// backend does: it can change it, such as normalising a name or stamping a field,
+// veto it by returning an error without calling next, or act once next returned.
+// The reads go straight to the backend.
+package hooks
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+)
+
+// Write is a write going through the chain
+type Write struct {
+	// Op is history.Create for the Add methods, Replace, Update, Merge or Delete
+	Op history.Op
+	// Kind is empty for DeleteItemByID, which does not tell
+	Kind history.Kind
+	// Node or Edge is the item written, nil for the deletes; a hook may replace it
+	Node handler.Node
+	Edge handler.Edge
+	// Key is the name given to DeleteNode or the ID given to DeleteItemByID
+	Key interface{}
+	// Result is the ID returned by AddNode and AddEdge, set once next returned
+	Result interface{}
+}
+
+// Handler applies a write
+type Handler func(w *Write) error
+
+// Middleware wraps the rest of the chain
+type Middleware func(next Handler) Handler
+
+// Before runs fn before the write, an error vetoes it
+func Before(fn func(w *Write) error) Middleware {
+	return func(next Handler) Handler {
+		return func(w *Write) error {
+			if err := fn(w); err != nil {
+				return err
+			}
+			return next(w)
+		}
+	}
+}
+
+// After runs fn once the write is done or failed with err, which it cannot change
+func After(fn func(w *Write, err error)) Middleware {
+	return func(next Handler) Handler {
+		return func(w *Write) error {
+			err := next(w)
+			fn(w, err)
+			return err
+		}
+	}
+}
+
+// On applies the middleware to the writes with one of the ops only
+func On(m Middleware, ops ...history.Op) Middleware {
+	return func(next Handler) Handler {
+		wrapped := m(next)
+		return func(w *Write) error {
+			for _, op := range ops {
+				if w.Op == op {
+					return wrapped(w)
+				}
+			}
+			return next(w)
+		}
+	}
+}
+
+// # the hooks read and change the items of every backend by their common fields:
+// # Collection, Name for the nodes, Relationship for the edges and Data
+
+// item returns the struct of the node or the edge written, invalid for a delete
+func (w *Write) item() reflect.Value {
+	var v interface{} = w.Node
+	if w.Kind == history.EdgeKind {
+		v = w.Edge
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return rv.Elem()
+}
+
+// field returns the field of the item, invalid if it has none
+func (w *Write) field(name string) reflect.Value {
+	it := w.item()
+	if !it.IsValid() {
+		return it
+	}
+	return it.FieldByName(name)
+}
+
+// Collection returns the collection of the item written, empty for a delete
+func (w *Write) Collection() string {
+	if f := w.field("Collection"); f.IsValid() && f.Kind() == reflect.String {
+		return f.String()
+	}
+	return ""
+}
+
+// nameField is Name for a node, Relationship for an edge
+func (w *Write) nameField() reflect.Value {
+	if w.Kind == history.EdgeKind {
+		return w.field("Relationship")
+	}
+	return w.field("Name")
+}
+
+// Name returns the name of the node or the relationship of the edge written
+func (w *Write) Name() string {
+	if f := w.nameField(); f.IsValid() && f.Kind() == reflect.String {
+		return f.String()
+	}
+	return ""
+}
+
+// SetName changes the name of the node or the relationship of the edge written
+func (w *Write) SetName(name string) error {
+	f := w.nameField()
+	if !f.IsValid() || f.Kind() != reflect.String || !f.CanSet() {
+		return fmt.Errorf("the %s write has no name to set", w.Op)
+	}
+	f.SetString(name)
+	return nil
+}
+
+// Data returns the Data of the item written, created if nil so a hook can add fields
+// nil for a delete
+func (w *Write) Data() map[string]interface{} {
+	f := w.field("Data")
+	if !f.IsValid() || f.Type() != reflect.TypeOf(map[string]interface{}{}) {
+		return nil
+	}
+	if f.IsNil() && f.CanSet() {
+		f.Set(reflect.ValueOf(map[string]interface{}{}))
+	}
+	data, _ := f.Interface().(map[string]interface{})
+	return data
+}
@@ -0,0 +1,92 @@
+package hooks
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"github.com/wonderstone/chainstorm/local"
+)
+
+func TestHooks(t *testing.T) {
+	db, err := local.NewInMemoryDB()
+	assert.NoError(t, err)
+	var seen []string
+	g := Wrap(db,
+		// normalise the tickers
+		Before(func(w *Write) error {
+			if w.Kind == history.NodeKind && w.Op != history.Delete {
+				return w.SetName(strings.ToUpper(strings.TrimSpace(w.Name())))
+			}
+			return nil
+		}),
+		// veto the deletes of the protected nodes
+		On(Before(func(w *Write) error {
+			if w.Key == "PROTECTED" {
+				return fmt.Errorf("%v is protected", w.Key)
+			}
+			return nil
+		}), history.Delete),
+		After(func(w *Write, err error) {
+			seen = append(seen, fmt.Sprintf("%s %s %v", w.Op, w.Kind, err == nil))
+		}),
+	)
+	// the stamp runs last, on the node already renamed
+	g.Use(On(Before(func(w *Write) error {
+		w.Data()["source"] = w.Collection() + "/" + w.Name()
+		return nil
+	}), history.Create))
+
+	a, _ := local.NewNode(local.WithNID("a"), local.WithNCollection("company"), local.WithNName(" sh600001 "))
+	id, err := g.AddNode(a)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", id)
+	n, err := g.GetNode("SH600001")
+	assert.NoError(t, err)
+	assert.Equal(t, "company/SH600001", n.(*local.Node).Data["source"])
+
+	p, _ := local.NewNode(local.WithNID("p"), local.WithNCollection("company"), local.WithNName("protected"))
+	_, err = g.AddNode(p)
+	assert.NoError(t, err)
+	assert.EqualError(t, g.DeleteNode("PROTECTED"), "PROTECTED is protected")
+	_, err = db.GetNode("PROTECTED")
+	assert.NoError(t, err)
+	// the veto comes first in the chain, After does not see it
+	assert.Equal(t, []string{"create node true", "create node true"}, seen)
+
+	// the writes of a transaction go through the hooks, a veto rolls it back
+	err = g.Update(func(tx handler.Tx) error {
+		b, _ := local.NewNode(local.WithNID("b"), local.WithNCollection("company"), local.WithNName("sz000001"))
+		if _, err := tx.AddNode(b); err != nil {
+			return err
+		}
+		if _, err := tx.GetNode("SZ000001"); err != nil {
+			return err
+		}
+		return tx.DeleteNode("PROTECTED")
+	})
+	assert.EqualError(t, err, "PROTECTED is protected")
+	_, err = db.GetNode("SZ000001")
+	assert.Error(t, err)
+	assert.Same(t, db, g.Unwrap())
+}
+
+func TestWriteFields(t *testing.T) {
+	from, _ := local.NewNode(local.WithNID("a"), local.WithNCollection("company"), local.WithNName("a"))
+	to, _ := local.NewNode(local.WithNID("b"), local.WithNCollection("company"), local.WithNName("b"))
+	e, _ := local.NewEdge(local.WithEID("e"), local.WithECollection("invest"), local.WithEName("invest"), local.WithEFrom(from), local.WithETo(to))
+	w := &Write{Op: history.Update, Kind: history.EdgeKind, Edge: e}
+	assert.Equal(t, "invest", w.Collection())
+	assert.Equal(t, "invest", w.Name())
+	assert.NoError(t, w.SetName("hold"))
+	assert.Equal(t, "hold", e.Relationship)
+
+	// a delete has no item
+	w = &Write{Op: history.Delete, Key: "e"}
+	assert.Nil(t, w.Data())
+	assert.Empty(t, w.Collection())
+	assert.Error(t, w.SetName("x"))
+}
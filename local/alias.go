@@ -0,0 +1,91 @@
+package local
+
+import (
+	"fmt"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"github.com/wonderstone/chainstorm/resolve"
+)
+
+// the InMemoryDB finds the nodes by their aliases
+var _ handler.AliasGraph = (*InMemoryDB)(nil)
+
+// loadAliases indexes the aliases of the loaded nodes, they are persisted with the Data
+func (db *InMemoryDB) loadAliases() {
+	for id, n := range db.Nodes {
+		db.aliases.Track(id, history.NodeVersion(history.Create, id, n.Collection, n.Name, n.Data), handler.AliasesKey)
+	}
+}
+
+// resolveName returns the name of the node owning the alias, names are returned as they are
+// the caller holds the lock
+func (db *InMemoryDB) resolveName(name interface{}) interface{} {
+	nameStr, ok := name.(string)
+	if !ok || db.checkNodeNameExists(nameStr) {
+		return name
+	}
+	if owner, ok := db.aliases.Resolve(nameStr); ok {
+		return owner
+	}
+	return name
+}
+
+// checkAliases returns an error if a name is the name or an alias of another node
+// the caller holds the lock
+func (db *InMemoryDB) checkAliases(id string, names ...string) error {
+	for _, a := range names {
+		if other, ok := db.NodeNameMap.Get(a); ok && other.(string) != id {
+			return fmt.Errorf("alias %s is the name of another node", a)
+		}
+	}
+	return db.aliases.Check(id, names...)
+}
+
+// AddAliases(name interface{}, aliases ...string) error
+func (db *InMemoryDB) AddAliases(name interface{}, aliases ...string) error {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	n, err := db.nodeByName(db.resolveName(name))
+	if err != nil {
+		return err
+	}
+	if err := db.checkAliases(n.ID, aliases...); err != nil {
+		return err
+	}
+	for _, a := range aliases {
+		if a == n.Name {
+			return fmt.Errorf("alias %s is the name of the node", a)
+		}
+	}
+	n.Data = resolve.AddAliases(n.Data, handler.AliasesKey, aliases...)
+	db.recordNode(history.Update, n, nil)
+	return nil
+}
+
+// RemoveAliases(name interface{}, aliases ...string) error
+func (db *InMemoryDB) RemoveAliases(name interface{}, aliases ...string) error {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	n, err := db.nodeByName(db.resolveName(name))
+	if err != nil {
+		return err
+	}
+	n.Data = resolve.RemoveAliases(n.Data, handler.AliasesKey, aliases...)
+	db.recordNode(history.Update, n, nil)
+	return nil
+}
+
+// GetAliases(name interface{}) ([]string, error)
+func (db *InMemoryDB) GetAliases(name interface{}) ([]string, error) {
+	db.m.RLock()
+	defer db.m.RUnlock()
+
+	n, err := db.nodeByName(db.resolveName(name))
+	if err != nil {
+		return nil, err
+	}
+	return resolve.Aliases(n.Data, handler.AliasesKey), nil
+}
@@ -0,0 +1,83 @@
+package local
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+func TestAliases(t *testing.T) {
+	db, err := NewInMemoryDB()
+	assert.NoError(t, err)
+	a, _ := NewNode(WithNID("a"), WithNCollection("company"), WithNName("600001"),
+		WithNData(map[string]interface{}{handler.AliasesKey: []interface{}{"平安银行"}}))
+	_, err = db.AddNode(a)
+	assert.NoError(t, err)
+	b, _ := NewNode(WithNID("b"), WithNCollection("person"), WithNName("holder"))
+	_, err = db.AddNode(b)
+	assert.NoError(t, err)
+	e, _ := NewEdge(WithEID("e"), WithECollection("invest"), WithEName("invest"), WithEFrom(b), WithETo(a))
+	_, err = db.AddEdge(e)
+	assert.NoError(t, err)
+
+	// the aliases of the data are indexed, GetNode and the traversals accept them
+	n, err := db.GetNode("平安银行")
+	assert.NoError(t, err)
+	assert.Equal(t, a, n)
+	assert.NoError(t, db.AddAliases("600001", "SH600001", "PAB"))
+	from, err := db.GetToNodes("PAB")
+	assert.NoError(t, err)
+	assert.Len(t, from, 1)
+	aliases, err := db.GetAliases("SH600001")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"平安银行", "SH600001", "PAB"}, aliases)
+
+	// the regex search matches the aliases, a node is found once
+	nodes, err := db.GetNodesByRegex("^SH|^600")
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 1)
+
+	// the aliases are unique across the graph
+	assert.Error(t, db.AddAliases("holder", "PAB"))
+	assert.Error(t, db.AddAliases("holder", "600001"))
+	assert.Error(t, db.AddAliases("600001", "holder"))
+	c, _ := NewNode(WithNID("c"), WithNCollection("company"), WithNName("PAB"))
+	_, err = db.AddNode(c)
+	assert.Error(t, err)
+
+	// so are the aliases of the other writes
+	dup := func() *Node {
+		n, _ := NewNode(WithNID("b"), WithNCollection("person"), WithNName("holder"),
+			WithNData(map[string]interface{}{handler.AliasesKey: []interface{}{"PAB"}}))
+		return n
+	}
+	assert.Error(t, db.ReplaceNode(dup()))
+	assert.Error(t, db.UpdateNode(dup()))
+	assert.Error(t, db.MergeNode(dup()))
+	_, err = db.GetNode("holder")
+	assert.NoError(t, err)
+	aliases, err = db.GetAliases("holder")
+	assert.NoError(t, err)
+	assert.Empty(t, aliases)
+
+	assert.NoError(t, db.RemoveAliases("600001", "PAB"))
+	_, err = db.GetNode("PAB")
+	assert.Error(t, err)
+	assert.NoError(t, db.AddAliases("holder", "PAB"))
+
+	// the aliases are persisted with the data
+	dir, err := os.MkdirTemp("", "alias")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db.configPath = dir
+	assert.NoError(t, db.Disconnect())
+	db2, err := NewInMemoryDB()
+	assert.NoError(t, err)
+	db2.configPath = dir
+	assert.NoError(t, db2.Connect())
+	n, err = db2.GetNode("PAB")
+	assert.NoError(t, err)
+	assert.Equal(t, "holder", n.Export()["Name"])
+}
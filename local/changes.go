@@ -0,0 +1,26 @@
+package local
+
+import (
+	"context"
+
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+// the InMemoryDB has an in-process change feed, the cursors do not outlive it
+var _ handler.ChangeGraph = (*InMemoryDB)(nil)
+
+// publish sends the event of a write, held until the commit in a transaction
+// the caller holds the lock
+func (db *InMemoryDB) publish(e handler.ChangeEvent) {
+	if db.pending != nil {
+		db.pending = append(db.pending, e)
+		return
+	}
+	db.feed.Publish(e)
+}
+
+// Subscribe(ctx context.Context, f handler.ChangeFilter) (<-chan handler.ChangeEvent, error)
+// the feed keeps the last DefaultSize events of the changes package to resume from
+func (db *InMemoryDB) Subscribe(ctx context.Context, f handler.ChangeFilter) (<-chan handler.ChangeEvent, error) {
+	return db.feed.Subscribe(ctx, f)
+}
@@ -0,0 +1,67 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+)
+
+func nextChange(t *testing.T, ch <-chan handler.ChangeEvent) handler.ChangeEvent {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no change event")
+	}
+	return handler.ChangeEvent{}
+}
+
+func TestSubscribe(t *testing.T) {
+	db := resolveGraph(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	feed, err := db.Subscribe(ctx, handler.ChangeFilter{Kinds: []history.Kind{history.NodeKind}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.UpdateNode(&Node{ID: "a", Data: map[string]interface{}{"revenue": 2.0}}))
+	e := nextChange(t, feed)
+	assert.Equal(t, history.Update, e.Op)
+	assert.Equal(t, "a", e.ID)
+	assert.Equal(t, "company", e.Collection)
+	assert.Equal(t, 1.0, e.Before.Data["revenue"])
+	assert.Equal(t, 2.0, e.After.Data["revenue"])
+
+	// the edges of a deleted node are filtered out
+	assert.NoError(t, db.DeleteNode("holder2"))
+	e = nextChange(t, feed)
+	assert.Equal(t, history.Delete, e.Op)
+	assert.Equal(t, "holder2", e.Before.Name)
+	assert.Nil(t, e.After)
+	cursor := e.Cursor
+
+	// a rolled back transaction publishes nothing, a committed one on commit
+	err = db.Update(func(tx handler.Tx) error {
+		_ = tx.DeleteNode("holder1")
+		return fmt.Errorf("abort")
+	})
+	assert.Error(t, err)
+	assert.NoError(t, db.Update(func(tx handler.Tx) error {
+		n, _ := NewNode(WithNID("c"), WithNCollection("company"), WithNName("600002"))
+		_, err := tx.AddNode(n)
+		return err
+	}))
+	e = nextChange(t, feed)
+	assert.Equal(t, history.Create, e.Op)
+	assert.Equal(t, "c", e.ID)
+
+	// a feed resumes after a cursor
+	resumed, err := db.Subscribe(ctx, handler.ChangeFilter{Cursor: cursor, Ops: []history.Op{history.Create}})
+	assert.NoError(t, err)
+	assert.Equal(t, "c", nextChange(t, resumed).ID)
+}
@@ -0,0 +1,13 @@
+package local
+
+import "github.com/wonderstone/chainstorm/handler"
+
+// the InMemoryDB tells its size
+var _ handler.CountingGraph = (*InMemoryDB)(nil)
+
+// CountItems() (nodes, edges int64, err error)
+func (db *InMemoryDB) CountItems() (nodes, edges int64, err error) {
+	db.m.RLock()
+	defer db.m.RUnlock()
+	return int64(len(db.Nodes)), int64(len(db.Edges)), nil
+}
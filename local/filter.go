@@ -0,0 +1,76 @@
+package local
+
+import (
+	"sort"
+
+	"github.com/wonderstone/chainstorm/filter"
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+// the InMemoryDB evaluates the filters on the Data in memory
+// the secondary indexes of the collection narrow the items to check, see candidates
+var _ handler.FilterGraph = (*InMemoryDB)(nil)
+
+// FindNodes(collection string, f filter.Expr) ([]handler.Node, error)
+// the nodes are sorted by name
+func (db *InMemoryDB) FindNodes(collection string, f filter.Expr) ([]handler.Node, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	db.m.RLock()
+	defer db.m.RUnlock()
+
+	var nodes []*Node
+	if ids, ok := db.candidates(collection, f); ok {
+		for id := range ids {
+			if n, ok := db.Nodes[id]; ok && f.Match(n.Data) {
+				nodes = append(nodes, n)
+			}
+		}
+	} else {
+		for _, n := range db.Nodes {
+			if (collection == "" || n.Collection == collection) && f.Match(n.Data) {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	result := make([]handler.Node, len(nodes))
+	for i, n := range nodes {
+		result[i] = copyNode(n)
+	}
+	return result, nil
+}
+
+// FindEdges(collection string, f filter.Expr) ([]handler.Edge, error)
+// the edges are sorted by ID
+func (db *InMemoryDB) FindEdges(collection string, f filter.Expr) ([]handler.Edge, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	db.m.RLock()
+	defer db.m.RUnlock()
+
+	var edges []*Edge
+	if ids, ok := db.candidates(collection, f); ok {
+		for id := range ids {
+			if e, ok := db.Edges[id]; ok && f.Match(e.Data) {
+				edges = append(edges, e)
+			}
+		}
+	} else {
+		for _, e := range db.Edges {
+			if (collection == "" || e.Collection == collection) && f.Match(e.Data) {
+				edges = append(edges, e)
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].ID < edges[j].ID })
+
+	result := make([]handler.Edge, len(edges))
+	for i, e := range edges {
+		result[i] = db.edgeCopy(e)
+	}
+	return result, nil
+}
@@ -0,0 +1,48 @@
+package local
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/filter"
+)
+
+func TestFindNodes(t *testing.T) {
+	db, err := NewInMemoryDB()
+	assert.NoError(t, err)
+	for _, n := range []struct {
+		id, name string
+		data     map[string]interface{}
+	}{
+		{"a", "Acme", map[string]interface{}{"companyRevenue": 2e6, "companyCountry": "USA"}},
+		{"b", "Bolt", map[string]interface{}{"companyRevenue": 5e5, "companyCountry": "USA"}},
+		{"c", "Cathay", map[string]interface{}{"companyRevenue": 3e6, "companyCountry": "CN"}},
+	} {
+		node, _ := NewNode(WithNID(n.id), WithNCollection("company"), WithNName(n.name), WithNData(n.data))
+		_, err := db.AddNode(node)
+		assert.NoError(t, err)
+	}
+	e, _ := NewEdge(WithEID("e"), WithECollection("supply"), WithEName("supplies"),
+		WithEFrom(db.Nodes["a"]), WithETo(db.Nodes["c"]), WithEData(map[string]interface{}{"share": 0.4}))
+	_, err = db.AddEdge(e)
+	assert.NoError(t, err)
+
+	nodes, err := db.FindNodes("company", filter.And(filter.Gt("companyRevenue", 1e6), filter.Eq("companyCountry", "USA")))
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 1)
+	assert.Equal(t, db.Nodes["a"], nodes[0])
+
+	nodes, err = db.FindNodes("", filter.Gte("companyRevenue", 2e6))
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 2)
+	nodes, err = db.FindNodes("person", filter.And())
+	assert.NoError(t, err)
+	assert.Empty(t, nodes)
+
+	edges, err := db.FindEdges("", filter.Lt("share", 0.5))
+	assert.NoError(t, err)
+	assert.Len(t, edges, 1)
+
+	_, err = db.FindNodes("", filter.Gt("companyRevenue", nil))
+	assert.Error(t, err)
+}
@@ -5,10 +5,17 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 
 	"github.com/google/uuid"
+	"github.com/wonderstone/chainstorm/config"
 	"github.com/wonderstone/chainstorm/handler"
-	"gopkg.in/yaml.v3"
+	"github.com/wonderstone/chainstorm/history"
+	"github.com/wonderstone/chainstorm/merge"
+	"github.com/wonderstone/chainstorm/provenance"
+	"github.com/wonderstone/chainstorm/resolve"
+	"github.com/wonderstone/chainstorm/search"
+	"github.com/wonderstone/chainstorm/tools"
 )
 
 // implement the GraphDB interface
@@ -16,30 +23,43 @@ import (
 // - Init operations
 // Init(yamlPath string) error
 func (db *InMemoryDB) Init(yamlPath string) error {
-	// read the config yaml file from the yamlPath
-	// parse the yaml file and set the
-	// db.configPath is equal to dataPath field in the yaml file
-	// read the yaml file
-	yamlData, err := os.ReadFile(yamlPath)
-
+	// read the config yaml file from the yamlPath into the typed config
+	// the environment overrides it, db.configPath is equal to its dataPath
+	c, err := config.LoadLocal(yamlPath)
 	if err != nil {
 		return err
 	}
+	return db.Configure(c)
+}
 
-	// unmarshal the yaml data into a map
-	var data map[string]interface{}
-	err = yaml.Unmarshal(yamlData, &data)
+// Configure(c *config.Local) error
+// Init reads the config from a file, Configure takes it as it is
+func (db *InMemoryDB) Configure(c *config.Local) error {
+	// set the db.configPath
+	db.configPath = c.DataPath
 
+	// merge strategies, the section is optional
+	merger, err := merge.Parse(c.Merge)
 	if err != nil {
 		return err
 	}
+	db.merger = merger
 
-	// set the db.configPath
-	dataPath, ok := data["dataPath"].(string)
-	if !ok {
-		return fmt.Errorf("dataPath is not a string")
+	// full-text search fields, the section is optional
+	searcher, err := search.Parse(c.Search)
+	if err != nil {
+		return err
 	}
-	db.configPath = dataPath
+	db.index = search.NewIndex(searcher)
+
+	// logger, the section is optional
+	logger, err := tools.NewLogger(c.Logger)
+	if err != nil {
+		return err
+	}
+	db.logger.Close()
+	db.logger = logger
+	db.logger.Info().Str("dataPath", db.configPath).Msg("InMemoryDB initialized")
 	return nil
 }
 
@@ -73,7 +93,15 @@ func checkType(data map[string]interface{}) string {
 
 // Connect() error
 // 读取本地文件并将其内容加载到内存中
-func (db *InMemoryDB) Connect() error {
+func (db *InMemoryDB) Connect() (err error) {
+	defer func() {
+		if err != nil {
+			db.logger.Error().Err(err).Str("dataPath", db.configPath).Msg("Failed to load the data")
+			return
+		}
+		db.logger.Info().Int("nodes", len(db.Nodes)).Int("edges", len(db.Edges)).Msg("InMemoryDB connected")
+	}()
+
 	// read the configPath file
 	// every collection is a directory in the configPath
 	// get all the json files in each collection
@@ -87,8 +115,9 @@ func (db *InMemoryDB) Connect() error {
 	}
 
 	// iterate over the directories the first time for nodes only
+	// the reserved directories are not collections, they are loaded on their own
 	for _, dir := range dirs {
-		if dir.IsDir() {
+		if dir.IsDir() && !handler.IsReserved(dir.Name()) {
 			// get all the json files in the directory
 			files, err := os.ReadDir(filepath.Join(db.configPath, dir.Name()))
 			if err != nil {
@@ -98,7 +127,8 @@ func (db *InMemoryDB) Connect() error {
 			// iterate over the files
 			for _, file := range files {
 				// read the json file
-				data, err := ReadJSONFile(filepath.Join(db.configPath, dir.Name(), file.Name()))
+				path := filepath.Join(db.configPath, dir.Name(), file.Name())
+				data, err := ReadJSONFile(path)
 				if err != nil {
 					return err
 				}
@@ -106,7 +136,7 @@ func (db *InMemoryDB) Connect() error {
 				if checkType(data) == "node" {
 
 					// check if the node has the data field
-					if _, ok := data["Data"]; !ok {
+					if _, ok := data["Data"].(map[string]interface{}); !ok {
 						// create a new node
 						node, err := NewNode(
 							WithNID(data["ID"].(string)),
@@ -118,6 +148,7 @@ func (db *InMemoryDB) Connect() error {
 						}
 						// add the node to the db.Nodes
 						db.Nodes[node.ID] = node
+						db.files[path] = void{}
 						// add the node name to the nodeNameSet
 						db.nodeNameSet[node.Name] = void{}
 						// add the node name and id to the NodeNameMap
@@ -136,6 +167,7 @@ func (db *InMemoryDB) Connect() error {
 						}
 						// add the node to the db.Nodes
 						db.Nodes[node.ID] = node
+						db.files[path] = void{}
 						// add the node name to the nodeNameSet
 						db.nodeNameSet[node.Name] = void{}
 						// add the node name and id to the NodeNameMap
@@ -148,7 +180,7 @@ func (db *InMemoryDB) Connect() error {
 
 	// iterate over the directories the second time for edges only
 	for _, dir := range dirs {
-		if dir.IsDir() {
+		if dir.IsDir() && !handler.IsReserved(dir.Name()) {
 			// get all the json files in the directory
 			files, err := os.ReadDir(filepath.Join(db.configPath, dir.Name()))
 			if err != nil {
@@ -157,23 +189,24 @@ func (db *InMemoryDB) Connect() error {
 			// iterate over the files
 			for _, file := range files {
 				// read the json file
-				data, err := ReadJSONFile(filepath.Join(db.configPath, dir.Name(), file.Name()))
+				path := filepath.Join(db.configPath, dir.Name(), file.Name())
+				data, err := ReadJSONFile(path)
 				if err != nil {
 					return err
 				}
 
-				// check if the from node and to node exist with checkNodeNameExists method
-				if !db.checkNodeNameExists(data["From"].(string)) {
-					return fmt.Errorf("node with name %s does not exist", data["From"].(string))
-				}
-
-				if !db.checkNodeNameExists(data["To"].(string)) {
-					return fmt.Errorf("node with name %s does not exist", data["To"].(string))
-				}
-				// check if the data is an edge
+				// check if the data is an edge, node files are in the same directories
 				if checkType(data) == "edge" {
+					// check if the from node and to node exist, edges refer to the node ID
+					if _, ok := db.Nodes[data["From"].(string)]; !ok {
+						return fmt.Errorf("node with ID %s does not exist", data["From"].(string))
+					}
+
+					if _, ok := db.Nodes[data["To"].(string)]; !ok {
+						return fmt.Errorf("node with ID %s does not exist", data["To"].(string))
+					}
 					// check if the edge has the data field
-					if _, ok := data["Data"]; !ok {
+					if _, ok := data["Data"].(map[string]interface{}); !ok {
 						// create a new edge
 						edge, err := NewEdge(
 							WithEID(data["ID"].(string)),
@@ -187,6 +220,7 @@ func (db *InMemoryDB) Connect() error {
 						}
 						// add the edge to the db.Edges
 						db.Edges[edge.ID] = edge
+						db.files[path] = void{}
 					} else {
 						// create a new edge with the data field
 						edge, err := NewEdge(
@@ -202,37 +236,97 @@ func (db *InMemoryDB) Connect() error {
 						}
 						// add the edge to the db.Edges
 						db.Edges[edge.ID] = edge
+						db.files[path] = void{}
 					}
 				}
 			}
 		}
 	}
 
-	return nil
+	// the revisions count the writes from here
+	for _, n := range db.Nodes {
+		n.Rev = 1
+	}
+	for _, e := range db.Edges {
+		e.Rev = 1
+	}
+
+	// index the aliases and the text of the nodes
+	db.loadAliases()
+	db.indexNodes()
+	if err := db.loadIndexes(); err != nil {
+		return err
+	}
+
+	// load the time series store, the history and the provenance if any
+	if err := db.loadSeries(); err != nil {
+		return err
+	}
+	if err := db.loadHistory(); err != nil {
+		return err
+	}
+	return db.loadProvenance()
 }
 
 // Disconnect() error
 // 将内存中的数据写入到本地文件
-func (db *InMemoryDB) Disconnect() error {
+func (db *InMemoryDB) Disconnect() (err error) {
+	defer func() {
+		if err != nil {
+			db.logger.Error().Err(err).Str("dataPath", db.configPath).Msg("Failed to write the data")
+			return
+		}
+		db.logger.Info().Int("nodes", len(db.Nodes)).Int("edges", len(db.Edges)).Msg("InMemoryDB disconnected")
+		// the log files are released, Init opens them again
+		err = db.logger.Close()
+	}()
+
+	written := make(map[string]void)
+
 	// iterate over the db.Nodes
-	// write the node to the file
+	// write the node to the file in the same layout Connect reads
 	for _, node := range db.Nodes {
-		err := WriteJSONFile(filepath.Join(db.configPath, node.Collection, node.Name+".json"), node.Export())
+		path := filepath.Join(db.configPath, node.Collection, node.Name+".json")
+		err := WriteJSONFile(path, node)
 		if err != nil {
 			return err
 		}
+		written[path] = void{}
 	}
 
 	// iterate over the db.Edges
-	// write the edge to the file
+	// write the edge to the file, relationship is not unique so the ID names the file
 	for _, edge := range db.Edges {
-		err := WriteJSONFile(filepath.Join(db.configPath, edge.Collection, edge.Relationship+".json"), edge.Export())
+		path := filepath.Join(db.configPath, edge.Collection, edge.ID+".json")
+		err := WriteJSONFile(path, edge.ExportJSON())
 		if err != nil {
 			return err
 		}
+		written[path] = void{}
 	}
 
-	return nil
+	// the files of the deleted and renamed items would come back on the next Connect
+	for path := range db.files {
+		if _, ok := written[path]; ok {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	db.files = written
+
+	// write the index definitions, the time series store, the history and the provenance
+	if err := db.saveIndexes(); err != nil {
+		return err
+	}
+	if err := db.saveSeries(); err != nil {
+		return err
+	}
+	if err := db.saveHistory(); err != nil {
+		return err
+	}
+	return db.saveProvenance()
 }
 
 // - CRUD operations
@@ -242,6 +336,11 @@ func (db *InMemoryDB) Disconnect() error {
 func (db *InMemoryDB) AddNode(ni handler.Node) (interface{}, error) {
 	db.m.Lock()
 	defer db.m.Unlock()
+	return db.addNode(ni)
+}
+
+// addNode is AddNode, the caller holds the lock
+func (db *InMemoryDB) addNode(ni handler.Node) (interface{}, error) {
 	// check ni type
 	// if ni is a pointer, use ni.(*Node)
 	// if ni is a value, use ni.(Node)
@@ -255,6 +354,13 @@ func (db *InMemoryDB) AddNode(ni handler.Node) (interface{}, error) {
 		return nil, fmt.Errorf("invalid input")
 	}
 
+	// split the provenance out of the data
+	data, src, err := provenance.Extract(n.Data)
+	if err != nil {
+		return nil, err
+	}
+	n.Data = data
+
 	// check if node has mandatory fields
 	if n.ID == "" {
 		// give uuid
@@ -276,13 +382,24 @@ func (db *InMemoryDB) AddNode(ni handler.Node) (interface{}, error) {
 	if n.Collection == "" {
 		return nil, fmt.Errorf("node collection is required")
 	}
+	// the names and the aliases are unique together
+	if err := db.checkAliases(n.ID, append([]string{n.Name}, resolve.Aliases(n.Data, handler.AliasesKey)...)...); err != nil {
+		return nil, err
+	}
 
 	// add the node to the Nodes and NodeNameMap
+	// recordNode makes it the revision 1
+	n.Rev = 0
 	db.Nodes[n.ID] = &n
 	// add the node id and name to the bidimap
 	db.NodeNameMap.Put(n.Name, n.ID)
 	// add the nodename to the nodeNameSet
 	db.nodeNameSet[n.Name] = void{}
+	// keep the version
+	db.recordNode(history.Create, &n, n.Data)
+	db.assert(history.Create, n.ID, history.NodeKind, n.Collection, n.Data, src)
+	// the caller's node has the revision written, for its next conditional write
+	ni.(*Node).Rev = n.Rev
 	return n.ID, nil
 }
 
@@ -290,6 +407,11 @@ func (db *InMemoryDB) AddNode(ni handler.Node) (interface{}, error) {
 func (db *InMemoryDB) AddEdge(ei handler.Edge) (interface{}, error) {
 	db.m.Lock()
 	defer db.m.Unlock()
+	return db.addEdge(ei)
+}
+
+// addEdge is AddEdge, the caller holds the lock
+func (db *InMemoryDB) addEdge(ei handler.Edge) (interface{}, error) {
 	// check ei type
 	// if ei is a pointer, use ei.(*Edge)
 	// if ei is a value, use ei.(Edge)
@@ -303,6 +425,13 @@ func (db *InMemoryDB) AddEdge(ei handler.Edge) (interface{}, error) {
 		return nil, fmt.Errorf("invalid input")
 	}
 
+	// split the provenance out of the data
+	data, src, err := provenance.Extract(e.Data)
+	if err != nil {
+		return nil, err
+	}
+	e.Data = data
+
 	// check if edge has mandatory fields
 	if e.ID == "" {
 		// give uuid
@@ -337,7 +466,13 @@ func (db *InMemoryDB) AddEdge(ei handler.Edge) (interface{}, error) {
 	}
 
 	// add the edge to the Edges and EdgeNameMap
+	// recordEdge makes it the revision 1
+	e.Rev = 0
 	db.Edges[e.ID] = &e
+	// keep the version
+	db.recordEdge(history.Create, &e, e.Data)
+	db.assert(history.Create, e.ID, history.EdgeKind, e.Collection, e.Data, src)
+	ei.(*Edge).Rev = e.Rev
 
 	return e.ID, nil
 }
@@ -348,6 +483,11 @@ func (db *InMemoryDB) AddEdge(ei handler.Edge) (interface{}, error) {
 func (db *InMemoryDB) ReplaceNode(ni handler.Node) error {
 	db.m.Lock()
 	defer db.m.Unlock()
+	return db.replaceNode(ni)
+}
+
+// replaceNode is ReplaceNode, the caller holds the lock
+func (db *InMemoryDB) replaceNode(ni handler.Node) error {
 	// check ni type
 	// if ni is a pointer, use ni.(*Node)
 	// if ni is a value, use ni.(Node)
@@ -361,13 +501,33 @@ func (db *InMemoryDB) ReplaceNode(ni handler.Node) error {
 		return fmt.Errorf("invalid input")
 	}
 
+	// split the provenance out of the data
+	data, src, err := provenance.Extract(n.Data)
+	if err != nil {
+		return err
+	}
+	n.Data = data
+
 	// check if the node exists
 	if _, ok := db.Nodes[n.ID]; !ok {
 		return fmt.Errorf("node with ID %s does not exist", n.ID)
 	}
 
+	if err := checkRev(n.ID, n.Rev, db.Nodes[n.ID].Rev); err != nil {
+		return err
+	}
+
+	// the aliases of the write are unique with the names and the other aliases
+	if err := db.checkAliases(n.ID, resolve.Aliases(n.Data, handler.AliasesKey)...); err != nil {
+		return err
+	}
+
 	// replace the node
+	n.Rev = db.Nodes[n.ID].Rev
 	db.Nodes[n.ID] = &n
+	db.recordNode(history.Replace, &n, n.Data)
+	db.assert(history.Replace, n.ID, history.NodeKind, n.Collection, n.Data, src)
+	ni.(*Node).Rev = n.Rev
 	return nil
 }
 
@@ -375,6 +535,11 @@ func (db *InMemoryDB) ReplaceNode(ni handler.Node) error {
 func (db *InMemoryDB) ReplaceEdge(ei handler.Edge) error {
 	db.m.Lock()
 	defer db.m.Unlock()
+	return db.replaceEdge(ei)
+}
+
+// replaceEdge is ReplaceEdge, the caller holds the lock
+func (db *InMemoryDB) replaceEdge(ei handler.Edge) error {
 	// check ei type
 	// if ei is a pointer, use ei.(*Edge)
 	// if ei is a value, use ei.(Edge)
@@ -388,13 +553,28 @@ func (db *InMemoryDB) ReplaceEdge(ei handler.Edge) error {
 		return fmt.Errorf("invalid input")
 	}
 
+	// split the provenance out of the data
+	data, src, err := provenance.Extract(e.Data)
+	if err != nil {
+		return err
+	}
+	e.Data = data
+
 	// check if the edge exists
 	if _, ok := db.Edges[e.ID]; !ok {
 		return fmt.Errorf("edge with ID %s does not exist", e.ID)
 	}
 
+	if err := checkRev(e.ID, e.Rev, db.Edges[e.ID].Rev); err != nil {
+		return err
+	}
+
 	// replace the edge
+	e.Rev = db.Edges[e.ID].Rev
 	db.Edges[e.ID] = &e
+	db.recordEdge(history.Replace, &e, e.Data)
+	db.assert(history.Replace, e.ID, history.EdgeKind, e.Collection, e.Data, src)
+	ei.(*Edge).Rev = e.Rev
 	return nil
 }
 
@@ -403,6 +583,11 @@ func (db *InMemoryDB) ReplaceEdge(ei handler.Edge) error {
 func (db *InMemoryDB) UpdateNode(ni handler.Node) error {
 	db.m.Lock()
 	defer db.m.Unlock()
+	return db.updateNode(ni)
+}
+
+// updateNode is UpdateNode, the caller holds the lock
+func (db *InMemoryDB) updateNode(ni handler.Node) error {
 	// check ni type
 	// if ni is a pointer, use ni.(*Node)
 	// if ni is a value, use ni.(Node)
@@ -416,13 +601,32 @@ func (db *InMemoryDB) UpdateNode(ni handler.Node) error {
 		return fmt.Errorf("invalid input")
 	}
 
+	// split the provenance out of the data
+	data, src, err := provenance.Extract(n.Data)
+	if err != nil {
+		return err
+	}
+	n.Data = data
+
 	// check if the node exists
 	if _, ok := db.Nodes[n.ID]; !ok {
 		return fmt.Errorf("node with ID %s does not exist", n.ID)
 	}
 
+	if err := checkRev(n.ID, n.Rev, db.Nodes[n.ID].Rev); err != nil {
+		return err
+	}
+
+	// the aliases of the write are unique with the names and the other aliases
+	if err := db.checkAliases(n.ID, resolve.Aliases(n.Data, handler.AliasesKey)...); err != nil {
+		return err
+	}
+
 	// update the node
 	db.Nodes[n.ID].Data = MergeMaps(db.Nodes[n.ID].Data, n.Data)
+	db.recordNode(history.Update, db.Nodes[n.ID], n.Data)
+	db.assert(history.Update, n.ID, history.NodeKind, db.Nodes[n.ID].Collection, n.Data, src)
+	ni.(*Node).Rev = db.Nodes[n.ID].Rev
 	return nil
 }
 
@@ -430,6 +634,11 @@ func (db *InMemoryDB) UpdateNode(ni handler.Node) error {
 func (db *InMemoryDB) UpdateEdge(ei handler.Edge) error {
 	db.m.Lock()
 	defer db.m.Unlock()
+	return db.updateEdge(ei)
+}
+
+// updateEdge is UpdateEdge, the caller holds the lock
+func (db *InMemoryDB) updateEdge(ei handler.Edge) error {
 	// check ei type
 	// if ei is a pointer, use ei.(*Edge)
 	// if ei is a value, use ei.(Edge)
@@ -443,13 +652,27 @@ func (db *InMemoryDB) UpdateEdge(ei handler.Edge) error {
 		return fmt.Errorf("invalid input")
 	}
 
+	// split the provenance out of the data
+	data, src, err := provenance.Extract(e.Data)
+	if err != nil {
+		return err
+	}
+	e.Data = data
+
 	// check if the edge exists
 	if _, ok := db.Edges[e.ID]; !ok {
 		return fmt.Errorf("edge with ID %s does not exist", e.ID)
 	}
 
+	if err := checkRev(e.ID, e.Rev, db.Edges[e.ID].Rev); err != nil {
+		return err
+	}
+
 	// update the edge
 	db.Edges[e.ID].Data = MergeMaps(db.Edges[e.ID].Data, e.Data)
+	db.recordEdge(history.Update, db.Edges[e.ID], e.Data)
+	db.assert(history.Update, e.ID, history.EdgeKind, db.Edges[e.ID].Collection, e.Data, src)
+	ei.(*Edge).Rev = db.Edges[e.ID].Rev
 	return nil
 }
 
@@ -458,6 +681,11 @@ func (db *InMemoryDB) UpdateEdge(ei handler.Edge) error {
 func (db *InMemoryDB) MergeNode(ni handler.Node) error {
 	db.m.Lock()
 	defer db.m.Unlock()
+	return db.mergeNode(ni)
+}
+
+// mergeNode is MergeNode, the caller holds the lock
+func (db *InMemoryDB) mergeNode(ni handler.Node) error {
 	// check ni type
 	// if ni is a pointer, use ni.(*Node)
 	// if ni is a value, use ni.(Node)
@@ -471,13 +699,37 @@ func (db *InMemoryDB) MergeNode(ni handler.Node) error {
 		return fmt.Errorf("invalid input")
 	}
 
+	// split the provenance out of the data
+	data, src, err := provenance.Extract(n.Data)
+	if err != nil {
+		return err
+	}
+	n.Data = data
+
 	// check if the node exists
 	if _, ok := db.Nodes[n.ID]; !ok {
 		return fmt.Errorf("node with ID %s does not exist", n.ID)
 	}
 
-	// merge the node
-	db.Nodes[n.ID].Data = MergeMaps(db.Nodes[n.ID].Data, n.Data)
+	if err := checkRev(n.ID, n.Rev, db.Nodes[n.ID].Rev); err != nil {
+		return err
+	}
+
+	// the aliases of the write are unique with the names and the other aliases
+	if err := db.checkAliases(n.ID, resolve.Aliases(n.Data, handler.AliasesKey)...); err != nil {
+		return err
+	}
+
+	// merge the node with the configured strategies
+	old := db.Nodes[n.ID]
+	merged, err := db.mergeConfig().Merge(old.Collection, old.Data, n.Data)
+	if err != nil {
+		return err
+	}
+	old.Data = merged
+	db.recordNode(history.Merge, old, n.Data)
+	db.assert(history.Merge, n.ID, history.NodeKind, old.Collection, n.Data, src)
+	ni.(*Node).Rev = old.Rev
 	return nil
 }
 
@@ -485,6 +737,11 @@ func (db *InMemoryDB) MergeNode(ni handler.Node) error {
 func (db *InMemoryDB) MergeEdge(ei handler.Edge) error {
 	db.m.Lock()
 	defer db.m.Unlock()
+	return db.mergeEdge(ei)
+}
+
+// mergeEdge is MergeEdge, the caller holds the lock
+func (db *InMemoryDB) mergeEdge(ei handler.Edge) error {
 	// check ei type
 	// if ei is a pointer, use ei.(*Edge)
 	// if ei is a value, use ei.(Edge)
@@ -498,13 +755,32 @@ func (db *InMemoryDB) MergeEdge(ei handler.Edge) error {
 		return fmt.Errorf("invalid input")
 	}
 
+	// split the provenance out of the data
+	data, src, err := provenance.Extract(e.Data)
+	if err != nil {
+		return err
+	}
+	e.Data = data
+
 	// check if the edge exists
 	if _, ok := db.Edges[e.ID]; !ok {
 		return fmt.Errorf("edge with ID %s does not exist", e.ID)
 	}
 
-	// merge the edge
-	db.Edges[e.ID].Data = MergeMaps(db.Edges[e.ID].Data, e.Data)
+	if err := checkRev(e.ID, e.Rev, db.Edges[e.ID].Rev); err != nil {
+		return err
+	}
+
+	// merge the edge with the configured strategies
+	old := db.Edges[e.ID]
+	merged, err := db.mergeConfig().Merge(old.Collection, old.Data, e.Data)
+	if err != nil {
+		return err
+	}
+	old.Data = merged
+	db.recordEdge(history.Merge, old, e.Data)
+	db.assert(history.Merge, e.ID, history.EdgeKind, old.Collection, e.Data, src)
+	ei.(*Edge).Rev = old.Rev
 	return nil
 }
 
@@ -513,6 +789,11 @@ func (db *InMemoryDB) MergeEdge(ei handler.Edge) error {
 func (db *InMemoryDB) DeleteNode(name interface{}) error {
 	db.m.Lock()
 	defer db.m.Unlock()
+	return db.deleteNode(name)
+}
+
+// deleteNode is DeleteNode, the caller holds the lock
+func (db *InMemoryDB) deleteNode(name interface{}) error {
 
 	// check if the node name exists
 	if !db.checkNodeNameExists(name.(string)) {
@@ -524,6 +805,11 @@ func (db *InMemoryDB) DeleteNode(name interface{}) error {
 
 	// delete the node from the Nodes
 	delete(db.Nodes, id.(string))
+	// delete the time series of the node
+	delete(db.series, id.(string))
+	// close the versions of the node, the facts go with it
+	db.record(id.(string), nil, nil)
+	delete(db.facts, id.(string))
 	// delete the node name from the nodeNameSet
 	delete(db.nodeNameSet, name.(string))
 	// delete the node name from the NodeNameMap
@@ -536,17 +822,28 @@ func (db *InMemoryDB) DeleteNode(name interface{}) error {
 func (db *InMemoryDB) DeleteItemByID(id interface{}) error {
 	db.m.Lock()
 	defer db.m.Unlock()
+	return db.deleteItemByID(id)
+}
+
+// deleteItemByID is DeleteItemByID, the caller holds the lock
+func (db *InMemoryDB) deleteItemByID(id interface{}) error {
 
 	// check if the id exists
 	if _, ok := db.Nodes[id.(string)]; ok {
 		// delete the node from the Nodes
 		delete(db.Nodes, id.(string))
+		delete(db.series, id.(string))
+		db.record(id.(string), nil, nil)
+		delete(db.facts, id.(string))
 		return nil
 	}
 
 	if _, ok := db.Edges[id.(string)]; ok {
 		// delete the edge from the Edges
 		delete(db.Edges, id.(string))
+		delete(db.series, id.(string))
+		db.record(id.(string), nil, nil)
+		delete(db.facts, id.(string))
 		return nil
 	}
 
@@ -558,14 +855,19 @@ func (db *InMemoryDB) DeleteItemByID(id interface{}) error {
 func (db *InMemoryDB) GetItemByID(id interface{}) (interface{}, error) {
 	db.m.RLock()
 	defer db.m.RUnlock()
+	return db.getItemByID(id)
+}
+
+// getItemByID is GetItemByID, the caller holds the lock
+func (db *InMemoryDB) getItemByID(id interface{}) (interface{}, error) {
 
 	// check if the id exists
 	if node, ok := db.Nodes[id.(string)]; ok {
-		return node, nil
+		return copyNode(node), nil
 	}
 
 	if edge, ok := db.Edges[id.(string)]; ok {
-		return edge, nil
+		return db.edgeCopy(edge), nil
 	}
 
 	return nil, fmt.Errorf("item with ID %s does not exist", id)
@@ -575,6 +877,12 @@ func (db *InMemoryDB) GetItemByID(id interface{}) (interface{}, error) {
 func (db *InMemoryDB) GetNode(name interface{}) (handler.Node, error) {
 	db.m.RLock()
 	defer db.m.RUnlock()
+	return db.getNode(name)
+}
+
+// getNode is GetNode, the caller holds the lock
+func (db *InMemoryDB) getNode(name interface{}) (handler.Node, error) {
+	name = db.resolveName(name)
 
 	// check if the node name exists
 	if !db.checkNodeNameExists(name.(string)) {
@@ -584,23 +892,42 @@ func (db *InMemoryDB) GetNode(name interface{}) (handler.Node, error) {
 	// get the node id
 	id, _ := db.NodeNameMap.Get(name.(string))
 
-	return db.Nodes[id.(string)], nil
+	return db.nodeCopy(id.(string)), nil
 }
 
 // GetNodesByRegex(regex string) ([]Node, error)
 func (db *InMemoryDB) GetNodesByRegex(regex string) ([]handler.Node, error) {
 	db.m.RLock()
 	defer db.m.RUnlock()
+	return db.getNodesByRegex(regex)
+}
+
+// getNodesByRegex is GetNodesByRegex, the caller holds the lock
+func (db *InMemoryDB) getNodesByRegex(regex string) ([]handler.Node, error) {
 
+	// compile the regex once for all the names
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %v", err)
+	}
 	var result []handler.Node
-	for _, k := range db.NodeNameMap.Values() {
-		if match, _ := regexp.MatchString(regex, k.(string)); match {
-			key, found := db.NodeNameMap.GetKey(k)
+	// the NodeNameMap maps the names to the ids
+	for _, k := range db.NodeNameMap.Keys() {
+		if re.MatchString(k.(string)) {
+			id, found := db.NodeNameMap.Get(k)
 			if found {
-				result = append(result, db.Nodes[key.(string)])
+				result = append(result, db.nodeCopy(id.(string)))
 			}
 		}
 	}
+	// the nodes found by an alias
+	for _, name := range db.aliases.Match(re) {
+		if re.MatchString(name) {
+			continue
+		}
+		id, _ := db.NodeNameMap.Get(name)
+		result = append(result, db.nodeCopy(id.(string)))
+	}
 
 	return result, nil
 }
@@ -609,11 +936,16 @@ func (db *InMemoryDB) GetNodesByRegex(regex string) ([]handler.Node, error) {
 func (db *InMemoryDB) GetEdgesByRegex(regex string) ([]handler.Edge, error) {
 	db.m.RLock()
 	defer db.m.RUnlock()
+	return db.getEdgesByRegex(regex)
+}
+
+// getEdgesByRegex is GetEdgesByRegex, the caller holds the lock
+func (db *InMemoryDB) getEdgesByRegex(regex string) ([]handler.Edge, error) {
 
 	var result []handler.Edge
 	for _, edge := range db.Edges {
 		if match, _ := regexp.MatchString(regex, edge.Relationship); match {
-			result = append(result, edge)
+			result = append(result, db.edgeCopy(edge))
 		}
 	}
 
@@ -624,6 +956,12 @@ func (db *InMemoryDB) GetEdgesByRegex(regex string) ([]handler.Edge, error) {
 func (db *InMemoryDB) GetFromNodes(name interface{}) ([]handler.Node, error) {
 	db.m.RLock()
 	defer db.m.RUnlock()
+	return db.getFromNodes(name)
+}
+
+// getFromNodes is GetFromNodes, the caller holds the lock
+func (db *InMemoryDB) getFromNodes(name interface{}) ([]handler.Node, error) {
+	name = db.resolveName(name)
 
 	// check if the node name exists
 	if !db.checkNodeNameExists(name.(string)) {
@@ -636,7 +974,7 @@ func (db *InMemoryDB) GetFromNodes(name interface{}) ([]handler.Node, error) {
 	var result []handler.Node
 	for _, edge := range db.Edges {
 		if edge.From.ID == id {
-			result = append(result, edge.To)
+			result = append(result, db.nodeCopy(edge.To.ID))
 		}
 	}
 
@@ -647,6 +985,12 @@ func (db *InMemoryDB) GetFromNodes(name interface{}) ([]handler.Node, error) {
 func (db *InMemoryDB) GetToNodes(name interface{}) ([]handler.Node, error) {
 	db.m.RLock()
 	defer db.m.RUnlock()
+	return db.getToNodes(name)
+}
+
+// getToNodes is GetToNodes, the caller holds the lock
+func (db *InMemoryDB) getToNodes(name interface{}) ([]handler.Node, error) {
+	name = db.resolveName(name)
 
 	// check if the node name exists
 	if !db.checkNodeNameExists(name.(string)) {
@@ -659,7 +1003,7 @@ func (db *InMemoryDB) GetToNodes(name interface{}) ([]handler.Node, error) {
 	var result []handler.Node
 	for _, edge := range db.Edges {
 		if edge.To.ID == id {
-			result = append(result, edge.From)
+			result = append(result, db.nodeCopy(edge.From.ID))
 		}
 	}
 
@@ -670,6 +1014,12 @@ func (db *InMemoryDB) GetToNodes(name interface{}) ([]handler.Node, error) {
 func (db *InMemoryDB) GetInEdges(name interface{}) ([]handler.Edge, error) {
 	db.m.RLock()
 	defer db.m.RUnlock()
+	return db.getInEdges(name)
+}
+
+// getInEdges is GetInEdges, the caller holds the lock
+func (db *InMemoryDB) getInEdges(name interface{}) ([]handler.Edge, error) {
+	name = db.resolveName(name)
 
 	// check if the node name exists
 	if !db.checkNodeNameExists(name.(string)) {
@@ -682,7 +1032,7 @@ func (db *InMemoryDB) GetInEdges(name interface{}) ([]handler.Edge, error) {
 	var result []handler.Edge
 	for _, edge := range db.Edges {
 		if edge.To.ID == id {
-			result = append(result, edge)
+			result = append(result, db.edgeCopy(edge))
 		}
 	}
 
@@ -693,6 +1043,12 @@ func (db *InMemoryDB) GetInEdges(name interface{}) ([]handler.Edge, error) {
 func (db *InMemoryDB) GetOutEdges(name interface{}) ([]handler.Edge, error) {
 	db.m.RLock()
 	defer db.m.RUnlock()
+	return db.getOutEdges(name)
+}
+
+// getOutEdges is GetOutEdges, the caller holds the lock
+func (db *InMemoryDB) getOutEdges(name interface{}) ([]handler.Edge, error) {
+	name = db.resolveName(name)
 
 	// check if the node name exists
 	if !db.checkNodeNameExists(name.(string)) {
@@ -705,7 +1061,7 @@ func (db *InMemoryDB) GetOutEdges(name interface{}) ([]handler.Edge, error) {
 	var result []handler.Edge
 	for _, edge := range db.Edges {
 		if edge.From.ID == id {
-			result = append(result, edge)
+			result = append(result, db.edgeCopy(edge))
 		}
 	}
 
@@ -718,6 +1074,7 @@ func (db *InMemoryDB) GetOutEdges(name interface{}) ([]handler.Edge, error) {
 func (db *InMemoryDB) GetAllRelatedNodes(name interface{}) ([][]handler.Node, error) {
 	db.m.RLock()
 	defer db.m.RUnlock()
+	name = db.resolveName(name)
 
 	// check if the node name exists
 	if !db.checkNodeNameExists(name.(string)) {
@@ -735,6 +1092,7 @@ func (db *InMemoryDB) GetAllRelatedNodes(name interface{}) ([][]handler.Node, er
 func (db *InMemoryDB) GetAllRelatedNodesInEdgeSlice(name interface{}, edgeSlice ... handler.Edge) ([][]handler.Node, error) {
 	db.m.RLock()
 	defer db.m.RUnlock()
+	name = db.resolveName(name)
 
 	// check if the node name exists
 	if !db.checkNodeNameExists(name.(string)) {
@@ -778,6 +1136,7 @@ func (db *InMemoryDB) GetAllRelatedNodesInEdgeSlice(name interface{}, edgeSlice
 func (db *InMemoryDB) GetAllRelatedNodesInRange(name interface{}, max int) ([][]handler.Node, error) {
 	db.m.RLock()
 	defer db.m.RUnlock()
+	name = db.resolveName(name)
 
 	// check if the node name exists
 	if !db.checkNodeNameExists(name.(string)) {
@@ -807,7 +1166,7 @@ func (db *InMemoryDB) GetAllRelatedNodesInRange(name interface{}, max int) ([][]
 			// remove the node from the queue
 			queue = queue[1:]
 			// add the node to the level
-			level = append(level, node)
+			level = append(level, copyNode(node))
 			// get the related nodes
 			for _, edge := range db.Edges {
 				if edge.From.ID == node.ID {
@@ -831,6 +1190,38 @@ func (db *InMemoryDB) GetAllRelatedNodesInRange(name interface{}, max int) ([][]
 
 // ~ 01 Fundamental Function Section
 
+// nodeCopy returns a copy of the stored node of the id, the caller holds the lock
+func (db *InMemoryDB) nodeCopy(id string) *Node {
+	return copyNode(db.Nodes[id])
+}
+
+// edgeCopy returns a copy of the stored edge, its From and To copies of the stored nodes, the caller holds the lock
+// % the edge keeps the pointers of its nodes at add time, a replaced node is only in db.Nodes
+func (db *InMemoryDB) edgeCopy(e *Edge) *Edge {
+	c := *e
+	c.Data = copyData(e.Data)
+	if n, ok := db.Nodes[e.From.ID]; ok {
+		c.From = copyNode(n)
+	} else {
+		c.From = copyNode(e.From)
+	}
+	if n, ok := db.Nodes[e.To.ID]; ok {
+		c.To = copyNode(n)
+	} else {
+		c.To = copyNode(e.To)
+	}
+	return &c
+}
+
+// checkRev returns a handler.ConflictError if the write gives a revision the item does not have
+// a write without revision applies anyway
+func checkRev(id string, given, current uint64) error {
+	if given != 0 && given != current {
+		return &handler.ConflictError{ID: id, Expected: strconv.FormatUint(given, 10), Actual: strconv.FormatUint(current, 10)}
+	}
+	return nil
+}
+
 // MergeMaps merges two maps and returns the result
 func MergeMaps(map1, map2 map[string]interface{}) map[string]interface{} {
 	merged := make(map[string]interface{})
@@ -847,6 +1238,14 @@ func MergeMaps(map1, map2 map[string]interface{}) map[string]interface{} {
 }
 
 
+// mergeConfig returns the merge strategies, the default ones if not initialized
+func (db *InMemoryDB) mergeConfig() *merge.Config {
+	if db.merger == nil {
+		return merge.Default()
+	}
+	return db.merger
+}
+
 // BFS 实现广度优先搜索
 // BFSWithLevels 实现广度优先搜索并返回每一层的节点
 func (db *InMemoryDB) BFSWithLevels(startID string) ([][]handler.Node, error) {
@@ -875,7 +1274,7 @@ func (db *InMemoryDB) BFSWithLevels(startID string) ([][]handler.Node, error) {
 			// remove the node from the queue
 			queue = queue[1:]
 			// add the node to the level
-			level = append(level, node)
+			level = append(level, copyNode(node))
 			// get the related nodes
 			for _, edge := range db.Edges {
 				if edge.From.ID == node.ID {
@@ -0,0 +1,170 @@
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+)
+
+// the InMemoryDB keeps the bitemporal history
+var _ handler.TemporalGraph = (*InMemoryDB)(nil)
+
+// historyFile is where the versions are written under the dataPath
+func (db *InMemoryDB) historyFile() string {
+	return filepath.Join(db.configPath, handler.HistoryCollection, "history.json")
+}
+
+// loadHistory reads the versions written by saveHistory
+func (db *InMemoryDB) loadHistory() error {
+	if _, err := os.Stat(db.historyFile()); os.IsNotExist(err) {
+		return nil
+	}
+	file, err := os.ReadFile(db.historyFile())
+	if err != nil {
+		return err
+	}
+	versions := make(map[string][]history.Version)
+	if err := json.Unmarshal(file, &versions); err != nil {
+		return err
+	}
+	db.history = versions
+	return nil
+}
+
+// saveHistory writes the versions next to the collections
+// an empty store still overwrites the file so purged versions do not come back
+func (db *InMemoryDB) saveHistory() error {
+	if len(db.history) == 0 {
+		if _, err := os.Stat(db.historyFile()); os.IsNotExist(err) {
+			return nil
+		}
+	}
+	return WriteJSONFile(db.historyFile(), db.history)
+}
+
+// record stores the version changes of a write, the caller holds the lock
+// next is nil for a delete
+func (db *InMemoryDB) record(itemID string, next *history.Version, data map[string]interface{}) {
+	txTime := history.Now()
+	var current []history.Version
+	for _, v := range db.history[itemID] {
+		if v.Current() {
+			current = append(current, v)
+		}
+	}
+	closed, inserted := history.Record(current, next, history.ValidTime(data, txTime), txTime)
+	versions := db.history[itemID]
+	for _, c := range closed {
+		for i := range versions {
+			if versions[i].ID == c.ID {
+				versions[i] = c
+			}
+		}
+	}
+	db.history[itemID] = append(versions, inserted...)
+	db.publish(handler.NewChangeEvent(itemID, current, next, txTime))
+	// the alias, search and secondary indexes follow the current version
+	db.aliases.Track(itemID, next, handler.AliasesKey)
+	db.indexVersion(itemID, next)
+	db.indexItem(itemID, next)
+}
+
+// recordNode stores the current state of the node as a new version, a write more for its revision
+// written is the data of the write, its ValidFromKey gives the valid time
+func (db *InMemoryDB) recordNode(op history.Op, n *Node, written map[string]interface{}) {
+	n.Rev++
+	db.record(n.ID, history.NodeVersion(op, n.ID, n.Collection, n.Name, n.Data), written)
+}
+
+// recordEdge stores the current state of the edge as a new version, a write more for its revision
+func (db *InMemoryDB) recordEdge(op history.Op, e *Edge, written map[string]interface{}) {
+	e.Rev++
+	db.record(e.ID, history.EdgeVersion(op, e.ID, e.Collection, e.Relationship, e.From.ID, e.To.ID, e.Data), written)
+}
+
+// allVersions returns the versions of every item
+func (db *InMemoryDB) allVersions() []history.Version {
+	var res []history.Version
+	for _, versions := range db.history {
+		res = append(res, versions...)
+	}
+	return res
+}
+
+// versionToNode rebuilds the node of a version
+func versionToNode(v history.Version) *Node {
+	return &Node{ID: v.ItemID, Collection: v.Collection, Name: v.Name, Data: MergeMaps(v.Data, nil)}
+}
+
+// GetNodeAsOf(name interface{}, t time.Time) (handler.Node, error)
+func (db *InMemoryDB) GetNodeAsOf(name interface{}, t time.Time) (handler.Node, error) {
+	return db.GetNodeAt(name, t, t)
+}
+
+// GetNodeAt(name interface{}, validAt, knownAt time.Time) (handler.Node, error)
+func (db *InMemoryDB) GetNodeAt(name interface{}, validAt, knownAt time.Time) (handler.Node, error) {
+	db.m.RLock()
+	defer db.m.RUnlock()
+
+	nameStr, ok := name.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid name")
+	}
+	v, ok := history.FindNode(db.allVersions(), nameStr, validAt, knownAt)
+	if !ok {
+		return nil, fmt.Errorf("node with name %s does not exist at %v", nameStr, validAt)
+	}
+	return versionToNode(v), nil
+}
+
+// TraverseAsOf(t time.Time, name interface{}) ([][]handler.Node, error)
+// the edges are followed from From to To like GetAllRelatedNodes
+func (db *InMemoryDB) TraverseAsOf(t time.Time, name interface{}) ([][]handler.Node, error) {
+	db.m.RLock()
+	defer db.m.RUnlock()
+
+	nameStr, ok := name.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid name")
+	}
+	versions := db.allVersions()
+	start, ok := history.FindNode(versions, nameStr, t, t)
+	if !ok {
+		return nil, fmt.Errorf("node with name %s does not exist at %v", nameStr, t)
+	}
+
+	snapshot := history.Snapshot(versions, t, t)
+	var result [][]handler.Node
+	for _, level := range history.Traverse(start.ItemID, snapshot, true) {
+		nodes := make([]handler.Node, len(level))
+		for i, id := range level {
+			nodes[i] = versionToNode(snapshot[id])
+		}
+		result = append(result, nodes)
+	}
+	return result, nil
+}
+
+// GetHistory(id interface{}) ([]history.Version, error)
+func (db *InMemoryDB) GetHistory(id interface{}) ([]history.Version, error) {
+	db.m.RLock()
+	defer db.m.RUnlock()
+
+	idStr, ok := id.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid id")
+	}
+	versions, ok := db.history[idStr]
+	if !ok {
+		return nil, fmt.Errorf("item with ID %s has no history", idStr)
+	}
+	res := append([]history.Version(nil), versions...)
+	sort.SliceStable(res, func(i, j int) bool { return res[i].TxFrom.Before(res[j].TxFrom) })
+	return res, nil
+}
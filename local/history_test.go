@@ -0,0 +1,94 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/history"
+)
+
+func TestHistory(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.WriteFile(cfg, []byte("dataPath: "+filepath.Join(dir, "data")+"\n"), 0644))
+
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	defer func(now func() time.Time) { history.Now = now }(history.Now)
+	setDay := func(d int) { history.Now = func() time.Time { return day(d) } }
+
+	db, err := NewInMemoryDB()
+	assert.NoError(t, err)
+	assert.NoError(t, db.Init(cfg))
+
+	setDay(1)
+	a, _ := NewNode(WithNID("n1"), WithNCollection("company"), WithNName("600001"),
+		WithNData(map[string]interface{}{"revenue": 100.0}))
+	b, _ := NewNode(WithNID("n2"), WithNCollection("company"), WithNName("600002"))
+	_, err = db.AddNode(a)
+	assert.NoError(t, err)
+	_, err = db.AddNode(b)
+	assert.NoError(t, err)
+
+	setDay(5)
+	edge, _ := NewEdge(WithEID("e1"), WithECollection("invest"), WithEName("invest"), WithEFrom(a), WithETo(b))
+	_, err = db.AddEdge(edge)
+	assert.NoError(t, err)
+
+	// day 10: a correction, revenue was 110 since day 3
+	setDay(10)
+	upd, _ := NewNode(WithNID("n1"), WithNCollection("company"), WithNName("600001"),
+		WithNData(map[string]interface{}{"revenue": 110.0, history.ValidFromKey: "2024-01-03"}))
+	assert.NoError(t, db.UpdateNode(upd))
+
+	// what we believed on day 6 about day 4, and what we believe now
+	n, err := db.GetNodeAt("600001", day(4), day(6))
+	assert.NoError(t, err)
+	assert.Equal(t, 100.0, n.(*Node).Data["revenue"])
+	n, err = db.GetNodeAsOf("600001", day(11))
+	assert.NoError(t, err)
+	assert.Equal(t, 110.0, n.(*Node).Data["revenue"])
+	_, err = db.GetNodeAsOf("600001", day(0))
+	assert.Error(t, err)
+
+	// the edge only exists from day 5
+	levels, err := db.TraverseAsOf(day(4), "600001")
+	assert.NoError(t, err)
+	assert.Len(t, levels, 1)
+	levels, err = db.TraverseAsOf(day(6), "600001")
+	assert.NoError(t, err)
+	assert.Len(t, levels, 2)
+	assert.Equal(t, "600002", levels[1][0].(*Node).Name)
+
+	// day 12: the edge is deleted, the past traversal is kept
+	setDay(12)
+	assert.NoError(t, db.DeleteItemByID("e1"))
+	levels, err = db.TraverseAsOf(day(13), "600001")
+	assert.NoError(t, err)
+	assert.Len(t, levels, 1)
+	levels, err = db.TraverseAsOf(day(6), "600001")
+	assert.NoError(t, err)
+	assert.Len(t, levels, 2)
+
+	// the history survives a disconnect and connect
+	assert.NoError(t, db.Disconnect())
+	db2, _ := NewInMemoryDB()
+	assert.NoError(t, db2.Init(cfg))
+	assert.NoError(t, db2.Connect())
+	versions, err := db2.GetHistory("n1")
+	assert.NoError(t, err)
+	// create, the kept part before day 3 and the correction
+	assert.Len(t, versions, 3)
+	assert.Equal(t, history.Create, versions[0].Op)
+	n, err = db2.GetNodeAt("600001", day(4), day(6))
+	assert.NoError(t, err)
+	assert.Equal(t, 100.0, n.(*Node).Data["revenue"])
+
+	// purged versions stay purged after a restart
+	clear(db2.history)
+	db3 := reconnect(t, db2, cfg)
+	_, err = db3.GetHistory("n1")
+	assert.Error(t, err)
+}
@@ -0,0 +1,411 @@
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/emirpasic/gods/trees/redblacktree"
+	"github.com/wonderstone/chainstorm/filter"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+)
+
+// IndexKind is the structure of a secondary index
+type IndexKind string
+
+const (
+	// HashIndex answers Eq and In
+	HashIndex IndexKind = "hash"
+	// OrderedIndex answers the ranges too, it is a red-black tree
+	OrderedIndex IndexKind = "ordered"
+)
+
+// IndexDef declares a secondary index on a Data path of the items of a collection
+type IndexDef struct {
+	Collection string    `json:"collection"`
+	Path       string    `json:"path"`
+	Kind       IndexKind `json:"kind"`
+}
+
+// Validate checks the collection, the path and the kind
+func (d IndexDef) Validate() error {
+	if d.Collection == "" {
+		return fmt.Errorf("index collection is required")
+	}
+	if err := filter.Exists(d.Path).Validate(); err != nil {
+		return err
+	}
+	if d.Kind != HashIndex && d.Kind != OrderedIndex {
+		return fmt.Errorf("unknown index kind %q", d.Kind)
+	}
+	return nil
+}
+
+// indexKey is an indexed value, the numbers of any Go type are float64
+// only the numbers, the strings and the booleans are indexed, like filter.Equal compares them
+type indexKey struct {
+	kind byte // 'n' number, 's' string, 'b' bool
+	f    float64
+	s    string
+	b    bool
+}
+
+// toIndexKey returns the key of a value, ok is false for the values not indexed
+func toIndexKey(v interface{}) (indexKey, bool) {
+	switch x := v.(type) {
+	case string:
+		return indexKey{kind: 's', s: x}, true
+	case bool:
+		return indexKey{kind: 'b', b: x}, true
+	case nil:
+		return indexKey{}, false
+	}
+	if f, ok := filter.Number(v); ok {
+		return indexKey{kind: 'n', f: f}, true
+	}
+	return indexKey{}, false
+}
+
+// orderedKey is a key of the tree, the ID keeps the equal values apart
+type orderedKey struct {
+	key indexKey
+	id  string
+}
+
+// compareOrdered orders the numbers before the strings, then by value, then by ID
+// % the booleans are not in the tree
+func compareOrdered(a, b interface{}) int {
+	x, y := a.(orderedKey), b.(orderedKey)
+	if x.key.kind != y.key.kind {
+		if x.key.kind == 'n' {
+			return -1
+		}
+		return 1
+	}
+	var c int
+	if x.key.kind == 'n' {
+		c, _ = filter.Compare(x.key.f, y.key.f)
+	} else {
+		c, _ = filter.Compare(x.key.s, y.key.s)
+	}
+	if c != 0 {
+		return c
+	}
+	switch {
+	case x.id < y.id:
+		return -1
+	case x.id > y.id:
+		return 1
+	}
+	return 0
+}
+
+// propIndex is a secondary index, maintained by record under the lock of the db
+type propIndex struct {
+	def IndexDef
+	// hash is value -> item IDs
+	hash map[indexKey]map[string]void
+	// tree holds the orderedKeys of the numbers and the strings
+	tree *redblacktree.Tree
+	// items is item ID -> indexed value, to remove an item whatever its data now
+	items map[string]indexKey
+}
+
+func newPropIndex(def IndexDef) *propIndex {
+	ix := &propIndex{def: def, items: make(map[string]indexKey)}
+	if def.Kind == OrderedIndex {
+		ix.tree = redblacktree.NewWith(compareOrdered)
+	} else {
+		ix.hash = make(map[indexKey]map[string]void)
+	}
+	return ix
+}
+
+// put indexes the value of the item, replacing its former one
+func (ix *propIndex) put(id string, data map[string]interface{}) {
+	ix.remove(id)
+	v, _ := filter.Get(data, ix.def.Path)
+	key, ok := toIndexKey(v)
+	if !ok {
+		return
+	}
+	ix.items[id] = key
+	if ix.tree != nil {
+		if key.kind != 'b' {
+			ix.tree.Put(orderedKey{key, id}, void{})
+		}
+		return
+	}
+	if ix.hash[key] == nil {
+		ix.hash[key] = make(map[string]void)
+	}
+	ix.hash[key][id] = void{}
+}
+
+// remove drops the item
+func (ix *propIndex) remove(id string) {
+	key, ok := ix.items[id]
+	if !ok {
+		return
+	}
+	delete(ix.items, id)
+	if ix.tree != nil {
+		ix.tree.Remove(orderedKey{key, id})
+		return
+	}
+	delete(ix.hash[key], id)
+	if len(ix.hash[key]) == 0 {
+		delete(ix.hash, key)
+	}
+}
+
+// scan adds to ids the items of the tree from the lower to the upper bound, nil for no bound
+// the bounds are of the same kind, the scan stops at the end of that kind
+func (ix *propIndex) scan(ids map[string]void, lo *indexKey, loIncl bool, hi *indexKey, hiIncl bool, kind byte) {
+	var start *redblacktree.Node
+	switch {
+	case lo != nil:
+		start, _ = ix.tree.Ceiling(orderedKey{key: *lo})
+	case kind == 's':
+		start, _ = ix.tree.Ceiling(orderedKey{key: indexKey{kind: 's'}})
+	default:
+		start = ix.tree.Left()
+	}
+	if start == nil {
+		return
+	}
+	it := ix.tree.IteratorAt(start)
+	for ok := true; ok; ok = it.Next() {
+		k := it.Key().(orderedKey)
+		if k.key.kind != kind {
+			return
+		}
+		if lo != nil && !loIncl && compareOrdered(orderedKey{key: k.key}, orderedKey{key: *lo}) == 0 {
+			continue
+		}
+		if hi != nil {
+			c := compareOrdered(orderedKey{key: k.key}, orderedKey{key: *hi})
+			if c > 0 || (c == 0 && !hiIncl) {
+				return
+			}
+		}
+		ids[k.id] = void{}
+	}
+}
+
+// lookup returns the items having one of the values
+func (ix *propIndex) lookup(values ...interface{}) (map[string]void, bool) {
+	ids := make(map[string]void)
+	for _, v := range values {
+		key, ok := toIndexKey(v)
+		if !ok {
+			// % Eq nil matches the items without the field, they are not indexed
+			return nil, false
+		}
+		if ix.tree == nil {
+			for id := range ix.hash[key] {
+				ids[id] = void{}
+			}
+			continue
+		}
+		if key.kind == 'b' {
+			return nil, false
+		}
+		ix.scan(ids, &key, true, &key, true, key.kind)
+	}
+	return ids, true
+}
+
+// rangeLookup returns the items of a range comparison, only an ordered index answers it
+func (ix *propIndex) rangeLookup(op filter.Op, v interface{}) (map[string]void, bool) {
+	key, ok := toIndexKey(v)
+	if ix.tree == nil || !ok || key.kind == 'b' {
+		return nil, false
+	}
+	ids := make(map[string]void)
+	switch op {
+	case filter.OpGt, filter.OpGte:
+		ix.scan(ids, &key, op == filter.OpGte, nil, false, key.kind)
+	default:
+		ix.scan(ids, nil, false, &key, op == filter.OpLte, key.kind)
+	}
+	return ids, true
+}
+
+// candidates returns the items of the collection the filter may match, found with the indexes
+// ok is false when the indexes can not narrow the filter and the collection has to be scanned
+func (db *InMemoryDB) candidates(collection string, f filter.Expr) (map[string]void, bool) {
+	switch f.Op {
+	case filter.OpAnd:
+		// the smallest set of the indexed conditions, the others are checked by Match
+		var best map[string]void
+		for _, sub := range f.Exprs {
+			if ids, ok := db.candidates(collection, sub); ok && (best == nil || len(ids) < len(best)) {
+				best = ids
+			}
+		}
+		return best, best != nil
+	case filter.OpOr:
+		if len(f.Exprs) == 0 {
+			return nil, false
+		}
+		union := make(map[string]void)
+		for _, sub := range f.Exprs {
+			ids, ok := db.candidates(collection, sub)
+			if !ok {
+				return nil, false
+			}
+			for id := range ids {
+				union[id] = void{}
+			}
+		}
+		return union, true
+	}
+
+	ix, ok := db.indexes[collection][f.Path]
+	if !ok {
+		return nil, false
+	}
+	switch f.Op {
+	case filter.OpEq:
+		return ix.lookup(f.Value)
+	case filter.OpIn:
+		return ix.lookup(filter.Values(f.Value)...)
+	case filter.OpGt, filter.OpGte, filter.OpLt, filter.OpLte:
+		return ix.rangeLookup(f.Op, f.Value)
+	}
+	return nil, false
+}
+
+// indexItem follows a write, next is nil for a delete, the caller holds the lock
+func (db *InMemoryDB) indexItem(itemID string, next *history.Version) {
+	// # the item may have changed collection, it is removed from all
+	for _, paths := range db.indexes {
+		for _, ix := range paths {
+			ix.remove(itemID)
+		}
+	}
+	if next == nil {
+		return
+	}
+	for _, ix := range db.indexes[next.Collection] {
+		ix.put(itemID, next.Data)
+	}
+}
+
+// build indexes the items already in the collection, the caller holds the lock
+func (db *InMemoryDB) build(ix *propIndex) {
+	for id, n := range db.Nodes {
+		if n.Collection == ix.def.Collection {
+			ix.put(id, n.Data)
+		}
+	}
+	for id, e := range db.Edges {
+		if e.Collection == ix.def.Collection {
+			ix.put(id, e.Data)
+		}
+	}
+}
+
+// CreateIndex declares a secondary index and builds it
+func (db *InMemoryDB) CreateIndex(def IndexDef) error {
+	if err := def.Validate(); err != nil {
+		return err
+	}
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	if _, ok := db.indexes[def.Collection][def.Path]; ok {
+		return fmt.Errorf("index on %s.%s already exists", def.Collection, def.Path)
+	}
+	ix := newPropIndex(def)
+	db.build(ix)
+	if db.indexes[def.Collection] == nil {
+		db.indexes[def.Collection] = make(map[string]*propIndex)
+	}
+	db.indexes[def.Collection][def.Path] = ix
+	return nil
+}
+
+// DropIndex removes the index on the path of the collection
+func (db *InMemoryDB) DropIndex(collection, path string) error {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	if _, ok := db.indexes[collection][path]; !ok {
+		return fmt.Errorf("index on %s.%s does not exist", collection, path)
+	}
+	delete(db.indexes[collection], path)
+	if len(db.indexes[collection]) == 0 {
+		delete(db.indexes, collection)
+	}
+	return nil
+}
+
+// Indexes returns the declared indexes sorted by collection and path
+func (db *InMemoryDB) Indexes() []IndexDef {
+	db.m.RLock()
+	defer db.m.RUnlock()
+
+	defs := []IndexDef{}
+	for _, paths := range db.indexes {
+		for _, ix := range paths {
+			defs = append(defs, ix.def)
+		}
+	}
+	sort.Slice(defs, func(i, j int) bool {
+		if defs[i].Collection != defs[j].Collection {
+			return defs[i].Collection < defs[j].Collection
+		}
+		return defs[i].Path < defs[j].Path
+	})
+	return defs
+}
+
+// indexFile is where the index definitions are written under the dataPath
+func (db *InMemoryDB) indexFile() string {
+	return filepath.Join(db.configPath, handler.ReservedPrefix+"indexes", "indexes.json")
+}
+
+// loadIndexes reads the definitions written by saveIndexes and builds the indexes
+// without the file, the indexes declared before Connect are rebuilt
+func (db *InMemoryDB) loadIndexes() error {
+	defs := db.Indexes()
+	if _, err := os.Stat(db.indexFile()); err == nil {
+		file, err := os.ReadFile(db.indexFile())
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(file, &defs); err != nil {
+			return err
+		}
+	}
+	db.indexes = make(map[string]map[string]*propIndex)
+	for _, def := range defs {
+		if err := def.Validate(); err != nil {
+			return err
+		}
+		ix := newPropIndex(def)
+		db.build(ix)
+		if db.indexes[def.Collection] == nil {
+			db.indexes[def.Collection] = make(map[string]*propIndex)
+		}
+		db.indexes[def.Collection][def.Path] = ix
+	}
+	return nil
+}
+
+// saveIndexes writes the index definitions next to the collections
+// the indexes themselves are rebuilt on Connect
+func (db *InMemoryDB) saveIndexes() error {
+	defs := db.Indexes()
+	if len(defs) == 0 {
+		if _, err := os.Stat(db.indexFile()); os.IsNotExist(err) {
+			return nil
+		}
+	}
+	return WriteJSONFile(db.indexFile(), defs)
+}
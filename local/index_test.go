@@ -0,0 +1,84 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/filter"
+)
+
+func TestIndexes(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.WriteFile(cfg, []byte("dataPath: "+filepath.Join(dir, "data")+"\n"), 0644))
+
+	db, err := NewInMemoryDB()
+	assert.NoError(t, err)
+	assert.NoError(t, db.Init(cfg))
+	for _, n := range []struct {
+		id      string
+		revenue interface{}
+		country string
+	}{{"a", 2e6, "USA"}, {"b", 500000, "USA"}, {"c", 3e6, "CN"}, {"d", "n/a", "CN"}} {
+		node, _ := NewNode(WithNID(n.id), WithNCollection("company"), WithNName("company-"+n.id),
+			WithNData(map[string]interface{}{"companyRevenue": n.revenue, "companyCountry": n.country}))
+		_, err := db.AddNode(node)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, db.CreateIndex(IndexDef{Collection: "company", Path: "companyCountry", Kind: HashIndex}))
+	assert.NoError(t, db.CreateIndex(IndexDef{Collection: "company", Path: "companyRevenue", Kind: OrderedIndex}))
+	assert.Error(t, db.CreateIndex(IndexDef{Collection: "company", Path: "companyCountry", Kind: HashIndex}))
+	assert.Error(t, db.CreateIndex(IndexDef{Collection: "company", Path: "x", Kind: "bitmap"}))
+
+	ids := func(f filter.Expr) []string {
+		nodes, err := db.FindNodes("company", f)
+		assert.NoError(t, err)
+		res := []string{}
+		for _, n := range nodes {
+			res = append(res, n.(*Node).ID)
+		}
+		return res
+	}
+	used := func(f filter.Expr) bool {
+		_, ok := db.candidates("company", f)
+		return ok
+	}
+
+	f := filter.And(filter.Gt("companyRevenue", 1e6), filter.Eq("companyCountry", "USA"))
+	assert.True(t, used(f))
+	assert.Equal(t, []string{"a"}, ids(f))
+	assert.Equal(t, []string{"a", "c"}, ids(filter.Gte("companyRevenue", 2000000)))
+	assert.Equal(t, []string{"b"}, ids(filter.Lt("companyRevenue", 2e6)))
+	assert.Equal(t, []string{"d"}, ids(filter.Gt("companyRevenue", "")))
+	assert.Equal(t, []string{"c", "d"}, ids(filter.In("companyCountry", "CN", "JP")))
+	// the ones without an index are scanned
+	assert.False(t, used(filter.Ne("companyCountry", "USA")))
+	assert.False(t, used(filter.Or(filter.Eq("companyCountry", "USA"), filter.Exists("ceo"))))
+	assert.Equal(t, []string{"c", "d"}, ids(filter.Ne("companyCountry", "USA")))
+
+	// the writes maintain the indexes
+	b := &Node{ID: "b", Collection: "company", Name: "company-b",
+		Data: map[string]interface{}{"companyRevenue": 4e6, "companyCountry": "USA"}}
+	assert.NoError(t, db.ReplaceNode(b))
+	assert.Equal(t, []string{"a", "b"}, ids(f))
+	assert.NoError(t, db.DeleteNode("company-a"))
+	assert.Equal(t, []string{"b"}, ids(f))
+
+	// the definitions are saved under the dataPath, the indexes rebuilt on Connect
+	assert.NoError(t, db.Disconnect())
+	db2, err := NewInMemoryDB()
+	assert.NoError(t, err)
+	assert.NoError(t, db2.Init(cfg))
+	assert.NoError(t, db2.Connect())
+	assert.Len(t, db2.Indexes(), 2)
+	db = db2
+	assert.True(t, used(f))
+	assert.Equal(t, []string{"b"}, ids(f))
+
+	assert.NoError(t, db.DropIndex("company", "companyRevenue"))
+	assert.Error(t, db.DropIndex("company", "companyRevenue"))
+	assert.Equal(t, []IndexDef{{Collection: "company", Path: "companyCountry", Kind: HashIndex}}, db.Indexes())
+}
@@ -5,6 +5,15 @@ import (
 	"sync"
 
 	"github.com/emirpasic/gods/maps/hashbidimap"
+	"github.com/wonderstone/chainstorm/changes"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"github.com/wonderstone/chainstorm/merge"
+	"github.com/wonderstone/chainstorm/provenance"
+	"github.com/wonderstone/chainstorm/resolve"
+	"github.com/wonderstone/chainstorm/search"
+	"github.com/wonderstone/chainstorm/timeseries"
+	"github.com/wonderstone/chainstorm/tools"
 
 	"encoding/json"
 	"io"
@@ -29,6 +38,8 @@ type Node struct {
 	Collection string                 `json:"Collection"`   // 节点所属的集合
 	Name       string                 `json:"Name"`         // 节点的名称
 	Data       map[string]interface{} `json:"Data"`         // 节点存储的数据
+	// Rev counts the writes of the node in memory, a write given it applies only if the node still has it
+	Rev uint64 `json:"-"`
 }
 
 type NOption func(*Node)
@@ -86,7 +97,7 @@ func NewNode(options ...NOption) (*Node, error) {
 
 // Export 用于导出节点的数据 in json format
 func (n *Node) Export() map[string]interface{} {
-	tmp := n.Data
+	tmp := MergeMaps(n.Data, nil)
 	tmp["ID"] = n.ID
 	tmp["Collection"] = n.Collection
 	tmp["Name"] = n.Name
@@ -107,13 +118,15 @@ type Edge struct {
 	From         *Node                  // 起始节点
 	To           *Node                  // 目标节点
 	Data         map[string]interface{} // 节点存储的数据
+	// Rev counts the writes of the edge in memory, a write given it applies only if the edge still has it
+	Rev uint64
 }
 
 // EdgeJSON is an intermediate struct for JSON deserialization
 type EdgeJSON struct {
 	ID           string                 `json:"ID"`
 	Collection   string                 `json:"Collection"`
-	Relationship string                 `json:"Name"`
+	Relationship string                 `json:"Relationship"`
 	From         string                 `json:"From"`
 	To           string                 `json:"To"`
 	Data         map[string]interface{} `json:"Data"`
@@ -195,7 +208,7 @@ func NewEdge(options ...EOption) (*Edge, error) {
 }
 
 func (e *Edge) Export() map[string]interface{} {
-	tmp := e.Data
+	tmp := MergeMaps(e.Data, nil)
 	tmp["ID"] = e.ID
 	tmp["Collection"] = e.Collection
 	tmp["Relationship"] = e.Relationship
@@ -215,8 +228,43 @@ func (e *Edge) ExportJSON() *EdgeJSON {
 	}
 }
 
+// copyNode returns a copy of the node with its own Data
+// the reads hand out copies, so a node read keeps the revision it was read at
+func copyNode(n *Node) *Node {
+	c := *n
+	c.Data = copyData(n.Data)
+	return &c
+}
+
+// copyData returns a copy of the data, the nested maps and slices copied too
+func copyData(data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		return nil
+	}
+	res := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		res[k] = copyValue(v)
+	}
+	return res
+}
+
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return copyData(v)
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, x := range v {
+			res[i] = copyValue(x)
+		}
+		return res
+	default:
+		return v
+	}
+}
+
 func (ej *EdgeJSON) Export() map[string]interface{} {
-	tmp := ej.Data
+	tmp := MergeMaps(ej.Data, nil)
 	tmp["ID"] = ej.ID
 	tmp["Collection"] = ej.Collection
 	tmp["Relationship"] = ej.Relationship
@@ -284,6 +332,37 @@ type InMemoryDB struct {
 
 	// BidiMap for ID : NodeName and ID : EdgeName
 	NodeNameMap *hashbidimap.Map
+
+	// merger decides how MergeNode and MergeEdge combine the data field
+	merger *merge.Config
+
+	// series stores the time series properties, item ID -> metric -> points sorted by time
+	series map[string]map[string][]timeseries.Point
+
+	// history stores the bitemporal versions, item ID -> versions
+	history map[string][]history.Version
+
+	// facts stores the provenance, item ID -> facts
+	facts map[string][]provenance.Fact
+
+	// aliases maps the aliases in the Data to the node names
+	aliases resolve.AliasIndex
+
+	// index is the full-text index of the nodes
+	index *search.Index
+
+	// indexes are the secondary indexes on the Data, collection -> path -> index
+	indexes map[string]map[string]*propIndex
+
+	// feed is the change feed, pending holds the events of the running transaction
+	feed    *changes.Bus
+	pending []handler.ChangeEvent
+
+	// logger writes nothing until Init reads the "logger" section
+	logger *tools.Logger
+
+	// files are the node and edge files Connect read or Disconnect wrote
+	files map[string]void
 }
 
 func NewInMemoryDB() (*InMemoryDB, error) {
@@ -294,6 +373,24 @@ func NewInMemoryDB() (*InMemoryDB, error) {
 		nodeNameSet: make(map[string]void),
 
 		NodeNameMap: hashbidimap.New(),
+
+		merger: merge.Default(),
+
+		series: make(map[string]map[string][]timeseries.Point),
+
+		history: make(map[string][]history.Version),
+
+		facts: make(map[string][]provenance.Fact),
+
+		index: search.NewIndex(nil),
+
+		indexes: make(map[string]map[string]*propIndex),
+
+		feed: changes.NewBus(changes.DefaultSize),
+
+		logger: tools.NopLogger(),
+
+		files: make(map[string]void),
 	}
 
 	// Check if any of the initializations failed
@@ -304,6 +401,13 @@ func NewInMemoryDB() (*InMemoryDB, error) {
 	return db, nil
 }
 
+// SetMergeConfig replaces the merge strategies used by MergeNode and MergeEdge
+func (db *InMemoryDB) SetMergeConfig(c *merge.Config) {
+	db.m.Lock()
+	defer db.m.Unlock()
+	db.merger = c
+}
+
 // Export 用于导出NodeNameMap 的数据 in json format
 func (db *InMemoryDB) Export() map[string]interface{} {
 	tmp := make(map[string]interface{})
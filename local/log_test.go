@@ -0,0 +1,40 @@
+package local
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+func TestLogger(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.yaml")
+	logPath := filepath.Join(dir, "logs", "local.log")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "data"), 0755))
+	assert.NoError(t, os.WriteFile(cfg, []byte("dataPath: "+filepath.Join(dir, "data")+"\n"+
+		"logger:\n  enabled: true\n  level: info\n  output: "+logPath+"\n"), 0644))
+
+	db, err := NewInMemoryDB()
+	assert.NoError(t, err)
+	assert.NoError(t, db.Init(cfg))
+	assert.NoError(t, db.Connect())
+	err = db.Update(func(tx handler.Tx) error { return errors.New("nope") })
+	assert.Error(t, err)
+	assert.NoError(t, db.Disconnect())
+
+	b, err := os.ReadFile(logPath)
+	assert.NoError(t, err)
+	logs := string(b)
+	assert.Contains(t, logs, `"message":"InMemoryDB initialized"`)
+	assert.Contains(t, logs, `"message":"InMemoryDB connected"`)
+	assert.Contains(t, logs, `"level":"warn","error":"nope"`)
+	assert.Contains(t, logs, `"message":"InMemoryDB disconnected"`)
+
+	// a mistyped key fails the Init
+	assert.NoError(t, os.WriteFile(cfg, []byte("dataPath: "+dir+"\nlogger:\n  enabled: [1]\n"), 0644))
+	assert.Error(t, db.Init(cfg))
+}
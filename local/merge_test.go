@@ -0,0 +1,48 @@
+package local
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/merge"
+)
+
+func TestMergeNodeStrategies(t *testing.T) {
+	db, err := NewInMemoryDB()
+	assert.NoError(t, err)
+
+	_, err = db.AddNode(&Node{ID: "1", Collection: "company", Name: "600001",
+		Data: map[string]interface{}{"revenue": 100.0, "country": "CN"}})
+	assert.NoError(t, err)
+
+	// default strategy: the incoming value overwrites
+	err = db.MergeNode(&Node{ID: "1", Data: map[string]interface{}{"revenue": 50.0}})
+	assert.NoError(t, err)
+	assert.Equal(t, 50.0, db.Nodes["1"].Data["revenue"])
+	assert.Equal(t, "CN", db.Nodes["1"].Data["country"])
+
+	c, err := merge.Parse(map[string]interface{}{
+		"collections": map[string]interface{}{
+			"company": map[string]interface{}{
+				"fields": map[string]interface{}{"revenue": "max", "country": "keep-first"},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	db.SetMergeConfig(c)
+
+	err = db.MergeNode(&Node{ID: "1", Data: map[string]interface{}{"revenue": 120.0, "country": "USA"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 120.0, db.Nodes["1"].Data["revenue"])
+	assert.Equal(t, "CN", db.Nodes["1"].Data["country"])
+
+	// sum adds the same fields together
+	db.SetMergeConfig(&merge.Config{Default: merge.Sum})
+	err = db.MergeNode(&Node{ID: "1", Data: map[string]interface{}{"revenue": 30.0}})
+	assert.NoError(t, err)
+	assert.Equal(t, 150.0, db.Nodes["1"].Data["revenue"])
+
+	// incompatible values are reported instead of silently dropped
+	err = db.MergeNode(&Node{ID: "1", Data: map[string]interface{}{"country": 1.0}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,59 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// reconnect writes db to its data path and reads it back in a fresh store
+func reconnect(t *testing.T, db *InMemoryDB, cfg string) *InMemoryDB {
+	assert.NoError(t, db.Disconnect())
+	db2, err := NewInMemoryDB()
+	assert.NoError(t, err)
+	assert.NoError(t, db2.Init(cfg))
+	assert.NoError(t, db2.Connect())
+	return db2
+}
+
+func TestDisconnectConnect(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.WriteFile(cfg, []byte("dataPath: "+filepath.Join(dir, "data")+"\n"), 0644))
+
+	db, err := NewInMemoryDB()
+	assert.NoError(t, err)
+	assert.NoError(t, db.Init(cfg))
+
+	from, _ := NewNode(WithNID("n1"), WithNCollection("company"), WithNName("600001"),
+		WithNData(map[string]interface{}{"companyName": "Google"}))
+	to, _ := NewNode(WithNID("n2"), WithNCollection("company"), WithNName("600002"))
+	_, err = db.AddNode(from)
+	assert.NoError(t, err)
+	_, err = db.AddNode(to)
+	assert.NoError(t, err)
+	// two edges with the same relationship are two files
+	for _, id := range []string{"e1", "e2"} {
+		edge, _ := NewEdge(WithEID(id), WithECollection("invest"), WithEName("invest"), WithEFrom(from), WithETo(to),
+			WithEData(map[string]interface{}{"amount": 1.0}))
+		_, err = db.AddEdge(edge)
+		assert.NoError(t, err)
+	}
+
+	db2 := reconnect(t, db, cfg)
+	assert.Len(t, db2.Nodes, 2)
+	assert.Len(t, db2.Edges, 2)
+	assert.Equal(t, "Google", db2.Nodes["n1"].Data["companyName"])
+	assert.Equal(t, "invest", db2.Edges["e1"].Relationship)
+	assert.Equal(t, "n1", db2.Edges["e1"].From.ID)
+	assert.Equal(t, "n2", db2.Edges["e2"].To.ID)
+	assert.Equal(t, 1.0, db2.Edges["e2"].Data["amount"])
+
+	// a deleted edge stays deleted, the file of the other one is kept
+	assert.NoError(t, db2.DeleteItemByID("e1"))
+	db3 := reconnect(t, db2, cfg)
+	assert.Len(t, db3.Edges, 1)
+	assert.Contains(t, db3.Edges, "e2")
+}
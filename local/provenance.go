@@ -0,0 +1,179 @@
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"github.com/wonderstone/chainstorm/provenance"
+)
+
+// the InMemoryDB keeps the provenance of the facts
+var _ handler.ProvenanceGraph = (*InMemoryDB)(nil)
+
+// provenanceFile is where the facts are written under the dataPath
+func (db *InMemoryDB) provenanceFile() string {
+	return filepath.Join(db.configPath, handler.ProvenanceCollection, "provenance.json")
+}
+
+// loadProvenance reads the facts written by saveProvenance
+func (db *InMemoryDB) loadProvenance() error {
+	if _, err := os.Stat(db.provenanceFile()); os.IsNotExist(err) {
+		return nil
+	}
+	file, err := os.ReadFile(db.provenanceFile())
+	if err != nil {
+		return err
+	}
+	facts := make(map[string][]provenance.Fact)
+	if err := json.Unmarshal(file, &facts); err != nil {
+		return err
+	}
+	db.facts = facts
+	return nil
+}
+
+// saveProvenance writes the facts next to the collections
+// an empty store still overwrites the file so deleted facts do not come back
+func (db *InMemoryDB) saveProvenance() error {
+	if len(db.facts) == 0 {
+		if _, err := os.Stat(db.provenanceFile()); os.IsNotExist(err) {
+			return nil
+		}
+	}
+	return WriteJSONFile(db.provenanceFile(), db.facts)
+}
+
+// assert stores the facts of a write, the caller holds the lock
+// written is the data of the write without the provenance key, rec is nil without provenance
+// a replace also drops the facts of the fields it removed
+func (db *InMemoryDB) assert(op history.Op, item string, kind history.Kind, collection string, written map[string]interface{}, rec *provenance.Record) {
+	facts := db.facts[item]
+	if op == history.Replace {
+		facts = provenance.Prune(facts, written)
+	}
+	if rec != nil {
+		facts = provenance.Upsert(facts, provenance.Facts(op, *rec, item, kind, collection, written))
+	}
+	if len(facts) == 0 {
+		delete(db.facts, item)
+		return
+	}
+	db.facts[item] = facts
+}
+
+// GetProvenance(id interface{}) ([]provenance.Fact, error)
+func (db *InMemoryDB) GetProvenance(id interface{}) ([]provenance.Fact, error) {
+	db.m.RLock()
+	defer db.m.RUnlock()
+
+	idStr, err := db.itemID(id)
+	if err != nil {
+		return nil, err
+	}
+	res := append([]provenance.Fact{}, db.facts[idStr]...)
+	provenance.Sort(res)
+	return res, nil
+}
+
+// GetFactsBySource(source string) ([]provenance.Fact, error)
+func (db *InMemoryDB) GetFactsBySource(source string) ([]provenance.Fact, error) {
+	db.m.RLock()
+	defer db.m.RUnlock()
+
+	if source == "" {
+		return nil, fmt.Errorf("source is required")
+	}
+	res := []provenance.Fact{}
+	for _, facts := range db.facts {
+		res = append(res, provenance.BySource(facts, source)...)
+	}
+	provenance.Sort(res)
+	return res, nil
+}
+
+// RetractSource(source string, dryRun bool) (provenance.Report, error)
+func (db *InMemoryDB) RetractSource(source string, dryRun bool) (provenance.Report, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	if source == "" {
+		return provenance.Report{}, fmt.Errorf("source is required")
+	}
+	// all the facts of the items the source touched
+	var facts []provenance.Fact
+	for _, itemFacts := range db.facts {
+		if len(provenance.BySource(itemFacts, source)) > 0 {
+			facts = append(facts, itemFacts...)
+		}
+	}
+	report := provenance.Plan(source, facts)
+	report.DryRun = dryRun
+
+	// the edges of the deleted nodes go with them
+	for _, it := range report.Deleted {
+		if it.Kind != history.NodeKind {
+			continue
+		}
+		for _, e := range db.Edges {
+			if (e.From.ID == it.ID || e.To.ID == it.ID) && !report.Delete(e.ID) {
+				report.Cascaded = append(report.Cascaded, provenance.Item{ID: e.ID, Kind: history.EdgeKind, Collection: e.Collection})
+			}
+		}
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	for id, fields := range report.Removed {
+		if n, ok := db.Nodes[id]; ok {
+			n.Data = MergeMaps(n.Data, nil)
+			for _, f := range fields {
+				delete(n.Data, f)
+			}
+			db.recordNode(history.Retract, n, nil)
+		} else if e, ok := db.Edges[id]; ok {
+			e.Data = MergeMaps(e.Data, nil)
+			for _, f := range fields {
+				delete(e.Data, f)
+			}
+			db.recordEdge(history.Retract, e, nil)
+		}
+	}
+	for _, it := range report.DeleteOrder() {
+		db.removeItem(it.ID)
+	}
+	for id, itemFacts := range db.facts {
+		var kept []provenance.Fact
+		for _, f := range itemFacts {
+			if f.Source != source {
+				kept = append(kept, f)
+			}
+		}
+		if len(kept) == 0 {
+			delete(db.facts, id)
+		} else {
+			db.facts[id] = kept
+		}
+	}
+	return report, nil
+}
+
+// removeItem deletes a node or an edge with its series, versions and facts, the caller holds the lock
+func (db *InMemoryDB) removeItem(id string) {
+	if n, ok := db.Nodes[id]; ok {
+		delete(db.Nodes, id)
+		delete(db.nodeNameSet, n.Name)
+		db.NodeNameMap.Remove(n.Name)
+	} else if _, ok := db.Edges[id]; ok {
+		delete(db.Edges, id)
+	} else {
+		return
+	}
+	delete(db.series, id)
+	db.record(id, nil, nil)
+	delete(db.facts, id)
+}
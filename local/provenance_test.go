@@ -0,0 +1,177 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/provenance"
+)
+
+func TestProvenance(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.WriteFile(cfg, []byte("dataPath: "+filepath.Join(dir, "data")+"\n"), 0644))
+
+	db, err := NewInMemoryDB()
+	assert.NoError(t, err)
+	assert.NoError(t, db.Init(cfg))
+
+	crawler := provenance.Record{Source: "crawler/600001", Extractor: "crawler", Version: "0.3", Confidence: 0.6}
+	pdf := map[string]interface{}{"source": "annual-2023.pdf", "extractor": "pdf-llm", "version": "1.2", "confidence": 0.9}
+
+	a, _ := NewNode(WithNID("n1"), WithNCollection("company"), WithNName("600001"),
+		WithNData(map[string]interface{}{"revenue": 100.0, provenance.Key: crawler}))
+	b, _ := NewNode(WithNID("n2"), WithNCollection("company"), WithNName("600002"))
+	_, err = db.AddNode(a)
+	assert.NoError(t, err)
+	_, err = db.AddNode(b)
+	assert.NoError(t, err)
+	// the provenance key is not stored with the data
+	assert.NotContains(t, db.Nodes["n1"].Data, provenance.Key)
+
+	// a merge from another source keeps both
+	m, _ := NewNode(WithNID("n1"), WithNCollection("company"), WithNName("600001"),
+		WithNData(map[string]interface{}{"revenue": 20.0, "employees": 10.0, provenance.Key: pdf}))
+	assert.NoError(t, db.MergeNode(m))
+	facts, err := db.GetProvenance("n1")
+	assert.NoError(t, err)
+	// the node from the crawler, revenue from both sources, employees from the pdf
+	assert.Len(t, facts, 4)
+
+	edge, _ := NewEdge(WithEID("e1"), WithECollection("invest"), WithEName("invest"), WithEFrom(a), WithETo(b),
+		WithEData(map[string]interface{}{provenance.Key: crawler}))
+	_, err = db.AddEdge(edge)
+	assert.NoError(t, err)
+
+	facts, err = db.GetFactsBySource("crawler/600001")
+	assert.NoError(t, err)
+	assert.Len(t, facts, 3)
+	assert.Equal(t, "e1", facts[0].Item)
+
+	// an invalid record fails the write
+	bad, _ := NewNode(WithNID("n1"), WithNCollection("company"), WithNName("600001"),
+		WithNData(map[string]interface{}{provenance.Key: map[string]interface{}{"confidence": 0.5}}))
+	assert.Error(t, db.UpdateNode(bad))
+
+	// the facts survive a disconnect and connect
+	assert.NoError(t, db.Disconnect())
+	db2, _ := NewInMemoryDB()
+	assert.NoError(t, db2.Init(cfg))
+	assert.NoError(t, db2.Connect())
+	facts, err = db2.GetFactsBySource("annual-2023.pdf")
+	assert.NoError(t, err)
+	assert.Len(t, facts, 2)
+
+	// deleting the item deletes its facts
+	assert.NoError(t, db2.DeleteItemByID("e1"))
+	facts, err = db2.GetFactsBySource("crawler/600001")
+	assert.NoError(t, err)
+	assert.Len(t, facts, 2)
+
+	// the last deleted facts stay deleted after a restart
+	assert.NoError(t, db2.DeleteItemByID("n1"))
+	assert.NoError(t, db2.DeleteItemByID("n2"))
+	db3 := reconnect(t, db2, cfg)
+	facts, err = db3.GetFactsBySource("annual-2023.pdf")
+	assert.NoError(t, err)
+	assert.Empty(t, facts)
+}
+
+func TestRetractSource(t *testing.T) {
+	db, err := NewInMemoryDB()
+	assert.NoError(t, err)
+
+	crawler := provenance.Record{Source: "crawler", Confidence: 0.3}
+	pdf := provenance.Record{Source: "annual.pdf", Confidence: 0.9}
+
+	// n1 from the pdf, the crawler added revenue and tags, both assert revenue
+	n1, _ := NewNode(WithNID("n1"), WithNCollection("company"), WithNName("600001"),
+		WithNData(map[string]interface{}{"revenue": 100.0, provenance.Key: pdf}))
+	_, err = db.AddNode(n1)
+	assert.NoError(t, err)
+	m, _ := NewNode(WithNID("n1"), WithNCollection("company"), WithNName("600001"),
+		WithNData(map[string]interface{}{"revenue": 100.0, "tags": "bank", provenance.Key: crawler}))
+	assert.NoError(t, db.MergeNode(m))
+
+	// n2 and its edge only from the crawler, an edge without provenance goes with n2
+	n2, _ := NewNode(WithNID("n2"), WithNCollection("company"), WithNName("600002"),
+		WithNData(map[string]interface{}{provenance.Key: crawler}))
+	_, err = db.AddNode(n2)
+	assert.NoError(t, err)
+	e1, _ := NewEdge(WithEID("e1"), WithECollection("invest"), WithEName("invest"), WithEFrom(n1), WithETo(n2),
+		WithEData(map[string]interface{}{provenance.Key: crawler}))
+	_, err = db.AddEdge(e1)
+	assert.NoError(t, err)
+	e2, _ := NewEdge(WithEID("e2"), WithECollection("invest"), WithEName("invest"), WithEFrom(n2), WithETo(n1))
+	_, err = db.AddEdge(e2)
+	assert.NoError(t, err)
+
+	// the dry run changes nothing
+	report, err := db.RetractSource("crawler", true)
+	assert.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Len(t, report.Deleted, 2)
+	assert.Equal(t, "e2", report.Cascaded[0].ID)
+	assert.Equal(t, []string{"tags"}, report.Removed["n1"])
+	assert.Equal(t, []string{"revenue"}, report.Kept["n1"])
+	assert.Len(t, db.Nodes, 2)
+	assert.Contains(t, db.Nodes["n1"].Data, "tags")
+
+	report, err = db.RetractSource("crawler", false)
+	assert.NoError(t, err)
+	assert.False(t, report.DryRun)
+	assert.Len(t, db.Nodes, 1)
+	assert.Len(t, db.Edges, 0)
+	assert.False(t, db.checkNodeNameExists("600002"))
+	assert.Equal(t, map[string]interface{}{"revenue": 100.0}, db.Nodes["n1"].Data)
+	facts, err := db.GetFactsBySource("crawler")
+	assert.NoError(t, err)
+	assert.Empty(t, facts)
+	facts, err = db.GetProvenance("n1")
+	assert.NoError(t, err)
+	assert.Len(t, facts, 2)
+}
+
+func TestRetractSourceRestart(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.WriteFile(cfg, []byte("dataPath: "+filepath.Join(dir, "data")+"\n"), 0644))
+
+	db, err := NewInMemoryDB()
+	assert.NoError(t, err)
+	assert.NoError(t, db.Init(cfg))
+
+	// everything from the crawler, an edge without provenance goes with the nodes
+	crawler := provenance.Record{Source: "crawler", Confidence: 0.3}
+	n1, _ := NewNode(WithNID("n1"), WithNCollection("company"), WithNName("600001"),
+		WithNData(map[string]interface{}{"revenue": 100.0, provenance.Key: crawler}))
+	n2, _ := NewNode(WithNID("n2"), WithNCollection("company"), WithNName("600002"),
+		WithNData(map[string]interface{}{provenance.Key: crawler}))
+	_, err = db.AddNode(n1)
+	assert.NoError(t, err)
+	_, err = db.AddNode(n2)
+	assert.NoError(t, err)
+	e1, _ := NewEdge(WithEID("e1"), WithECollection("invest"), WithEName("invest"), WithEFrom(n1), WithETo(n2))
+	_, err = db.AddEdge(e1)
+	assert.NoError(t, err)
+
+	db = reconnect(t, db, cfg)
+	assert.Len(t, db.Nodes, 2)
+	assert.Len(t, db.Edges, 1)
+
+	report, err := db.RetractSource("crawler", false)
+	assert.NoError(t, err)
+	assert.Len(t, report.Deleted, 2)
+	assert.Len(t, report.Cascaded, 1)
+
+	// the retracted facts and the deleted items stay gone after a restart
+	db = reconnect(t, db, cfg)
+	assert.Empty(t, db.Nodes)
+	assert.Empty(t, db.Edges)
+	assert.False(t, db.checkNodeNameExists("600001"))
+	facts, err := db.GetFactsBySource("crawler")
+	assert.NoError(t, err)
+	assert.Empty(t, facts)
+}
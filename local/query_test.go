@@ -0,0 +1,58 @@
+package local
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/query"
+)
+
+func TestQuery(t *testing.T) {
+	db, err := NewInMemoryDB()
+	assert.NoError(t, err)
+	nodes := map[string]*Node{}
+	for _, n := range []struct{ id, name, country string }{
+		{"a", "600001", "CN"}, {"b", "600002", "CN"}, {"c", "Acme", "USA"}, {"d", "Bolt", "USA"}, {"e", "600003", "CN"},
+	} {
+		nodes[n.id], _ = NewNode(WithNID(n.id), WithNCollection("company"), WithNName(n.name),
+			WithNData(map[string]interface{}{"companyCountry": n.country}))
+		_, err := db.AddNode(nodes[n.id])
+		assert.NoError(t, err)
+	}
+	for _, e := range []struct{ id, rel, from, to string }{
+		{"ab", "invest", "a", "b"}, {"bc", "invest", "b", "c"}, {"ad", "hold", "a", "d"}, {"ea", "invest", "e", "a"},
+	} {
+		edge, _ := NewEdge(WithEID(e.id), WithECollection("relation"), WithEName(e.rel),
+			WithEFrom(nodes[e.from]), WithETo(nodes[e.to]))
+		_, err := db.AddEdge(edge)
+		assert.NoError(t, err)
+	}
+	names := func(src string) []string {
+		res, err := query.Run(db, src)
+		assert.NoError(t, err, src)
+		out := []string{}
+		for _, row := range res.Rows {
+			for _, n := range row {
+				out = append(out, n.(*Node).Name)
+			}
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"Acme"}, names(`MATCH (a:company {Name:"600001"})-[:invest*1..3]->(b)
+		WHERE b.Data.companyCountry = "USA" RETURN b`))
+	assert.ElementsMatch(t, []string{"600002", "Acme", "Bolt"}, names(`MATCH (a {Name:"600001"})-[*1..2]->(b) RETURN b`))
+	assert.Equal(t, []string{"Bolt"}, names(`MATCH (a {Name:"600001"})-[:hold]->(b) RETURN b`))
+	assert.Equal(t, []string{"600003"}, names(`MATCH (a)-[:invest]->(b {Name:"600001"}) RETURN a`))
+	assert.ElementsMatch(t, []string{"600002", "600003", "Bolt"}, names(`MATCH (a {Name:"600001"})-[]-(b) RETURN b`))
+	// two variables, an OR across them is checked on the rows
+	assert.Equal(t, []string{"600003", "600002"}, names(`MATCH (x)-[:invest]->(y)-[:invest]->(z)
+		WHERE x.Name = "600003" OR z.Name = "600003" RETURN x, z`))
+	assert.Len(t, names(`MATCH (a:company) WHERE a.Data.companyCountry IN ["CN"] RETURN a LIMIT 2`), 2)
+
+	res, err := query.Run(db, `MATCH (a:company) WHERE a.Data.companyCountry = "USA" RETURN a`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, res.Columns)
+	assert.Equal(t, [][]handler.Node{{nodes["c"]}, {nodes["d"]}}, res.Rows)
+}
@@ -0,0 +1,94 @@
+package local
+
+import (
+	"fmt"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"github.com/wonderstone/chainstorm/provenance"
+	"github.com/wonderstone/chainstorm/resolve"
+)
+
+// the InMemoryDB merges duplicate nodes
+var _ handler.ResolvingGraph = (*InMemoryDB)(nil)
+
+// nodeByName returns the node of the name, the caller holds the lock
+func (db *InMemoryDB) nodeByName(name interface{}) (*Node, error) {
+	nameStr, ok := name.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid name")
+	}
+	if !db.checkNodeNameExists(nameStr) {
+		return nil, fmt.Errorf("node with name %s does not exist", nameStr)
+	}
+	id, _ := db.NodeNameMap.Get(nameStr)
+	return db.Nodes[id.(string)], nil
+}
+
+// MergeNodesInto(survivor interface{}, duplicates ...interface{}) error
+func (db *InMemoryDB) MergeNodesInto(survivor interface{}, duplicates ...interface{}) error {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	s, err := db.nodeByName(survivor)
+	if err != nil {
+		return err
+	}
+	// check everything before changing anything
+	dups := make([]*Node, 0, len(duplicates))
+	for _, name := range duplicates {
+		d, err := db.nodeByName(name)
+		if err != nil {
+			return err
+		}
+		if d.ID == s.ID {
+			return fmt.Errorf("node %s can not be merged into itself", d.Name)
+		}
+		dups = append(dups, d)
+	}
+
+	// # the data of each duplicate, its name and aliases become aliases of the survivor
+	// the merges run before the first write, a failed one changes nothing
+	datas := make([]map[string]interface{}, len(dups))
+	sData := s.Data
+	for i, d := range dups {
+		data := MergeMaps(d.Data, nil)
+		delete(data, handler.AliasesKey)
+		merged, err := db.mergeConfig().Merge(s.Collection, sData, data)
+		if err != nil {
+			return err
+		}
+		sData = resolve.AddAliases(merged, handler.AliasesKey, append([]string{d.Name}, resolve.Aliases(d.Data, handler.AliasesKey)...)...)
+		datas[i] = sData
+	}
+
+	for i, d := range dups {
+		// # repoint the edges, the ones between the two nodes would become loops and are dropped
+		for id, e := range db.Edges {
+			if e.From.ID != d.ID && e.To.ID != d.ID {
+				continue
+			}
+			if e.From.ID == d.ID {
+				e.From = s
+			}
+			if e.To.ID == d.ID {
+				e.To = s
+			}
+			if e.From.ID == s.ID && e.To.ID == s.ID {
+				db.removeItem(id)
+				continue
+			}
+			db.recordEdge(history.Replace, e, nil)
+		}
+
+		s.Data = datas[i]
+		db.recordNode(history.Merge, s, nil)
+
+		// the facts of the duplicate now hold for the survivor
+		if facts := db.facts[d.ID]; len(facts) > 0 {
+			db.facts[s.ID] = provenance.Upsert(db.facts[s.ID], provenance.Move(facts, s.ID, s.Collection))
+		}
+		db.removeItem(d.ID)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package local
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/merge"
+	"github.com/wonderstone/chainstorm/provenance"
+	"github.com/wonderstone/chainstorm/resolve"
+)
+
+// resolveGraph builds two names of one company sharing the same shareholders
+func resolveGraph(t *testing.T) *InMemoryDB {
+	db, err := NewInMemoryDB()
+	assert.NoError(t, err)
+	add := func(id, name string, data map[string]interface{}) *Node {
+		n, _ := NewNode(WithNID(id), WithNCollection("company"), WithNName(name), WithNData(data))
+		_, err := db.AddNode(n)
+		assert.NoError(t, err)
+		return n
+	}
+	link := func(id string, from, to *Node) {
+		e, _ := NewEdge(WithEID(id), WithECollection("invest"), WithEName("invest"), WithEFrom(from), WithETo(to))
+		_, err := db.AddEdge(e)
+		assert.NoError(t, err)
+	}
+	a := add("a", "600001", map[string]interface{}{"revenue": 1.0})
+	b := add("b", "SH600001", map[string]interface{}{"city": "SZ", handler.AliasesKey: []interface{}{"PAB"},
+		provenance.Key: provenance.Record{Source: "crawler"}})
+	p1 := add("p1", "holder1", nil)
+	p2 := add("p2", "holder2", nil)
+	link("e1", p1, a)
+	link("e2", p2, a)
+	link("e3", p1, b)
+	link("e4", p2, b)
+	link("e5", a, b)
+	return db
+}
+
+func TestFindDuplicates(t *testing.T) {
+	db := resolveGraph(t)
+	cands, err := resolve.FindDuplicates(db, resolve.DefaultOptions())
+	assert.NoError(t, err)
+	assert.Len(t, cands, 2)
+	assert.Equal(t, "600001", cands[0].A)
+	assert.Equal(t, "SH600001", cands[0].B)
+	assert.Equal(t, 1.0, cands[0].Score)
+	assert.Equal(t, []string{resolve.ByName, resolve.ByNeighbours}, cands[0].Reasons)
+	// the holders share their two companies
+	assert.Equal(t, "holder1", cands[1].A)
+	assert.Equal(t, []string{resolve.ByNeighbours}, cands[1].Reasons)
+
+	cands, err = resolve.FindDuplicates(db, resolve.Options{})
+	assert.NoError(t, err)
+	assert.Len(t, cands, 1)
+}
+
+func TestMergeNodesInto(t *testing.T) {
+	db := resolveGraph(t)
+	assert.Error(t, db.MergeNodesInto("600001", "600001"))
+	assert.Error(t, db.MergeNodesInto("600001", "missing"))
+
+	assert.NoError(t, db.MergeNodesInto("600001", "SH600001"))
+	assert.Len(t, db.Nodes, 3)
+	assert.False(t, db.checkNodeNameExists("SH600001"))
+	a := db.Nodes["a"]
+	assert.Equal(t, 1.0, a.Data["revenue"])
+	assert.Equal(t, "SZ", a.Data["city"])
+	assert.Equal(t, []interface{}{"SH600001", "PAB"}, a.Data[handler.AliasesKey])
+	// the merged names lead to the survivor
+	n, err := db.GetNode("SH600001")
+	assert.NoError(t, err)
+	assert.Equal(t, a, n)
+	n, err = db.GetNode("PAB")
+	assert.NoError(t, err)
+	assert.Equal(t, a, n)
+
+	// the edge between them is dropped, the others point to the survivor
+	assert.Len(t, db.Edges, 4)
+	assert.NotContains(t, db.Edges, "e5")
+	assert.Equal(t, a, db.Edges["e3"].To)
+	from, err := db.GetToNodes("600001")
+	assert.NoError(t, err)
+	assert.Len(t, from, 4)
+
+	// the facts of the duplicate moved to the survivor
+	facts, err := db.GetFactsBySource("crawler")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, facts)
+	for _, f := range facts {
+		assert.Equal(t, "a", f.Item)
+	}
+}
+
+func TestMergeNodesIntoFailed(t *testing.T) {
+	db := resolveGraph(t)
+	db.SetMergeConfig(&merge.Config{Default: merge.Sum})
+	c, _ := NewNode(WithNID("c"), WithNCollection("company"), WithNName("PA600001"),
+		WithNData(map[string]interface{}{"city": 1.0}))
+	_, err := db.AddNode(c)
+	assert.NoError(t, err)
+
+	// the city of the second duplicate can not be summed, the first one is not merged either
+	assert.Error(t, db.MergeNodesInto("600001", "SH600001", "PA600001"))
+	assert.Len(t, db.Nodes, 5)
+	assert.True(t, db.checkNodeNameExists("SH600001"))
+	assert.Equal(t, map[string]interface{}{"revenue": 1.0}, db.Nodes["a"].Data)
+	assert.Equal(t, "b", db.Edges["e3"].To.ID)
+	assert.Contains(t, db.Edges, "e5")
+}
@@ -0,0 +1,84 @@
+package local
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+func TestRevisions(t *testing.T) {
+	db := resolveGraph(t)
+	n, err := db.GetNode("600001")
+	assert.NoError(t, err)
+	read := n.(*Node).Rev
+	assert.Equal(t, uint64(1), read)
+
+	// the first worker writes with the revision it read
+	first := &Node{ID: "a", Data: map[string]interface{}{"revenue": 2.0}, Rev: read}
+	assert.NoError(t, db.MergeNode(first))
+	assert.Equal(t, uint64(2), first.Rev)
+	// the node read keeps the revision it was read at
+	assert.Equal(t, read, n.(*Node).Rev)
+
+	// the second one read the same revision, its write is refused
+	err = db.MergeNode(&Node{ID: "a", Data: map[string]interface{}{"revenue": 5.0}, Rev: read})
+	assert.ErrorIs(t, err, handler.ErrConflict)
+	var conflict *handler.ConflictError
+	assert.True(t, errors.As(err, &conflict))
+	assert.Equal(t, "a", conflict.ID)
+	assert.Equal(t, "1", conflict.Expected)
+	assert.Equal(t, "2", conflict.Actual)
+	n, _ = db.GetNode("600001")
+	assert.Equal(t, uint64(2), n.(*Node).Rev)
+	assert.Equal(t, 2.0, n.(*Node).Data["revenue"])
+
+	// without revision the write applies anyway
+	assert.NoError(t, db.UpdateNode(&Node{ID: "a", Data: map[string]interface{}{"city": "SZ"}}))
+	n, _ = db.GetNode("600001")
+	assert.Equal(t, uint64(3), n.(*Node).Rev)
+	assert.ErrorIs(t, db.ReplaceNode(&Node{ID: "a", Collection: "company", Name: "600001", Rev: 2}), handler.ErrConflict)
+	assert.NoError(t, db.ReplaceNode(&Node{ID: "a", Collection: "company", Name: "600001", Rev: 3}))
+	n, _ = db.GetNode("600001")
+	assert.Equal(t, uint64(4), n.(*Node).Rev)
+
+	e, err := db.GetItemByID("e5")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), e.(*Edge).Rev)
+	assert.ErrorIs(t, db.UpdateEdge(&Edge{ID: "e5", Rev: 7}), handler.ErrConflict)
+}
+
+func TestReadModifyWrite(t *testing.T) {
+	db := resolveGraph(t)
+	mine, err := db.GetNode("600001")
+	assert.NoError(t, err)
+	theirs, err := db.GetNode("600001")
+	assert.NoError(t, err)
+
+	// another writer updates the node it read
+	theirs.(*Node).Data["city"] = "SZ"
+	assert.NoError(t, db.UpdateNode(theirs))
+	assert.Equal(t, uint64(2), theirs.(*Node).Rev)
+
+	// the node first read is written back and refused, the write of the other one stays
+	mine.(*Node).Data["city"] = "BJ"
+	assert.ErrorIs(t, db.UpdateNode(mine), handler.ErrConflict)
+	assert.Equal(t, uint64(1), mine.(*Node).Rev)
+	n, _ := db.GetNode("600001")
+	assert.Equal(t, "SZ", n.(*Node).Data["city"])
+
+	// read again, the write applies
+	mine, _ = db.GetNode("600001")
+	mine.(*Node).Data["city"] = "BJ"
+	assert.NoError(t, db.ReplaceNode(mine))
+	n, _ = db.GetNode("600001")
+	assert.Equal(t, "BJ", n.(*Node).Data["city"])
+	assert.Equal(t, uint64(3), n.(*Node).Rev)
+
+	// the same for the edges
+	e, _ := db.GetItemByID("e5")
+	other, _ := db.GetItemByID("e5")
+	assert.NoError(t, db.MergeEdge(other.(*Edge)))
+	assert.ErrorIs(t, db.MergeEdge(e.(*Edge)), handler.ErrConflict)
+}
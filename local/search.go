@@ -0,0 +1,63 @@
+package local
+
+import (
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"github.com/wonderstone/chainstorm/resolve"
+	"github.com/wonderstone/chainstorm/search"
+)
+
+// the InMemoryDB keeps an in-process full-text index
+var _ handler.SearchGraph = (*InMemoryDB)(nil)
+
+// searchDoc is what the index keeps of a node version
+func searchDoc(v *history.Version) search.Doc {
+	return search.Doc{
+		ID:         v.ItemID,
+		Collection: v.Collection,
+		Name:       v.Name,
+		Aliases:    resolve.Aliases(v.Data, handler.AliasesKey),
+		Data:       v.Data,
+	}
+}
+
+// indexNodes indexes all the nodes, the caller holds the lock
+func (db *InMemoryDB) indexNodes() {
+	for id, n := range db.Nodes {
+		db.index.Put(searchDoc(history.NodeVersion(history.Create, id, n.Collection, n.Name, n.Data)))
+	}
+}
+
+// indexVersion follows a write, next is nil for a delete
+func (db *InMemoryDB) indexVersion(itemID string, next *history.Version) {
+	if next == nil || next.Kind != history.NodeKind {
+		db.index.Remove(itemID)
+		return
+	}
+	db.index.Put(searchDoc(next))
+}
+
+// SetSearchConfig(c *search.Config) error
+func (db *InMemoryDB) SetSearchConfig(c *search.Config) error {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	db.index = search.NewIndex(c)
+	db.indexNodes()
+	return nil
+}
+
+// Search(query string, opts search.Options) ([]handler.SearchHit, error)
+func (db *InMemoryDB) Search(query string, opts search.Options) ([]handler.SearchHit, error) {
+	db.m.RLock()
+	defer db.m.RUnlock()
+
+	hits := db.index.Search(query, opts)
+	res := make([]handler.SearchHit, 0, len(hits))
+	for _, h := range hits {
+		if n, ok := db.Nodes[h.ID]; ok {
+			res = append(res, handler.SearchHit{Node: copyNode(n), Score: h.Score})
+		}
+	}
+	return res, nil
+}
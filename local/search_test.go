@@ -0,0 +1,42 @@
+package local
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/search"
+)
+
+func TestSearch(t *testing.T) {
+	db, err := NewInMemoryDB()
+	assert.NoError(t, err)
+	assert.NoError(t, db.SetSearchConfig(&search.Config{Fields: []string{"industry"}}))
+	a, _ := NewNode(WithNID("a"), WithNCollection("company"), WithNName("平安银行"),
+		WithNData(map[string]interface{}{"industry": "银行"}))
+	_, err = db.AddNode(a)
+	assert.NoError(t, err)
+	b, _ := NewNode(WithNID("b"), WithNCollection("company"), WithNName("招商银行"))
+	_, err = db.AddNode(b)
+	assert.NoError(t, err)
+
+	hits, err := db.Search("平安银行", search.Options{})
+	assert.NoError(t, err)
+	assert.Len(t, hits, 2)
+	assert.Equal(t, a, hits[0].Node)
+
+	// the index follows the writes and the aliases
+	assert.NoError(t, db.AddAliases("招商银行", "CMB"))
+	hits, err = db.Search("cm", search.Options{Prefix: true})
+	assert.NoError(t, err)
+	assert.Len(t, hits, 1)
+	assert.NoError(t, db.DeleteNode("招商银行"))
+	hits, err = db.Search("招商", search.Options{})
+	assert.NoError(t, err)
+	assert.Empty(t, hits)
+
+	// the regex search checks the regex
+	_, err = db.GetNodesByRegex("(")
+	assert.Error(t, err)
+	var _ handler.SearchGraph = db
+}
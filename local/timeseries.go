@@ -0,0 +1,136 @@
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/timeseries"
+)
+
+// the InMemoryDB keeps the time series properties
+var _ handler.TimeSeriesGraph = (*InMemoryDB)(nil)
+
+// seriesFile is where the time series store is written under the dataPath
+func (db *InMemoryDB) seriesFile() string {
+	return filepath.Join(db.configPath, handler.SeriesCollection, "series.json")
+}
+
+// loadSeries reads the time series store written by saveSeries
+func (db *InMemoryDB) loadSeries() error {
+	if _, err := os.Stat(db.seriesFile()); os.IsNotExist(err) {
+		return nil
+	}
+	file, err := os.ReadFile(db.seriesFile())
+	if err != nil {
+		return err
+	}
+	series := make(map[string]map[string][]timeseries.Point)
+	if err := json.Unmarshal(file, &series); err != nil {
+		return err
+	}
+	db.series = series
+	return nil
+}
+
+// saveSeries writes the time series store next to the collections
+// an empty store still overwrites the file so deleted series do not come back
+func (db *InMemoryDB) saveSeries() error {
+	if len(db.series) == 0 {
+		if _, err := os.Stat(db.seriesFile()); os.IsNotExist(err) {
+			return nil
+		}
+	}
+	return WriteJSONFile(db.seriesFile(), db.series)
+}
+
+// itemID checks the id and returns it as a string
+// the caller should hold the lock
+func (db *InMemoryDB) itemID(id interface{}) (string, error) {
+	idStr, ok := id.(string)
+	if !ok {
+		return "", fmt.Errorf("invalid id")
+	}
+	if _, ok := db.Nodes[idStr]; ok {
+		return idStr, nil
+	}
+	if _, ok := db.Edges[idStr]; ok {
+		return idStr, nil
+	}
+	return "", fmt.Errorf("item with ID %s does not exist", idStr)
+}
+
+// AppendPoints(id interface{}, metric string, points ...timeseries.Point) error
+func (db *InMemoryDB) AppendPoints(id interface{}, metric string, points ...timeseries.Point) error {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	if err := timeseries.ValidMetric(metric); err != nil {
+		return err
+	}
+	idStr, err := db.itemID(id)
+	if err != nil {
+		return err
+	}
+
+	if db.series[idStr] == nil {
+		db.series[idStr] = make(map[string][]timeseries.Point)
+	}
+	db.series[idStr][metric] = timeseries.Insert(db.series[idStr][metric], points...)
+	return nil
+}
+
+// GetSeries(id interface{}, metric string, from, to time.Time) ([]timeseries.Point, error)
+func (db *InMemoryDB) GetSeries(id interface{}, metric string, from, to time.Time) ([]timeseries.Point, error) {
+	db.m.RLock()
+	defer db.m.RUnlock()
+
+	idStr, err := db.itemID(id)
+	if err != nil {
+		return nil, err
+	}
+	return timeseries.Range(db.series[idStr][metric], from, to), nil
+}
+
+// GetValueAsOf(id interface{}, metric string, t time.Time) (timeseries.Point, error)
+func (db *InMemoryDB) GetValueAsOf(id interface{}, metric string, t time.Time) (timeseries.Point, error) {
+	db.m.RLock()
+	defer db.m.RUnlock()
+
+	idStr, err := db.itemID(id)
+	if err != nil {
+		return timeseries.Point{}, err
+	}
+	return timeseries.AsOf(db.series[idStr][metric], t)
+}
+
+// AggregateSeries(id interface{}, metric string, from, to time.Time, agg timeseries.Aggregation) (float64, error)
+func (db *InMemoryDB) AggregateSeries(id interface{}, metric string, from, to time.Time, agg timeseries.Aggregation) (float64, error) {
+	db.m.RLock()
+	defer db.m.RUnlock()
+
+	idStr, err := db.itemID(id)
+	if err != nil {
+		return 0, err
+	}
+	return timeseries.Aggregate(timeseries.Range(db.series[idStr][metric], from, to), agg)
+}
+
+// DeleteSeries(id interface{}, metric string) error
+func (db *InMemoryDB) DeleteSeries(id interface{}, metric string) error {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	idStr, err := db.itemID(id)
+	if err != nil {
+		return err
+	}
+	delete(db.series[idStr], metric)
+	if len(db.series[idStr]) == 0 {
+		delete(db.series, idStr)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/timeseries"
+)
+
+func TestTimeSeries(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.WriteFile(cfg, []byte("dataPath: "+filepath.Join(dir, "data")+"\n"), 0644))
+
+	db, err := NewInMemoryDB()
+	assert.NoError(t, err)
+	assert.NoError(t, db.Init(cfg))
+
+	from, _ := NewNode(WithNID("n1"), WithNCollection("company"), WithNName("600001"))
+	to, _ := NewNode(WithNID("n2"), WithNCollection("company"), WithNName("600002"))
+	_, err = db.AddNode(from)
+	assert.NoError(t, err)
+	_, err = db.AddNode(to)
+	assert.NoError(t, err)
+	edge, _ := NewEdge(WithEID("e1"), WithECollection("invest"), WithEName("invest"), WithEFrom(from), WithETo(to))
+	_, err = db.AddEdge(edge)
+	assert.NoError(t, err)
+
+	q1 := time.Date(2023, 3, 31, 0, 0, 0, 0, time.UTC)
+	q2 := time.Date(2023, 6, 30, 0, 0, 0, 0, time.UTC)
+	q3 := time.Date(2023, 9, 30, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, db.AppendPoints("n1", "companyRevenue", timeseries.Point{Time: q2, Value: 20}, timeseries.Point{Time: q1, Value: 10}))
+	assert.NoError(t, db.AppendPoints("n1", "companyRevenue", timeseries.Point{Time: q3, Value: 30}))
+	assert.NoError(t, db.AppendPoints("e1", "investAmount", timeseries.Point{Time: q1, Value: 1e6}))
+	assert.Error(t, db.AppendPoints("missing", "companyRevenue", timeseries.Point{Time: q1, Value: 1}))
+
+	pts, err := db.GetSeries("n1", "companyRevenue", q2, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, pts, 2)
+
+	p, err := db.GetValueAsOf("n1", "companyRevenue", q2.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 20.0, p.Value)
+
+	total, err := db.AggregateSeries("n1", "companyRevenue", time.Time{}, time.Time{}, timeseries.Sum)
+	assert.NoError(t, err)
+	assert.Equal(t, 60.0, total)
+
+	// the store survives a disconnect and connect
+	assert.NoError(t, db.Disconnect())
+	db2, _ := NewInMemoryDB()
+	assert.NoError(t, db2.Init(cfg))
+	assert.NoError(t, db2.Connect())
+	assert.Len(t, db2.Nodes, 2)
+	assert.Len(t, db2.Edges, 1)
+	pts, err = db2.GetSeries("n1", "companyRevenue", time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, pts, 3)
+	assert.True(t, pts[0].Time.Equal(q1))
+
+	// deleting the item deletes its series
+	assert.NoError(t, db2.DeleteItemByID("e1"))
+	_, ok := db2.series["e1"]
+	assert.False(t, ok)
+
+	// the last deleted series stays deleted after a restart
+	assert.NoError(t, db2.DeleteSeries("n1", "companyRevenue"))
+	db3 := reconnect(t, db2, cfg)
+	pts, err = db3.GetSeries("n1", "companyRevenue", time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Empty(t, pts)
+}
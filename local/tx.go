@@ -0,0 +1,258 @@
+package local
+
+import (
+	"fmt"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"github.com/wonderstone/chainstorm/provenance"
+	"github.com/wonderstone/chainstorm/timeseries"
+)
+
+// the InMemoryDB groups writes in transactions
+var _ handler.TxGraph = (*InMemoryDB)(nil)
+
+// # a transaction holds the write lock from start to end, so it sees its writes and nobody else does;
+// # an undo log keeps each item as it was before the transaction first touched it, a rollback puts it back
+
+// undoEntry is an item as it was before the transaction
+type undoEntry struct {
+	id string
+	// node and edge are the item and its fields, nil if it did not exist
+	// the pointer is kept because the edges point to their nodes
+	node    *Node
+	nodeVal Node
+	edge    *Edge
+	edgeVal Edge
+
+	history []history.Version
+	facts   []provenance.Fact
+	series  map[string][]timeseries.Point
+}
+
+// tx is the handler.Tx of the InMemoryDB
+type tx struct {
+	db   *InMemoryDB
+	undo []undoEntry
+	seen map[string]bool
+}
+
+var _ handler.Tx = (*tx)(nil)
+
+// Update(fn func(tx handler.Tx) error) error
+// the other calls wait for the transaction, fn must not call the db itself
+func (db *InMemoryDB) Update(fn func(tx handler.Tx) error) (err error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+	t := &tx{db: db, seen: make(map[string]bool)}
+	// the change events wait for the commit
+	db.pending = []handler.ChangeEvent{}
+	defer func() {
+		if r := recover(); r != nil {
+			t.rollback()
+			panic(r)
+		}
+	}()
+	if err = fn(t); err != nil {
+		t.rollback()
+		db.logger.Warn().Err(err).Int("items", len(t.seen)).Msg("Transaction rolled back")
+		return err
+	}
+	for _, e := range db.pending {
+		db.feed.Publish(e)
+	}
+	db.pending = nil
+	return nil
+}
+
+// keep logs the item before its first write in the transaction
+func (t *tx) keep(id string) {
+	if t.seen[id] {
+		return
+	}
+	t.seen[id] = true
+	db := t.db
+	u := undoEntry{id: id, series: db.series[id]}
+	if n, ok := db.Nodes[id]; ok {
+		u.node, u.nodeVal = n, *n
+	}
+	if e, ok := db.Edges[id]; ok {
+		u.edge, u.edgeVal = e, *e
+	}
+	// % record and assert write the slices in place
+	if v, ok := db.history[id]; ok {
+		u.history = append([]history.Version{}, v...)
+	}
+	if f, ok := db.facts[id]; ok {
+		u.facts = append([]provenance.Fact{}, f...)
+	}
+	t.undo = append(t.undo, u)
+}
+
+// rollback puts the items back, the last written first
+func (t *tx) rollback() {
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		t.db.restore(t.undo[i])
+	}
+	t.undo = nil
+	t.db.pending = nil
+}
+
+// restore puts the item back as the undo entry kept it, the caller holds the lock
+func (db *InMemoryDB) restore(u undoEntry) {
+	if n, ok := db.Nodes[u.id]; ok {
+		delete(db.nodeNameSet, n.Name)
+		db.NodeNameMap.Remove(n.Name)
+	}
+	delete(db.Nodes, u.id)
+	delete(db.Edges, u.id)
+	delete(db.history, u.id)
+	delete(db.facts, u.id)
+	delete(db.series, u.id)
+
+	var current *history.Version
+	if u.node != nil {
+		*u.node = u.nodeVal
+		db.Nodes[u.id] = u.node
+		db.nodeNameSet[u.node.Name] = void{}
+		db.NodeNameMap.Put(u.node.Name, u.id)
+		current = history.NodeVersion(history.Create, u.id, u.node.Collection, u.node.Name, u.node.Data)
+	}
+	if u.edge != nil {
+		*u.edge = u.edgeVal
+		db.Edges[u.id] = u.edge
+		current = history.EdgeVersion(history.Create, u.id, u.edge.Collection, u.edge.Relationship, u.edge.From.ID, u.edge.To.ID, u.edge.Data)
+	}
+	if u.history != nil {
+		db.history[u.id] = u.history
+	}
+	if u.facts != nil {
+		db.facts[u.id] = u.facts
+	}
+	if u.series != nil {
+		db.series[u.id] = u.series
+	}
+	// the alias, search and secondary indexes follow the restored item
+	db.aliases.Track(u.id, current, handler.AliasesKey)
+	db.indexVersion(u.id, current)
+	db.indexItem(u.id, current)
+}
+
+// + Create operations, the new items are logged as missing
+func (t *tx) AddNode(n handler.Node) (interface{}, error) {
+	id, err := t.db.addNode(n)
+	if err == nil {
+		t.keepNew(id.(string))
+	}
+	return id, err
+}
+
+func (t *tx) AddEdge(e handler.Edge) (interface{}, error) {
+	id, err := t.db.addEdge(e)
+	if err == nil {
+		t.keepNew(id.(string))
+	}
+	return id, err
+}
+
+// keepNew logs an item created by the transaction
+func (t *tx) keepNew(id string) {
+	if !t.seen[id] {
+		t.seen[id] = true
+		t.undo = append(t.undo, undoEntry{id: id})
+	}
+}
+
+// + Update operations
+func (t *tx) ReplaceNode(n handler.Node) error {
+	if err := t.keepNode(n); err != nil {
+		return err
+	}
+	return t.db.replaceNode(n)
+}
+
+func (t *tx) ReplaceEdge(e handler.Edge) error {
+	if err := t.keepEdge(e); err != nil {
+		return err
+	}
+	return t.db.replaceEdge(e)
+}
+
+func (t *tx) UpdateNode(n handler.Node) error {
+	if err := t.keepNode(n); err != nil {
+		return err
+	}
+	return t.db.updateNode(n)
+}
+
+func (t *tx) UpdateEdge(e handler.Edge) error {
+	if err := t.keepEdge(e); err != nil {
+		return err
+	}
+	return t.db.updateEdge(e)
+}
+
+func (t *tx) MergeNode(n handler.Node) error {
+	if err := t.keepNode(n); err != nil {
+		return err
+	}
+	return t.db.mergeNode(n)
+}
+
+func (t *tx) MergeEdge(e handler.Edge) error {
+	if err := t.keepEdge(e); err != nil {
+		return err
+	}
+	return t.db.mergeEdge(e)
+}
+
+// keepNode logs the node written by its ID
+func (t *tx) keepNode(ni handler.Node) error {
+	n, ok := ni.(*Node)
+	if !ok {
+		return fmt.Errorf("invalid input")
+	}
+	t.keep(n.ID)
+	return nil
+}
+
+// keepEdge logs the edge written by its ID
+func (t *tx) keepEdge(ei handler.Edge) error {
+	e, ok := ei.(*Edge)
+	if !ok {
+		return fmt.Errorf("invalid input")
+	}
+	t.keep(e.ID)
+	return nil
+}
+
+// + Delete operations
+func (t *tx) DeleteNode(name interface{}) error {
+	if nameStr, ok := name.(string); ok {
+		if id, ok := t.db.NodeNameMap.Get(nameStr); ok {
+			t.keep(id.(string))
+		}
+	}
+	return t.db.deleteNode(name)
+}
+
+func (t *tx) DeleteItemByID(id interface{}) error {
+	if idStr, ok := id.(string); ok {
+		t.keep(idStr)
+	}
+	return t.db.deleteItemByID(id)
+}
+
+// + Query operations, the lock is held already
+func (t *tx) GetItemByID(id interface{}) (interface{}, error) { return t.db.getItemByID(id) }
+func (t *tx) GetNode(name interface{}) (handler.Node, error)  { return t.db.getNode(name) }
+func (t *tx) GetNodesByRegex(regex string) ([]handler.Node, error) {
+	return t.db.getNodesByRegex(regex)
+}
+func (t *tx) GetEdgesByRegex(regex string) ([]handler.Edge, error) {
+	return t.db.getEdgesByRegex(regex)
+}
+func (t *tx) GetFromNodes(name interface{}) ([]handler.Node, error) { return t.db.getFromNodes(name) }
+func (t *tx) GetToNodes(name interface{}) ([]handler.Node, error)   { return t.db.getToNodes(name) }
+func (t *tx) GetInEdges(name interface{}) ([]handler.Edge, error)   { return t.db.getInEdges(name) }
+func (t *tx) GetOutEdges(name interface{}) ([]handler.Edge, error)  { return t.db.getOutEdges(name) }
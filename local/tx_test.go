@@ -0,0 +1,90 @@
+package local
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/search"
+)
+
+func TestUpdateCommit(t *testing.T) {
+	db := resolveGraph(t)
+	err := db.Update(func(tx handler.Tx) error {
+		n, _ := NewNode(WithNID("c"), WithNCollection("company"), WithNName("600002"))
+		if _, err := tx.AddNode(n); err != nil {
+			return err
+		}
+		// the reads see the writes of the transaction
+		got, err := tx.GetNode("600002")
+		if err != nil {
+			return err
+		}
+		from, _ := tx.GetNode("holder1")
+		e, _ := NewEdge(WithEID("e6"), WithECollection("invest"), WithEName("invest"), WithEFrom(from.(*Node)), WithETo(got.(*Node)))
+		_, err = tx.AddEdge(e)
+		return err
+	})
+	assert.NoError(t, err)
+	out, err := db.GetOutEdges("holder1")
+	assert.NoError(t, err)
+	assert.Len(t, out, 3)
+}
+
+func TestUpdateRollback(t *testing.T) {
+	db := resolveGraph(t)
+	before, _ := db.GetNode("600001")
+	revenue := before.(*Node).Data["revenue"]
+
+	err := db.Update(func(tx handler.Tx) error {
+		n, _ := NewNode(WithNID("c"), WithNCollection("company"), WithNName("600002"), WithNData(map[string]interface{}{"city": "SZ"}))
+		if _, err := tx.AddNode(n); err != nil {
+			return err
+		}
+		if err := tx.UpdateNode(&Node{ID: "a", Data: map[string]interface{}{"revenue": 2.0}}); err != nil {
+			return err
+		}
+		if err := tx.DeleteNode("holder2"); err != nil {
+			return err
+		}
+		if err := tx.DeleteItemByID("e5"); err != nil {
+			return err
+		}
+		return fmt.Errorf("abort")
+	})
+	assert.EqualError(t, err, "abort")
+
+	_, err = db.GetNode("600002")
+	assert.Error(t, err)
+	after, err := db.GetNode("600001")
+	assert.NoError(t, err)
+	assert.Equal(t, before, after)
+	assert.Equal(t, revenue, after.(*Node).Data["revenue"])
+	_, err = db.GetNode("holder2")
+	assert.NoError(t, err)
+	_, err = db.GetItemByID("e5")
+	assert.NoError(t, err)
+	// the derived indexes follow the rollback
+	hits, err := db.Search("600002", search.Options{})
+	assert.NoError(t, err)
+	assert.Empty(t, hits)
+	node, err := db.GetNode("PAB")
+	assert.NoError(t, err)
+	assert.Equal(t, "SH600001", node.(*Node).Name)
+	versions, err := db.GetHistory("a")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+}
+
+func TestUpdatePanic(t *testing.T) {
+	db := resolveGraph(t)
+	assert.Panics(t, func() {
+		_ = db.Update(func(tx handler.Tx) error {
+			_ = tx.DeleteNode("holder1")
+			panic("boom")
+		})
+	})
+	_, err := db.GetNode("holder1")
+	assert.NoError(t, err)
+}
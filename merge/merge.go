@@ -0,0 +1,403 @@
+// Package merge holds the per-field merge strategies shared by every backend.
+// MergeNode and MergeEdge of local, arango and mongo all delegate to a Config,
+// so the same yaml section gives the same result whatever the storage is.
+package merge
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Strategy decides how an incoming Data value is combined with the existing one
+type Strategy string
+
+const (
+	// Overwrite: the incoming value replaces the existing one, the default
+	Overwrite Strategy = "overwrite"
+	// Sum: numbers are added, strings are concatenated and bools are ORed
+	Sum Strategy = "sum"
+	// Max: keep the bigger one of numbers, strings or times
+	Max Strategy = "max"
+	// Min: keep the smaller one of numbers, strings or times
+	Min Strategy = "min"
+	// Append: the existing value becomes a list and the incoming value is appended
+	Append Strategy = "append"
+	// KeepFirst: the existing value wins, the incoming one only fills a missing field
+	KeepFirst Strategy = "keep-first"
+	// Newest: the side with the newer timestamp (see TimestampField) wins
+	Newest Strategy = "newest"
+	// Union: like Append, but every element is kept only once
+	Union Strategy = "union"
+)
+
+// DefaultStrategy is used when neither the collection nor the field sets one
+// ~ it is what local and mongo always did, arango summed before and needs "default: sum" to keep doing so
+const DefaultStrategy = Overwrite
+
+// valid checks if the strategy is one of the known ones
+func (s Strategy) valid() bool {
+	switch s {
+	case Overwrite, Sum, Max, Min, Append, KeepFirst, Newest, Union:
+		return true
+	}
+	return false
+}
+
+// Policy is the merge setting of one collection
+// blank values fall back to the Config level settings
+type Policy struct {
+	Default        Strategy            `yaml:"default"`
+	TimestampField string              `yaml:"timestampField"`
+	Fields         map[string]Strategy `yaml:"fields"`
+}
+
+// Config is the merge setting of the whole graph
+//
+//	merge:
+//	  default: sum
+//	  timestampField: updatedAt
+//	  collections:
+//	    company:
+//	      default: overwrite
+//	      fields:
+//	        companyRevenue: sum
+//	        tags: union
+type Config struct {
+	Default        Strategy          `yaml:"default"`
+	TimestampField string            `yaml:"timestampField"`
+	Collections    map[string]Policy `yaml:"collections"`
+}
+
+// Default returns the config used when nothing is configured
+func Default() *Config {
+	return &Config{Default: DefaultStrategy}
+}
+
+// Parse builds a Config from the untyped "merge" section of a backend yaml file
+// nil gives the Default config
+func Parse(raw interface{}) (*Config, error) {
+	if raw == nil {
+		return Default(), nil
+	}
+	// round trip through yaml, the section comes from yaml anyway
+	b, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("invalid merge config: %v", err)
+	}
+	if c.Default == "" {
+		c.Default = DefaultStrategy
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks all the strategy names in the config
+func (c *Config) Validate() error {
+	if !c.Default.valid() {
+		return fmt.Errorf("unknown merge strategy %q", c.Default)
+	}
+	for col, p := range c.Collections {
+		if p.Default != "" && !p.Default.valid() {
+			return fmt.Errorf("collection %s: unknown merge strategy %q", col, p.Default)
+		}
+		for field, s := range p.Fields {
+			if !s.valid() {
+				return fmt.Errorf("collection %s field %s: unknown merge strategy %q", col, field, s)
+			}
+		}
+	}
+	return nil
+}
+
+// StrategyFor returns the strategy of a field in a collection
+func (c *Config) StrategyFor(collection, field string) Strategy {
+	if p, ok := c.Collections[collection]; ok {
+		if s, ok := p.Fields[field]; ok {
+			return s
+		}
+		if p.Default != "" {
+			return p.Default
+		}
+	}
+	if c.Default != "" {
+		return c.Default
+	}
+	return DefaultStrategy
+}
+
+// timestampField returns the field used by the Newest strategy
+func (c *Config) timestampField(collection string) string {
+	if p, ok := c.Collections[collection]; ok && p.TimestampField != "" {
+		return p.TimestampField
+	}
+	return c.TimestampField
+}
+
+// Merge combines the incoming data into the existing data of an item in the collection
+// the existing map is left untouched, a new map is returned
+func (c *Config) Merge(collection string, existing, incoming map[string]interface{}) (map[string]interface{}, error) {
+	merged := make(map[string]interface{}, len(existing)+len(incoming))
+	for k, v := range existing {
+		merged[k] = v
+	}
+
+	// # decide once which side is newer for the Newest strategy
+	tsField := c.timestampField(collection)
+	incomingNewer := true
+	if tsField != "" {
+		incomingNewer = isNewer(incoming[tsField], existing[tsField])
+	}
+
+	for k, v := range incoming {
+		old, ok := existing[k]
+		if !ok {
+			merged[k] = v
+			continue
+		}
+		// the timestamp itself always follows the newest side
+		if tsField != "" && k == tsField {
+			if incomingNewer {
+				merged[k] = v
+			}
+			continue
+		}
+		res, err := Apply(c.StrategyFor(collection, k), old, v, incomingNewer)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", k, err)
+		}
+		merged[k] = res
+	}
+	return merged, nil
+}
+
+// Apply combines two values of one field with the strategy
+// incomingNewer is only used by the Newest strategy
+func Apply(s Strategy, old, incoming interface{}, incomingNewer bool) (interface{}, error) {
+	// $ nothing new to merge, the Overwrite strategy is the only one to take nil
+	if incoming == nil && s != Overwrite {
+		return old, nil
+	}
+	switch s {
+	case Overwrite:
+		return incoming, nil
+	case KeepFirst:
+		if old == nil {
+			return incoming, nil
+		}
+		return old, nil
+	case Newest:
+		if incomingNewer {
+			return incoming, nil
+		}
+		return old, nil
+	case Sum:
+		return sum(old, incoming)
+	case Max, Min:
+		if old == nil {
+			return incoming, nil
+		}
+		cmp, err := compare(old, incoming)
+		if err != nil {
+			return nil, err
+		}
+		if (s == Max && cmp < 0) || (s == Min && cmp > 0) {
+			return incoming, nil
+		}
+		return old, nil
+	case Append:
+		return append(toList(old), toList(incoming)...), nil
+	case Union:
+		res := []interface{}{}
+		for _, v := range append(toList(old), toList(incoming)...) {
+			if !contains(res, v) {
+				res = append(res, v)
+			}
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unknown merge strategy %q", s)
+	}
+}
+
+// sum adds numbers, concatenates strings and ORs bools
+func sum(old, incoming interface{}) (interface{}, error) {
+	if old == nil {
+		return incoming, nil
+	}
+	switch o := old.(type) {
+	case string:
+		if v, ok := incoming.(string); ok {
+			return o + v, nil
+		}
+	case bool:
+		if v, ok := incoming.(bool); ok {
+			return o || v, nil
+		}
+	default:
+		if isInteger(old) && isInteger(incoming) {
+			total := reflect.ValueOf(old).Convert(int64Type).Int() + reflect.ValueOf(incoming).Convert(int64Type).Int()
+			// keep the type when both sides agree, bson int32 stays int32 and so on
+			if reflect.TypeOf(old) == reflect.TypeOf(incoming) {
+				return reflect.ValueOf(total).Convert(reflect.TypeOf(old)).Interface(), nil
+			}
+			return total, nil
+		}
+		a, okA := toFloat(old)
+		b, okB := toFloat(incoming)
+		if okA && okB {
+			return a + b, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot sum %T and %T", old, incoming)
+}
+
+var int64Type = reflect.TypeOf(int64(0))
+
+func isInteger(v interface{}) bool {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// toFloat converts any go number to float64
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// compare returns -1, 0 or 1 for numbers, strings and times
+func compare(a, b interface{}) (int, error) {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			return cmpFloat(fa, fb), nil
+		}
+	}
+	if sa, ok := a.(string); ok {
+		if sb, ok := b.(string); ok {
+			switch {
+			case sa < sb:
+				return -1, nil
+			case sa > sb:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Compare(tb), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %T and %T", a, b)
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// toList turns a value into a list, a slice of any type is flattened
+// bson arrays (primitive.A) and json arrays are both slices
+func toList(v interface{}) []interface{} {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice {
+		res := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			res[i] = rv.Index(i).Interface()
+		}
+		return res
+	}
+	return []interface{}{v}
+}
+
+func contains(list []interface{}, v interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, v) {
+			return true
+		}
+		// numbers decoded by different drivers may differ in type only
+		fa, okA := toFloat(item)
+		fb, okB := toFloat(v)
+		if okA && okB && fa == fb {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTime reads a timestamp written as time.Time, RFC3339 string
+// or unix seconds number
+func ParseTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		if ts, err := time.Parse(time.RFC3339Nano, t); err == nil {
+			return ts, true
+		}
+		if ts, err := time.Parse("2006-01-02", t); err == nil {
+			return ts, true
+		}
+		if f, err := strconv.ParseFloat(t, 64); err == nil {
+			return unix(f), true
+		}
+	default:
+		// bson dates have their own type, they know how to become time.Time
+		if tt, ok := v.(interface{ Time() time.Time }); ok {
+			return tt.Time(), true
+		}
+		if f, ok := toFloat(v); ok {
+			return unix(f), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func unix(f float64) time.Time {
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+}
+
+// isNewer reports if the incoming timestamp is not older than the existing one
+// a side without a readable timestamp is treated as the oldest
+func isNewer(incoming, existing interface{}) bool {
+	ti, okI := ParseTime(incoming)
+	te, okE := ParseTime(existing)
+	switch {
+	case !okE:
+		return true
+	case !okI:
+		return false
+	}
+	return !ti.Before(te)
+}
@@ -0,0 +1,109 @@
+package merge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		old      interface{}
+		incoming interface{}
+		want     interface{}
+		wantErr  bool
+	}{
+		{name: "overwrite", strategy: Overwrite, old: 1.0, incoming: 2.0, want: 2.0},
+		{name: "sum float", strategy: Sum, old: 1.5, incoming: 2.0, want: 3.5},
+		{name: "sum int keeps type", strategy: Sum, old: int32(1), incoming: int32(2), want: int32(3)},
+		{name: "sum mixed ints", strategy: Sum, old: int32(1), incoming: 2, want: int64(3)},
+		{name: "sum int float", strategy: Sum, old: 1, incoming: 0.5, want: 1.5},
+		{name: "sum string", strategy: Sum, old: "a", incoming: "b", want: "ab"},
+		{name: "sum bool", strategy: Sum, old: false, incoming: true, want: true},
+		{name: "sum mismatch", strategy: Sum, old: "a", incoming: 1.0, wantErr: true},
+		{name: "max", strategy: Max, old: 3.0, incoming: 2, want: 3.0},
+		{name: "min", strategy: Min, old: 3.0, incoming: 2, want: 2},
+		{name: "max string", strategy: Max, old: "a", incoming: "b", want: "b"},
+		{name: "min mismatch", strategy: Min, old: "a", incoming: 2, wantErr: true},
+		{name: "append scalar", strategy: Append, old: "a", incoming: "a", want: []interface{}{"a", "a"}},
+		{name: "append list", strategy: Append, old: []interface{}{1}, incoming: []int{2, 3}, want: []interface{}{1, 2, 3}},
+		{name: "keep first", strategy: KeepFirst, old: "a", incoming: "b", want: "a"},
+		{name: "union", strategy: Union, old: []interface{}{"a", 1.0}, incoming: []interface{}{"a", 1, "b"}, want: []interface{}{"a", 1.0, "b"}},
+		{name: "nil incoming keeps old", strategy: Sum, old: 1.0, incoming: nil, want: 1.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(tt.strategy, tt.old, tt.incoming, true)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConfigMerge(t *testing.T) {
+	c, err := Parse(map[string]interface{}{
+		"default":        "overwrite",
+		"timestampField": "updatedAt",
+		"collections": map[string]interface{}{
+			"company": map[string]interface{}{
+				"default": "sum",
+				"fields": map[string]interface{}{
+					"tags":    "union",
+					"country": "newest",
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	existing := map[string]interface{}{
+		"revenue":   100.0,
+		"tags":      []interface{}{"bank"},
+		"country":   "CN",
+		"updatedAt": "2024-03-01T00:00:00Z",
+	}
+	// older update: numbers still add up, newest fields are kept
+	merged, err := c.Merge("company", existing, map[string]interface{}{
+		"revenue":   50.0,
+		"tags":      "listed",
+		"country":   "USA",
+		"updatedAt": "2024-01-01T00:00:00Z",
+		"employees": 10.0,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 150.0, merged["revenue"])
+	assert.Equal(t, []interface{}{"bank", "listed"}, merged["tags"])
+	assert.Equal(t, "CN", merged["country"])
+	assert.Equal(t, "2024-03-01T00:00:00Z", merged["updatedAt"])
+	assert.Equal(t, 10.0, merged["employees"])
+	// the existing map is untouched
+	assert.Equal(t, 100.0, existing["revenue"])
+
+	// other collections use the config default
+	merged, err = c.Merge("person", existing, map[string]interface{}{"revenue": 1.0})
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, merged["revenue"])
+}
+
+func TestParse(t *testing.T) {
+	c, err := Parse(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Overwrite, c.StrategyFor("any", "field"))
+
+	_, err = Parse(map[string]interface{}{"default": "average"})
+	assert.Error(t, err)
+
+	_, err = Parse(map[string]interface{}{
+		"collections": map[string]interface{}{
+			"company": map[string]interface{}{"fields": map[string]interface{}{"a": "bogus"}},
+		},
+	})
+	assert.Error(t, err)
+}
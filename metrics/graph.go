@@ -0,0 +1,248 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wonderstone/chainstorm/cache"
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+// ErrorKind names the errors counted apart in the error counter
+type ErrorKind struct {
+	Name string
+	Err  error
+}
+
+// ErrorKinds are matched with errors.Is in order, an error matching none is "other"
+var ErrorKinds = []ErrorKind{
+	{Name: "conflict", Err: handler.ErrConflict},
+	{Name: "cursor_expired", Err: handler.ErrCursorExpired},
+}
+
+func errorKind(err error) string {
+	for _, k := range ErrorKinds {
+		if errors.Is(err, k.Err) {
+			return k.Name
+		}
+	}
+	return "other"
+}
+
+// Graph is a handler.GraphDB measuring the calls to the wrapped one
+type Graph struct {
+	handler.GraphDB
+
+	backend string
+	calls   *Counter
+	errs    *Counter
+	latency *Histogram
+}
+
+var _ handler.GraphDB = (*Graph)(nil)
+var _ handler.TxGraph = (*Graph)(nil)
+
+// Wrap returns g measured in the registry under the backend label,
+// with the node and edge counts of the handler.CountingGraph it is or wraps
+func Wrap(g handler.GraphDB, r *Registry, backend string) *Graph {
+	m := &Graph{
+		GraphDB: g,
+		backend: backend,
+		calls:   r.Counter("chainstorm_operations_total", "Calls by method.", "backend", "method"),
+		errs:    r.Counter("chainstorm_operation_errors_total", "Failed calls by method and error kind.", "backend", "method", "kind"),
+		latency: r.Histogram("chainstorm_operation_duration_seconds", "Call latency by method.", nil, "backend", "method"),
+	}
+	if cg, ok := counting(g); ok {
+		r.Gauge("chainstorm_items", "Nodes and edges stored.", func() []Sample {
+			nodes, edges, err := cg.CountItems()
+			if err != nil {
+				// ~ no sample beats a wrong one
+				return nil
+			}
+			return []Sample{
+				{Values: []string{backend, "node"}, Value: float64(nodes)},
+				{Values: []string{backend, "edge"}, Value: float64(edges)},
+			}
+		}, "backend", "kind")
+	}
+	return m
+}
+
+// counting finds the handler.CountingGraph under the decorators
+func counting(g handler.GraphDB) (handler.CountingGraph, bool) {
+	for {
+		if cg, ok := g.(handler.CountingGraph); ok {
+			return cg, true
+		}
+		u, ok := g.(interface{ Unwrap() handler.GraphDB })
+		if !ok {
+			return nil, false
+		}
+		g = u.Unwrap()
+	}
+}
+
+// Unwrap returns the backend, for its other interfaces
+func (g *Graph) Unwrap() handler.GraphDB {
+	return g.GraphDB
+}
+
+// observe records a call started at start, deferred with the address of its error
+func (g *Graph) observe(method string, start time.Time, err *error) {
+	g.calls.Inc(g.backend, method)
+	g.latency.Observe(time.Since(start).Seconds(), g.backend, method)
+	if *err != nil {
+		g.errs.Inc(g.backend, method, errorKind(*err))
+	}
+}
+
+// RegisterCache reads the stats of the cache at each scrape under the name label
+func RegisterCache(r *Registry, name string, c *cache.Graph) {
+	r.Gauge("chainstorm_cache_entries", "Results kept by the cache.", func() []Sample {
+		return []Sample{{Values: []string{name}, Value: float64(c.Stats().Len)}}
+	}, "cache")
+	r.CounterFunc("chainstorm_cache_lookups_total", "Cache lookups by result.", func() []Sample {
+		s := c.Stats()
+		return []Sample{
+			{Values: []string{name, "hit"}, Value: float64(s.Hits)},
+			{Values: []string{name, "miss"}, Value: float64(s.Misses)},
+		}
+	}, "cache", "result")
+	r.CounterFunc("chainstorm_cache_evictions_total", "Results evicted from the cache.", func() []Sample {
+		return []Sample{{Values: []string{name}, Value: float64(c.Stats().Evictions)}}
+	}, "cache")
+}
+
+// - Init operations
+func (g *Graph) Init(yamlPath string) (err error) {
+	defer g.observe("Init", time.Now(), &err)
+	return g.GraphDB.Init(yamlPath)
+}
+
+// - Connection operations
+func (g *Graph) Connect() (err error) {
+	defer g.observe("Connect", time.Now(), &err)
+	return g.GraphDB.Connect()
+}
+
+func (g *Graph) Disconnect() (err error) {
+	defer g.observe("Disconnect", time.Now(), &err)
+	return g.GraphDB.Disconnect()
+}
+
+// + Create operations
+func (g *Graph) AddNode(n handler.Node) (id interface{}, err error) {
+	defer g.observe("AddNode", time.Now(), &err)
+	return g.GraphDB.AddNode(n)
+}
+
+func (g *Graph) AddEdge(e handler.Edge) (id interface{}, err error) {
+	defer g.observe("AddEdge", time.Now(), &err)
+	return g.GraphDB.AddEdge(e)
+}
+
+// + Update operations
+func (g *Graph) ReplaceNode(n handler.Node) (err error) {
+	defer g.observe("ReplaceNode", time.Now(), &err)
+	return g.GraphDB.ReplaceNode(n)
+}
+
+func (g *Graph) ReplaceEdge(e handler.Edge) (err error) {
+	defer g.observe("ReplaceEdge", time.Now(), &err)
+	return g.GraphDB.ReplaceEdge(e)
+}
+
+func (g *Graph) UpdateNode(n handler.Node) (err error) {
+	defer g.observe("UpdateNode", time.Now(), &err)
+	return g.GraphDB.UpdateNode(n)
+}
+
+func (g *Graph) UpdateEdge(e handler.Edge) (err error) {
+	defer g.observe("UpdateEdge", time.Now(), &err)
+	return g.GraphDB.UpdateEdge(e)
+}
+
+func (g *Graph) MergeNode(n handler.Node) (err error) {
+	defer g.observe("MergeNode", time.Now(), &err)
+	return g.GraphDB.MergeNode(n)
+}
+
+func (g *Graph) MergeEdge(e handler.Edge) (err error) {
+	defer g.observe("MergeEdge", time.Now(), &err)
+	return g.GraphDB.MergeEdge(e)
+}
+
+// + Delete operations
+func (g *Graph) DeleteNode(name interface{}) (err error) {
+	defer g.observe("DeleteNode", time.Now(), &err)
+	return g.GraphDB.DeleteNode(name)
+}
+
+func (g *Graph) DeleteItemByID(id interface{}) (err error) {
+	defer g.observe("DeleteItemByID", time.Now(), &err)
+	return g.GraphDB.DeleteItemByID(id)
+}
+
+// + Query operations
+func (g *Graph) GetItemByID(id interface{}) (item interface{}, err error) {
+	defer g.observe("GetItemByID", time.Now(), &err)
+	return g.GraphDB.GetItemByID(id)
+}
+
+func (g *Graph) GetNode(name interface{}) (n handler.Node, err error) {
+	defer g.observe("GetNode", time.Now(), &err)
+	return g.GraphDB.GetNode(name)
+}
+
+func (g *Graph) GetNodesByRegex(regex string) (nodes []handler.Node, err error) {
+	defer g.observe("GetNodesByRegex", time.Now(), &err)
+	return g.GraphDB.GetNodesByRegex(regex)
+}
+
+func (g *Graph) GetEdgesByRegex(regex string) (edges []handler.Edge, err error) {
+	defer g.observe("GetEdgesByRegex", time.Now(), &err)
+	return g.GraphDB.GetEdgesByRegex(regex)
+}
+
+func (g *Graph) GetFromNodes(name interface{}) (nodes []handler.Node, err error) {
+	defer g.observe("GetFromNodes", time.Now(), &err)
+	return g.GraphDB.GetFromNodes(name)
+}
+
+func (g *Graph) GetToNodes(name interface{}) (nodes []handler.Node, err error) {
+	defer g.observe("GetToNodes", time.Now(), &err)
+	return g.GraphDB.GetToNodes(name)
+}
+
+func (g *Graph) GetInEdges(name interface{}) (edges []handler.Edge, err error) {
+	defer g.observe("GetInEdges", time.Now(), &err)
+	return g.GraphDB.GetInEdges(name)
+}
+
+func (g *Graph) GetOutEdges(name interface{}) (edges []handler.Edge, err error) {
+	defer g.observe("GetOutEdges", time.Now(), &err)
+	return g.GraphDB.GetOutEdges(name)
+}
+
+// + Graph operations
+func (g *Graph) GetAllRelatedNodes(name interface{}) (nodes [][]handler.Node, err error) {
+	defer g.observe("GetAllRelatedNodes", time.Now(), &err)
+	return g.GraphDB.GetAllRelatedNodes(name)
+}
+
+func (g *Graph) GetAllRelatedNodesInEdgeSlice(name interface{}, EdgeSlice ...handler.Edge) (nodes [][]handler.Node, err error) {
+	defer g.observe("GetAllRelatedNodesInEdgeSlice", time.Now(), &err)
+	return g.GraphDB.GetAllRelatedNodesInEdgeSlice(name, EdgeSlice...)
+}
+
+// Update(fn func(tx handler.Tx) error) error
+// the transaction is measured as a whole
+func (g *Graph) Update(fn func(tx handler.Tx) error) (err error) {
+	defer g.observe("Update", time.Now(), &err)
+	txg, ok := g.GraphDB.(handler.TxGraph)
+	if !ok {
+		return fmt.Errorf("%T has no transactions", g.GraphDB)
+	}
+	return txg.Update(fn)
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/cache"
+	"github.com/wonderstone/chainstorm/local"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("calls_total", "Calls.", "method")
+	c.Inc("Get")
+	c.Add(2, `a"b`)
+	h := r.Histogram("latency_seconds", "Latency.", []float64{1, 0.1}, "method")
+	h.Observe(0.05, "Get")
+	h.Observe(0.5, "Get")
+	h.Observe(5, "Get")
+	r.Gauge("size", "Size.", func() []Sample { return []Sample{{Value: 3}} })
+
+	var b strings.Builder
+	assert.NoError(t, r.WriteText(&b))
+	assert.Equal(t, `# HELP calls_total Calls.
+# TYPE calls_total counter
+calls_total{method="Get"} 1
+calls_total{method="a\"b"} 2
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{method="Get",le="0.1"} 1
+latency_seconds_bucket{method="Get",le="1"} 2
+latency_seconds_bucket{method="Get",le="+Inf"} 3
+latency_seconds_sum{method="Get"} 5.55
+latency_seconds_count{method="Get"} 3
+# HELP size Size.
+# TYPE size gauge
+size 3
+`, b.String())
+}
+
+func TestGraph(t *testing.T) {
+	db, err := local.NewInMemoryDB()
+	assert.NoError(t, err)
+	r := NewRegistry()
+	c := cache.Wrap(db, 0)
+	g := Wrap(c, r, "local")
+	RegisterCache(r, "nodes", c)
+
+	n, _ := local.NewNode(local.WithNID("a"), local.WithNCollection("company"), local.WithNName("600001"))
+	_, err = g.AddNode(n)
+	assert.NoError(t, err)
+	_, err = g.GetNode("600001")
+	assert.NoError(t, err)
+	_, err = g.GetNode("missing")
+	assert.Error(t, err)
+	err = g.UpdateNode(&local.Node{ID: "a", Rev: 9})
+	assert.Error(t, err)
+
+	rec := httptest.NewRecorder()
+	Handler(r).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	text := string(body)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, text, `chainstorm_operations_total{backend="local",method="GetNode"} 2`)
+	assert.Contains(t, text, `chainstorm_operation_errors_total{backend="local",method="GetNode",kind="other"} 1`)
+	assert.Contains(t, text, `chainstorm_operation_errors_total{backend="local",method="UpdateNode",kind="conflict"} 1`)
+	assert.Contains(t, text, `chainstorm_operation_duration_seconds_count{backend="local",method="AddNode"} 1`)
+	assert.Contains(t, text, `chainstorm_items{backend="local",kind="node"} 1`)
+	assert.Contains(t, text, `chainstorm_cache_lookups_total{cache="nodes",result="miss"} 2`)
+}
+
+func TestTwoRegistrations(t *testing.T) {
+	r := NewRegistry()
+	for _, backend := range []string{"first", "second"} {
+		db, err := local.NewInMemoryDB()
+		assert.NoError(t, err)
+		c := cache.Wrap(db, 0)
+		g := Wrap(c, r, backend)
+		RegisterCache(r, backend, c)
+		n, _ := local.NewNode(local.WithNID("a"), local.WithNCollection("company"), local.WithNName("600001"))
+		_, err = g.AddNode(n)
+		assert.NoError(t, err)
+		_, err = g.GetNode("600001")
+		assert.NoError(t, err)
+	}
+
+	// both registrants are exported, the family is written once
+	var b strings.Builder
+	assert.NoError(t, r.WriteText(&b))
+	text := b.String()
+	assert.Equal(t, 1, strings.Count(text, "# TYPE chainstorm_items gauge"))
+	assert.Contains(t, text, `chainstorm_items{backend="first",kind="node"} 1`)
+	assert.Contains(t, text, `chainstorm_items{backend="second",kind="node"} 1`)
+	assert.Contains(t, text, `chainstorm_cache_entries{cache="first"} 1`)
+	assert.Contains(t, text, `chainstorm_cache_entries{cache="second"} 1`)
+}
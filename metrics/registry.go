@@ -0,0 +1,257 @@
+// Package metrics instruments a handler.GraphDB and serves the measures in the
+// Prometheus text format, without any client library or external service.
+//
+// Wrap counts the calls, the errors by kind and the latency of every method;
+// a Registry also reads gauges at each scrape, such as the node and edge counts
+// of the backend or the size of a cache. Handler serves the Registry on /metrics.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the latency histograms
+var DefaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Sample is a gauge value read at a scrape, the label values follow the label names of its gauge
+type Sample struct {
+	Values []string
+	Value  float64
+}
+
+// family is a metric with its series, one per set of label values
+type family struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	// counters and histograms keep their series, the collected ones read theirs
+	series  map[string]*series
+	buckets []float64
+	// every registration of the name adds its collect, told apart by the label values
+	collect []func() []Sample
+}
+
+// series is the state of a counter or a histogram for its label values
+type series struct {
+	values []string
+	count  float64
+	sum    float64
+	// counts are per bucket, not cumulative
+	counts []uint64
+}
+
+// Registry holds the metrics
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// register returns the family of the name, the first registration defines it
+func (r *Registry) register(name, help, typ string, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.families[name]; ok {
+		return f
+	}
+	f := &family{name: name, help: help, typ: typ, labels: labels, series: make(map[string]*series)}
+	r.families[name] = f
+	return f
+}
+
+// seriesOf returns the series of the label values, the caller holds the lock
+func (f *family) seriesOf(values []string) *series {
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...), counts: make([]uint64, len(f.buckets))}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Counter counts events by label values
+type Counter struct {
+	r *Registry
+	f *family
+}
+
+// Counter registers a counter with the label names
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r: r, f: r.register(name, help, "counter", labels)}
+}
+
+// Add adds v to the series of the label values
+func (c *Counter) Add(v float64, values ...string) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.f.seriesOf(values).count += v
+}
+
+// Inc adds one to the series of the label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Histogram counts observations into buckets by label values
+type Histogram struct {
+	r *Registry
+	f *family
+}
+
+// Histogram registers a histogram with the bucket upper bounds, DefaultBuckets if nil
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	f := r.register(name, help, "histogram", labels)
+	r.mu.Lock()
+	if f.buckets == nil {
+		f.buckets = append([]float64(nil), buckets...)
+		sort.Float64s(f.buckets)
+	}
+	r.mu.Unlock()
+	return &Histogram{r: r, f: f}
+}
+
+// Observe adds v to the series of the label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.r.mu.Lock()
+	defer h.r.mu.Unlock()
+	s := h.f.seriesOf(values)
+	s.count++
+	s.sum += v
+	if i := sort.SearchFloat64s(h.f.buckets, v); i < len(h.f.buckets) {
+		s.counts[i]++
+	}
+}
+
+// Gauge registers a gauge read by collect at each scrape
+func (r *Registry) Gauge(name, help string, collect func() []Sample, labels ...string) {
+	r.collector(name, help, "gauge", collect, labels)
+}
+
+// CounterFunc registers a counter kept elsewhere, read by collect at each scrape
+func (r *Registry) CounterFunc(name, help string, collect func() []Sample, labels ...string) {
+	r.collector(name, help, "counter", collect, labels)
+}
+
+func (r *Registry) collector(name, help, typ string, collect func() []Sample, labels []string) {
+	f := r.register(name, help, typ, labels)
+	r.mu.Lock()
+	f.collect = append(f.collect, collect)
+	r.mu.Unlock()
+}
+
+// WriteText writes the metrics in the Prometheus text format, sorted by name
+func (r *Registry) WriteText(w io.Writer) error {
+	// # the gauges are read outside the lock, they may call the graph
+	r.mu.Lock()
+	families := make([]*family, 0, len(r.families))
+	collects := make(map[*family][]func() []Sample)
+	for _, f := range r.families {
+		families = append(families, f)
+		if f.collect != nil {
+			collects[f] = append([]func() []Sample(nil), f.collect...)
+		}
+	}
+	r.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	var b strings.Builder
+	for _, f := range families {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.typ)
+		if collect, ok := collects[f]; ok {
+			for _, c := range collect {
+				for _, s := range c() {
+					writeSample(&b, f.name, f.labels, s.Values, "", s.Value)
+				}
+			}
+			continue
+		}
+		r.mu.Lock()
+		keys := make([]string, 0, len(f.series))
+		for k := range f.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s := f.series[k]
+			if f.typ != "histogram" {
+				writeSample(&b, f.name, f.labels, s.values, "", s.count)
+				continue
+			}
+			var cumulative uint64
+			for i, bound := range f.buckets {
+				cumulative += s.counts[i]
+				writeSample(&b, f.name+"_bucket", f.labels, s.values, formatFloat(bound), float64(cumulative))
+			}
+			writeSample(&b, f.name+"_bucket", f.labels, s.values, "+Inf", s.count)
+			writeSample(&b, f.name+"_sum", f.labels, s.values, "", s.sum)
+			writeSample(&b, f.name+"_count", f.labels, s.values, "", s.count)
+		}
+		r.mu.Unlock()
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeSample writes a line, le is the bucket label of a histogram
+func writeSample(b *strings.Builder, name string, labels, values []string, le string, v float64) {
+	b.WriteString(name)
+	var pairs []string
+	for i, l := range labels {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs = append(pairs, l+`="`+escapeValue(value)+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	if len(pairs) > 0 {
+		b.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	b.WriteString(" " + formatFloat(v) + "\n")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var valueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeValue(s string) string { return valueEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+// Handler serves the registry, mount it on /metrics
+func Handler(r *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.WriteText(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package mongo
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"github.com/wonderstone/chainstorm/resolve"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// the MongoGraph finds the nodes by their aliases
+var _ handler.AliasGraph = (*MongoGraph)(nil)
+
+// resolveName returns the name of the node owning the alias, names are returned as they are
+// the route of the node is looked up by its indexed name or alias
+func (mg *MongoGraph) resolveName(name string) string {
+	if _, ok := mg.cachedNode(name); ok {
+		return name
+	}
+	r, ok, err := mg.findRoute(bson.M{"kind": history.NodeKind, "$or": bson.A{
+		bson.M{"name": name},
+		bson.M{"aliases": name},
+	}})
+	// ~ the error comes again from the lookup of the name
+	if err != nil || !ok {
+		return name
+	}
+	return r.Name
+}
+
+// checkAliases returns an error if a name is already a node name or an alias of another node
+// id is the hex ID of the node, blank for a new one
+func (mg *MongoGraph) checkAliases(id string, names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	for _, a := range names {
+		if a == "" {
+			return fmt.Errorf("alias is empty")
+		}
+	}
+	cursor, err := mg.client.Database(mg.database).Collection(routeCollection).Find(mg.context(), bson.M{
+		"kind": history.NodeKind,
+		"$or": bson.A{
+			bson.M{"name": bson.M{"$in": names}},
+			bson.M{"aliases": bson.M{"$in": names}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	var routes []route
+	if err := cursor.All(mg.context(), &routes); err != nil {
+		return err
+	}
+	for _, a := range names {
+		for _, r := range routes {
+			if r.Name == a {
+				return fmt.Errorf("alias %s is already a node name", a)
+			}
+			if r.ID.Hex() != id && slices.Contains(r.Aliases, a) {
+				return fmt.Errorf("alias %s belongs to node %s", a, r.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// AddAliases(name interface{}, aliases ...string) error
+func (mg *MongoGraph) AddAliases(name interface{}, aliases ...string) error {
+	nodetmp, err := mg.GetNode(name)
+	if err != nil {
+		return err
+	}
+	n := nodetmp.(*Node)
+	if err := mg.checkAliases(n.ID.Hex(), aliases...); err != nil {
+		return err
+	}
+	n.Data = resolve.AddAliases(n.Data, handler.AliasesKey, aliases...)
+	return mg.ReplaceNode(n)
+}
+
+// RemoveAliases(name interface{}, aliases ...string) error
+func (mg *MongoGraph) RemoveAliases(name interface{}, aliases ...string) error {
+	nodetmp, err := mg.GetNode(name)
+	if err != nil {
+		return err
+	}
+	n := nodetmp.(*Node)
+	n.Data = resolve.RemoveAliases(n.Data, handler.AliasesKey, aliases...)
+	return mg.ReplaceNode(n)
+}
+
+// GetAliases(name interface{}) ([]string, error)
+func (mg *MongoGraph) GetAliases(name interface{}) ([]string, error) {
+	nodetmp, err := mg.GetNode(name)
+	if err != nil {
+		return nil, err
+	}
+	return resolve.Aliases(nodetmp.(*Node).Data, handler.AliasesKey), nil
+}
@@ -0,0 +1,318 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"github.com/wonderstone/chainstorm/resolve"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// # the catalog tells the kind of each collection, the routes the collection of each item:
+// # Connect reads the catalog only, the maps cache the routes read since, by ID or by an indexed name or alias
+
+const (
+	// catalogCollection holds a catalogEntry per node or edge collection
+	catalogCollection = handler.ReservedPrefix + "catalog"
+	// routeCollection holds a route per node or edge
+	routeCollection = handler.ReservedPrefix + "routes"
+	// catalogVersion is the schema version of the collections created now
+	catalogVersion = 1
+)
+
+// catalogEntry describes a node or edge collection
+type catalogEntry struct {
+	Collection string       `bson:"_id"`
+	Kind       history.Kind `bson:"kind"`
+	Version    int          `bson:"version"`
+	Indexes    []string     `bson:"indexes"`
+}
+
+// route locates an item, the nodes keep their name and aliases to be found by them
+type route struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Collection string             `bson:"collection"`
+	Kind       history.Kind       `bson:"kind"`
+	Name       string             `bson:"name,omitempty"`
+	Aliases    []string           `bson:"aliases,omitempty"`
+}
+
+// routeOf returns the route of the version of the item
+func routeOf(oid primitive.ObjectID, v *history.Version) route {
+	r := route{ID: oid, Collection: v.Collection, Kind: v.Kind}
+	if v.Kind == history.NodeKind {
+		r.Name = v.Name
+		r.Aliases = resolve.Aliases(v.Data, handler.AliasesKey)
+	}
+	return r
+}
+
+// putCatalog records the collection in the catalog
+func (mg *MongoGraph) putCatalog(collection string, kind history.Kind, indexes []string) error {
+	entry := catalogEntry{Collection: collection, Kind: kind, Version: catalogVersion, Indexes: indexes}
+	_, err := mg.client.Database(mg.database).Collection(catalogCollection).
+		ReplaceOne(mg.context(), bson.M{"_id": collection}, entry, options.Replace().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	mg.catalog[collection] = entry
+	mg.collSet[catalogCollection] = void{}
+	return nil
+}
+
+// dropCatalog removes the collection from the catalog
+func (mg *MongoGraph) dropCatalog(collection string) error {
+	delete(mg.catalog, collection)
+	_, err := mg.client.Database(mg.database).Collection(catalogCollection).
+		DeleteOne(mg.context(), bson.M{"_id": collection})
+	return err
+}
+
+// collectionsOf returns the collections of the kind, sorted
+func (mg *MongoGraph) collectionsOf(kind history.Kind) []string {
+	var res []string
+	for col, entry := range mg.catalog {
+		if entry.Kind == kind {
+			res = append(res, col)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// nodeCollections returns the collections holding nodes, sorted
+func (mg *MongoGraph) nodeCollections() []string {
+	return mg.collectionsOf(history.NodeKind)
+}
+
+// edgeCollections returns the collections holding edges, sorted
+func (mg *MongoGraph) edgeCollections() []string {
+	return mg.collectionsOf(history.EdgeKind)
+}
+
+// routeIndexes find the nodes by name and by alias
+func routeIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "name", Value: 1}}},
+		{Keys: bson.D{{Key: "aliases", Value: 1}}},
+	}
+}
+
+// ensureRouteIndexes indexes the routes once at Connect, creating an index twice is a no-op
+func (mg *MongoGraph) ensureRouteIndexes() error {
+	_, err := mg.client.Database(mg.database).Collection(routeCollection).
+		Indexes().CreateMany(mg.context(), routeIndexes())
+	if err != nil {
+		return err
+	}
+	mg.collSet[routeCollection] = void{}
+	return nil
+}
+
+// saveRoute makes the route follow the version of the item, nil once deleted
+// the route it had leaves the maps, the new one enters them
+func (mg *MongoGraph) saveRoute(oid primitive.ObjectID, next *history.Version) error {
+	col := mg.client.Database(mg.database).Collection(routeCollection)
+	var res *mongo.SingleResult
+	if next == nil {
+		res = col.FindOneAndDelete(mg.context(), bson.M{"_id": oid})
+	} else {
+		res = col.FindOneAndReplace(mg.context(), bson.M{"_id": oid}, routeOf(oid, next), options.FindOneAndReplace().SetUpsert(true))
+	}
+	var old route
+	if err := res.Decode(&old); err == nil {
+		mg.forgetRoute(old)
+	} else if err != mongo.ErrNoDocuments {
+		return err
+	}
+	if next != nil {
+		mg.useRoute(routeOf(oid, next))
+	}
+	mg.collSet[routeCollection] = void{}
+	return nil
+}
+
+// useRoute puts the item in the maps
+func (mg *MongoGraph) useRoute(r route) {
+	mg.routesMu.Lock()
+	defer mg.routesMu.Unlock()
+	mg.itemSet[r.ID.Hex()] = r.Collection
+	if r.Kind == history.NodeKind {
+		mg.nodeNameCollMap[r.Name] = r.Collection
+	}
+}
+
+// forgetRoute takes the item out of the maps
+func (mg *MongoGraph) forgetRoute(r route) {
+	mg.routesMu.Lock()
+	defer mg.routesMu.Unlock()
+	delete(mg.itemSet, r.ID.Hex())
+	if r.Kind == history.NodeKind {
+		delete(mg.nodeNameCollMap, r.Name)
+	}
+}
+
+// cachedItem returns the collection of the item if the maps have its route
+func (mg *MongoGraph) cachedItem(oid primitive.ObjectID) (string, bool) {
+	mg.routesMu.RLock()
+	defer mg.routesMu.RUnlock()
+	col, ok := mg.itemSet[oid.Hex()]
+	return col, ok
+}
+
+// cachedNode returns the collection of the node of the name if the maps have its route
+func (mg *MongoGraph) cachedNode(name string) (string, bool) {
+	mg.routesMu.RLock()
+	defer mg.routesMu.RUnlock()
+	col, ok := mg.nodeNameCollMap[name]
+	return col, ok
+}
+
+// findRoute returns the route matching the filter, false if none
+func (mg *MongoGraph) findRoute(filter bson.M) (route, bool, error) {
+	var r route
+	err := mg.client.Database(mg.database).Collection(routeCollection).
+		FindOne(mg.context(), filter).Decode(&r)
+	if err == mongo.ErrNoDocuments {
+		return r, false, nil
+	} else if err != nil {
+		return r, false, err
+	}
+	mg.useRoute(r)
+	return r, true, nil
+}
+
+// locate returns the collection of the item, from the maps or else from its route
+func (mg *MongoGraph) locate(oid primitive.ObjectID) (string, error) {
+	if col, ok := mg.cachedItem(oid); ok {
+		return col, nil
+	}
+	r, ok, err := mg.findRoute(bson.M{"_id": oid})
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("item not found")
+	}
+	return r.Collection, nil
+}
+
+// exists tells whether the item exists, from the maps or else from its route
+func (mg *MongoGraph) exists(oid primitive.ObjectID) (bool, error) {
+	if _, ok := mg.cachedItem(oid); ok {
+		return true, nil
+	}
+	_, ok, err := mg.findRoute(bson.M{"_id": oid})
+	return ok, err
+}
+
+// nodeCollection returns the collection of the node of the name, from the maps or else from its route
+func (mg *MongoGraph) nodeCollection(name string) (string, bool, error) {
+	if col, ok := mg.cachedNode(name); ok {
+		return col, true, nil
+	}
+	r, ok, err := mg.findRoute(bson.M{"kind": history.NodeKind, "name": name})
+	return r.Collection, ok, err
+}
+
+// resetMaps empties the maps of the items in place, the copies of mg made by Update and WithContext share them
+func (mg *MongoGraph) resetMaps() {
+	clear(mg.catalog)
+	mg.routesMu.Lock()
+	defer mg.routesMu.Unlock()
+	clear(mg.nodeNameCollMap)
+	clear(mg.itemSet)
+}
+
+// loadCatalog reads the catalog and empties the maps, the routes are read when needed
+// a database without catalog is scanned once and gets one
+func (mg *MongoGraph) loadCatalog() error {
+	ctx := mg.context()
+	db := mg.client.Database(mg.database)
+	collections, err := db.ListCollectionNames(ctx, bson.D{})
+	if err != nil {
+		return err
+	}
+	clear(mg.collSet)
+	for _, col := range collections {
+		mg.collSet[col] = void{}
+	}
+	if !mg.collectionExists(catalogCollection) {
+		mg.log().Info().Str("database", mg.database).Msg("No catalog, scanning the collections")
+		return mg.updateNameCollMap_IDSet()
+	}
+
+	mg.resetMaps()
+
+	cursor, err := db.Collection(catalogCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	var entries []catalogEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		mg.catalog[e.Collection] = e
+	}
+	return nil
+}
+
+// saveCatalog writes the catalog and the routes found by the scan of updateNameCollMap_IDSet
+func (mg *MongoGraph) saveCatalog(routes []route) error {
+	ctx := mg.context()
+	db := mg.client.Database(mg.database)
+	if _, err := db.Collection(routeCollection).DeleteMany(ctx, bson.M{}); err != nil {
+		return err
+	}
+	// % in batches, the documents of an InsertMany share a message
+	const batch = 1000
+	for i := 0; i < len(routes); i += batch {
+		end := i + batch
+		if end > len(routes) {
+			end = len(routes)
+		}
+		docs := make([]interface{}, 0, end-i)
+		for _, r := range routes[i:end] {
+			docs = append(docs, r)
+		}
+		if _, err := db.Collection(routeCollection).InsertMany(ctx, docs); err != nil {
+			return err
+		}
+	}
+	mg.collSet[routeCollection] = void{}
+	for col, entry := range mg.catalog {
+		if err := mg.putCatalog(col, entry.Kind, entry.Indexes); err != nil {
+			return err
+		}
+	}
+	// ~ the catalog collection marks the database as scanned, even without collections
+	if !mg.collectionExists(catalogCollection) {
+		err := db.CreateCollection(ctx, catalogCollection)
+		// 48 is NamespaceExists
+		if e, ok := err.(mongo.CommandError); err != nil && !(ok && e.Code == 48) {
+			return err
+		}
+		mg.collSet[catalogCollection] = void{}
+	}
+	return nil
+}
+
+// indexNames returns the names of the indexes of the collection
+func indexNames(ctx context.Context, col *mongo.Collection) ([]string, error) {
+	specs, err := col.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(specs))
+	for i, s := range specs {
+		names[i] = s.Name
+	}
+	return names, nil
+}
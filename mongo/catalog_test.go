@@ -0,0 +1,101 @@
+package mongo
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRouteOf(t *testing.T) {
+	oid := primitive.NewObjectID()
+	node := history.NodeVersion(history.Create, oid.Hex(), "people", "alice",
+		map[string]interface{}{handler.AliasesKey: []interface{}{"al"}})
+	r := routeOf(oid, node)
+	want := route{ID: oid, Collection: "people", Kind: history.NodeKind, Name: "alice", Aliases: []string{"al"}}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("Expected %v, got %v", want, r)
+	}
+
+	// the edges keep their collection only
+	edge := &history.Version{ItemID: oid.Hex(), Kind: history.EdgeKind, Collection: "knows", Relationship: "friend"}
+	r = routeOf(oid, edge)
+	if r.Name != "" || r.Aliases != nil || r.Collection != "knows" {
+		t.Errorf("Unexpected edge route %v", r)
+	}
+}
+
+func TestCollectionsOf(t *testing.T) {
+	mg := &MongoGraph{catalog: map[string]catalogEntry{
+		"people": {Collection: "people", Kind: history.NodeKind},
+		"knows":  {Collection: "knows", Kind: history.EdgeKind},
+		"cities": {Collection: "cities", Kind: history.NodeKind},
+	}}
+	if got := mg.nodeCollections(); !reflect.DeepEqual(got, []string{"cities", "people"}) {
+		t.Errorf("Expected the node collections, got %v", got)
+	}
+	if got := mg.edgeCollections(); !reflect.DeepEqual(got, []string{"knows"}) {
+		t.Errorf("Expected the edge collections, got %v", got)
+	}
+	if !mg.isEdgeCollection("knows") || mg.isEdgeCollection("people") {
+		t.Errorf("Unexpected kinds")
+	}
+}
+
+func TestRouteMaps(t *testing.T) {
+	mg := &MongoGraph{nodeNameCollMap: map[string]string{}, itemSet: map[string]string{}, routesMu: &sync.RWMutex{}}
+	oid := primitive.NewObjectID()
+	old := route{ID: oid, Collection: "people", Kind: history.NodeKind, Name: "alice"}
+	mg.useRoute(old)
+	if col, _, _ := mg.nodeCollection("alice"); col != "people" {
+		t.Errorf("Expected the cached collection, got %v", col)
+	}
+	if col, _ := mg.locate(oid); col != "people" {
+		t.Errorf("Expected the cached collection, got %v", col)
+	}
+
+	// a renamed node leaves its former name behind
+	mg.forgetRoute(old)
+	mg.useRoute(route{ID: oid, Collection: "people", Kind: history.NodeKind, Name: "alicia"})
+	if _, ok := mg.nodeNameCollMap["alice"]; ok {
+		t.Errorf("Expected the former name to be forgotten")
+	}
+	if mg.nodeNameCollMap["alicia"] != "people" || mg.itemSet[oid.Hex()] != "people" {
+		t.Errorf("Unexpected maps %v %v", mg.nodeNameCollMap, mg.itemSet)
+	}
+}
+
+func TestRouteMapsParallel(t *testing.T) {
+	mg := &MongoGraph{nodeNameCollMap: map[string]string{}, itemSet: map[string]string{}, routesMu: &sync.RWMutex{}}
+	known := route{ID: primitive.NewObjectID(), Collection: "people", Kind: history.NodeKind, Name: "alice"}
+	mg.useRoute(known)
+
+	// the copies of mg share the maps and their lock, run it with -race
+	view := *mg
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r := route{ID: primitive.NewObjectID(), Collection: "people", Kind: history.NodeKind, Name: fmt.Sprintf("n%d-%d", i, j)}
+				view.useRoute(r)
+				if col, err := mg.locate(known.ID); err != nil || col != "people" {
+					t.Errorf("Expected the cached collection, got %v %v", col, err)
+				}
+				if col, ok, err := view.nodeCollection("alice"); err != nil || !ok || col != "people" {
+					t.Errorf("Expected the cached node, got %v %v %v", col, ok, err)
+				}
+				mg.forgetRoute(r)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(mg.itemSet) != 1 || len(mg.nodeNameCollMap) != 1 {
+		t.Errorf("Expected the known route only, got %v %v", mg.itemSet, mg.nodeNameCollMap)
+	}
+}
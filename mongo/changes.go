@@ -0,0 +1,132 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// the MongoGraph has a change feed over a change stream
+var _ handler.ChangeGraph = (*MongoGraph)(nil)
+
+// # record inserts a change document next to the versions, in the same transaction if any,
+// # so a change stream on the changes collection sees every committed write once with its before and after images
+// # and the resume token of the stream is the cursor. The documents expire after changesRetention,
+// # resuming also needs the token to be still in the oplog
+
+// changesRetention is how long the change documents are kept
+const changesRetention = 7 * 24 * time.Hour
+
+// the server codes of a resume token no longer in the oplog
+const (
+	codeChangeStreamFatal       = 280
+	codeChangeStreamHistoryLost = 286
+)
+
+// changeDoc is the stored handler.ChangeEvent
+type changeDoc struct {
+	Op         history.Op       `bson:"op"`
+	Kind       history.Kind     `bson:"kind"`
+	ID         string           `bson:"item"`
+	Collection string           `bson:"collection"`
+	Before     *history.Version `bson:"before,omitempty"`
+	After      *history.Version `bson:"after,omitempty"`
+	Time       time.Time        `bson:"time"`
+}
+
+func toChangeDoc(e handler.ChangeEvent) changeDoc {
+	return changeDoc{Op: e.Op, Kind: e.Kind, ID: e.ID, Collection: e.Collection, Before: e.Before, After: e.After, Time: e.Time}
+}
+
+func (d changeDoc) event() handler.ChangeEvent {
+	return handler.ChangeEvent{Op: d.Op, Kind: d.Kind, ID: d.ID, Collection: d.Collection, Before: d.Before, After: d.After, Time: d.Time.UTC()}
+}
+
+// changesCollection returns the changes collection, created with its TTL index on first use
+func (mg *MongoGraph) changesCollection() (*mongo.Collection, error) {
+	col := mg.client.Database(mg.database).Collection(handler.ChangesCollection)
+	if mg.collectionExists(handler.ChangesCollection) {
+		return col, nil
+	}
+	_, err := col.Indexes().CreateOne(mg.context(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "time", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(changesRetention.Seconds())),
+	})
+	if err != nil {
+		return nil, err
+	}
+	mg.collSet[handler.ChangesCollection] = void{}
+	return col, nil
+}
+
+// publish stores the event of a write
+func (mg *MongoGraph) publish(e handler.ChangeEvent) error {
+	col, err := mg.changesCollection()
+	if err != nil {
+		return err
+	}
+	_, err = col.InsertOne(mg.context(), toChangeDoc(e))
+	return err
+}
+
+// changesPipeline matches the inserted change documents selected by the filter
+func changesPipeline(f handler.ChangeFilter) mongo.Pipeline {
+	match := bson.M{"operationType": "insert"}
+	if len(f.Ops) > 0 {
+		match["fullDocument.op"] = bson.M{"$in": f.Ops}
+	}
+	if len(f.Kinds) > 0 {
+		match["fullDocument.kind"] = bson.M{"$in": f.Kinds}
+	}
+	if len(f.Collections) > 0 {
+		match["fullDocument.collection"] = bson.M{"$in": f.Collections}
+	}
+	return mongo.Pipeline{{{Key: "$match", Value: match}}}
+}
+
+// Subscribe(ctx context.Context, f handler.ChangeFilter) (<-chan handler.ChangeEvent, error)
+// the cursor is the _data of the resume token
+func (mg *MongoGraph) Subscribe(ctx context.Context, f handler.ChangeFilter) (<-chan handler.ChangeEvent, error) {
+	opts := options.ChangeStream()
+	if f.Cursor != "" {
+		opts.SetResumeAfter(bson.M{"_data": f.Cursor})
+	}
+	col := mg.client.Database(mg.database).Collection(handler.ChangesCollection)
+	stream, err := col.Watch(ctx, changesPipeline(f), opts)
+	if err != nil {
+		var se mongo.ServerError
+		if errors.As(err, &se) && (se.HasErrorCode(codeChangeStreamHistoryLost) || se.HasErrorCode(codeChangeStreamFatal)) {
+			return nil, handler.ErrCursorExpired
+		}
+		return nil, fmt.Errorf("failed to watch the changes: %w", err)
+	}
+
+	ch := make(chan handler.ChangeEvent)
+	go func() {
+		defer close(ch)
+		defer stream.Close(context.Background())
+		for stream.Next(ctx) {
+			var change struct {
+				Doc changeDoc `bson:"fullDocument"`
+			}
+			if err := stream.Decode(&change); err != nil {
+				return
+			}
+			e := change.Doc.event()
+			e.Cursor, _ = stream.ResumeToken().Lookup("_data").StringValueOK()
+			select {
+			case ch <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,29 @@
+package mongo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestChangesPipeline(t *testing.T) {
+	match := changesPipeline(handler.ChangeFilter{})[0][0].Value
+	if !reflect.DeepEqual(match, bson.M{"operationType": "insert"}) {
+		t.Errorf("Expected the inserts alone, got %v", match)
+	}
+	match = changesPipeline(handler.ChangeFilter{
+		Kinds:       []history.Kind{history.NodeKind},
+		Collections: []string{"company"},
+	})[0][0].Value
+	want := bson.M{
+		"operationType":           "insert",
+		"fullDocument.kind":       bson.M{"$in": []history.Kind{history.NodeKind}},
+		"fullDocument.collection": bson.M{"$in": []string{"company"}},
+	}
+	if !reflect.DeepEqual(match, want) {
+		t.Errorf("Expected %v, got %v", want, match)
+	}
+}
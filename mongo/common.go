@@ -1,6 +1,10 @@
 package mongo
 
-import "fmt"
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 func recoverFromPanic(err *error) {
 	if r := recover(); r != nil {
@@ -11,3 +15,15 @@ func recoverFromPanic(err *error) {
 		}
 	}
 }
+
+// toObjectID accepts the primitive.ObjectID or its hex string
+func toObjectID(id interface{}) (primitive.ObjectID, error) {
+	switch v := id.(type) {
+	case primitive.ObjectID:
+		return v, nil
+	case string:
+		return primitive.ObjectIDFromHex(v)
+	default:
+		return primitive.NilObjectID, fmt.Errorf("invalid id %v", id)
+	}
+}
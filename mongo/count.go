@@ -0,0 +1,33 @@
+package mongo
+
+import (
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+// the MongoGraph tells its size
+var _ handler.CountingGraph = (*MongoGraph)(nil)
+
+// CountItems() (nodes, edges int64, err error)
+// ~ the counts come from the collection metadata, they are estimates after an unclean shutdown
+func (mg *MongoGraph) CountItems() (nodes, edges int64, err error) {
+	if nodes, err = mg.countCollections(mg.nodeCollections()); err != nil {
+		return 0, 0, err
+	}
+	if edges, err = mg.countCollections(mg.edgeCollections()); err != nil {
+		return 0, 0, err
+	}
+	return nodes, edges, nil
+}
+
+// countCollections sums the document counts of the collections
+func (mg *MongoGraph) countCollections(collections []string) (int64, error) {
+	var total int64
+	for _, col := range collections {
+		n, err := mg.client.Database(mg.database).Collection(col).EstimatedDocumentCount(mg.context())
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
@@ -0,0 +1,123 @@
+package mongo
+
+import (
+	"sort"
+
+	"github.com/wonderstone/chainstorm/filter"
+	"github.com/wonderstone/chainstorm/handler"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// the MongoGraph translates the filters to BSON queries
+var _ handler.FilterGraph = (*MongoGraph)(nil)
+
+// bsonOps are the query operators of the comparisons
+var bsonOps = map[filter.Op]string{
+	filter.OpEq: "$eq", filter.OpNe: "$ne", filter.OpIn: "$in",
+	filter.OpGt: "$gt", filter.OpGte: "$gte", filter.OpLt: "$lt", filter.OpLte: "$lte",
+}
+
+// bsonFilter translates a valid filter on the data field
+// ~ the ranges need no type check, mongo compares the numbers and the strings apart
+func bsonFilter(f filter.Expr) bson.M {
+	switch f.Op {
+	case filter.OpAnd, filter.OpOr:
+		if len(f.Exprs) == 0 {
+			if f.Op == filter.OpAnd {
+				return bson.M{}
+			}
+			// $or refuses an empty array
+			return bson.M{"_id": bson.M{"$exists": false}}
+		}
+		subs := make(bson.A, len(f.Exprs))
+		for i, sub := range f.Exprs {
+			subs[i] = bsonFilter(sub)
+		}
+		return bson.M{"$" + string(f.Op): subs}
+	case filter.OpNot:
+		return bson.M{"$nor": bson.A{bsonFilter(f.Exprs[0])}}
+	case filter.OpExists:
+		return bson.M{"data." + f.Path: bson.M{"$exists": true, "$ne": nil}}
+	case filter.OpIn:
+		return bson.M{"data." + f.Path: bson.M{"$in": filter.Values(f.Value)}}
+	}
+	return bson.M{"data." + f.Path: bson.M{bsonOps[f.Op]: f.Value}}
+}
+
+// findDocs runs the query on the collections of the catalog, only the given one if not blank
+// cols is mg.nodeCollections() or mg.edgeCollections()
+func (mg *MongoGraph) findDocs(cols []string, collection string, query bson.M) ([]primitive.M, error) {
+	db := mg.client.Database(mg.database)
+	var docs []primitive.M
+	for _, col := range cols {
+		if collection != "" && col != collection {
+			continue
+		}
+		cursor, err := db.Collection(col).Find(mg.context(), query)
+		if err != nil {
+			return nil, err
+		}
+		var found []primitive.M
+		err = cursor.All(mg.context(), &found)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, found...)
+	}
+	return docs, nil
+}
+
+// FindNodes(collection string, f filter.Expr) ([]handler.Node, error)
+// the nodes are sorted by name
+func (mg *MongoGraph) FindNodes(collection string, f filter.Expr) ([]handler.Node, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	docs, err := mg.findDocs(mg.nodeCollections(), collection, bsonFilter(f))
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*Node, 0, len(docs))
+	for _, doc := range docs {
+		n, err := mapToNode(doc)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, &n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	result := make([]handler.Node, len(nodes))
+	for i, n := range nodes {
+		result[i] = n
+	}
+	return result, nil
+}
+
+// FindEdges(collection string, f filter.Expr) ([]handler.Edge, error)
+// the edges are sorted by ID
+func (mg *MongoGraph) FindEdges(collection string, f filter.Expr) ([]handler.Edge, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	docs, err := mg.findDocs(mg.edgeCollections(), collection, bsonFilter(f))
+	if err != nil {
+		return nil, err
+	}
+	edges := make([]*Edge, 0, len(docs))
+	for _, doc := range docs {
+		e, err := mapToEdge(doc)
+		if err != nil {
+			return nil, err
+		}
+		edges = append(edges, &e)
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].ID.Hex() < edges[j].ID.Hex() })
+
+	result := make([]handler.Edge, len(edges))
+	for i, e := range edges {
+		result[i] = e
+	}
+	return result, nil
+}
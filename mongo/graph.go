@@ -0,0 +1,346 @@
+package mongo
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// # the edges live in edge collections indexed on from and to,
+// # the adjacency collection mirrors them both ways for $graphLookup, whatever their collection
+
+// adjacencyCollection holds an adjacency document per edge and direction
+const adjacencyCollection = handler.ReservedPrefix + "adjacency"
+
+// adjacency links the node a to its neighbour b by the edge
+type adjacency struct {
+	Edge primitive.ObjectID `bson:"edge"`
+	A    primitive.ObjectID `bson:"a"`
+	B    primitive.ObjectID `bson:"b"`
+	// Out tells b is the target of the edge, a its source
+	Out bool `bson:"out"`
+}
+
+// adjacencyDocs returns the two adjacency documents of an edge
+func adjacencyDocs(edge, from, to primitive.ObjectID) []interface{} {
+	return []interface{}{
+		adjacency{Edge: edge, A: from, B: to, Out: true},
+		adjacency{Edge: edge, A: to, B: from, Out: false},
+	}
+}
+
+// edgeIndexes are the indexes of the edge collections
+func edgeIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "from", Value: 1}}},
+		{Keys: bson.D{{Key: "to", Value: 1}}},
+	}
+}
+
+// createEdgeCollection creates a collection for the edges, indexed on from and to
+func (mg *MongoGraph) createEdgeCollection(collection string) error {
+	db := mg.client.Database(mg.database)
+	if err := db.CreateCollection(mg.context(), collection); err != nil {
+		return err
+	}
+	indexes, err := db.Collection(collection).Indexes().CreateMany(mg.context(), edgeIndexes())
+	if err != nil {
+		return err
+	}
+	mg.collSet[collection] = void{}
+	return mg.putCatalog(collection, history.EdgeKind, indexes)
+}
+
+// isEdgeCollection tells whether the collection holds edges
+func (mg *MongoGraph) isEdgeCollection(collection string) bool {
+	return mg.catalog[collection].Kind == history.EdgeKind
+}
+
+// ensureEdgeIndexes indexes the edge collections found by a scan, creating an index twice is a no-op
+func (mg *MongoGraph) ensureEdgeIndexes() error {
+	db := mg.client.Database(mg.database)
+	for _, col := range mg.edgeCollections() {
+		indexes, err := db.Collection(col).Indexes().CreateMany(mg.context(), edgeIndexes())
+		if err != nil {
+			return err
+		}
+		entry := mg.catalog[col]
+		entry.Indexes = append(entry.Indexes, indexes...)
+		mg.catalog[col] = entry
+	}
+	return nil
+}
+
+// adjacencyColl returns the adjacency collection, created and filled from the edge collections on first use
+func (mg *MongoGraph) adjacencyColl() (*mongo.Collection, error) {
+	db := mg.client.Database(mg.database)
+	col := db.Collection(adjacencyCollection)
+	if mg.collectionExists(adjacencyCollection) {
+		return col, nil
+	}
+	// $graphLookup matches a, the edges are replaced by edge
+	_, err := col.Indexes().CreateMany(mg.context(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "a", Value: 1}, {Key: "out", Value: 1}}},
+		{Keys: bson.D{{Key: "edge", Value: 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := col.DeleteMany(mg.context(), bson.M{}); err != nil {
+		return nil, err
+	}
+	for _, edgeCol := range mg.edgeCollections() {
+		cursor, err := db.Collection(edgeCol).Find(mg.context(), bson.M{"from": bson.M{"$exists": true}})
+		if err != nil {
+			return nil, err
+		}
+		var docs []interface{}
+		for cursor.Next(mg.context()) {
+			var e Edge
+			if err := cursor.Decode(&e); err != nil {
+				cursor.Close(mg.context())
+				return nil, err
+			}
+			docs = append(docs, adjacencyDocs(e.ID, e.From, e.To)...)
+		}
+		cursor.Close(mg.context())
+		if len(docs) > 0 {
+			if _, err := col.InsertMany(mg.context(), docs); err != nil {
+				return nil, err
+			}
+		}
+	}
+	mg.collSet[adjacencyCollection] = void{}
+	return col, nil
+}
+
+// indexEdge makes the adjacency follow the version of the item, nil once deleted
+func (mg *MongoGraph) indexEdge(oid primitive.ObjectID, next *history.Version) error {
+	if next != nil && next.Kind != history.EdgeKind {
+		return nil
+	}
+	col, err := mg.adjacencyColl()
+	if err != nil {
+		return err
+	}
+	if _, err := col.DeleteMany(mg.context(), bson.M{"edge": oid}); err != nil {
+		return err
+	}
+	if next == nil {
+		return nil
+	}
+	from, err := primitive.ObjectIDFromHex(next.From)
+	if err != nil {
+		return err
+	}
+	to, err := primitive.ObjectIDFromHex(next.To)
+	if err != nil {
+		return err
+	}
+	_, err = col.InsertMany(mg.context(), adjacencyDocs(oid, from, to))
+	return err
+}
+
+// loadNodes reads the nodes by ID, a query per node collection, the missing ones are left out
+func (mg *MongoGraph) loadNodes(ids []primitive.ObjectID) (map[primitive.ObjectID]*Node, error) {
+	res := make(map[primitive.ObjectID]*Node, len(ids))
+	if len(ids) == 0 {
+		return res, nil
+	}
+	db := mg.client.Database(mg.database)
+	for _, colName := range mg.nodeCollections() {
+		cursor, err := db.Collection(colName).Find(mg.context(), bson.M{"_id": bson.M{"$in": ids}, "name": bson.M{"$exists": true}})
+		if err != nil {
+			return nil, err
+		}
+		for cursor.Next(mg.context()) {
+			var n Node
+			if err := cursor.Decode(&n); err != nil {
+				cursor.Close(mg.context())
+				return nil, err
+			}
+			res[n.ID] = &n
+		}
+		err = cursor.Err()
+		cursor.Close(mg.context())
+		if err != nil {
+			return nil, err
+		}
+		if len(res) == len(ids) {
+			break
+		}
+	}
+	return res, nil
+}
+
+// edgeIDs returns the IDs of the edges
+func edgeIDs(edges []handler.Edge) ([]primitive.ObjectID, error) {
+	ids := make([]primitive.ObjectID, len(edges))
+	for i, e := range edges {
+		edge, ok := e.(*Edge)
+		if !ok {
+			return nil, fmt.Errorf("invalid edge type")
+		}
+		ids[i] = edge.ID
+	}
+	return ids, nil
+}
+
+// neighbours returns the node at the other end of each matching edge of the named node
+// out picks the targets of its out edges, otherwise the sources of its in edges, edges restricts them if not nil
+func (mg *MongoGraph) neighbours(name interface{}, out bool, edges []primitive.ObjectID) ([]handler.Node, error) {
+	nodetmp, err := mg.GetNode(name)
+	if err != nil {
+		return nil, err
+	}
+	node := nodetmp.(*Node)
+	col, err := mg.adjacencyColl()
+	if err != nil {
+		return nil, err
+	}
+	query := bson.M{"a": node.ID, "out": out}
+	if edges != nil {
+		query["edge"] = bson.M{"$in": edges}
+	}
+	cursor, err := col.Find(mg.context(), query, options.Find().SetSort(bson.D{{Key: "edge", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var adj []adjacency
+	if err := cursor.All(mg.context(), &adj); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, len(adj))
+	for i, a := range adj {
+		ids[i] = a.B
+	}
+	nodes, err := mg.loadNodes(ids)
+	if err != nil {
+		return nil, err
+	}
+	// a node per edge, like the edges
+	var res []handler.Node
+	for _, a := range adj {
+		if n, ok := nodes[a.B]; ok {
+			res = append(res, n)
+		}
+	}
+	return res, nil
+}
+
+// relatedPipeline finds the nodes linked to start both ways with their depth, the start node included
+// a negative maxDepth means no limit, restrict limits the adjacency documents followed
+func relatedPipeline(start primitive.ObjectID, maxDepth int, restrict bson.M) mongo.Pipeline {
+	lookup := bson.D{
+		{Key: "from", Value: adjacencyCollection},
+		{Key: "startWith", Value: "$a"},
+		{Key: "connectFromField", Value: "b"},
+		{Key: "connectToField", Value: "a"},
+		{Key: "as", Value: "reached"},
+		{Key: "depthField", Value: "depth"},
+	}
+	if maxDepth >= 0 {
+		lookup = append(lookup, bson.E{Key: "maxDepth", Value: maxDepth})
+	}
+	if restrict != nil {
+		lookup = append(lookup, bson.E{Key: "restrictSearchWithMatch", Value: restrict})
+	}
+	return mongo.Pipeline{
+		// % any adjacency document of start runs the lookup once
+		{{Key: "$match", Value: bson.M{"a": start}}},
+		{{Key: "$limit", Value: 1}},
+		{{Key: "$graphLookup", Value: lookup}},
+		{{Key: "$unwind", Value: "$reached"}},
+		// the depth of a document is the hops before it, its b node is one more away
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$reached.b"},
+			{Key: "depth", Value: bson.M{"$min": "$reached.depth"}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "depth", Value: 1}, {Key: "_id", Value: 1}}}},
+	}
+}
+
+// reachedNode is a node found by relatedPipeline
+type reachedNode struct {
+	ID    primitive.ObjectID `bson:"_id"`
+	Depth int                `bson:"depth"`
+}
+
+// levelIDs groups the reached nodes by level, start alone on the first one
+func levelIDs(start primitive.ObjectID, reached []reachedNode) [][]primitive.ObjectID {
+	levels := [][]primitive.ObjectID{{start}}
+	for _, r := range reached {
+		if r.ID == start {
+			continue
+		}
+		for len(levels) <= r.Depth+1 {
+			levels = append(levels, nil)
+		}
+		levels[r.Depth+1] = append(levels[r.Depth+1], r.ID)
+	}
+	return levels
+}
+
+// related returns the levels of the nodes linked to the named node both ways, at most levels of them if positive
+// ~ an aggregation and a query per node collection, whatever the size of the graph
+func (mg *MongoGraph) related(name interface{}, levels int, restrict bson.M) ([][]handler.Node, error) {
+	staNode, err := mg.GetNode(name)
+	if err != nil {
+		return nil, err
+	}
+	start := staNode.(*Node)
+	if levels == 1 {
+		return [][]handler.Node{{start}}, nil
+	}
+	col, err := mg.adjacencyColl()
+	if err != nil {
+		return nil, err
+	}
+	maxDepth := -1
+	if levels > 1 {
+		maxDepth = levels - 2
+	}
+	cursor, err := col.Aggregate(mg.context(), relatedPipeline(start.ID, maxDepth, restrict))
+	if err != nil {
+		return nil, err
+	}
+	var reached []reachedNode
+	if err := cursor.All(mg.context(), &reached); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, len(reached))
+	for i, r := range reached {
+		ids[i] = r.ID
+	}
+	nodes, err := mg.loadNodes(ids)
+	if err != nil {
+		return nil, err
+	}
+	nodes[start.ID] = start
+	var result [][]handler.Node
+	for _, level := range levelIDs(start.ID, reached) {
+		var ns []*Node
+		for _, id := range level {
+			if n, ok := nodes[id]; ok {
+				ns = append(ns, n)
+			}
+		}
+		if len(ns) == 0 {
+			continue
+		}
+		sort.Slice(ns, func(i, j int) bool { return ns[i].Name < ns[j].Name })
+		out := make([]handler.Node, len(ns))
+		for i, n := range ns {
+			out[i] = n
+		}
+		result = append(result, out)
+	}
+	return result, nil
+}
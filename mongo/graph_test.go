@@ -0,0 +1,53 @@
+package mongo
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestLevelIDs(t *testing.T) {
+	start, a, b, c := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	// the start node comes back through a circle, its first level stays
+	reached := []reachedNode{{a, 0}, {b, 0}, {start, 1}, {c, 1}}
+	want := [][]primitive.ObjectID{{start}, {a, b}, {c}}
+	if got := levelIDs(start, reached); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got := levelIDs(start, nil); !reflect.DeepEqual(got, [][]primitive.ObjectID{{start}}) {
+		t.Errorf("Expected the start node alone, got %v", got)
+	}
+}
+
+func TestRelatedPipeline(t *testing.T) {
+	start := primitive.NewObjectID()
+	lookup := func(p []bson.D) bson.D {
+		return p[2][0].Value.(bson.D)
+	}
+	keys := func(d bson.D) map[string]interface{} {
+		m := map[string]interface{}{}
+		for _, e := range d {
+			m[e.Key] = e.Value
+		}
+		return m
+	}
+
+	unlimited := keys(lookup(relatedPipeline(start, -1, nil)))
+	if _, ok := unlimited["maxDepth"]; ok {
+		t.Errorf("Expected no maxDepth, got %v", unlimited["maxDepth"])
+	}
+	if unlimited["from"] != adjacencyCollection || unlimited["depthField"] != "depth" {
+		t.Errorf("Unexpected lookup %v", unlimited)
+	}
+
+	restrict := bson.M{"edge": bson.M{"$in": []primitive.ObjectID{start}}}
+	limited := keys(lookup(relatedPipeline(start, 2, restrict)))
+	if limited["maxDepth"] != 2 {
+		t.Errorf("Expected maxDepth 2, got %v", limited["maxDepth"])
+	}
+	if !reflect.DeepEqual(limited["restrictSearchWithMatch"], restrict) {
+		t.Errorf("Expected the restriction, got %v", limited["restrictSearchWithMatch"])
+	}
+}
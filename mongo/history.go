@@ -0,0 +1,233 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// the MongoGraph keeps the bitemporal history
+var _ handler.TemporalGraph = (*MongoGraph)(nil)
+
+// historyCollection returns the history collection, created with its indexes on first use
+// ~ the versions are history.Version documents, the item IDs are stored as hex strings
+func (mg *MongoGraph) historyCollection() (*mongo.Collection, error) {
+	db := mg.client.Database(mg.database)
+	col := db.Collection(handler.HistoryCollection)
+	if mg.collectionExists(handler.HistoryCollection) {
+		return col, nil
+	}
+	// the versions of one item, and the node versions by name
+	_, err := col.Indexes().CreateMany(mg.context(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "item", Value: 1}, {Key: "txTo", Value: 1}}},
+		{Keys: bson.D{{Key: "name", Value: 1}, {Key: "txFrom", Value: 1}}},
+		{Keys: bson.D{{Key: "vid", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	mg.collSet[handler.HistoryCollection] = void{}
+	return col, nil
+}
+
+// findVersions reads all the versions matching the filter
+func findVersions(ctx context.Context, col *mongo.Collection, filter bson.M, opts ...*options.FindOptions) ([]history.Version, error) {
+	cursor, err := col.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	versions := []history.Version{}
+	for cursor.Next(ctx) {
+		var v history.Version
+		if err := cursor.Decode(&v); err != nil {
+			return nil, err
+		}
+		// bson dates come back in the local zone
+		v.ValidFrom, v.ValidTo = v.ValidFrom.UTC(), v.ValidTo.UTC()
+		v.TxFrom, v.TxTo = v.TxFrom.UTC(), v.TxTo.UTC()
+		versions = append(versions, v)
+	}
+	return versions, cursor.Err()
+}
+
+// currentVersion reads the stored item back as the next version
+func (mg *MongoGraph) currentVersion(op history.Op, collection string, oid primitive.ObjectID) (*history.Version, error) {
+	var doc bson.M
+	err := mg.client.Database(mg.database).Collection(collection).
+		FindOne(mg.context(), bson.M{"_id": oid}).Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := doc["data"].(bson.M)
+	collection, _ = doc["collection"].(string)
+	if hasEdgeKeys(doc) {
+		from, _ := doc["from"].(primitive.ObjectID)
+		to, _ := doc["to"].(primitive.ObjectID)
+		rel, _ := doc["relationship"].(string)
+		return history.EdgeVersion(op, oid.Hex(), collection, rel, from.Hex(), to.Hex(), data), nil
+	}
+	name, _ := doc["name"].(string)
+	return history.NodeVersion(op, oid.Hex(), collection, name, data), nil
+}
+
+// record stores the version changes of a write on the item in the collection
+// written is the data of the write, its history.ValidFromKey gives the valid time
+func (mg *MongoGraph) record(op history.Op, collection string, oid primitive.ObjectID, written map[string]interface{}) error {
+	col, err := mg.historyCollection()
+	if err != nil {
+		return err
+	}
+	var next *history.Version
+	if op != history.Delete {
+		if next, err = mg.currentVersion(op, collection, oid); err != nil {
+			return err
+		}
+	}
+	// the search collection follows the current version
+	if err := mg.indexVersion(oid.Hex(), next); err != nil {
+		return err
+	}
+	// and the adjacency of the edges
+	if err := mg.indexEdge(oid, next); err != nil {
+		return err
+	}
+	// and the route of the item
+	if err := mg.saveRoute(oid, next); err != nil {
+		return err
+	}
+	current, err := findVersions(mg.context(), col, bson.M{"item": oid.Hex(), "txTo": history.EndOfTime})
+	if err != nil {
+		return err
+	}
+
+	txTime := history.Now()
+	closed, inserted := history.Record(current, next, history.ValidTime(written, txTime), txTime)
+	if len(closed) > 0 {
+		ids := make([]string, len(closed))
+		for i, c := range closed {
+			ids[i] = c.ID
+		}
+		_, err = col.UpdateMany(mg.context(), bson.M{"vid": bson.M{"$in": ids}}, bson.M{"$set": bson.M{"txTo": txTime}})
+		if err != nil {
+			return err
+		}
+	}
+	if len(inserted) > 0 {
+		docs := make([]interface{}, len(inserted))
+		for i, v := range inserted {
+			docs[i] = v
+		}
+		if _, err = col.InsertMany(mg.context(), docs); err != nil {
+			return err
+		}
+	}
+	return mg.publish(handler.NewChangeEvent(oid.Hex(), current, next, txTime))
+}
+
+// versionToNode rebuilds the node of a version
+func versionToNode(v history.Version) *Node {
+	oid, _ := primitive.ObjectIDFromHex(v.ItemID)
+	return &Node{ID: oid, Collection: v.Collection, Name: v.Name, Data: v.Data}
+}
+
+// atFilter returns the filter of the versions valid at validAt as believed at knownAt
+func atFilter(validAt, knownAt time.Time) bson.M {
+	return bson.M{
+		"txFrom":    bson.M{"$lte": knownAt},
+		"txTo":      bson.M{"$gt": knownAt},
+		"validFrom": bson.M{"$lte": validAt},
+		"validTo":   bson.M{"$gt": validAt},
+	}
+}
+
+// GetNodeAsOf(name interface{}, t time.Time) (handler.Node, error)
+func (mg *MongoGraph) GetNodeAsOf(name interface{}, t time.Time) (handler.Node, error) {
+	return mg.GetNodeAt(name, t, t)
+}
+
+// GetNodeAt(name interface{}, validAt, knownAt time.Time) (handler.Node, error)
+func (mg *MongoGraph) GetNodeAt(name interface{}, validAt, knownAt time.Time) (handler.Node, error) {
+	nameStr, ok := name.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid name")
+	}
+	col, err := mg.historyCollection()
+	if err != nil {
+		return nil, err
+	}
+	filter := atFilter(validAt, knownAt)
+	filter["kind"] = history.NodeKind
+	filter["name"] = nameStr
+	versions, err := findVersions(mg.context(), col, filter,
+		options.Find().SetSort(bson.D{{Key: "txFrom", Value: -1}}).SetLimit(1))
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("node with name %s does not exist at %v", nameStr, validAt)
+	}
+	return versionToNode(versions[0]), nil
+}
+
+// TraverseAsOf(t time.Time, name interface{}) ([][]handler.Node, error)
+// the edges are followed both ways like GetAllRelatedNodes
+func (mg *MongoGraph) TraverseAsOf(t time.Time, name interface{}) ([][]handler.Node, error) {
+	nameStr, ok := name.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid name")
+	}
+	col, err := mg.historyCollection()
+	if err != nil {
+		return nil, err
+	}
+	// the whole graph as of t, the BFS runs on the snapshot
+	versions, err := findVersions(mg.context(), col, atFilter(t, t))
+	if err != nil {
+		return nil, err
+	}
+	start, ok := history.FindNode(versions, nameStr, t, t)
+	if !ok {
+		return nil, fmt.Errorf("node with name %s does not exist at %v", nameStr, t)
+	}
+
+	snapshot := history.Snapshot(versions, t, t)
+	var result [][]handler.Node
+	for _, level := range history.Traverse(start.ItemID, snapshot, false) {
+		nodes := make([]handler.Node, len(level))
+		for i, id := range level {
+			nodes[i] = versionToNode(snapshot[id])
+		}
+		result = append(result, nodes)
+	}
+	return result, nil
+}
+
+// GetHistory(id interface{}) ([]history.Version, error)
+func (mg *MongoGraph) GetHistory(id interface{}) ([]history.Version, error) {
+	oid, err := toObjectID(id)
+	if err != nil {
+		return nil, err
+	}
+	col, err := mg.historyCollection()
+	if err != nil {
+		return nil, err
+	}
+	versions, err := findVersions(mg.context(), col, bson.M{"item": oid.Hex()},
+		options.Find().SetSort(bson.D{{Key: "txFrom", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("item with ID %s has no history", oid.Hex())
+	}
+	return versions, nil
+}
@@ -6,14 +6,21 @@ package mongo
 import (
 	"context"
 	"fmt"
-	"os"
+	"net/url"
+	"sync"
 
+	"github.com/wonderstone/chainstorm/config"
 	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"github.com/wonderstone/chainstorm/merge"
+	"github.com/wonderstone/chainstorm/provenance"
+	"github.com/wonderstone/chainstorm/resolve"
+	"github.com/wonderstone/chainstorm/search"
+	"github.com/wonderstone/chainstorm/tools"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"gopkg.in/yaml.v3"
 )
 
 type void struct{}
@@ -24,6 +31,8 @@ type Node struct {
 	Collection string                 `bson:"collection"`
 	Name       string                 `bson:"name"`
 	Data       map[string]interface{} `bson:"data"`
+	// Rev changes at each write, a write given it applies only if the node still has it
+	Rev string `bson:"rev,omitempty"`
 }
 
 func (v *Node) Export() map[string]interface{} {
@@ -86,6 +95,7 @@ func mapToNode(doc map[string]interface{}) (Node, error) {
 	if _, ok := doc["_id"]; ok {
 		node.ID = doc["_id"].(primitive.ObjectID)
 	}
+	node.Rev, _ = doc["rev"].(string)
 	return node, nil
 }
 
@@ -97,6 +107,8 @@ type Edge struct {
 	Collection   string                 `bson:"collection"`
 	Relationship string                 `bson:"relationship"`
 	Data         map[string]interface{} `bson:"data,omitempty"`
+	// Rev changes at each write, a write given it applies only if the edge still has it
+	Rev string `bson:"rev,omitempty"`
 }
 
 func (e *Edge) Export() map[string]interface{} {
@@ -160,6 +172,7 @@ func mapToEdge(doc map[string]interface{}) (Edge, error) {
 	if _, ok := doc["_id"]; ok {
 		edge.ID = doc["_id"].(primitive.ObjectID)
 	}
+	edge.Rev, _ = doc["rev"].(string)
 
 	if _, ok := doc["data"]; ok {
 		var data map[string]interface{}
@@ -192,65 +205,136 @@ type MongoGraph struct {
 	// * for both nodes and edges
 	collSet map[string]void
 
+	// * catalog with type map[collection]catalogEntry tells the node and the edge collections
+	// * persisted in the catalog collection, read on Connect
+	catalog map[string]catalogEntry
+
 	// * nodeNameCollMap with type map[name]collection
 	// * to prevent iteration over all collections
+	// * a cache of the routes, a name missing is looked up in them
 	nodeNameCollMap map[string]string
 
-	// * itemSet with type map[primitive.ObjectID]void to store the item IDs for further check
+	// * itemSet with type map[primitive.ObjectID]collection to store the item IDs for further check
 	// # however primitive.ObjectID contains a byte slice ([12]byte), which makes it non-comparable
 	// # so use string and primitive.ObjectID.Hex() to store the ID
-	itemSet map[string]void
+	// # the collection routes GetItemByID, the routes collection persists it
+	// # a cache of the routes too, see locate
+	itemSet map[string]string
+
+	// * routesMu guards nodeNameCollMap and itemSet, the reads fill them
+	// * a pointer, the copies of mg made by Update and WithContext share it
+	routesMu *sync.RWMutex
+
+	// * merger holds the merge strategies for MergeNode and MergeEdge
+	merger *merge.Config
+
+	// * searcher tells the Data fields of the full-text search
+	searcher *search.Config
 
 	client *mongo.Client
+
+	// * ctx is the session of the transaction, nil outside the transactions
+	ctx mongo.SessionContext
+
+	// * base is the caller context of a WithContext view, nil for the graph itself
+	base context.Context
+
+	// * logger is created by Init from the "logger" section, read it with log()
+	logger *tools.Logger
+}
+
+// log returns the logger, one writing nothing before Init
+func (mg *MongoGraph) log() *tools.Logger {
+	if mg.logger == nil {
+		return tools.NopLogger()
+	}
+	return mg.logger
 }
 
 // - implement Init operations
 func (mg *MongoGraph) Init(yamlPath string) error {
+	// = read the yaml file into the typed config, the environment overrides it
+	c, err := config.LoadMongo(yamlPath)
+	if err != nil {
+		return err
+	}
+	return mg.Configure(c)
+}
+
+// Configure(c *config.Mongo) error
+// Init reads the config from a file, Configure takes it as it is
+func (mg *MongoGraph) Configure(c *config.Mongo) error {
 	var err error
-	// = recover from panic
-	defer recoverFromPanic(&err)
+	// = section for mongoDB connection
+	mg.username = c.Username
+	mg.password = c.Password
+	mg.server = c.Server
+	mg.port = c.Port
+	mg.database = c.Database
+
+	// = section for better performance
+	mg.collSet = make(map[string]void)
+	mg.catalog = make(map[string]catalogEntry)
+	mg.nodeNameCollMap = make(map[string]string)
+	mg.itemSet = make(map[string]string)
+	mg.routesMu = &sync.RWMutex{}
 
-	yamlData, err := os.ReadFile(yamlPath)
+	// = merge strategies, the section is optional
+	mg.merger, err = merge.Parse(c.Merge)
 	if err != nil {
 		return err
 	}
 
-	// = unmarshal the yaml data into a map
-	var data map[string]interface{}
-	err = yaml.Unmarshal(yamlData, &data)
+	// = full-text search fields, the section is optional
+	mg.searcher, err = search.Parse(c.Search)
 	if err != nil {
 		return err
 	}
 
-	// = section for mongoDB connection
-	mg.username = data["username"].(string)
-	mg.password = data["password"].(string)
-	mg.server = data["server"].(string)
-	mg.port = data["port"].(int)
-	mg.database = data["database"].(string)
+	// = logger, the section is optional
+	logger, err := tools.NewLogger(c.Logger)
+	if err != nil {
+		return err
+	}
+	mg.logger.Close()
+	mg.logger = logger
+	mg.log().Info().Str("database", mg.database).Msg("MongoGraph initialized")
 
-	// = section for better performance
-	mg.collSet = make(map[string]void)
-	mg.nodeNameCollMap = make(map[string]string)
-	mg.itemSet = make(map[string]void)
+	return nil
+}
 
-	return err
+// SetMergeConfig replaces the merge strategies used by MergeNode and MergeEdge
+func (mg *MongoGraph) SetMergeConfig(c *merge.Config) {
+	mg.merger = c
+}
+
+// mergeConfig returns the merge strategies, the default ones if not initialized
+func (mg *MongoGraph) mergeConfig() *merge.Config {
+	if mg.merger == nil {
+		return merge.Default()
+	}
+	return mg.merger
 }
 
 // - implement Connection operations
-// + Connect also update the collset, nodeNameCollMap and itemSet
+// + Connect also update the collset and the catalog, nodeNameCollMap and itemSet fill from the routes
 func (mg *MongoGraph) Connect() error {
 	// @ build the uri with the username , password , server and port
-	uri := fmt.Sprintf("mongodb://%s:%s@%s:%d", mg.username, mg.password, mg.server, mg.port)
-	clientOptions := options.Client().ApplyURI(uri)
-	client, err := mongo.Connect(context.TODO(), clientOptions)
+	// @ the credentials are escaped, no username connects without them
+	uri := url.URL{Scheme: "mongodb", Host: fmt.Sprintf("%s:%d", mg.server, mg.port)}
+	if mg.username != "" {
+		uri.User = url.UserPassword(mg.username, mg.password)
+	}
+	clientOptions := options.Client().ApplyURI(uri.String()).SetMonitor(commandMonitor())
+	client, err := mongo.Connect(mg.context(), clientOptions)
 	if err != nil {
+		mg.log().Error().Err(err).Str("server", mg.server).Int("port", mg.port).Msg("Failed to connect")
 		return err
 	}
 	mg.client = client
 
 	// // @ get all the collections in the database
-	// collections, err := client.Database(mg.database).ListCollectionNames(context.Background(), bson.D{})
+	// collections, err := client.Database(mg.database).ListCollectionNames(mg.context(), bson.D{})
 	// if err != nil {
 	// 	return err
 	// }
@@ -261,57 +345,64 @@ func (mg *MongoGraph) Connect() error {
 	// 	mg.collSet[col] = void{}
 	// }
 
-	// @ update the nodeNameCollMap and itemSet
-	err = mg.updateNameCollMap_IDSet()
+	// @ read the catalog, the nodeNameCollMap and itemSet fill as the routes are read
+	err = mg.loadCatalog()
+	if err != nil {
+		mg.log().Error().Err(err).Str("database", mg.database).Msg("Failed to load the catalog")
+		return err
+	}
+	// @ the nodes are found by name and alias on the routes
+	err = mg.ensureRouteIndexes()
 	if err != nil {
 		return err
 	}
 
+	mg.log().Info().Str("database", mg.database).Int("collections", len(mg.catalog)).Msg("MongoGraph connected")
 	return nil
 }
 
 // implement the Disconnect method
 func (mg *MongoGraph) Disconnect() error {
-	err := mg.client.Disconnect(context.TODO())
+	err := mg.client.Disconnect(mg.context())
 	if err != nil {
+		mg.log().Error().Err(err).Msg("Failed to disconnect")
 		return err
 	}
-	return nil
+	mg.log().Info().Str("database", mg.database).Msg("MongoGraph disconnected")
+	// the log files are released, a later message opens them again
+	return mg.log().Close()
 }
 
 // iterate all nodes in database to update the nameCollectionMap
+// ~ only for a database without catalog, the catalog and the routes are saved after
 func (mg *MongoGraph) updateNameCollMap_IDSet() error {
 	// get the database
 	db := mg.client.Database(mg.database)
-	// get all the collections in the database
-	collections, err := db.ListCollectionNames(context.Background(), bson.D{})
-	if err != nil {
-		return err
-	}
-	// make mg.nodeNameCollMap , mg.itemSet and mg.collSet  empty
-	mg.nodeNameCollMap = make(map[string]string)
-	mg.itemSet = make(map[string]void)
-	mg.collSet = make(map[string]void)
+	// make mg.nodeNameCollMap , mg.itemSet and mg.catalog empty, loadCatalog filled mg.collSet
+	mg.resetMaps()
+	var routes []route
 
 	// iterate all the collections
-	for _, col := range collections {
+	for col := range mg.collSet {
 
-		// @ update the collSet
-		mg.collSet[col] = void{}
+		// @ the chainstorm collections hold no node or edge
+		if handler.IsReserved(col) {
+			continue
+		}
 
 		// get the collection
 		// colName := col
 		col := db.Collection(col)
 		// get all the nodes in the collection
-		cursor, err := col.Find(context.Background(), bson.D{})
+		cursor, err := col.Find(mg.context(), bson.D{})
 		if err != nil {
 			return err
 		}
-		defer cursor.Close(context.Background())
+		defer cursor.Close(mg.context())
 
 		// iterate the elements in the collection
 		// only when the element is a Node, update the nameCollectionMap
-		for cursor.Next(context.Background()) {
+		for cursor.Next(mg.context()) {
 			// ! cannot use cursor.Decode(&node) or cursor.Decode(&edge) directly
 			// ! because the doc can both decode to Node and Edge with blank fields
 			// ! so decode the doc to primitive.M first
@@ -332,16 +423,39 @@ func (mg *MongoGraph) updateNameCollMap_IDSet() error {
 			_, isNode := doc["name"]
 			// ! ugly code above
 			if isEdge {
-				mg.itemSet[doc["_id"].(primitive.ObjectID).Hex()] = void{}
+				mg.catalog[col.Name()] = catalogEntry{Collection: col.Name(), Kind: history.EdgeKind}
+				r := route{ID: doc["_id"].(primitive.ObjectID), Collection: col.Name(), Kind: history.EdgeKind}
+				mg.useRoute(r)
+				routes = append(routes, r)
 			} else if isNode {
-				mg.nodeNameCollMap[doc["name"].(string)] = col.Name()
-				mg.itemSet[doc["_id"].(primitive.ObjectID).Hex()] = void{}
+				if _, ok := mg.catalog[col.Name()]; !ok {
+					mg.catalog[col.Name()] = catalogEntry{Collection: col.Name(), Kind: history.NodeKind}
+				}
+				// the route keeps the aliases of the node
+				data, _ := doc["data"].(primitive.M)
+				oid := doc["_id"].(primitive.ObjectID)
+				v := history.NodeVersion(history.Create, oid.Hex(), col.Name(), doc["name"].(string), data)
+				r := routeOf(oid, v)
+				mg.useRoute(r)
+				routes = append(routes, r)
 			} else {
 				return fmt.Errorf("invalid document")
 			}
 		}
 	}
-	return nil
+
+	// @ the traversals look the edges up by from and to
+	err := mg.ensureEdgeIndexes()
+	if err != nil {
+		return err
+	}
+	for name, entry := range mg.catalog {
+		if entry.Indexes, err = indexNames(mg.context(), db.Collection(name)); err != nil {
+			return err
+		}
+		mg.catalog[name] = entry
+	}
+	return mg.saveCatalog(routes)
 }
 
 // - implement CRUD operations
@@ -365,19 +479,19 @@ func (mg *MongoGraph) createCollection(collection string) error {
 		Options: options.Index().SetUnique(true),
 	}
 	// create the collection
-	err := db.CreateCollection(context.Background(), collection)
+	err := db.CreateCollection(mg.context(), collection)
 	if err != nil {
 		return err
 	}
 
 	// create the index
-	_, err = db.Collection(collection).Indexes().CreateOne(context.Background(), indexModel)
+	index, err := db.Collection(collection).Indexes().CreateOne(mg.context(), indexModel)
 	if err != nil {
 		return err
 	}
-	// add the collection to the collectionSet
+	// add the collection to the collectionSet and the catalog
 	mg.collSet[collection] = void{}
-	return nil
+	return mg.putCatalog(collection, history.NodeKind, []string{index})
 }
 
 // func to drop a collection
@@ -385,14 +499,14 @@ func (mg *MongoGraph) dropCollection(collection string) error {
 	// get the database
 	db := mg.client.Database(mg.database)
 	// drop the collection
-	err := db.Collection(collection).Drop(context.Background())
+	err := db.Collection(collection).Drop(mg.context())
 	if err != nil {
 		return err
 	}
 	// delete the collection from the collectionSet
 	delete(mg.collSet, collection)
 	//
-	return nil
+	return mg.dropCatalog(collection)
 }
 
 // implement the AddNode method，
@@ -411,6 +525,21 @@ func (mg *MongoGraph) AddNode(ni handler.Node) (interface{}, error) {
 
 	var err error
 	defer recoverFromPanic(&err)
+	// split the provenance out of the data
+	data, src, err := provenance.Extract(n.Data)
+	if err != nil {
+		return nil, err
+	}
+	n.Data = data
+	// the names and the aliases are unique together
+	if err := mg.checkAliases("", append([]string{n.Name}, resolve.Aliases(n.Data, handler.AliasesKey)...)...); err != nil {
+		return nil, err
+	}
+
+	// the edge collections hold no node
+	if mg.isEdgeCollection(n.Collection) {
+		return nil, fmt.Errorf("collection %s holds edges", n.Collection)
+	}
 	// check if the collection exists, if not create the collection
 	if !mg.collectionExists(n.Collection) {
 		// create the collection
@@ -426,14 +555,19 @@ func (mg *MongoGraph) AddNode(ni handler.Node) (interface{}, error) {
 	verticesCol := db.Collection(n.Collection)
 
 	// insert the node
-	res, err := verticesCol.InsertOne(context.TODO(), n)
+	n.Rev = newRev()
+	res, err := verticesCol.InsertOne(mg.context(), n)
 	if err != nil {
 		return nil, err
 	}
-	// update the nameCollectionMap
-	mg.nodeNameCollMap[n.Name] = n.Collection
-	// update the itemSet
-	mg.itemSet[res.InsertedID.(primitive.ObjectID).Hex()] = void{}
+	// update the nameCollectionMap and the itemSet
+	mg.useRoute(route{ID: res.InsertedID.(primitive.ObjectID), Collection: n.Collection, Kind: history.NodeKind, Name: n.Name})
+	if err := mg.record(history.Create, n.Collection, res.InsertedID.(primitive.ObjectID), n.Data); err != nil {
+		return nil, err
+	}
+	if err := mg.assert(history.Create, res.InsertedID.(primitive.ObjectID), history.NodeKind, n.Collection, n.Data, src); err != nil {
+		return nil, err
+	}
 	return res.InsertedID, nil
 }
 
@@ -453,18 +587,38 @@ func (mg *MongoGraph) AddEdge(ei handler.Edge) (interface{}, error) {
 
 	var err error
 	defer recoverFromPanic(&err)
+	// split the provenance out of the data
+	data, src, err := provenance.Extract(e.Data)
+	if err != nil {
+		return nil, err
+	}
+	e.Data = data
 
 	// get the database and collection
 	db := mg.client.Database(mg.database)
 	edgesCol := db.Collection(e.Collection)
 
-	// check if the from and to nodes exist by using itemSet
-	if _, ok := mg.itemSet[e.From.Hex()]; !ok {
+	// check if the from and to nodes exist by using itemSet or their routes
+	if ok, err := mg.exists(e.From); err != nil {
+		return nil, err
+	} else if !ok {
 		return nil, fmt.Errorf("from node not found")
 	}
-	if _, ok := mg.itemSet[e.To.Hex()]; !ok {
+	if ok, err := mg.exists(e.To); err != nil {
+		return nil, err
+	} else if !ok {
 		return nil, fmt.Errorf("to node not found")
 	}
+
+	// the edges go to edge collections, created with the from and to indexes
+	if !mg.collectionExists(e.Collection) {
+		err = mg.createEdgeCollection(e.Collection)
+		if err != nil {
+			return nil, err
+		}
+	} else if !mg.isEdgeCollection(e.Collection) {
+		return nil, fmt.Errorf("collection %s holds nodes", e.Collection)
+	}
 	// ! refactored the code
 	// ! check if the from and to nodes exist by using GetItemByID
 	// ! GetItemByID is not efficient because it iterates all the collections
@@ -479,11 +633,20 @@ func (mg *MongoGraph) AddEdge(ei handler.Edge) (interface{}, error) {
 	// }
 
 	// insert the edge
-	res, err := edgesCol.InsertOne(context.TODO(), e)
+	e.Rev = newRev()
+	res, err := edgesCol.InsertOne(mg.context(), e)
 
 	if err != nil {
 		return nil, err
 	}
+	// update the itemSet
+	mg.useRoute(route{ID: res.InsertedID.(primitive.ObjectID), Collection: e.Collection, Kind: history.EdgeKind})
+	if err := mg.record(history.Create, e.Collection, res.InsertedID.(primitive.ObjectID), e.Data); err != nil {
+		return nil, err
+	}
+	if err := mg.assert(history.Create, res.InsertedID.(primitive.ObjectID), history.EdgeKind, e.Collection, e.Data, src); err != nil {
+		return nil, err
+	}
 
 	return res.InsertedID, nil
 }
@@ -499,17 +662,22 @@ func (mg *MongoGraph) GetNode(name interface{}) (handler.Node, error) {
 	db := mg.client.Database(mg.database)
 
 	if _, ok := name.(string); ok {
+		// an alias leads to the name of its node
+		name = mg.resolveName(name.(string))
 		// get the collection name
-		if _, ok := mg.nodeNameCollMap[name.(string)]; !ok {
+		colName, ok, err := mg.nodeCollection(name.(string))
+		if err != nil {
+			return &Node{}, err
+		}
+		if !ok {
 			return &Node{}, fmt.Errorf("Node not found")
 		}
-		colName := mg.nodeNameCollMap[name.(string)]
 		nodeName := name.(string)
 		verticesCol := db.Collection(colName)
 
 		// find the node
 		var node Node
-		err = verticesCol.FindOne(context.TODO(), bson.M{"name": nodeName}).Decode(&node)
+		err = verticesCol.FindOne(mg.context(), bson.M{"name": nodeName}).Decode(&node)
 		if err != nil {
 			return &Node{}, err
 		}
@@ -521,35 +689,28 @@ func (mg *MongoGraph) GetNode(name interface{}) (handler.Node, error) {
 }
 
 // func GetItemByID to get the item by ID
-// iter evey time return the item in primitive.M type and error
-// not the best solution
+// return the item in primitive.M type and error
+// the route of the item tells its collection, a single query
 func (mg *MongoGraph) GetItemByID(id interface{}) (interface{}, error) {
 	var err error
 	defer recoverFromPanic(&err)
-	// fmt.Println("ID", id)
-	// get the database
-	db := mg.client.Database(mg.database)
-
-	// get all the collections in the database
-	collections, err := db.ListCollectionNames(context.Background(), bson.D{})
+	oid, err := toObjectID(id)
 	if err != nil {
 		return nil, err
 	}
-
-	// iterate all the collections
-	for _, col := range collections {
-		// get the collection
-		col := db.Collection(col)
-		// get the item by ID
-		var item primitive.M
-		err_decode := col.FindOne(context.Background(), bson.M{"_id": id}).Decode(&item)
-
-		if err_decode == nil {
-			return item, nil
-		}
-
+	colName, err := mg.locate(oid)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("item not found")
+	// get the item by ID
+	var item primitive.M
+	err = mg.client.Database(mg.database).Collection(colName).FindOne(mg.context(), bson.M{"_id": oid}).Decode(&item)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("item not found")
+	} else if err != nil {
+		return nil, err
+	}
+	return item, nil
 }
 
 // GetNodesByRegex(regex string) ([]Node, error)
@@ -557,27 +718,25 @@ func (mg *MongoGraph) GetItemByID(id interface{}) (interface{}, error) {
 func (mg *MongoGraph) GetNodesByRegex(regex string) ([]handler.Node, error) {
 	// get the database
 	db := mg.client.Database(mg.database)
-	// iter the items in the database to get the nodes
-	collections, err := db.ListCollectionNames(context.Background(), bson.D{})
-	if err != nil {
-		return nil, err
-	}
 
 	// get the nodes
 	var nodes []handler.Node
-	for _, col := range collections {
+	for _, col := range mg.nodeCollections() {
 		// get the collection
 		col := db.Collection(col)
-		// get the nodes
-		cursor, err := col.Find(context.Background(), bson.M{"name": bson.M{"$regex": regex}})
+		// get the nodes, the aliases match too
+		cursor, err := col.Find(mg.context(), bson.M{"$or": bson.A{
+			bson.M{"name": bson.M{"$regex": regex}},
+			bson.M{"data." + handler.AliasesKey: bson.M{"$regex": regex}},
+		}})
 		if err != nil {
 			return nil, err
 		}
-		defer cursor.Close(context.Background())
+		defer cursor.Close(mg.context())
 
 		// iterate the elements in the collection
 
-		for cursor.Next(context.Background()) {
+		for cursor.Next(mg.context()) {
 			var doc primitive.M
 			err = cursor.Decode(&doc)
 			if err != nil {
@@ -600,27 +759,22 @@ func (mg *MongoGraph) GetNodesByRegex(regex string) ([]handler.Node, error) {
 func (mg *MongoGraph) GetEdgesByRegex(regex string) ([]handler.Edge, error) {
 	// get the database
 	db := mg.client.Database(mg.database)
-	// iter the items in the database to get the edges
-	collections, err := db.ListCollectionNames(context.Background(), bson.D{})
-	if err != nil {
-		return nil, err
-	}
 
 	// get the edges
 	var edges []handler.Edge
-	for _, col := range collections {
+	for _, col := range mg.edgeCollections() {
 		// get the collection
 		col := db.Collection(col)
 		// get the edges
-		cursor, err := col.Find(context.Background(), bson.M{"relationship": bson.M{"$regex": regex}})
+		cursor, err := col.Find(mg.context(), bson.M{"relationship": bson.M{"$regex": regex}})
 		if err != nil {
 			return nil, err
 		}
-		defer cursor.Close(context.Background())
+		defer cursor.Close(mg.context())
 
 		// iterate the elements in the collection
 
-		for cursor.Next(context.Background()) {
+		for cursor.Next(mg.context()) {
 			var doc primitive.M
 			err = cursor.Decode(&doc)
 			if err != nil {
@@ -639,263 +793,33 @@ func (mg *MongoGraph) GetEdgesByRegex(regex string) ([]handler.Edge, error) {
 }
 
 // implement GetFromNodes(name interface{}) ([]Node, error)
-
+// the sources of the in edges, read from the adjacency
 func (mg *MongoGraph) GetFromNodes(name interface{}) ([]handler.Node, error) {
-	// get node by name
-	nodetmp, err := mg.GetNode(name)
-	if err != nil {
-		return nil, err
-	}
-	node := nodetmp.(*Node)
-	// get the database
-	db := mg.client.Database(mg.database)
-	// iter the items in the database to get the from nodes
-	collections, err := db.ListCollectionNames(context.Background(), bson.D{})
-	if err != nil {
-		return nil, err
-	}
-
-	// get the from nodes
-	var fromNodes []handler.Node
-	for _, col := range collections {
-		// get the collection
-		col := db.Collection(col)
-		// get the from nodes
-		cursor, err := col.Find(context.Background(), bson.M{"to": node.ID})
-		if err != nil {
-			return nil, err
-		}
-		defer cursor.Close(context.Background())
-
-		// iterate the elements in the collection
-
-		for cursor.Next(context.Background()) {
-			var doc primitive.M
-			err = cursor.Decode(&doc)
-			if err != nil {
-				return nil, err
-			}
-			// get the from node id
-			fromNodeID := doc["from"].(primitive.ObjectID)
-			// get the from node by id
-			fromNode, err := mg.GetItemByID(fromNodeID)
-			if err != nil {
-				return nil, err
-			}
-			// append the from node to the fromNodes
-
-			tmpNode, errtmp := mapToNode(fromNode.(primitive.M))
-			if errtmp != nil {
-				return nil, errtmp
-			}
-
-			fromNodes = append(fromNodes, &tmpNode)
-		}
-
-	}
-	return fromNodes, nil
-
+	return mg.neighbours(name, false, nil)
 }
 
 // GetFromNodesInEdges(name interface{}, edges ... Edge) ([]Node, error)
 func (mg *MongoGraph) GetFromNodesInEdges(name interface{}, edges ...handler.Edge) ([]handler.Node, error) {
-
-	var edgeSet = make(map[string]void)
-
-	// iter the edges to get the edgeSet
-	for _, edgetmp := range edges {
-		edge := edgetmp.(*Edge)
-		edgeSet[edge.ID.Hex()] = void{}
-	}
-
-	// get node by name
-	nodetmp, err := mg.GetNode(name)
-	if err != nil {
-		return nil, err
-	}
-	node := nodetmp.(*Node)
-	// get the database
-	db := mg.client.Database(mg.database)
-	// iter the items in the database to get the from nodes
-	collections, err := db.ListCollectionNames(context.Background(), bson.D{})
+	ids, err := edgeIDs(edges)
 	if err != nil {
 		return nil, err
 	}
-
-	// get the from nodes
-	var fromNodes []handler.Node
-	for _, col := range collections {
-		// get the collection
-		col := db.Collection(col)
-		// get the from nodes
-		cursor, err := col.Find(context.Background(), bson.M{"to": node.ID})
-		if err != nil {
-			return nil, err
-		}
-		defer cursor.Close(context.Background())
-
-		// iter all the matching edges and only edge in the edgeSet will be considered
-
-		for cursor.Next(context.Background()) {
-			var doc primitive.M
-			err = cursor.Decode(&doc)
-			if err != nil {
-				return nil, err
-			}
-			// get the edge id
-			edgeID := doc["_id"].(primitive.ObjectID)
-			// check if the edge is in the edgeSet
-			if _, ok := edgeSet[edgeID.Hex()]; !ok {
-				continue
-			}
-			// get the from node id
-			fromNodeID := doc["from"].(primitive.ObjectID)
-			// get the from node by id
-			fromNode, err := mg.GetItemByID(fromNodeID)
-			if err != nil {
-				return nil, err
-			}
-
-			// append the from node to the fromNodes
-			tmpNode, errtmp := mapToNode(fromNode.(primitive.M))
-			if errtmp != nil {
-				return nil, errtmp
-			}
-
-			fromNodes = append(fromNodes, &tmpNode)
-		}
-
-	}
-	return fromNodes, nil
+	return mg.neighbours(name, false, ids)
 }
 
 // implement GetToNodes(name interface{}) ([]Node, error)
+// the targets of the out edges, read from the adjacency
 func (mg *MongoGraph) GetToNodes(name interface{}) ([]handler.Node, error) {
-	// get node by name
-	nodetmp, err := mg.GetNode(name)
-	if err != nil {
-		return nil, err
-	}
-
-	node := nodetmp.(*Node)
-	// get the database
-	db := mg.client.Database(mg.database)
-	// iter the items in the database to get the to nodes
-	collections, err := db.ListCollectionNames(context.Background(), bson.D{})
-	if err != nil {
-		return nil, err
-	}
-
-	// get the to nodes
-	var toNodes []handler.Node
-	for _, col := range collections {
-		// get the collection
-		col := db.Collection(col)
-		// get the to nodes
-		cursor, err := col.Find(context.Background(), bson.M{"from": node.ID})
-		if err != nil {
-			return nil, err
-		}
-		defer cursor.Close(context.Background())
-
-		// iterate the elements in the collection
-
-		for cursor.Next(context.Background()) {
-			var doc primitive.M
-			err = cursor.Decode(&doc)
-			if err != nil {
-				return nil, err
-			}
-			// get the to node id
-			toNodeID := doc["to"].(primitive.ObjectID)
-			// get the to node by id
-			toNode, err := mg.GetItemByID(toNodeID)
-			if err != nil {
-				return nil, err
-			}
-			// append the to node to the toNodes
-
-			tmpNode, errtmp := mapToNode(toNode.(primitive.M))
-			if errtmp != nil {
-				return nil, errtmp
-			}
-
-			toNodes = append(toNodes, &tmpNode)
-		}
-
-	}
-	return toNodes, nil
+	return mg.neighbours(name, true, nil)
 }
 
 // GetToNodesInEdges(name interface{}, edges ... Edge) ([]Node, error)
 func (mg *MongoGraph) GetToNodesInEdges(name interface{}, edges ...handler.Edge) ([]handler.Node, error) {
-	var edgeSet = make(map[string]void)
-
-	// iter the edges to get the edgeSet
-	for _, edgetmp := range edges {
-		edge := edgetmp.(*Edge)
-		edgeSet[edge.ID.Hex()] = void{}
-	}
-
-	// get node by name
-	nodetmp, err := mg.GetNode(name)
-	if err != nil {
-		return nil, err
-	}
-	node := nodetmp.(*Node)
-	// get the database
-	db := mg.client.Database(mg.database)
-	// iter the items in the database to get the to nodes
-	collections, err := db.ListCollectionNames(context.Background(), bson.D{})
+	ids, err := edgeIDs(edges)
 	if err != nil {
 		return nil, err
 	}
-
-	// get the to nodes
-	var toNodes []handler.Node
-	for _, col := range collections {
-		// get the collection
-		col := db.Collection(col)
-		// get the to nodes
-		cursor, err := col.Find(context.Background(), bson.M{"from": node.ID})
-		if err != nil {
-			return nil, err
-		}
-		defer cursor.Close(context.Background())
-
-		// iter all the matching edges and only edge in the edgeSet will be considered
-
-		for cursor.Next(context.Background()) {
-			var doc primitive.M
-			err = cursor.Decode(&doc)
-			if err != nil {
-				return nil, err
-			}
-			// get the edge id
-			edgeID := doc["_id"].(primitive.ObjectID)
-			// check if the edge is in the edgeSet
-			if _, ok := edgeSet[edgeID.Hex()]; !ok {
-				continue
-			}
-			// get the to node id
-			toNodeID := doc["to"].(primitive.ObjectID)
-			// get the to node by id
-			toNode, err := mg.GetItemByID(toNodeID)
-			if err != nil {
-				return nil, err
-			}
-
-			// append the to node to the toNodes
-			tmpNode, errtmp := mapToNode(toNode.(primitive.M))
-			if errtmp != nil {
-				return nil, errtmp
-			}
-
-			toNodes = append(toNodes, &tmpNode)
-		}
-
-	}
-	return toNodes, nil
+	return mg.neighbours(name, true, ids)
 }
 
 // implement GetInEdges(name interface{}) ([]Edge, error)
@@ -908,51 +832,29 @@ func (mg *MongoGraph) GetInEdges(name interface{}) ([]handler.Edge, error) {
 	node := nodetmp.(*Node)
 	// get the database
 	db := mg.client.Database(mg.database)
-	// iter the items in the database to get the in edges
-	collections, err := db.ListCollectionNames(context.Background(), bson.D{})
-	if err != nil {
-		return nil, err
-	}
 
 	// get the in edges
 	var inEdges []handler.Edge
-	for _, col := range collections {
+	for _, col := range mg.edgeCollections() {
 		// get the collection
 		col := db.Collection(col)
 		// get the in edges
-		cursor, err := col.Find(context.Background(), bson.M{"to": node.ID})
+		cursor, err := col.Find(mg.context(), bson.M{"to": node.ID})
 		if err != nil {
 			return nil, err
 		}
-		defer cursor.Close(context.Background())
+		defer cursor.Close(mg.context())
 
 		// iterate the elements in the collection
 
-		for cursor.Next(context.Background()) {
+		for cursor.Next(mg.context()) {
 			var doc primitive.M
 			err = cursor.Decode(&doc)
 			if err != nil {
 				return nil, err
 			}
-			// get the in edge id
-			inEdgeID := doc["_id"].(primitive.ObjectID)
-			// get the in edge by id
-			inEdge, err := mg.GetItemByID(inEdgeID)
-			if err != nil {
-				return nil, err
-			}
 			// append the in edge to the inEdges
-			var tmp map[string]interface{}
-			switch v := inEdge.(type) {
-			case primitive.M:
-				tmp = map[string]interface{}(v)
-			case map[string]interface{}:
-				tmp = v
-			default:
-				return nil, fmt.Errorf("invalid type")
-			}
-
-			tmpEdge, errtmp := mapToEdge(tmp)
+			tmpEdge, errtmp := mapToEdge(doc)
 			if errtmp != nil {
 				return nil, errtmp
 			}
@@ -974,42 +876,29 @@ func (mg *MongoGraph) GetOutEdges(name interface{}) ([]handler.Edge, error) {
 	node := nodetmp.(*Node)
 	// get the database
 	db := mg.client.Database(mg.database)
-	// iter the items in the database to get the out edges
-	collections, err := db.ListCollectionNames(context.Background(), bson.D{})
-	if err != nil {
-		return nil, err
-	}
 
 	// get the out edges
 	var outEdges []handler.Edge
-	for _, col := range collections {
+	for _, col := range mg.edgeCollections() {
 		// get the collection
 		col := db.Collection(col)
 		// get the out edges
-		cursor, err := col.Find(context.Background(), bson.M{"from": node.ID})
+		cursor, err := col.Find(mg.context(), bson.M{"from": node.ID})
 		if err != nil {
 			return nil, err
 		}
-		defer cursor.Close(context.Background())
+		defer cursor.Close(mg.context())
 
 		// iterate the elements in the collection
 
-		for cursor.Next(context.Background()) {
+		for cursor.Next(mg.context()) {
 			var doc primitive.M
 			err = cursor.Decode(&doc)
 			if err != nil {
 				return nil, err
 			}
-			// get the out edge id
-			outEdgeID := doc["_id"].(primitive.ObjectID)
-			// get the out edge by id
-			outEdge, err := mg.GetItemByID(outEdgeID)
-			if err != nil {
-				return nil, err
-			}
 			// append the out edge to the outEdges
-
-			tmpEdge, errtmp := mapToEdge(outEdge.(primitive.M))
+			tmpEdge, errtmp := mapToEdge(doc)
 			if errtmp != nil {
 				return nil, errtmp
 			}
@@ -1037,16 +926,34 @@ func (mg *MongoGraph) ReplaceNode(ni handler.Node) error {
 	default:
 		return fmt.Errorf("invalid input")
 	}
+	// split the provenance out of the data
+	data, src, err := provenance.Extract(n.Data)
+	if err != nil {
+		return err
+	}
+	n.Data = data
+	// the aliases of the write are unique with the names and the other aliases
+	if err := mg.checkAliases(n.ID.Hex(), resolve.Aliases(n.Data, handler.AliasesKey)...); err != nil {
+		return err
+	}
 	// get the database and collection
 	db := mg.client.Database(mg.database)
 	verticesCol := db.Collection(n.Collection)
 
-	// replace the node with the same id
-	_, err = verticesCol.ReplaceOne(context.TODO(), bson.M{"_id": n.ID}, n)
+	// replace the node with the same id, and the revision given if any
+	expected := n.Rev
+	n.Rev = newRev()
+	res, err := verticesCol.ReplaceOne(mg.context(), revFilter(n.ID, expected), n)
 	if err != nil {
 		return err
 	}
-	return nil
+	if res.MatchedCount == 0 && expected != "" {
+		return mg.conflict(verticesCol, n.ID, expected)
+	}
+	if err := mg.record(history.Replace, n.Collection, n.ID, n.Data); err != nil {
+		return err
+	}
+	return mg.assert(history.Replace, n.ID, history.NodeKind, n.Collection, n.Data, src)
 }
 
 // ReplaceEdge(e Edge) (id interface{}, err error)
@@ -1062,17 +969,31 @@ func (mg *MongoGraph) ReplaceEdge(ei handler.Edge) error {
 	default:
 		return fmt.Errorf("invalid input")
 	}
+	// split the provenance out of the data
+	data, src, err := provenance.Extract(e.Data)
+	if err != nil {
+		return err
+	}
+	e.Data = data
 
 	// get the database and collection
 	db := mg.client.Database(mg.database)
 	edgesCol := db.Collection(e.Collection)
 
-	// replace the edge with the same id
-	_, err = edgesCol.ReplaceOne(context.TODO(), bson.M{"_id": e.ID}, e)
+	// replace the edge with the same id, and the revision given if any
+	expected := e.Rev
+	e.Rev = newRev()
+	res, err := edgesCol.ReplaceOne(mg.context(), revFilter(e.ID, expected), e)
 	if err != nil {
 		return err
 	}
-	return nil
+	if res.MatchedCount == 0 && expected != "" {
+		return mg.conflict(edgesCol, e.ID, expected)
+	}
+	if err := mg.record(history.Replace, e.Collection, e.ID, e.Data); err != nil {
+		return err
+	}
+	return mg.assert(history.Replace, e.ID, history.EdgeKind, e.Collection, e.Data, src)
 }
 
 // UpdateNode(n Node) (id Node,err error)
@@ -1088,16 +1009,35 @@ func (mg *MongoGraph) UpdateNode(ntmp handler.Node) error {
 	default:
 		return fmt.Errorf("invalid input")
 	}
+	// split the provenance out of the data
+	data, src, err := provenance.Extract(n.Data)
+	if err != nil {
+		return err
+	}
+	n.Data = data
+	// the aliases of the write are unique with the names and the other aliases
+	if err := mg.checkAliases(n.ID.Hex(), resolve.Aliases(n.Data, handler.AliasesKey)...); err != nil {
+		return err
+	}
 	// get the database and collection
 	db := mg.client.Database(mg.database)
 	verticesCol := db.Collection(n.Collection)
 
-	// update the node with the same id
-	_, err = verticesCol.UpdateOne(context.TODO(), bson.M{"_id": n.ID}, bson.M{"$set": n})
+	// update the node with the same id, and the revision given if any
+	expected := n.Rev
+	n.Rev = newRev()
+	res, err := verticesCol.UpdateOne(mg.context(), revFilter(n.ID, expected), bson.M{"$set": n})
 	if err != nil {
 		return err
 	}
-	return nil
+	if res.MatchedCount == 0 && expected != "" {
+		return mg.conflict(verticesCol, n.ID, expected)
+	}
+	if err := mg.record(history.Update, n.Collection, n.ID, n.Data); err != nil {
+		return err
+	}
+	// $set of the whole node replaces the data field, the facts of the dropped fields go too
+	return mg.assert(history.Replace, n.ID, history.NodeKind, n.Collection, n.Data, src)
 }
 
 // UpdateEdge(e Edge) (id Edge,err error)
@@ -1112,23 +1052,42 @@ func (mg *MongoGraph) UpdateEdge(etmp handler.Edge) error {
 	default:
 		return fmt.Errorf("invalid input")
 	}
+	// split the provenance out of the data
+	data, src, err := provenance.Extract(e.Data)
+	if err != nil {
+		return err
+	}
+	e.Data = data
 
 	// get the database and collection
 	db := mg.client.Database(mg.database)
 	edgesCol := db.Collection(e.Collection)
 
-	// update the edge with the same id
-	_, err = edgesCol.UpdateOne(context.TODO(), bson.M{"_id": e.ID}, bson.M{"$set": e})
+	// update the edge with the same id, and the revision given if any
+	expected := e.Rev
+	e.Rev = newRev()
+	res, err := edgesCol.UpdateOne(mg.context(), revFilter(e.ID, expected), bson.M{"$set": e})
 	if err != nil {
 		return err
 	}
-	return nil
+	if res.MatchedCount == 0 && expected != "" {
+		return mg.conflict(edgesCol, e.ID, expected)
+	}
+	if err := mg.record(history.Update, e.Collection, e.ID, e.Data); err != nil {
+		return err
+	}
+	// $set of the whole edge replaces the data field unless it is blank
+	op := history.Update
+	if len(e.Data) > 0 {
+		op = history.Replace
+	}
+	return mg.assert(op, e.ID, history.EdgeKind, e.Collection, e.Data, src)
 }
 
 // MergeNode(n Node) (id Node,err error)
-// Merge: data field will be merged,
+// Merge: data field will be merged by the merge strategies,
 //
-//	other fields except ID will be replaced
+//	other fields are kept
 func (mg *MongoGraph) MergeNode(ntmp handler.Node) error {
 	var err error
 	defer recoverFromPanic(&err)
@@ -1139,37 +1098,65 @@ func (mg *MongoGraph) MergeNode(ntmp handler.Node) error {
 	default:
 		return fmt.Errorf("invalid input")
 	}
+	// split the provenance out of the data
+	data, src, err := provenance.Extract(n.Data)
+	if err != nil {
+		return err
+	}
+	n.Data = data
+	// the aliases of the write are unique with the names and the other aliases
+	if err := mg.checkAliases(n.ID.Hex(), resolve.Aliases(n.Data, handler.AliasesKey)...); err != nil {
+		return err
+	}
 	// get the database and collection
 	db := mg.client.Database(mg.database)
 	verticesCol := db.Collection(n.Collection)
 
-	// get the node with the same id
-	var node Node
-	err = verticesCol.FindOne(context.TODO(), bson.M{"_id": n.ID}).Decode(&node)
-	if err != nil {
-		return err
-	}
+	// read, merge and write back if nobody wrote in between
+	// the write filters on the revision read, a merge without revision reads again after a conflict
+	for attempt := 0; ; attempt++ {
+		// get the node with the same id
+		var node Node
+		err = verticesCol.FindOne(mg.context(), bson.M{"_id": n.ID}).Decode(&node)
+		if err != nil {
+			return err
+		}
+		if n.Rev != "" && n.Rev != node.Rev {
+			return &handler.ConflictError{ID: n.ID.Hex(), Expected: n.Rev, Actual: node.Rev}
+		}
 
-	// merge the data field, keep the n untouched
-	for k, v := range n.Data {
-		node.Data[k] = v
+		// merge the data field with the configured strategies, keep the n untouched
+		merged, err := mg.mergeConfig().Merge(node.Collection, node.Data, n.Data)
+		if err != nil {
+			return err
+		}
+		// // if the name is not blank, replace the name
+		// if n.Name != "" {
+		// 	node.Name = n.Name
+		// }
+		// // if the collection is not blank, replace the collection
+		// if n.Collection != "" {
+		// 	node.Collection = n.Collection
+		// }
+
+		// only the data field is written back
+		res, err := verticesCol.UpdateOne(mg.context(), readFilter(n.ID, node.Rev), bson.M{"$set": bson.M{"data": merged, "rev": newRev()}})
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			if n.Rev == "" && attempt < handler.MergeRetries {
+				continue
+			}
+			return mg.conflict(verticesCol, n.ID, node.Rev)
+		}
+		break
 	}
-	// // if the name is not blank, replace the name
-	// if n.Name != "" {
-	// 	node.Name = n.Name
-	// }
-	// // if the collection is not blank, replace the collection
-	// if n.Collection != "" {
-	// 	node.Collection = n.Collection
-	// }
 
-	// replace the node with the same id
-	_, err = verticesCol.UpdateOne(context.TODO(), bson.M{"_id": n.ID}, bson.M{"$set": n})
-	if err != nil {
+	if err := mg.record(history.Merge, n.Collection, n.ID, n.Data); err != nil {
 		return err
 	}
-
-	return nil
+	return mg.assert(history.Merge, n.ID, history.NodeKind, n.Collection, n.Data, src)
 }
 
 // MergeEdge(e Edge) (id Edge,err error)
@@ -1186,46 +1173,72 @@ func (mg *MongoGraph) MergeEdge(etmp handler.Edge) error {
 	default:
 		return fmt.Errorf("invalid input")
 	}
+	// split the provenance out of the data
+	data, src, err := provenance.Extract(e.Data)
+	if err != nil {
+		return err
+	}
+	e.Data = data
 
 	// get the database and collection
 	db := mg.client.Database(mg.database)
 	edgesCol := db.Collection(e.Collection)
 
-	// get the edge with the same id
-	var edge Edge
-	err = edgesCol.FindOne(context.TODO(), bson.M{"_id": e.ID}).Decode(&edge)
-	if err != nil {
-		return err
-	}
+	// read, merge and write back if nobody wrote in between
+	// the write filters on the revision read, a merge without revision reads again after a conflict
+	for attempt := 0; ; attempt++ {
+		// get the edge with the same id
+		var edge Edge
+		err = edgesCol.FindOne(mg.context(), bson.M{"_id": e.ID}).Decode(&edge)
+		if err != nil {
+			return err
+		}
+		if e.Rev != "" && e.Rev != edge.Rev {
+			return &handler.ConflictError{ID: e.ID.Hex(), Expected: e.Rev, Actual: edge.Rev}
+		}
+		read := edge.Rev
 
-	// merge the data field, keep the e untouched
-	for k, v := range e.Data {
-		edge.Data[k] = v
-	}
-	// if the from is not blank, replace the from
-	if e.From != primitive.NilObjectID {
-		edge.From = e.From
-	}
-	// if the to is not blank, replace the to
-	if e.To != primitive.NilObjectID {
-		edge.To = e.To
-	}
-	// // if the collection is not blank, replace the collection
-	// if e.Collection != "" {
-	// 	edge.Collection = e.Collection
-	// }
-	// if the relationship is not blank, replace the relationship
-	if e.Relationship != "" {
-		edge.Relationship = e.Relationship
+		// merge the data field with the configured strategies, keep the e untouched
+		edge.Data, err = mg.mergeConfig().Merge(edge.Collection, edge.Data, e.Data)
+		if err != nil {
+			return err
+		}
+		// if the from is not blank, replace the from
+		if e.From != primitive.NilObjectID {
+			edge.From = e.From
+		}
+		// if the to is not blank, replace the to
+		if e.To != primitive.NilObjectID {
+			edge.To = e.To
+		}
+		// // if the collection is not blank, replace the collection
+		// if e.Collection != "" {
+		// 	edge.Collection = e.Collection
+		// }
+		// if the relationship is not blank, replace the relationship
+		if e.Relationship != "" {
+			edge.Relationship = e.Relationship
+		}
+
+		// replace the edge with the same id
+		edge.Rev = newRev()
+		res, err := edgesCol.UpdateOne(mg.context(), readFilter(e.ID, read), bson.M{"$set": edge})
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			if e.Rev == "" && attempt < handler.MergeRetries {
+				continue
+			}
+			return mg.conflict(edgesCol, e.ID, read)
+		}
+		break
 	}
 
-	// replace the edge with the same id
-	_, err = edgesCol.UpdateOne(context.TODO(), bson.M{"_id": e.ID}, bson.M{"$set": edge})
-	if err != nil {
+	if err := mg.record(history.Merge, e.Collection, e.ID, e.Data); err != nil {
 		return err
 	}
-
-	return nil
+	return mg.assert(history.Merge, e.ID, history.EdgeKind, e.Collection, e.Data, src)
 }
 
 // + Delete operations
@@ -1238,24 +1251,43 @@ func (mg *MongoGraph) DeleteNode(name interface{}) error {
 	db := mg.client.Database(mg.database)
 
 	// get the collection name
-	if _, ok := mg.nodeNameCollMap[name.(string)]; !ok {
+	colName, ok, err := mg.nodeCollection(name.(string))
+	if err != nil {
+		return err
+	}
+	if !ok {
 		return fmt.Errorf("Node not found")
 	}
-	colName := mg.nodeNameCollMap[name.(string)]
 	verticesCol := db.Collection(colName)
 
 	// delete the node with the same name
-	_, err = verticesCol.DeleteOne(context.TODO(), bson.M{"name": name})
+	var node Node
+	err = verticesCol.FindOneAndDelete(mg.context(), bson.M{"name": name}).Decode(&node)
+	if err != nil {
+		return err
+	}
+	mg.forgetRoute(route{ID: node.ID, Kind: history.NodeKind, Name: name.(string)})
+	// the time series of the node go with it
+	err = mg.removeSeries(node.ID, "")
+	if err != nil {
+		return err
+	}
+	// close the versions of the node
+	err = mg.record(history.Delete, colName, node.ID, nil)
+	if err != nil {
+		return err
+	}
+	err = mg.removeFacts(node.ID)
 	if err != nil {
 		return err
 	}
 	// check if the collection is empty, if so, drop the collection
-	cursor, err := verticesCol.Find(context.TODO(), bson.D{})
+	cursor, err := verticesCol.Find(mg.context(), bson.D{})
 	if err != nil {
 		return err
 	}
-	defer cursor.Close(context.Background())
-	if !cursor.Next(context.Background()) {
+	defer cursor.Close(mg.context())
+	if !cursor.Next(mg.context()) {
 		// drop the collection
 		err = mg.dropCollection(colName)
 		if err != nil {
@@ -1271,44 +1303,55 @@ func (mg *MongoGraph) DeleteItemByID(id interface{}) error {
 	var err error
 	defer recoverFromPanic(&err)
 
-	// get the database
-	db := mg.client.Database(mg.database)
-
-	// get all the collections in the database
-	collections, err := db.ListCollectionNames(context.Background(), bson.D{})
+	// the route of the item tells its collection
+	oid, err := toObjectID(id)
 	if err != nil {
 		return err
 	}
-
-	// iterate all the collections
-	for _, col := range collections {
-		// get the collection
-		col := db.Collection(col)
-		// delete the item by ID
-		_, err = col.DeleteOne(context.Background(), bson.M{"_id": id})
-		if err == nil {
-			// check if the collection is empty, if so, drop the collection
-			cursor, err := col.Find(context.TODO(), bson.D{})
-			if err != nil {
-				return err
-			}
-
-			defer cursor.Close(context.Background())
-
-			if !cursor.Next(context.Background()) {
-				// drop the collection
-				err = mg.dropCollection(col.Name())
-				if err != nil {
-					return err
-				}
-			}
-
-			return nil
-
+	colName, err := mg.locate(oid)
+	if err != nil {
+		return err
+	}
+	col := mg.client.Database(mg.database).Collection(colName)
+	// delete the item by ID
+	var doc primitive.M
+	err = col.FindOneAndDelete(mg.context(), bson.M{"_id": oid}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		mg.forgetRoute(route{ID: oid})
+		return fmt.Errorf("item not found")
+	} else if err != nil {
+		return err
+	}
+	if name, ok := doc["name"].(string); ok {
+		mg.forgetRoute(route{ID: oid, Kind: history.NodeKind, Name: name})
+	} else {
+		mg.forgetRoute(route{ID: oid})
+	}
+	// the time series of the item go with it
+	if err := mg.removeSeries(oid, ""); err != nil {
+		return err
+	}
+	// close the versions of the item
+	if err := mg.record(history.Delete, colName, oid, nil); err != nil {
+		return err
+	}
+	if err := mg.removeFacts(oid); err != nil {
+		return err
+	}
+	// check if the collection is empty, if so, drop the collection
+	cursor, err := col.Find(mg.context(), bson.D{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(mg.context())
+	if !cursor.Next(mg.context()) {
+		// drop the collection
+		err = mg.dropCollection(colName)
+		if err != nil {
+			return err
 		}
-
 	}
-	return fmt.Errorf("item not found")
+	return nil
 }
 
 // + Graph operations
@@ -1316,231 +1359,31 @@ func (mg *MongoGraph) DeleteItemByID(id interface{}) error {
 // GetAllRelatedNodes(name interface{}) ([][]Node, error)
 // like BFS, get all the related nodes,
 // the first dimension is the level, the second dimension is the nodes in the level
-// need to avoid the circle
+// one $graphLookup over the adjacency follows the edges both ways, the circles included
 func (mg *MongoGraph) GetAllRelatedNodes(name interface{}) ([][]handler.Node, error) {
-    var err error
-    defer recoverFromPanic(&err)
-
-    // Get the starting node by name
-    staNode, err := mg.GetNode(name)
-    if err != nil {
-        return nil, err
-    }
-
-	// convert the node to Node
-	startNode := staNode.(*Node)
-    // Initialize the BFS queue and visited set
-    queue := []handler.Node{startNode}
-    visited := make(map[string]bool)
-    visited[startNode.ID.Hex()] = true
-
-    // Initialize the result to store nodes level-wise
-    var result [][]handler.Node
-    currentLevel := []handler.Node{startNode}
-
-    for len(queue) > 0 {
-        var nextLevel []handler.Node
-
-        // Process all nodes in the current level
-        for _, node := range currentLevel {
-			// node type conversion
-			node := node.(*Node)
-            // Get all related nodes (both incoming and outgoing edges)
-            fromNodes, err := mg.GetFromNodes(node.Name)
-            if err != nil {
-                return nil, err
-            }
-            toNodes, err := mg.GetToNodes(node.Name)
-            if err != nil {
-                return nil, err
-            }
-
-            // Combine fromNodes and toNodes
-            relatedNodes := append(fromNodes, toNodes...)
-
-            // Add unvisited related nodes to the next level
-            for _, relatedNode := range relatedNodes {
-				// node type conversion
-				relatedNode := relatedNode.(*Node)
-                if !visited[relatedNode.ID.Hex()] {
-                    visited[relatedNode.ID.Hex()] = true
-                    nextLevel = append(nextLevel, relatedNode)
-                    queue = append(queue, relatedNode)
-                }
-            }
-        }
-
-        // Add the current level to the result
-        result = append(result, currentLevel)
-		// ! check if the next level is empty
-		if len(nextLevel) == 0 {
-			break
-		}
-
-        // Move to the next level
-        currentLevel = nextLevel
-        queue = queue[len(currentLevel):]
-    }
-
-    return result, nil
+	return mg.related(name, 0, nil)
 }
 
 // GetAllRelatedNodesInRange(name interface{}, max int) ([][]Node, error)
 // get all the related nodes in the range of max levels
 // similar to GetAllRelatedNodes, but with a max level
-// need to avoid the circle
-
 func (mg *MongoGraph) GetAllRelatedNodesInRange(name interface{}, max int) ([][]handler.Node, error) {
-	var err error
-	defer recoverFromPanic(&err)
-
-	// Get the starting node by name
-	staNode, err := mg.GetNode(name)
-	if err != nil {
-		return nil, err
-	}
-
-	// convert the node to Node
-	startNode := staNode.(*Node)
-	// Initialize the BFS queue and visited set
-	queue := []handler.Node{startNode}
-	visited := make(map[string]bool)
-	visited[startNode.ID.Hex()] = true
-
-	// Initialize the result to store nodes level-wise
-	var result [][]handler.Node
-	currentLevel := []handler.Node{startNode}
-
-	for i := 0; i < max; i++ {
-		var nextLevel []handler.Node
-
-		// Process all nodes in the current level
-		for _, node := range currentLevel {
-			// node type conversion
-			node := node.(*Node)
-			// Get all related nodes (both incoming and outgoing edges)
-			fromNodes, err := mg.GetFromNodes(node.Name)
-			if err != nil {
-				return nil, err
-			}
-			toNodes, err := mg.GetToNodes(node.Name)
-			if err != nil {
-				return nil, err
-			}
-
-			// Combine fromNodes and toNodes
-			relatedNodes := append(fromNodes, toNodes...)
-
-			// Add unvisited related nodes to the next level
-			for _, relatedNode := range relatedNodes {
-				// node type conversion
-				relatedNode := relatedNode.(*Node)
-				if !visited[relatedNode.ID.Hex()] {
-					visited[relatedNode.ID.Hex()] = true
-					nextLevel = append(nextLevel, relatedNode)
-					queue = append(queue, relatedNode)
-				}
-			}
-		}
-
-		// Add the current level to the result
-		result = append(result, currentLevel)
-		// ! check if the next level is empty
-		if len(nextLevel) == 0 {
-			break
-		}
-
-		// Move to the next level
-		currentLevel = nextLevel
-		queue = queue[len(currentLevel):]
+	if max < 1 {
+		max = 1
 	}
-
-	return result, nil
+	return mg.related(name, max, nil)
 }
 
-
-
 // GetAllRelatedNodesInEdgeSlice(name interface{}, EdgeSlice ...Edge) ([][]Node, error)
 // similar to GetAllRelatedNodes, but only consider the edges in the EdgeSlice
 func (mg *MongoGraph) GetAllRelatedNodesInEdgeSlice(name interface{}, EdgeSlice ...handler.Edge) ([][]handler.Node, error) {
-	var err error
-	defer recoverFromPanic(&err)
-
-	// Get the starting node by name
-	staNode, err := mg.GetNode(name)
+	ids, err := edgeIDs(EdgeSlice)
 	if err != nil {
 		return nil, err
 	}
-
-	// convert the node to Node
-	startNode := staNode.(*Node)
-	// Initialize the BFS queue and visited set
-	queue := []handler.Node{startNode}
-	visited := make(map[string]bool)
-	visited[startNode.ID.Hex()] = true
-
-	// Initialize the result to store nodes level-wise
-	var result [][]handler.Node
-	currentLevel := []handler.Node{startNode}
-
-	for len(queue) > 0 {
-		var nextLevel []handler.Node
-
-		// Process all nodes in the current level
-		for _, node := range currentLevel {
-			// node type conversion
-			node := node.(*Node)
-			// Get all related nodes (both incoming and outgoing edges)
-			fromNodes, err := mg.GetFromNodesInEdges(node.Name, EdgeSlice...)
-			if err != nil {
-				return nil, err
-			}
-			toNodes, err := mg.GetToNodesInEdges(node.Name, EdgeSlice...)
-			if err != nil {
-				return nil, err
-			}
-
-			// Combine fromNodes and toNodes
-			relatedNodes := append(fromNodes, toNodes...)
-
-			// Add unvisited related nodes to the next level
-			for _, relatedNode := range relatedNodes {
-				// node type conversion
-				relatedNode := relatedNode.(*Node)
-				if !visited[relatedNode.ID.Hex()] {
-					visited[relatedNode.ID.Hex()] = true
-					nextLevel = append(nextLevel, relatedNode)
-					queue = append(queue, relatedNode)
-				}
-			}
-		}
-
-		// Add the current level to the result
-		result = append(result, currentLevel)
-		// ! check if the next level is empty
-		if len(nextLevel) == 0 {
-			break
-		}
-
-		// Move to the next level
-		currentLevel = nextLevel
-		queue = queue[len(currentLevel):]
-	}
-
-	return result, nil
+	return mg.related(name, 0, bson.M{"edge": bson.M{"$in": ids}})
 }
 
-
-
-
-
-
-
-
-
-
-
-
 // func (mg *MongoGraph) GetAllRelatedNodesInEdgeSlice(name interface{}, EdgeSlice ...handler.Edge) ([][]handler.Node, error) {
 // 	// get the node by name
 // 	node, err := mg.GetNode(name)
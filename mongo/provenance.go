@@ -0,0 +1,237 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"github.com/wonderstone/chainstorm/provenance"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// the MongoGraph keeps the provenance of the facts
+var _ handler.ProvenanceGraph = (*MongoGraph)(nil)
+
+// provenanceCollection returns the provenance collection, created with its indexes on first use
+// ~ the facts are provenance.Fact documents, the item IDs are stored as hex strings
+func (mg *MongoGraph) provenanceCollection() (*mongo.Collection, error) {
+	db := mg.client.Database(mg.database)
+	col := db.Collection(handler.ProvenanceCollection)
+	if mg.collectionExists(handler.ProvenanceCollection) {
+		return col, nil
+	}
+	// one fact per item, field and source, and the facts by source
+	_, err := col.Indexes().CreateMany(mg.context(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "item", Value: 1}, {Key: "field", Value: 1}, {Key: "source", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "source", Value: 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	mg.collSet[handler.ProvenanceCollection] = void{}
+	return col, nil
+}
+
+// findFacts reads all the facts matching the filter
+func findFacts(ctx context.Context, col *mongo.Collection, filter bson.M, sort bson.D) ([]provenance.Fact, error) {
+	cursor, err := col.Find(ctx, filter, options.Find().SetSort(sort))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	facts := []provenance.Fact{}
+	for cursor.Next(ctx) {
+		var f provenance.Fact
+		if err := cursor.Decode(&f); err != nil {
+			return nil, err
+		}
+		f.Timestamp = f.Timestamp.UTC()
+		facts = append(facts, f)
+	}
+	return facts, cursor.Err()
+}
+
+// assert stores the facts of a write on the item
+// written is the data of the write without the provenance key, rec is nil without provenance
+// a replace also drops the facts of the fields it removed
+func (mg *MongoGraph) assert(op history.Op, oid primitive.ObjectID, kind history.Kind, collection string, written map[string]interface{}, rec *provenance.Record) error {
+	if op != history.Replace && rec == nil {
+		return nil
+	}
+	col, err := mg.provenanceCollection()
+	if err != nil {
+		return err
+	}
+
+	if op == history.Replace {
+		fields := []string{provenance.ItemField}
+		for k := range written {
+			fields = append(fields, k)
+		}
+		_, err = col.DeleteMany(mg.context(), bson.M{"item": oid.Hex(), "field": bson.M{"$nin": fields}})
+		if err != nil {
+			return err
+		}
+	}
+	if rec == nil {
+		return nil
+	}
+
+	return upsertFacts(mg.context(), col, provenance.Facts(op, *rec, oid.Hex(), kind, collection, written))
+}
+
+// upsertFacts stores the facts, the same source replaces its fact, another source adds one
+func upsertFacts(ctx context.Context, col *mongo.Collection, facts []provenance.Fact) error {
+	models := make([]mongo.WriteModel, len(facts))
+	for i, f := range facts {
+		models[i] = mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"item": f.Item, "field": f.Field, "source": f.Source}).
+			SetReplacement(f).
+			SetUpsert(true)
+	}
+	_, err := col.BulkWrite(ctx, models)
+	return err
+}
+
+// removeFacts removes the facts of the item
+func (mg *MongoGraph) removeFacts(oid primitive.ObjectID) error {
+	if !mg.collectionExists(handler.ProvenanceCollection) {
+		return nil
+	}
+	_, err := mg.client.Database(mg.database).Collection(handler.ProvenanceCollection).
+		DeleteMany(mg.context(), bson.M{"item": oid.Hex()})
+	return err
+}
+
+// GetProvenance(id interface{}) ([]provenance.Fact, error)
+func (mg *MongoGraph) GetProvenance(id interface{}) ([]provenance.Fact, error) {
+	oid, err := toObjectID(id)
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := mg.exists(oid); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("item %s not found", oid.Hex())
+	}
+	col, err := mg.provenanceCollection()
+	if err != nil {
+		return nil, err
+	}
+	return findFacts(mg.context(), col, bson.M{"item": oid.Hex()}, bson.D{{Key: "field", Value: 1}, {Key: "source", Value: 1}})
+}
+
+// GetFactsBySource(source string) ([]provenance.Fact, error)
+func (mg *MongoGraph) GetFactsBySource(source string) ([]provenance.Fact, error) {
+	if source == "" {
+		return nil, fmt.Errorf("source is required")
+	}
+	col, err := mg.provenanceCollection()
+	if err != nil {
+		return nil, err
+	}
+	return findFacts(mg.context(), col, bson.M{"source": source}, bson.D{{Key: "item", Value: 1}, {Key: "field", Value: 1}})
+}
+
+// RetractSource(source string, dryRun bool) (provenance.Report, error)
+func (mg *MongoGraph) RetractSource(source string, dryRun bool) (provenance.Report, error) {
+	mine, err := mg.GetFactsBySource(source)
+	if err != nil {
+		return provenance.Report{}, err
+	}
+	col, err := mg.provenanceCollection()
+	if err != nil {
+		return provenance.Report{}, err
+	}
+	items := []string{}
+	collections := make(map[string]string)
+	for _, f := range mine {
+		if _, ok := collections[f.Item]; !ok {
+			collections[f.Item] = f.Collection
+			items = append(items, f.Item)
+		}
+	}
+	// all the facts of the items the source touched
+	facts, err := findFacts(mg.context(), col, bson.M{"item": bson.M{"$in": items}}, bson.D{{Key: "item", Value: 1}})
+	if err != nil {
+		return provenance.Report{}, err
+	}
+	report := provenance.Plan(source, facts)
+	report.DryRun = dryRun
+
+	// the edges of the deleted nodes go with them
+	names := make(map[string]string)
+	db := mg.client.Database(mg.database)
+	for _, it := range report.Deleted {
+		if it.Kind != history.NodeKind {
+			continue
+		}
+		oid, err := toObjectID(it.ID)
+		if err != nil {
+			return report, err
+		}
+		var node Node
+		if err := db.Collection(it.Collection).FindOne(mg.context(), bson.M{"_id": oid}).Decode(&node); err != nil {
+			return report, err
+		}
+		names[it.ID] = node.Name
+		in, err := mg.GetInEdges(node.Name)
+		if err != nil {
+			return report, err
+		}
+		out, err := mg.GetOutEdges(node.Name)
+		if err != nil {
+			return report, err
+		}
+		for _, he := range append(in, out...) {
+			e := he.(*Edge)
+			if !report.Delete(e.ID.Hex()) {
+				report.Cascaded = append(report.Cascaded, provenance.Item{ID: e.ID.Hex(), Kind: history.EdgeKind, Collection: e.Collection})
+			}
+		}
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	for id, fields := range report.Removed {
+		oid, err := toObjectID(id)
+		if err != nil {
+			return report, err
+		}
+		unset := bson.M{}
+		for _, f := range fields {
+			unset["data."+f] = ""
+		}
+		_, err = db.Collection(collections[id]).UpdateOne(mg.context(), bson.M{"_id": oid}, bson.M{"$unset": unset, "$set": bson.M{"rev": newRev()}})
+		if err != nil {
+			return report, err
+		}
+		if err := mg.record(history.Retract, collections[id], oid, nil); err != nil {
+			return report, err
+		}
+	}
+	for _, it := range report.DeleteOrder() {
+		if name, ok := names[it.ID]; ok {
+			err = mg.DeleteNode(name)
+		} else {
+			var oid primitive.ObjectID
+			if oid, err = toObjectID(it.ID); err == nil {
+				err = mg.DeleteItemByID(oid)
+			}
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+	_, err = col.DeleteMany(mg.context(), bson.M{"source": source})
+	return report, err
+}
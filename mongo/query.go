@@ -0,0 +1,115 @@
+package mongo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// the MongoGraph follows the relationship patterns of the query plans with $graphLookup
+var _ query.Native = (*MongoGraph)(nil)
+
+// RunPlan(p *query.Plan) (*query.Result, error)
+// the start is found with FindNodes, a relationship pattern with one $graphLookup over the adjacency per node
+func (mg *MongoGraph) RunPlan(p *query.Plan) (*query.Result, error) {
+	// the edges of the relationships, read once per plan
+	typed := map[string][]primitive.ObjectID{}
+	return query.GenericReach(mg, p, func(from handler.Node, hop query.RelPattern) ([]handler.Node, error) {
+		start, ok := from.(*Node)
+		if !ok {
+			return nil, fmt.Errorf("invalid node type")
+		}
+		var edges []primitive.ObjectID
+		if len(hop.Types) > 0 {
+			key := strings.Join(hop.Types, "|")
+			if edges, ok = typed[key]; !ok {
+				var err error
+				if edges, err = mg.edgesOf(hop.Types); err != nil {
+					return nil, err
+				}
+				typed[key] = edges
+			}
+			if len(edges) == 0 {
+				return nil, nil
+			}
+		}
+		return mg.reach(start.ID, hop, edges)
+	})
+}
+
+// hopMatch restricts the adjacency documents to the direction of the hop, and to the edges if not nil
+func hopMatch(direction query.Direction, edges []primitive.ObjectID) bson.M {
+	match := bson.M{}
+	switch direction {
+	case query.Out:
+		match["out"] = true
+	case query.In:
+		match["out"] = false
+	}
+	if edges != nil {
+		match["edge"] = bson.M{"$in": edges}
+	}
+	if len(match) == 0 {
+		return nil
+	}
+	return match
+}
+
+// edgesOf returns the IDs of the edges with the relationships
+func (mg *MongoGraph) edgesOf(relationships []string) ([]primitive.ObjectID, error) {
+	db := mg.client.Database(mg.database)
+	ids := []primitive.ObjectID{}
+	for _, col := range mg.edgeCollections() {
+		cursor, err := db.Collection(col).Find(mg.context(), bson.M{"relationship": bson.M{"$in": relationships}},
+			options.Find().SetProjection(bson.M{"_id": 1}))
+		if err != nil {
+			return nil, err
+		}
+		var docs []struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.All(mg.context(), &docs); err != nil {
+			return nil, err
+		}
+		for _, d := range docs {
+			ids = append(ids, d.ID)
+		}
+	}
+	return ids, nil
+}
+
+// reach returns the nodes one to hop.Max hops away from start, the nearest first
+func (mg *MongoGraph) reach(start primitive.ObjectID, hop query.RelPattern, edges []primitive.ObjectID) ([]handler.Node, error) {
+	col, err := mg.adjacencyColl()
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := col.Aggregate(mg.context(), relatedPipeline(start, hop.Max-1, hopMatch(hop.Direction, edges)))
+	if err != nil {
+		return nil, err
+	}
+	var reached []reachedNode
+	if err := cursor.All(mg.context(), &reached); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, len(reached))
+	for i, r := range reached {
+		ids[i] = r.ID
+	}
+	nodes, err := mg.loadNodes(ids)
+	if err != nil {
+		return nil, err
+	}
+	var res []handler.Node
+	for _, r := range reached {
+		if n, ok := nodes[r.ID]; ok && r.ID != start {
+			res = append(res, n)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,24 @@
+package mongo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wonderstone/chainstorm/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestHopMatch(t *testing.T) {
+	if got := hopMatch(query.Both, nil); got != nil {
+		t.Errorf("Expected no restriction, got %v", got)
+	}
+	if got := hopMatch(query.Out, nil); !reflect.DeepEqual(got, bson.M{"out": true}) {
+		t.Errorf("Expected the out documents, got %v", got)
+	}
+	edges := []primitive.ObjectID{primitive.NewObjectID()}
+	want := bson.M{"out": false, "edge": bson.M{"$in": edges}}
+	if got := hopMatch(query.In, edges); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
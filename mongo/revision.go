@@ -0,0 +1,45 @@
+package mongo
+
+import (
+	"github.com/wonderstone/chainstorm/handler"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// # the revision of a node or an edge is a new rev at each write,
+// # a write given the rev it read filters on it and matches nothing once the document changed
+
+// newRev returns the revision of a write
+func newRev() string {
+	return primitive.NewObjectID().Hex()
+}
+
+// revFilter matches the item by ID, and by revision if rev is not empty
+func revFilter(id primitive.ObjectID, rev string) bson.M {
+	filter := bson.M{"_id": id}
+	if rev != "" {
+		filter["rev"] = rev
+	}
+	return filter
+}
+
+// readFilter matches the item if it still has the revision read, a missing rev matches the documents without one
+func readFilter(id primitive.ObjectID, rev string) bson.M {
+	if rev == "" {
+		return bson.M{"_id": id, "rev": nil}
+	}
+	return revFilter(id, rev)
+}
+
+// conflict returns the handler.ConflictError of a write which matched nothing, with the revision the item has now
+func (mg *MongoGraph) conflict(col *mongo.Collection, id primitive.ObjectID, expected string) error {
+	var doc struct {
+		Rev string `bson:"rev"`
+	}
+	actual := ""
+	if err := col.FindOne(mg.context(), bson.M{"_id": id}).Decode(&doc); err == nil {
+		actual = doc.Rev
+	}
+	return &handler.ConflictError{ID: id.Hex(), Expected: expected, Actual: actual}
+}
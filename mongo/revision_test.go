@@ -0,0 +1,26 @@
+package mongo
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRevFilter(t *testing.T) {
+	id := primitive.NewObjectID()
+	if got := revFilter(id, ""); !reflect.DeepEqual(got, bson.M{"_id": id}) {
+		t.Errorf("Expected the ID alone, got %v", got)
+	}
+	if got := revFilter(id, "r1"); !reflect.DeepEqual(got, bson.M{"_id": id, "rev": "r1"}) {
+		t.Errorf("Expected the revision, got %v", got)
+	}
+	// the documents written before the revisions have none
+	if got := readFilter(id, ""); !reflect.DeepEqual(got, bson.M{"_id": id, "rev": nil}) {
+		t.Errorf("Expected a missing revision, got %v", got)
+	}
+	if newRev() == newRev() {
+		t.Errorf("Expected a new revision at each write")
+	}
+}
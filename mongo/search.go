@@ -0,0 +1,241 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/history"
+	"github.com/wonderstone/chainstorm/resolve"
+	"github.com/wonderstone/chainstorm/search"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// the MongoGraph searches the nodes with a text index
+// $ the text index cuts the words on spaces only, so the Chinese names are stored
+// $ already tokenized in the search collection, one document per node
+var _ handler.SearchGraph = (*MongoGraph)(nil)
+
+// maxExpansions caps the tokens a prefix expands to
+const maxExpansions = 64
+
+// searchConfig returns the indexed fields, the default ones if not initialized
+func (mg *MongoGraph) searchConfig() *search.Config {
+	if mg.searcher == nil {
+		return search.Default()
+	}
+	return mg.searcher
+}
+
+// searchCollection returns the search collection, created with its indexes and filled on first use
+func (mg *MongoGraph) searchCollection() (*mongo.Collection, error) {
+	col := mg.client.Database(mg.database).Collection(handler.SearchCollection)
+	if mg.collectionExists(handler.SearchCollection) {
+		return col, nil
+	}
+	// the text index weights the name, the aliases and the fields like the in-process index
+	_, err := col.Indexes().CreateMany(mg.context(), []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "name", Value: "text"}, {Key: "aliases", Value: "text"}, {Key: "fields", Value: "text"}},
+			Options: options.Index().
+				SetWeights(bson.M{"name": int(search.NameWeight), "aliases": int(search.AliasWeight), "fields": int(search.FieldWeight)}).
+				SetDefaultLanguage("none"),
+		},
+		{Keys: bson.D{{Key: "item", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "terms", Value: 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	mg.collSet[handler.SearchCollection] = void{}
+	if err := mg.indexNodes(col); err != nil {
+		return nil, err
+	}
+	return col, nil
+}
+
+// joinTokens returns the tokens of the texts, space separated for the text index
+func joinTokens(texts ...string) string {
+	var tokens []string
+	for _, t := range texts {
+		tokens = append(tokens, search.Tokenize(t)...)
+	}
+	return strings.Join(tokens, " ")
+}
+
+// searchDoc is the search document of a node version
+func (mg *MongoGraph) searchDoc(v *history.Version) bson.M {
+	cfg := mg.searchConfig()
+	aliases := resolve.Aliases(v.Data, handler.AliasesKey)
+	var fields []string
+	for _, f := range cfg.Fields {
+		if s, ok := v.Data[f].(string); ok {
+			fields = append(fields, s)
+		}
+	}
+	return bson.M{
+		"item":       v.ItemID,
+		"collection": v.Collection,
+		"name":       joinTokens(v.Name),
+		"aliases":    joinTokens(aliases...),
+		"fields":     joinTokens(fields...),
+		"terms": cfg.Terms(search.Doc{
+			ID: v.ItemID, Collection: v.Collection, Name: v.Name, Aliases: aliases, Data: v.Data,
+		}),
+	}
+}
+
+// indexNodes writes the search documents of all the nodes
+func (mg *MongoGraph) indexNodes(col *mongo.Collection) error {
+	db := mg.client.Database(mg.database)
+	for colName := range mg.collSet {
+		if handler.IsReserved(colName) {
+			continue
+		}
+		cursor, err := db.Collection(colName).Find(mg.context(), bson.M{"name": bson.M{"$exists": true}})
+		if err != nil {
+			return err
+		}
+		for cursor.Next(mg.context()) {
+			var doc primitive.M
+			if err := cursor.Decode(&doc); err != nil {
+				cursor.Close(mg.context())
+				return err
+			}
+			n, err := mapToNode(doc)
+			if err != nil {
+				cursor.Close(mg.context())
+				return err
+			}
+			v := history.NodeVersion(history.Create, n.ID.Hex(), n.Collection, n.Name, n.Data)
+			if err := mg.putSearchDoc(col, v); err != nil {
+				cursor.Close(mg.context())
+				return err
+			}
+		}
+		cursor.Close(mg.context())
+	}
+	return nil
+}
+
+// putSearchDoc upserts the search document of a node version
+func (mg *MongoGraph) putSearchDoc(col *mongo.Collection, v *history.Version) error {
+	_, err := col.ReplaceOne(mg.context(), bson.M{"item": v.ItemID}, mg.searchDoc(v), options.Replace().SetUpsert(true))
+	return err
+}
+
+// indexVersion follows a write, next is nil for a delete
+func (mg *MongoGraph) indexVersion(itemID string, next *history.Version) error {
+	col, err := mg.searchCollection()
+	if err != nil {
+		return err
+	}
+	if next == nil || next.Kind != history.NodeKind {
+		_, err = col.DeleteOne(mg.context(), bson.M{"item": itemID})
+		return err
+	}
+	return mg.putSearchDoc(col, next)
+}
+
+// SetSearchConfig(c *search.Config) error
+// the search collection is rebuilt with the new fields
+func (mg *MongoGraph) SetSearchConfig(c *search.Config) error {
+	mg.searcher = c
+	if err := mg.dropCollection(handler.SearchCollection); err != nil {
+		return err
+	}
+	_, err := mg.searchCollection()
+	return err
+}
+
+// expandTokens completes the query tokens with the indexed terms they start
+func expandTokens(ctx context.Context, col *mongo.Collection, tokens []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var res []string
+	for _, t := range tokens {
+		terms, err := col.Distinct(ctx, "terms", bson.M{"terms": bson.M{"$regex": "^" + regexp.QuoteMeta(t)}})
+		if err != nil {
+			return nil, err
+		}
+		n := 0
+		for _, term := range terms {
+			s, ok := term.(string)
+			// % Distinct returns all the terms of the matched documents, not only the matching ones
+			if !ok || !strings.HasPrefix(s, t) || seen[s] || n == maxExpansions {
+				continue
+			}
+			seen[s] = true
+			res = append(res, s)
+			n++
+		}
+	}
+	return res, nil
+}
+
+// Search(query string, opts search.Options) ([]handler.SearchHit, error)
+func (mg *MongoGraph) Search(query string, opts search.Options) ([]handler.SearchHit, error) {
+	tokens := search.QueryTokens(query)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("query is empty")
+	}
+	col, err := mg.searchCollection()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Prefix {
+		if tokens, err = expandTokens(mg.context(), col, tokens); err != nil {
+			return nil, err
+		}
+		if len(tokens) == 0 {
+			return []handler.SearchHit{}, nil
+		}
+	}
+
+	// # any token in any field, $text ORs the space separated terms
+	filter := bson.M{"$text": bson.M{"$search": strings.Join(tokens, " ")}}
+	if len(opts.Collections) > 0 {
+		filter["collection"] = bson.M{"$in": opts.Collections}
+	}
+	score := bson.M{"$meta": "textScore"}
+	findOpts := options.Find().
+		SetProjection(bson.M{"item": 1, "score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "item", Value: 1}})
+	if opts.Limit > 0 {
+		findOpts.SetLimit(int64(opts.Limit))
+	}
+	cursor, err := col.Find(mg.context(), filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(mg.context())
+
+	hits := []handler.SearchHit{}
+	for cursor.Next(mg.context()) {
+		var doc struct {
+			Item  string  `bson:"item"`
+			Score float64 `bson:"score"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		oid, err := primitive.ObjectIDFromHex(doc.Item)
+		if err != nil {
+			return nil, err
+		}
+		item, err := mg.GetItemByID(oid)
+		if err != nil {
+			return nil, err
+		}
+		node, err := mapToNode(item.(primitive.M))
+		if err != nil {
+			return nil, err
+		}
+		hits = append(hits, handler.SearchHit{Node: &node, Score: doc.Score})
+	}
+	return hits, cursor.Err()
+}
@@ -0,0 +1,240 @@
+package mongo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/timeseries"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// the MongoGraph keeps the time series properties
+var _ handler.TimeSeriesGraph = (*MongoGraph)(nil)
+
+// seriesDoc is one point in the series collection
+// ~ a plain collection with a unique index instead of a mongo time series collection,
+// ~ time series collections cannot replace the value of an existing timestamp
+type seriesDoc struct {
+	Item   primitive.ObjectID `bson:"item"`
+	Metric string             `bson:"metric"`
+	T      time.Time          `bson:"t"`
+	V      float64            `bson:"v"`
+}
+
+// seriesCollection returns the series collection, created with its index on first use
+func (mg *MongoGraph) seriesCollection() (*mongo.Collection, error) {
+	db := mg.client.Database(mg.database)
+	col := db.Collection(handler.SeriesCollection)
+	if mg.collectionExists(handler.SeriesCollection) {
+		return col, nil
+	}
+	// one point per item, metric and timestamp, the index also serves the range queries
+	_, err := col.Indexes().CreateOne(mg.context(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "item", Value: 1}, {Key: "metric", Value: 1}, {Key: "t", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	mg.collSet[handler.SeriesCollection] = void{}
+	return col, nil
+}
+
+// seriesItem checks the id, the metric and if the item exists
+func (mg *MongoGraph) seriesItem(id interface{}, metric string) (primitive.ObjectID, error) {
+	if err := timeseries.ValidMetric(metric); err != nil {
+		return primitive.NilObjectID, err
+	}
+	oid, err := toObjectID(id)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	if ok, err := mg.exists(oid); err != nil {
+		return primitive.NilObjectID, err
+	} else if !ok {
+		return primitive.NilObjectID, fmt.Errorf("item %s not found", oid.Hex())
+	}
+	return oid, nil
+}
+
+// seriesFilter returns the filter of the points in [from, to]
+func seriesFilter(item primitive.ObjectID, metric string, from, to time.Time) bson.M {
+	filter := bson.M{"item": item, "metric": metric}
+	rng := bson.M{}
+	if !from.IsZero() {
+		rng["$gte"] = from
+	}
+	if !to.IsZero() {
+		rng["$lte"] = to
+	}
+	if len(rng) > 0 {
+		filter["t"] = rng
+	}
+	return filter
+}
+
+// AppendPoints(id interface{}, metric string, points ...timeseries.Point) error
+func (mg *MongoGraph) AppendPoints(id interface{}, metric string, points ...timeseries.Point) error {
+	oid, err := mg.seriesItem(id, metric)
+	if err != nil {
+		return err
+	}
+	if len(points) == 0 {
+		return nil
+	}
+	col, err := mg.seriesCollection()
+	if err != nil {
+		return err
+	}
+
+	// upsert every point in one round trip, the same timestamp replaces the value
+	models := make([]mongo.WriteModel, len(points))
+	for i, p := range points {
+		doc := seriesDoc{Item: oid, Metric: metric, T: p.Time.UTC(), V: p.Value}
+		models[i] = mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"item": oid, "metric": metric, "t": doc.T}).
+			SetReplacement(doc).
+			SetUpsert(true)
+	}
+	_, err = col.BulkWrite(mg.context(), models)
+	return err
+}
+
+// GetSeries(id interface{}, metric string, from, to time.Time) ([]timeseries.Point, error)
+func (mg *MongoGraph) GetSeries(id interface{}, metric string, from, to time.Time) ([]timeseries.Point, error) {
+	oid, err := mg.seriesItem(id, metric)
+	if err != nil {
+		return nil, err
+	}
+	col, err := mg.seriesCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := col.Find(mg.context(), seriesFilter(oid, metric, from, to),
+		options.Find().SetSort(bson.D{{Key: "t", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(mg.context())
+
+	points := []timeseries.Point{}
+	for cursor.Next(mg.context()) {
+		var doc seriesDoc
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		points = append(points, timeseries.Point{Time: doc.T.UTC(), Value: doc.V})
+	}
+	return points, cursor.Err()
+}
+
+// GetValueAsOf(id interface{}, metric string, t time.Time) (timeseries.Point, error)
+func (mg *MongoGraph) GetValueAsOf(id interface{}, metric string, t time.Time) (timeseries.Point, error) {
+	oid, err := mg.seriesItem(id, metric)
+	if err != nil {
+		return timeseries.Point{}, err
+	}
+	col, err := mg.seriesCollection()
+	if err != nil {
+		return timeseries.Point{}, err
+	}
+
+	var doc seriesDoc
+	err = col.FindOne(mg.context(), seriesFilter(oid, metric, time.Time{}, t),
+		options.FindOne().SetSort(bson.D{{Key: "t", Value: -1}})).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return timeseries.Point{}, timeseries.ErrNoPoints
+	}
+	if err != nil {
+		return timeseries.Point{}, err
+	}
+	return timeseries.Point{Time: doc.T.UTC(), Value: doc.V}, nil
+}
+
+// AggregateSeries(id interface{}, metric string, from, to time.Time, agg timeseries.Aggregation) (float64, error)
+// the aggregate is computed by the aggregation pipeline, only the result comes back
+func (mg *MongoGraph) AggregateSeries(id interface{}, metric string, from, to time.Time, agg timeseries.Aggregation) (float64, error) {
+	if !agg.Valid() {
+		return 0, fmt.Errorf("unknown aggregation %q", agg)
+	}
+	oid, err := mg.seriesItem(id, metric)
+	if err != nil {
+		return 0, err
+	}
+	col, err := mg.seriesCollection()
+	if err != nil {
+		return 0, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: seriesFilter(oid, metric, from, to)}},
+		{{Key: "$sort", Value: bson.D{{Key: "t", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   nil,
+			"count": bson.M{"$sum": 1},
+			"sum":   bson.M{"$sum": "$v"},
+			"avg":   bson.M{"$avg": "$v"},
+			"min":   bson.M{"$min": "$v"},
+			"max":   bson.M{"$max": "$v"},
+			"first": bson.M{"$first": "$v"},
+			"last":  bson.M{"$last": "$v"},
+		}}},
+	}
+	cursor, err := col.Aggregate(mg.context(), pipeline)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(mg.context())
+
+	// $ no document means no point in the range
+	if !cursor.Next(mg.context()) {
+		if err := cursor.Err(); err != nil {
+			return 0, err
+		}
+		if agg == timeseries.Count {
+			return 0, nil
+		}
+		return 0, timeseries.ErrNoPoints
+	}
+	var res bson.M
+	if err := cursor.Decode(&res); err != nil {
+		return 0, err
+	}
+	switch v := res[string(agg)].(type) {
+	case float64:
+		return v, nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	default:
+		return 0, fmt.Errorf("invalid aggregate result %v", v)
+	}
+}
+
+// DeleteSeries(id interface{}, metric string) error
+func (mg *MongoGraph) DeleteSeries(id interface{}, metric string) error {
+	oid, err := mg.seriesItem(id, metric)
+	if err != nil {
+		return err
+	}
+	return mg.removeSeries(oid, metric)
+}
+
+// removeSeries removes the points of the item, all the metrics if metric is blank
+func (mg *MongoGraph) removeSeries(oid primitive.ObjectID, metric string) error {
+	if !mg.collectionExists(handler.SeriesCollection) {
+		return nil
+	}
+	filter := bson.M{"item": oid}
+	if metric != "" {
+		filter["metric"] = metric
+	}
+	_, err := mg.client.Database(mg.database).Collection(handler.SeriesCollection).DeleteMany(mg.context(), filter)
+	return err
+}
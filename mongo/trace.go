@@ -0,0 +1,57 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/trace"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// the MongoGraph calls the database in the context of its caller
+var _ handler.ContextGraph = (*MongoGraph)(nil)
+
+// WithContext(ctx context.Context) handler.GraphDB
+// the view is a copy sharing the maps, like the one of a transaction
+func (mg *MongoGraph) WithContext(ctx context.Context) handler.GraphDB {
+	view := *mg
+	view.base = ctx
+	return &view
+}
+
+// commandMonitor starts a span for each command, the child of the span of its context
+func commandMonitor() *event.CommandMonitor {
+	// spans holds the running commands by request ID
+	var spans sync.Map
+	end := func(requestID int64, err error) {
+		if s, ok := spans.LoadAndDelete(requestID); ok {
+			span := s.(*trace.Span)
+			span.Fail(err)
+			span.End()
+		}
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			_, span := trace.Start(ctx, "mongo."+e.CommandName)
+			if span == nil {
+				return
+			}
+			span.Set("database", e.DatabaseName)
+			// ~ the first field of most commands names the collection, like {find: "company"}
+			if elems, err := e.Command.Elements(); err == nil && len(elems) > 0 {
+				if col, ok := elems[0].Value().StringValueOK(); ok {
+					span.Set("collection", col)
+				}
+			}
+			spans.Store(e.RequestID, span)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			end(e.RequestID, nil)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			end(e.RequestID, errors.New(e.Failure))
+		},
+	}
+}
@@ -0,0 +1,69 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wonderstone/chainstorm/handler"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// the MongoGraph groups writes in transactions
+var _ handler.TxGraph = (*MongoGraph)(nil)
+
+// # a transaction runs the graph operations in a session: a copy of the graph carries its context,
+// # the catalog and the routes are written in the transaction too, so a rollback reloads the catalog and empties the maps
+
+// context returns the context of the operations, the session one inside a transaction
+func (mg *MongoGraph) context() context.Context {
+	if mg.ctx != nil {
+		return mg.ctx
+	}
+	if mg.base != nil {
+		return mg.base
+	}
+	return context.Background()
+}
+
+// Update(fn func(tx handler.Tx) error) error
+// the transactions need a replica set, fn must not call mg itself
+func (mg *MongoGraph) Update(fn func(tx handler.Tx) error) (err error) {
+	if mg.ctx != nil {
+		return fmt.Errorf("transactions do not nest")
+	}
+	return mg.client.UseSession(mg.context(), func(sc mongo.SessionContext) error {
+		if err := sc.StartTransaction(); err != nil {
+			return err
+		}
+		tx := *mg
+		tx.ctx = sc
+		// the maps may hold the writes of the transaction, the catalog has them as committed
+		abort := func() error {
+			if err := sc.AbortTransaction(mg.context()); err != nil {
+				return err
+			}
+			return mg.loadCatalog()
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				_ = abort()
+				panic(r)
+			}
+		}()
+		if err := fn(&tx); err != nil {
+			mg.log().Warn().Err(err).Msg("Transaction rolled back")
+			if abortErr := abort(); abortErr != nil {
+				return fmt.Errorf("%v, rollback failed: %v", err, abortErr)
+			}
+			return err
+		}
+		if err := sc.CommitTransaction(mg.context()); err != nil {
+			mg.log().Error().Err(err).Msg("Failed to commit the transaction")
+			if abortErr := abort(); abortErr != nil {
+				return fmt.Errorf("%v, rollback failed: %v", err, abortErr)
+			}
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,193 @@
+// Package provenance keeps where every fact of the graph comes from.
+//
+// A write carries its provenance Record in the reserved Key of its Data:
+//
+//	data["_provenance"] = map[string]interface{}{
+//		"source": "cninfo/600001-2023-annual.pdf", "extractor": "pdf-llm",
+//		"version": "1.2.0", "confidence": 0.8,
+//	}
+//
+// The backends strip the Key before storing the Data and keep one Fact per
+// written Data key, plus one for the item itself (Field "") when the write
+// creates or replaces it. A fact is unique by item, field and source, so two
+// sources asserting the same property are both kept, and a merge adds the
+// facts of the incoming source.
+package provenance
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wonderstone/chainstorm/history"
+)
+
+// Key is the Data key carrying the provenance of a write
+const Key = "_provenance"
+
+// ItemField is the Field of the fact asserting the item itself
+const ItemField = ""
+
+// Now is the clock of the records without a timestamp, replaced in tests
+var Now = func() time.Time { return time.Now().UTC() }
+
+// Record is the provenance of one write
+type Record struct {
+	// Source is the document or feed the facts come from, e.g. a PDF path or a crawler URL
+	Source string `json:"source" bson:"source" yaml:"source"`
+	// Extractor is the name of the crawler or processor and Version its version
+	Extractor string `json:"extractor,omitempty" bson:"extractor,omitempty" yaml:"extractor"`
+	Version   string `json:"version,omitempty" bson:"version,omitempty" yaml:"version"`
+	// Confidence in [0, 1]
+	Confidence float64   `json:"confidence" bson:"confidence" yaml:"confidence"`
+	Timestamp  time.Time `json:"timestamp" bson:"timestamp" yaml:"timestamp"`
+}
+
+// Validate checks the source and the confidence
+func (r Record) Validate() error {
+	if r.Source == "" {
+		return fmt.Errorf("provenance source is required")
+	}
+	if r.Confidence < 0 || r.Confidence > 1 {
+		return fmt.Errorf("provenance confidence %v is not in [0, 1]", r.Confidence)
+	}
+	return nil
+}
+
+// Fact is one property (or the item itself) asserted by one source
+type Fact struct {
+	Item       string       `json:"item" bson:"item"`
+	Kind       history.Kind `json:"kind" bson:"kind"`
+	Collection string       `json:"collection" bson:"collection"`
+	// Field is the Data key, ItemField for the item itself
+	Field  string `json:"field" bson:"field"`
+	Record `bson:",inline"`
+}
+
+// Extract splits the provenance record out of the data of a write
+// the data is copied, the record is nil when the write has no provenance
+// the record can be a Record, a *Record or a map as decoded from JSON or YAML
+func Extract(data map[string]interface{}) (map[string]interface{}, *Record, error) {
+	raw, ok := data[Key]
+	if !ok {
+		return data, nil, nil
+	}
+	clean := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if k != Key {
+			clean[k] = v
+		}
+	}
+
+	var rec Record
+	switch v := raw.(type) {
+	case Record:
+		rec = v
+	case *Record:
+		if v == nil {
+			return clean, nil, nil
+		}
+		rec = *v
+	default:
+		// # round trip through json so every map flavour decodes the same way
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid provenance: %v", err)
+		}
+		if err := json.Unmarshal(b, &rec); err != nil {
+			return nil, nil, fmt.Errorf("invalid provenance: %v", err)
+		}
+	}
+	if err := rec.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if rec.Timestamp.IsZero() {
+		rec.Timestamp = Now()
+	}
+	rec.Timestamp = rec.Timestamp.UTC()
+	return clean, &rec, nil
+}
+
+// Facts returns the facts a write asserts, one for every key of data
+// a create or a replace also asserts the item itself, an update or a merge only its fields
+func Facts(op history.Op, rec Record, item string, kind history.Kind, collection string, data map[string]interface{}) []Fact {
+	var facts []Fact
+	if op == history.Create || op == history.Replace {
+		facts = append(facts, Fact{Item: item, Kind: kind, Collection: collection, Field: ItemField, Record: rec})
+	}
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		facts = append(facts, Fact{Item: item, Kind: kind, Collection: collection, Field: k, Record: rec})
+	}
+	return facts
+}
+
+// Upsert adds the incoming facts, a fact of the same field and source is replaced
+func Upsert(existing, incoming []Fact) []Fact {
+	res := append([]Fact(nil), existing...)
+	for _, f := range incoming {
+		replaced := false
+		for i := range res {
+			if res[i].Item == f.Item && res[i].Field == f.Field && res[i].Source == f.Source {
+				res[i] = f
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// Prune drops the facts of the fields missing in data, after a replace
+func Prune(facts []Fact, data map[string]interface{}) []Fact {
+	var res []Fact
+	for _, f := range facts {
+		if _, ok := data[f.Field]; ok || f.Field == ItemField {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// BySource returns the facts of the source
+func BySource(facts []Fact, source string) []Fact {
+	var res []Fact
+	for _, f := range facts {
+		if f.Source == source {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// Sort orders the facts by item, field and source
+func Sort(facts []Fact) {
+	sort.SliceStable(facts, func(i, j int) bool {
+		if facts[i].Item != facts[j].Item {
+			return facts[i].Item < facts[j].Item
+		}
+		if facts[i].Field != facts[j].Field {
+			return facts[i].Field < facts[j].Field
+		}
+		return facts[i].Source < facts[j].Source
+	})
+}
+
+// Move returns the facts relabelled to another item, when a duplicate is merged into it
+func Move(facts []Fact, item, collection string) []Fact {
+	res := make([]Fact, len(facts))
+	for i, f := range facts {
+		f.Item = item
+		f.Collection = collection
+		res[i] = f
+	}
+	return res
+}
@@ -0,0 +1,92 @@
+package provenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/history"
+)
+
+func TestExtract(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	data := map[string]interface{}{
+		"revenue": 100.0,
+		Key: map[string]interface{}{
+			"source": "a.pdf", "extractor": "pdf-llm", "version": "1.0", "confidence": 0.8,
+			"timestamp": ts.Format(time.RFC3339),
+		},
+	}
+	clean, rec, err := Extract(data)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"revenue": 100.0}, clean)
+	// the input is not touched
+	assert.Contains(t, data, Key)
+	assert.Equal(t, Record{Source: "a.pdf", Extractor: "pdf-llm", Version: "1.0", Confidence: 0.8, Timestamp: ts}, *rec)
+
+	// no provenance
+	clean, rec, err = Extract(map[string]interface{}{"revenue": 1.0})
+	assert.NoError(t, err)
+	assert.Nil(t, rec)
+	assert.Len(t, clean, 1)
+
+	// the source is required and the confidence is bounded
+	_, _, err = Extract(map[string]interface{}{Key: Record{Confidence: 0.5}})
+	assert.Error(t, err)
+	_, _, err = Extract(map[string]interface{}{Key: &Record{Source: "a", Confidence: 2}})
+	assert.Error(t, err)
+}
+
+func TestUpsertAndPrune(t *testing.T) {
+	a := Record{Source: "a", Confidence: 0.5}
+	b := Record{Source: "b", Confidence: 0.9}
+	facts := Facts(history.Create, a, "n1", history.NodeKind, "company", map[string]interface{}{"revenue": 1.0, "tags": "x"})
+	assert.Len(t, facts, 3)
+
+	// another source keeps both, a merge only asserts the fields
+	facts = Upsert(facts, Facts(history.Merge, b, "n1", history.NodeKind, "company", map[string]interface{}{"revenue": 2.0}))
+	assert.Len(t, facts, 4)
+	// the same source replaces its fact
+	a.Confidence = 0.7
+	facts = Upsert(facts, Facts(history.Update, a, "n1", history.NodeKind, "company", map[string]interface{}{"revenue": 3.0}))
+	assert.Len(t, facts, 4)
+	assert.Len(t, BySource(facts, "a"), 3)
+	for _, f := range BySource(facts, "a") {
+		if f.Field == "revenue" {
+			assert.Equal(t, 0.7, f.Confidence)
+		}
+	}
+
+	// a replace without tags drops the tags fact
+	facts = Prune(facts, map[string]interface{}{"revenue": 3.0})
+	assert.Len(t, facts, 3)
+	Sort(facts)
+	assert.Equal(t, ItemField, facts[0].Field)
+}
+
+func TestPlan(t *testing.T) {
+	a := Record{Source: "a"}
+	b := Record{Source: "b"}
+	var facts []Fact
+	// n1 created by a, b merged revenue into it
+	facts = append(facts, Facts(history.Create, a, "n1", history.NodeKind, "company", map[string]interface{}{"revenue": 1.0, "tags": "x"})...)
+	facts = append(facts, Facts(history.Merge, b, "n1", history.NodeKind, "company", map[string]interface{}{"revenue": 2.0})...)
+	// n2 and e1 only asserted by a
+	facts = append(facts, Facts(history.Create, a, "n2", history.NodeKind, "company", nil)...)
+	facts = append(facts, Facts(history.Create, a, "e1", history.EdgeKind, "invest", map[string]interface{}{"amount": 1.0})...)
+	// a merged a field into n3 created without provenance
+	facts = append(facts, Facts(history.Merge, a, "n3", history.NodeKind, "company", map[string]interface{}{"tags": "y"})...)
+
+	report := Plan("a", facts)
+	assert.Equal(t, 7, report.Facts)
+	assert.Equal(t, []Item{{ID: "e1", Kind: history.EdgeKind, Collection: "invest"}, {ID: "n2", Kind: history.NodeKind, Collection: "company"}}, report.Deleted)
+	assert.Equal(t, map[string][]string{"n1": {"tags"}, "n3": {"tags"}}, report.Removed)
+	assert.Equal(t, map[string][]string{"n1": {"revenue"}}, report.Kept)
+
+	// the edges go before the nodes
+	report.Cascaded = append(report.Cascaded, Item{ID: "e2", Kind: history.EdgeKind})
+	order := report.DeleteOrder()
+	assert.Equal(t, "n2", order[len(order)-1].ID)
+	assert.True(t, report.Delete("e2"))
+	assert.False(t, report.Delete("n1"))
+}
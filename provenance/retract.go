@@ -0,0 +1,123 @@
+package provenance
+
+import (
+	"sort"
+
+	"github.com/wonderstone/chainstorm/history"
+)
+
+// Item is a node or an edge touched by a retraction
+type Item struct {
+	ID         string       `json:"id"`
+	Kind       history.Kind `json:"kind"`
+	Collection string       `json:"collection"`
+}
+
+// Report tells what retracting a source removes, or removed when DryRun is false
+type Report struct {
+	Source string `json:"source"`
+	DryRun bool   `json:"dryRun"`
+	// Facts is the number of facts of the source
+	Facts int `json:"facts"`
+	// Deleted are the items only the source asserted
+	Deleted []Item `json:"deleted"`
+	// Cascaded are the edges of the deleted nodes, they cannot outlive them
+	Cascaded []Item `json:"cascaded"`
+	// Removed are the Data keys only the source contributed to the items kept, by item ID
+	Removed map[string][]string `json:"removed"`
+	// Kept are the Data keys other sources also assert, the value is kept, by item ID
+	Kept map[string][]string `json:"kept"`
+}
+
+// Plan decides what retracting the source removes
+// facts are all the facts, of every source, of the items the source touched
+// an item is deleted when the source asserted it and no other source has a fact on it,
+// otherwise the fields only the source asserted are removed
+// the backends add the Cascaded edges, they know the edges of the nodes
+func Plan(source string, facts []Fact) Report {
+	report := Report{
+		Source:   source,
+		Deleted:  []Item{},
+		Cascaded: []Item{},
+		Removed:  make(map[string][]string),
+		Kept:     make(map[string][]string),
+	}
+
+	byItem := make(map[string][]Fact)
+	var ids []string
+	for _, f := range facts {
+		if _, ok := byItem[f.Item]; !ok {
+			ids = append(ids, f.Item)
+		}
+		byItem[f.Item] = append(byItem[f.Item], f)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		itemFacts := byItem[id]
+		mine := BySource(itemFacts, source)
+		if len(mine) == 0 {
+			continue
+		}
+		report.Facts += len(mine)
+
+		asserted, others := false, false
+		for _, f := range itemFacts {
+			if f.Source != source {
+				others = true
+			} else if f.Field == ItemField {
+				asserted = true
+			}
+		}
+		if asserted && !others {
+			report.Deleted = append(report.Deleted, Item{ID: id, Kind: mine[0].Kind, Collection: mine[0].Collection})
+			continue
+		}
+
+		for _, f := range mine {
+			if f.Field == ItemField {
+				continue
+			}
+			shared := false
+			for _, o := range itemFacts {
+				if o.Source != source && o.Field == f.Field {
+					shared = true
+					break
+				}
+			}
+			if shared {
+				report.Kept[id] = append(report.Kept[id], f.Field)
+			} else {
+				report.Removed[id] = append(report.Removed[id], f.Field)
+			}
+		}
+	}
+	return report
+}
+
+// Delete reports if the item is in Deleted or Cascaded
+func (r Report) Delete(id string) bool {
+	for _, items := range [][]Item{r.Deleted, r.Cascaded} {
+		for _, it := range items {
+			if it.ID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DeleteOrder returns the items to delete, the edges before the nodes
+func (r Report) DeleteOrder() []Item {
+	var edges, nodes []Item
+	for _, items := range [][]Item{r.Cascaded, r.Deleted} {
+		for _, it := range items {
+			if it.Kind == history.EdgeKind {
+				edges = append(edges, it)
+			} else {
+				nodes = append(nodes, it)
+			}
+		}
+	}
+	return append(edges, nodes...)
+}
@@ -0,0 +1,447 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/wonderstone/chainstorm/filter"
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+// Result holds the rows of a query, a node per returned variable
+type Result struct {
+	Columns []string
+	Rows    [][]handler.Node
+}
+
+// Native is implemented by the backends running a whole plan in their own query language
+type Native interface {
+	RunPlan(p *Plan) (*Result, error)
+}
+
+// Run parses, plans and executes the query
+func Run(g handler.GraphDB, src string) (*Result, error) {
+	q, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	p, err := NewPlan(q)
+	if err != nil {
+		return nil, err
+	}
+	return Execute(g, p)
+}
+
+// Execute runs the plan natively if the backend can, with Generic otherwise
+// the backend is looked for under the decorators, such as the cache and the metrics
+func Execute(g handler.GraphDB, p *Plan) (*Result, error) {
+	if n, ok := native(g); ok {
+		return n.RunPlan(p)
+	}
+	return Generic(g, p)
+}
+
+// native finds the Native backend under the decorators
+func native(g handler.GraphDB) (Native, bool) {
+	for {
+		if n, ok := g.(Native); ok {
+			return n, true
+		}
+		u, ok := g.(interface{ Unwrap() handler.GraphDB })
+		if !ok {
+			return nil, false
+		}
+		g = u.Unwrap()
+	}
+}
+
+// Reach returns in one query the nodes the relationship pattern reaches from the node, up to hop.Max away
+// a backend RunPlan gives it to GenericReach, the start node is left out of the result
+type Reach func(from handler.Node, hop RelPattern) ([]handler.Node, error)
+
+// item is what the executor reads of a node or an edge
+type item struct {
+	node       handler.Node
+	id         interface{}
+	name       string
+	collection string
+	data       map[string]interface{}
+	// the edges only
+	relationship string
+	from, to     interface{}
+}
+
+// fields are the item as the conditions see it
+func (it item) fields() map[string]interface{} {
+	return map[string]interface{}{"Name": it.name, "Collection": it.collection, "Data": it.data}
+}
+
+// toMap accepts the map types of the drivers, such as primitive.M
+func toMap(v interface{}) map[string]interface{} {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+	res := make(map[string]interface{}, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		res[iter.Key().String()] = iter.Value().Interface()
+	}
+	return res
+}
+
+// exported returns the Export of a node or an edge, given by value or pointer, or the map of a document
+func exported(v interface{}) map[string]interface{} {
+	type exporter interface {
+		Export() map[string]interface{}
+	}
+	if e, ok := v.(exporter); ok {
+		return e.Export()
+	}
+	// the Export methods have pointer receivers
+	rv := reflect.ValueOf(v)
+	if rv.IsValid() && rv.Kind() == reflect.Struct {
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		if e, ok := ptr.Interface().(exporter); ok {
+			return e.Export()
+		}
+	}
+	return toMap(v)
+}
+
+// the keys of the local Export, the Data are flattened with them
+var localKeys = []string{"ID", "Collection", "Name", "Relationship", "From", "To"}
+
+// view reads a node or an edge of any backend
+// ~ the local Export flattens the Data with capitalized keys, arango and mongo nest it in data
+func view(v interface{}) item {
+	exp := exported(v)
+	it := item{}
+	if n, ok := v.(handler.Node); ok {
+		it.node = n
+	}
+	if _, ok := exp["Collection"]; ok {
+		it.id = exp["ID"]
+		it.name, _ = exp["Name"].(string)
+		it.collection, _ = exp["Collection"].(string)
+		it.relationship, _ = exp["Relationship"].(string)
+		it.from, it.to = exp["From"], exp["To"]
+		it.data = make(map[string]interface{}, len(exp))
+		for k, v := range exp {
+			it.data[k] = v
+		}
+		for _, k := range localKeys {
+			delete(it.data, k)
+		}
+		return it
+	}
+	it.id = exp["_id"]
+	it.name, _ = exp["name"].(string)
+	it.collection, _ = exp["collection"].(string)
+	it.relationship, _ = exp["relationship"].(string)
+	it.data = toMap(exp["data"])
+	it.from, it.to = exp["_from"], exp["_to"]
+	if it.from == nil {
+		it.from, it.to = exp["from"], exp["to"]
+	}
+	return it
+}
+
+// eval checks the condition on a row, variable -> item
+func (c Cond) eval(row map[string]item) bool {
+	switch c.Op {
+	case filter.OpAnd:
+		for _, sub := range c.Conds {
+			if !sub.eval(row) {
+				return false
+			}
+		}
+		return true
+	case filter.OpOr:
+		for _, sub := range c.Conds {
+			if sub.eval(row) {
+				return true
+			}
+		}
+		return false
+	case filter.OpNot:
+		return !c.Conds[0].eval(row)
+	}
+	return filter.Expr{Op: c.Op, Path: c.Path, Value: c.Value}.Match(row[c.Var].fields())
+}
+
+// executor runs a plan through the handler.GraphDB methods
+type executor struct {
+	g handler.GraphDB
+	p *Plan
+	// nodes caches the nodes by ID, for the edges
+	nodes map[string]item
+	res   *Result
+	seen  map[string]bool
+	// push finds the nodes of a relationship pattern at once, nil to walk the edges
+	push Reach
+}
+
+// Generic runs the plan with the handler.GraphDB methods
+// the first node is found with FindNodes if the backend is a handler.FilterGraph,
+// the relationships are followed with GetOutEdges and GetInEdges
+func Generic(g handler.GraphDB, p *Plan) (*Result, error) {
+	return GenericReach(g, p, nil)
+}
+
+// GenericReach runs the plan like Generic, the relationship patterns starting at one hop are given to reach
+// % a node is reached by a path of Min to Max hops, from one hop the shortest path tells it, longer minimums walk the paths
+func GenericReach(g handler.GraphDB, p *Plan, reach Reach) (*Result, error) {
+	ex := &executor{g: g, p: p, nodes: map[string]item{}, res: &Result{Columns: p.Return}, seen: map[string]bool{}, push: reach}
+	start, err := ex.start()
+	if err != nil {
+		return nil, err
+	}
+	row := map[string]item{}
+	for _, it := range start {
+		if ex.full() {
+			break
+		}
+		if err := ex.extend(row, 0, it); err != nil {
+			return nil, err
+		}
+	}
+	if ex.res.Rows == nil {
+		ex.res.Rows = [][]handler.Node{}
+	}
+	return ex.res, nil
+}
+
+// full tells the limit is reached
+func (ex *executor) full() bool {
+	return ex.p.Limit > 0 && len(ex.res.Rows) >= ex.p.Limit
+}
+
+// accept checks the conditions of the step on the item
+func (ex *executor) accept(i int, it item) bool {
+	s := ex.p.Steps[i]
+	return s.Cond == nil || s.Cond.eval(map[string]item{s.Var: it})
+}
+
+// start returns the candidates of the first step
+func (ex *executor) start() ([]item, error) {
+	s := ex.p.Steps[0]
+	var nodes []handler.Node
+	var err error
+	switch fg, ok := ex.g.(handler.FilterGraph); {
+	case s.Name != "":
+		// % the regex also matches the aliases, accept checks the name
+		nodes, err = ex.g.GetNodesByRegex("^" + regexp.QuoteMeta(s.Name) + "$")
+	case ok:
+		nodes, err = fg.FindNodes(s.Collection, s.Push)
+	default:
+		nodes, err = ex.g.GetNodesByRegex(".*")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var res []item
+	for _, n := range nodes {
+		if it := ex.node(n); ex.accept(0, it) {
+			res = append(res, it)
+		}
+	}
+	return res, nil
+}
+
+// node views a node and caches it
+func (ex *executor) node(n handler.Node) item {
+	it := view(n)
+	if it.id != nil {
+		ex.nodes[fmt.Sprint(it.id)] = it
+	}
+	return it
+}
+
+// extend binds the item to the step i and matches the rest of the pattern
+func (ex *executor) extend(row map[string]item, i int, it item) error {
+	s := ex.p.Steps[i]
+	row[s.Var] = it
+	defer delete(row, s.Var)
+	if i == len(ex.p.Steps)-1 {
+		ex.emit(row)
+		return nil
+	}
+	next, err := ex.reach(it, ex.p.Hops[i])
+	if err != nil {
+		return err
+	}
+	for _, n := range next {
+		if ex.full() {
+			return nil
+		}
+		if !ex.accept(i+1, n) {
+			continue
+		}
+		if err := ex.extend(row, i+1, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emit adds the row if it passes the residual conditions and is a new one
+func (ex *executor) emit(row map[string]item) {
+	if ex.p.Residual != nil && !ex.p.Residual.eval(row) {
+		return
+	}
+	out := make([]handler.Node, len(ex.p.Return))
+	names := make([]string, len(ex.p.Return))
+	for i, v := range ex.p.Return {
+		out[i] = row[v].node
+		names[i] = row[v].name
+	}
+	key := strings.Join(names, "\x00")
+	if ex.seen[key] {
+		return
+	}
+	ex.seen[key] = true
+	ex.res.Rows = append(ex.res.Rows, out)
+}
+
+// reach returns the nodes Min to Max hops away, a path visits a node once
+func (ex *executor) reach(from item, hop RelPattern) ([]item, error) {
+	if ex.push != nil && hop.Min <= 1 {
+		return ex.pushed(from, hop)
+	}
+	var res []item
+	found := map[string]bool{}
+	onPath := map[string]bool{from.name: true}
+	var walk func(it item, depth int) error
+	walk = func(it item, depth int) error {
+		next, err := ex.neighbours(it, hop)
+		if err != nil {
+			return err
+		}
+		for _, n := range next {
+			if onPath[n.name] {
+				continue
+			}
+			if depth >= hop.Min && !found[n.name] {
+				found[n.name] = true
+				res = append(res, n)
+			}
+			if depth < hop.Max {
+				onPath[n.name] = true
+				if err := walk(n, depth+1); err != nil {
+					return err
+				}
+				delete(onPath, n.name)
+			}
+		}
+		return nil
+	}
+	return res, walk(from, 1)
+}
+
+// pushed returns the nodes the push finds, once each and without the start node
+func (ex *executor) pushed(from item, hop RelPattern) ([]item, error) {
+	nodes, err := ex.push(from.node, hop)
+	if err != nil {
+		return nil, err
+	}
+	var res []item
+	found := map[string]bool{from.name: true}
+	for _, n := range nodes {
+		it := ex.node(n)
+		if !found[it.name] {
+			found[it.name] = true
+			res = append(res, it)
+		}
+	}
+	return res, nil
+}
+
+// neighbours returns the nodes one hop away
+func (ex *executor) neighbours(it item, hop RelPattern) ([]item, error) {
+	var res []item
+	if hop.Direction == Out || hop.Direction == Both {
+		out, err := ex.side(it, hop.Types, true)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, out...)
+	}
+	if hop.Direction == In || hop.Direction == Both {
+		in, err := ex.side(it, hop.Types, false)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, in...)
+	}
+	return res, nil
+}
+
+// side returns the targets of the out edges, or the sources of the in edges
+// ~ only the edges are read, GetFromNodes and GetToNodes do not mean the same direction on every backend
+func (ex *executor) side(it item, types []string, out bool) ([]item, error) {
+	var edges []handler.Edge
+	var err error
+	if out {
+		edges, err = ex.g.GetOutEdges(it.name)
+	} else {
+		edges, err = ex.g.GetInEdges(it.name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	accepted := map[string]bool{}
+	for _, t := range types {
+		accepted[t] = true
+	}
+	var res []item
+	found := map[string]bool{}
+	for _, e := range edges {
+		ev := view(e)
+		if len(types) > 0 && !accepted[ev.relationship] {
+			continue
+		}
+		other := ev.to
+		if !out {
+			other = ev.from
+		}
+		v, err := ex.nodeOf(other)
+		if err != nil {
+			return nil, err
+		}
+		if !found[v.name] {
+			found[v.name] = true
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
+// nodeOf returns the node with the ID
+// % GetItemByID of mongo returns the document, the node is read again by name then
+func (ex *executor) nodeOf(id interface{}) (item, error) {
+	key := fmt.Sprint(id)
+	if it, ok := ex.nodes[key]; ok {
+		return it, nil
+	}
+	v, err := ex.g.GetItemByID(id)
+	if err != nil {
+		return item{}, err
+	}
+	it := view(v)
+	if it.node == nil {
+		n, err := ex.g.GetNode(it.name)
+		if err != nil {
+			return item{}, err
+		}
+		it = view(n)
+	}
+	ex.nodes[key] = it
+	return it, nil
+}
@@ -0,0 +1,177 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+// doc is a node or an edge as the mongo backend returns it
+type doc map[string]interface{}
+
+func (d doc) Export() map[string]interface{} { return d }
+
+// docGraph keeps the documents the way mongo does, the other methods are not used
+type docGraph struct {
+	handler.GraphDB
+	nodes []doc
+	edges []doc
+}
+
+func (g *docGraph) GetNodesByRegex(regex string) ([]handler.Node, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+	var res []handler.Node
+	for _, n := range g.nodes {
+		if re.MatchString(n["name"].(string)) {
+			res = append(res, n)
+		}
+	}
+	return res, nil
+}
+
+func (g *docGraph) GetNode(name interface{}) (handler.Node, error) {
+	for _, n := range g.nodes {
+		if n["name"] == name {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("node %v not found", name)
+}
+
+// GetItemByID returns the plain document, it is not a handler.Node
+func (g *docGraph) GetItemByID(id interface{}) (interface{}, error) {
+	for _, n := range g.nodes {
+		if n["_id"] == id {
+			return map[string]interface{}(n), nil
+		}
+	}
+	return nil, fmt.Errorf("item not found")
+}
+
+func (g *docGraph) edgesOf(name interface{}, key string) ([]handler.Edge, error) {
+	n, err := g.GetNode(name)
+	if err != nil {
+		return nil, err
+	}
+	var res []handler.Edge
+	for _, e := range g.edges {
+		if e[key] == n.(doc)["_id"] {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+func (g *docGraph) GetOutEdges(name interface{}) ([]handler.Edge, error) {
+	return g.edgesOf(name, "from")
+}
+
+func (g *docGraph) GetInEdges(name interface{}) ([]handler.Edge, error) {
+	return g.edgesOf(name, "to")
+}
+
+func TestGenericDocuments(t *testing.T) {
+	node := func(id, name string) doc {
+		return doc{"_id": id, "name": name, "collection": "company", "data": map[string]interface{}{}}
+	}
+	edge := func(id, rel, from, to string) doc {
+		return doc{"_id": id, "relationship": rel, "collection": rel, "from": from, "to": to, "data": map[string]interface{}{}}
+	}
+	g := &docGraph{
+		nodes: []doc{node("1", "a"), node("2", "b"), node("3", "c")},
+		// a invests in b, c holds a
+		edges: []doc{edge("e1", "invest", "1", "2"), edge("e2", "hold", "3", "1")},
+	}
+
+	names := func(res *Result) []string {
+		var out []string
+		for _, row := range res.Rows {
+			out = append(out, row[0].Export()["name"].(string))
+		}
+		return out
+	}
+	for src, want := range map[string][]string{
+		`MATCH (x {Name:"a"})-[]->(y) RETURN y`:        {"b"},
+		`MATCH (x {Name:"a"})-[:invest]->(y) RETURN y`: {"b"},
+		`MATCH (x {Name:"a"})-[:hold]->(y) RETURN y`:   nil,
+		`MATCH (x {Name:"a"})<-[]-(y) RETURN y`:        {"c"},
+		`MATCH (x {Name:"a"})<-[:hold]-(y) RETURN y`:   {"c"},
+		`MATCH (x {Name:"c"})-[*1..2]->(y) RETURN y`:   {"a", "b"},
+	} {
+		res, err := Run(g, src)
+		assert.NoError(t, err, src)
+		assert.Equal(t, want, names(res), src)
+	}
+}
+
+// nativeGraph runs the plans natively, counting them
+type nativeGraph struct {
+	docGraph
+	runs int
+}
+
+func (g *nativeGraph) RunPlan(p *Plan) (*Result, error) {
+	g.runs++
+	return &Result{Columns: p.Return, Rows: [][]handler.Node{}}, nil
+}
+
+// wrapper is a decorator, such as the cache
+type wrapper struct {
+	handler.GraphDB
+}
+
+func (w wrapper) Unwrap() handler.GraphDB {
+	return w.GraphDB
+}
+
+func TestExecuteUnwrap(t *testing.T) {
+	g := &nativeGraph{}
+	_, err := Run(wrapper{wrapper{g}}, `MATCH (a) RETURN a`)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, g.runs)
+}
+
+func TestGenericReach(t *testing.T) {
+	node := func(id, name string) doc {
+		return doc{"_id": id, "name": name, "collection": "company", "data": map[string]interface{}{}}
+	}
+	edge := func(id, from, to string) doc {
+		return doc{"_id": id, "relationship": "invest", "collection": "invest", "from": from, "to": to, "data": map[string]interface{}{}}
+	}
+	// a -> b -> c -> a
+	g := &docGraph{
+		nodes: []doc{node("1", "a"), node("2", "b"), node("3", "c")},
+		edges: []doc{edge("e1", "1", "2"), edge("e2", "2", "3"), edge("e3", "3", "1")},
+	}
+	// the pushed reach returns every node, the start included
+	var pushed []RelPattern
+	reach := func(from handler.Node, hop RelPattern) ([]handler.Node, error) {
+		pushed = append(pushed, hop)
+		return []handler.Node{g.nodes[0], g.nodes[1], g.nodes[2], g.nodes[1]}, nil
+	}
+	run := func(src string) []string {
+		q, err := Parse(src)
+		assert.NoError(t, err)
+		p, err := NewPlan(q)
+		assert.NoError(t, err)
+		res, err := GenericReach(g, p, reach)
+		assert.NoError(t, err)
+		var out []string
+		for _, row := range res.Rows {
+			out = append(out, row[0].Export()["name"].(string))
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"b", "c"}, run(`MATCH (x {Name:"a"})-[*1..3]->(y) RETURN y`))
+	assert.Len(t, pushed, 1)
+	// from two hops the paths are walked
+	assert.Equal(t, []string{"c"}, run(`MATCH (x {Name:"a"})-[*2..2]->(y) RETURN y`))
+	assert.Len(t, pushed, 1)
+}
@@ -0,0 +1,116 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tokenKind is the kind of a lexical token
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokPunct
+)
+
+// token is a lexical token, pos is its byte offset in the query
+type token struct {
+	kind tokenKind
+	text string
+	val  interface{}
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// puncts are the punctuation tokens, the longest first
+var puncts = []string{"->", "<-", "..", "==", "!=", "<>", "<=", ">=", "(", ")", "[", "]", "{", "}", ":", ",", ".", "-", "*", "|", "=", "<", ">"}
+
+// lex cuts the query in tokens
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		r := rune(src[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			// # a string literal, with the Go escapes
+			j := i + 1
+			for j < len(src) && rune(src[j]) != r {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			raw := src[i+1 : j]
+			if r == '\'' {
+				raw = strings.ReplaceAll(strings.ReplaceAll(raw, `\'`, `'`), `"`, `\"`)
+			}
+			s, err := strconv.Unquote(`"` + raw + `"`)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at %d: %v", i, err)
+			}
+			tokens = append(tokens, token{tokString, src[i : j+1], s, i})
+			i = j + 1
+		case unicode.IsDigit(r):
+			// # a number, the range dots of *1..3 are not a decimal point
+			j := i
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) ||
+				(src[j] == '.' && !strings.HasPrefix(src[j:], "..")) ||
+				src[j] == 'e' || src[j] == 'E' ||
+				((src[j] == '+' || src[j] == '-') && (src[j-1] == 'e' || src[j-1] == 'E'))) {
+				j++
+			}
+			f, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at %d", src[i:j], i)
+			}
+			tokens = append(tokens, token{tokNumber, src[i:j], f, i})
+			i = j
+		case r == '_' || unicode.IsLetter(r) || r >= 0x80:
+			// # an identifier, the Chinese names of the Data keys included
+			j := i
+			for j < len(src) {
+				c := []rune(src[j:])[0]
+				if c != '_' && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+					break
+				}
+				j += len(string(c))
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected character %q at %d", src[i:i+1], i)
+			}
+			tokens = append(tokens, token{tokIdent, src[i:j], nil, i})
+			i = j
+		default:
+			matched := false
+			for _, p := range puncts {
+				if strings.HasPrefix(src[i:], p) {
+					tokens = append(tokens, token{tokPunct, p, nil, i})
+					i += len(p)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at %d", src[i:i+1], i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
@@ -0,0 +1,562 @@
+// Package query is a small pattern-matching query language portable across the backends:
+//
+//	MATCH (a:company {Name:"600001"})-[:invest*1..3]->(b)
+//	WHERE b.Data.companyCountry = "USA" AND b.Data.companyRevenue > 1e6
+//	RETURN b LIMIT 10
+//
+// A node pattern (var:collection {key: value}) takes the Name or Data keys, a relationship
+// pattern -[:rel1|rel2*min..max]-> follows the edges of those relationships (any if omitted)
+// in the direction of the arrow, both ways without one. WHERE compares var.Name,
+// var.Collection or var.Data.path with literals (= != < <= > >= IN [..]), EXISTS(var.Data.path)
+// and combines them with AND, OR, NOT. RETURN lists the node variables, the rows are distinct.
+//
+// Parse builds the Query, NewPlan splits the conditions by variable and picks the end the
+// matching starts from, Execute runs the Plan on any handler.GraphDB: the backends
+// implementing Native run it in one native query, the handler.FilterGraph ones get the
+// conditions of the first node pushed down.
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wonderstone/chainstorm/filter"
+)
+
+// DefaultMaxHops bounds the relationships written * or *n.. without an upper bound
+const DefaultMaxHops = 5
+
+// Direction is the direction of a relationship pattern
+type Direction int
+
+const (
+	// Out follows the edges from the left node to the right one: ->
+	Out Direction = iota
+	// In follows the edges from the right node to the left one: <-
+	In
+	// Both follows the edges either way: -
+	Both
+)
+
+// NodePattern is a node of the pattern, Var is blank for an anonymous node
+type NodePattern struct {
+	Var        string
+	Collection string
+	// Props are the inline {key: value} conditions, the key Name or a Data path
+	Props map[string]interface{}
+}
+
+// RelPattern is a relationship between two node patterns
+type RelPattern struct {
+	// Types are the accepted relationships, any if empty
+	Types     []string
+	Direction Direction
+	Min, Max  int
+}
+
+// Cond is a WHERE condition, a comparison on Var.Path or a combination of Conds
+// Path is "Name", "Collection" or "Data.<path>" and Op is a filter.Op
+type Cond struct {
+	Op    filter.Op
+	Var   string
+	Path  string
+	Value interface{}
+	Conds []Cond
+}
+
+// Query is a parsed query, Nodes[i] and Nodes[i+1] are linked by Rels[i]
+type Query struct {
+	Nodes  []NodePattern
+	Rels   []RelPattern
+	Where  *Cond
+	Return []string
+	// Limit is the number of rows, 0 for all
+	Limit int
+}
+
+// parser reads the tokens of one query
+type parser struct {
+	tokens []token
+	i      int
+}
+
+func (p *parser) peek() token { return p.tokens[p.i] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// isKeyword tells whether the token is the keyword, the keywords are case-insensitive
+func (t token) isKeyword(kw string) bool {
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+func (t token) isPunct(s string) bool {
+	return t.kind == tokPunct && t.text == s
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("syntax error at %d: %s", p.peek().pos, fmt.Sprintf(format, args...))
+}
+
+// expect reads the punctuation
+func (p *parser) expect(s string) error {
+	if t := p.next(); !t.isPunct(s) {
+		p.i--
+		return p.errorf("expected %q, got %s", s, t)
+	}
+	return nil
+}
+
+func (p *parser) keyword(kw string) error {
+	if t := p.next(); !t.isKeyword(kw) {
+		p.i--
+		return p.errorf("expected %s, got %s", kw, t)
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		p.i--
+		return "", p.errorf("expected an identifier, got %s", t)
+	}
+	return t.text, nil
+}
+
+// integer reads a non-negative integer
+func (p *parser) integer() (int, error) {
+	t := p.next()
+	f, ok := t.val.(float64)
+	if t.kind != tokNumber || !ok || f < 0 || f != float64(int(f)) {
+		p.i--
+		return 0, p.errorf("expected an integer, got %s", t)
+	}
+	return int(f), nil
+}
+
+// literal reads a string, a number, true, false or null
+func (p *parser) literal() (interface{}, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokString || t.kind == tokNumber:
+		return t.val, nil
+	case t.isPunct("-") && p.peek().kind == tokNumber:
+		return -p.next().val.(float64), nil
+	case t.isKeyword("true"):
+		return true, nil
+	case t.isKeyword("false"):
+		return false, nil
+	case t.isKeyword("null"):
+		return nil, nil
+	}
+	p.i--
+	return nil, p.errorf("expected a literal, got %s", t)
+}
+
+// path reads the dotted keys of a Data path
+func (p *parser) path() (string, error) {
+	keys := []string{}
+	for {
+		k, err := p.ident()
+		if err != nil {
+			return "", err
+		}
+		keys = append(keys, k)
+		if !p.peek().isPunct(".") {
+			return strings.Join(keys, "."), nil
+		}
+		p.next()
+	}
+}
+
+// Parse parses a query
+func Parse(src string) (*Query, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	q := &Query{}
+	if err := p.keyword("MATCH"); err != nil {
+		return nil, err
+	}
+	if err := p.pattern(q); err != nil {
+		return nil, err
+	}
+	if p.peek().isKeyword("WHERE") {
+		p.next()
+		c, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		q.Where = &c
+	}
+	if err := p.keyword("RETURN"); err != nil {
+		return nil, err
+	}
+	for {
+		v, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		q.Return = append(q.Return, v)
+		if !p.peek().isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	if p.peek().isKeyword("LIMIT") {
+		p.next()
+		if q.Limit, err = p.integer(); err != nil {
+			return nil, err
+		}
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", t)
+	}
+	if err := q.check(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// pattern reads the nodes linked by relationships
+func (p *parser) pattern(q *Query) error {
+	n, err := p.node()
+	if err != nil {
+		return err
+	}
+	q.Nodes = append(q.Nodes, n)
+	for p.peek().isPunct("-") || p.peek().isPunct("<-") {
+		r, err := p.rel()
+		if err != nil {
+			return err
+		}
+		n, err := p.node()
+		if err != nil {
+			return err
+		}
+		q.Rels = append(q.Rels, r)
+		q.Nodes = append(q.Nodes, n)
+	}
+	return nil
+}
+
+// node reads (var:collection {key: value, ...})
+func (p *parser) node() (NodePattern, error) {
+	n := NodePattern{Props: map[string]interface{}{}}
+	if err := p.expect("("); err != nil {
+		return n, err
+	}
+	if p.peek().kind == tokIdent {
+		n.Var = p.next().text
+	}
+	if p.peek().isPunct(":") {
+		p.next()
+		c, err := p.ident()
+		if err != nil {
+			return n, err
+		}
+		n.Collection = c
+	}
+	if p.peek().isPunct("{") {
+		p.next()
+		for !p.peek().isPunct("}") {
+			k, err := p.path()
+			if err != nil {
+				return n, err
+			}
+			if err := p.expect(":"); err != nil {
+				return n, err
+			}
+			v, err := p.literal()
+			if err != nil {
+				return n, err
+			}
+			n.Props[k] = v
+			if !p.peek().isPunct(",") {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect("}"); err != nil {
+			return n, err
+		}
+	}
+	return n, p.expect(")")
+}
+
+// rel reads -[:a|b*min..max]-> or <-[...]- or -[...]-
+func (p *parser) rel() (RelPattern, error) {
+	r := RelPattern{Direction: Both, Min: 1, Max: 1}
+	if p.next().isPunct("<-") {
+		r.Direction = In
+	}
+	if err := p.expect("["); err != nil {
+		return r, err
+	}
+	if p.peek().kind == tokIdent {
+		return r, p.errorf("relationship variables are not supported")
+	}
+	if p.peek().isPunct(":") {
+		p.next()
+		for {
+			t, err := p.ident()
+			if err != nil {
+				return r, err
+			}
+			r.Types = append(r.Types, t)
+			if !p.peek().isPunct("|") {
+				break
+			}
+			p.next()
+		}
+	}
+	if p.peek().isPunct("*") {
+		p.next()
+		r.Max = DefaultMaxHops
+		if p.peek().kind == tokNumber {
+			n, err := p.integer()
+			if err != nil {
+				return r, err
+			}
+			r.Min, r.Max = n, n
+		}
+		if p.peek().isPunct("..") {
+			p.next()
+			r.Max = DefaultMaxHops
+			if p.peek().kind == tokNumber {
+				n, err := p.integer()
+				if err != nil {
+					return r, err
+				}
+				r.Max = n
+			}
+		}
+	}
+	if err := p.expect("]"); err != nil {
+		return r, err
+	}
+	switch t := p.next(); {
+	case t.isPunct("->"):
+		if r.Direction == In {
+			return r, p.errorf("a relationship can not point both ways")
+		}
+		r.Direction = Out
+	case t.isPunct("-"):
+	default:
+		p.i--
+		return r, p.errorf("expected \"-\" or \"->\", got %s", t)
+	}
+	if r.Min < 1 || r.Max < r.Min {
+		return r, p.errorf("invalid hops %d..%d", r.Min, r.Max)
+	}
+	return r, nil
+}
+
+// or reads the conditions joined by OR
+func (p *parser) or() (Cond, error) {
+	c, err := p.and()
+	if err != nil {
+		return c, err
+	}
+	conds := []Cond{c}
+	for p.peek().isKeyword("OR") {
+		p.next()
+		c, err := p.and()
+		if err != nil {
+			return c, err
+		}
+		conds = append(conds, c)
+	}
+	if len(conds) == 1 {
+		return conds[0], nil
+	}
+	return Cond{Op: filter.OpOr, Conds: conds}, nil
+}
+
+// and reads the conditions joined by AND
+func (p *parser) and() (Cond, error) {
+	c, err := p.not()
+	if err != nil {
+		return c, err
+	}
+	conds := []Cond{c}
+	for p.peek().isKeyword("AND") {
+		p.next()
+		c, err := p.not()
+		if err != nil {
+			return c, err
+		}
+		conds = append(conds, c)
+	}
+	if len(conds) == 1 {
+		return conds[0], nil
+	}
+	return Cond{Op: filter.OpAnd, Conds: conds}, nil
+}
+
+func (p *parser) not() (Cond, error) {
+	if p.peek().isKeyword("NOT") {
+		p.next()
+		c, err := p.not()
+		if err != nil {
+			return c, err
+		}
+		return Cond{Op: filter.OpNot, Conds: []Cond{c}}, nil
+	}
+	return p.primary()
+}
+
+// ops are the comparison operators
+var ops = map[string]filter.Op{
+	"=": filter.OpEq, "==": filter.OpEq, "!=": filter.OpNe, "<>": filter.OpNe,
+	"<": filter.OpLt, "<=": filter.OpLte, ">": filter.OpGt, ">=": filter.OpGte,
+}
+
+// primary reads a comparison, EXISTS(ref) or a parenthesized condition
+func (p *parser) primary() (Cond, error) {
+	if p.peek().isPunct("(") {
+		p.next()
+		c, err := p.or()
+		if err != nil {
+			return c, err
+		}
+		return c, p.expect(")")
+	}
+	if p.peek().isKeyword("EXISTS") {
+		p.next()
+		if err := p.expect("("); err != nil {
+			return Cond{}, err
+		}
+		v, path, err := p.ref()
+		if err != nil {
+			return Cond{}, err
+		}
+		return Cond{Op: filter.OpExists, Var: v, Path: path}, p.expect(")")
+	}
+
+	v, path, err := p.ref()
+	if err != nil {
+		return Cond{}, err
+	}
+	t := p.next()
+	if t.isKeyword("IN") {
+		if err := p.expect("["); err != nil {
+			return Cond{}, err
+		}
+		values := []interface{}{}
+		for !p.peek().isPunct("]") {
+			lit, err := p.literal()
+			if err != nil {
+				return Cond{}, err
+			}
+			values = append(values, lit)
+			if !p.peek().isPunct(",") {
+				break
+			}
+			p.next()
+		}
+		return Cond{Op: filter.OpIn, Var: v, Path: path, Value: values}, p.expect("]")
+	}
+	op, ok := ops[t.text]
+	if t.kind != tokPunct || !ok {
+		p.i--
+		return Cond{}, p.errorf("expected a comparison, got %s", t)
+	}
+	lit, err := p.literal()
+	if err != nil {
+		return Cond{}, err
+	}
+	return Cond{Op: op, Var: v, Path: path, Value: lit}, nil
+}
+
+// ref reads var.Name, var.Collection or var.Data.path
+func (p *parser) ref() (string, string, error) {
+	v, err := p.ident()
+	if err != nil {
+		return "", "", err
+	}
+	if err := p.expect("."); err != nil {
+		return "", "", err
+	}
+	field, err := p.ident()
+	if err != nil {
+		return "", "", err
+	}
+	switch field {
+	case "Name", "Collection":
+		return v, field, nil
+	case "Data":
+		if err := p.expect("."); err != nil {
+			return "", "", err
+		}
+		path, err := p.path()
+		if err != nil {
+			return "", "", err
+		}
+		return v, "Data." + path, nil
+	}
+	p.i--
+	return "", "", p.errorf("expected Name, Collection or Data, got %q", field)
+}
+
+// check verifies the variables: bound once, and the ones in WHERE and RETURN bound
+func (q *Query) check() error {
+	bound := map[string]bool{}
+	for _, n := range q.Nodes {
+		if n.Var == "" {
+			continue
+		}
+		if bound[n.Var] {
+			return fmt.Errorf("variable %s is bound twice", n.Var)
+		}
+		bound[n.Var] = true
+	}
+	if q.Where != nil {
+		for v := range q.Where.vars() {
+			if !bound[v] {
+				return fmt.Errorf("variable %s is not bound", v)
+			}
+		}
+		if err := q.Where.Filter().Validate(); err != nil {
+			return err
+		}
+	}
+	for _, v := range q.Return {
+		if !bound[v] {
+			return fmt.Errorf("variable %s is not bound", v)
+		}
+	}
+	return nil
+}
+
+// vars returns the variables of the condition
+func (c Cond) vars() map[string]bool {
+	res := map[string]bool{}
+	if c.Var != "" {
+		res[c.Var] = true
+	}
+	for _, sub := range c.Conds {
+		for v := range sub.vars() {
+			res[v] = true
+		}
+	}
+	return res
+}
+
+// Filter returns the condition as a filter on the fields of one item, see fields
+// the variables are dropped, the condition has to be on a single one
+func (c Cond) Filter() filter.Expr {
+	f := filter.Expr{Op: c.Op, Path: c.Path, Value: c.Value}
+	for _, sub := range c.Conds {
+		f.Exprs = append(f.Exprs, sub.Filter())
+	}
+	return f
+}
@@ -0,0 +1,214 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/wonderstone/chainstorm/filter"
+)
+
+// Step is a node of the plan with all its conditions
+type Step struct {
+	// Var is the variable of the node, "#i" for the anonymous node i of the pattern
+	Var        string
+	Collection string
+	// Name is the exact name of the node, blank if the conditions do not give it
+	Name string
+	// Cond holds the conditions on the node only, the collection included, nil if none
+	Cond *Cond
+	// Push is the part of Cond on the Data, for handler.FilterGraph.FindNodes
+	Push filter.Expr
+}
+
+// Plan is a query ready to run, Hops[i] links Steps[i] and Steps[i+1]
+// the matching starts from Steps[0]
+type Plan struct {
+	Steps []Step
+	Hops  []RelPattern
+	// Residual holds the conditions on several variables, checked on the complete rows
+	Residual *Cond
+	Return   []string
+	Limit    int
+	// Reversed tells the pattern is matched from its last node
+	Reversed bool
+}
+
+// conjuncts returns the conditions joined by a top-level AND
+func (c Cond) conjuncts() []Cond {
+	if c.Op != filter.OpAnd {
+		return []Cond{c}
+	}
+	var res []Cond
+	for _, sub := range c.Conds {
+		res = append(res, sub.conjuncts()...)
+	}
+	return res
+}
+
+// and joins the conditions, nil if none
+func and(conds []Cond) *Cond {
+	switch len(conds) {
+	case 0:
+		return nil
+	case 1:
+		return &conds[0]
+	}
+	return &Cond{Op: filter.OpAnd, Conds: conds}
+}
+
+// onData tells whether all the comparisons of the condition are on the Data
+func (c Cond) onData() bool {
+	if c.Var != "" {
+		return strings.HasPrefix(c.Path, "Data.")
+	}
+	for _, sub := range c.Conds {
+		if !sub.onData() {
+			return false
+		}
+	}
+	return true
+}
+
+// dataFilter returns the condition on the Data as a filter on the Data
+func (c Cond) dataFilter() filter.Expr {
+	f := filter.Expr{Op: c.Op, Path: strings.TrimPrefix(c.Path, "Data."), Value: c.Value}
+	for _, sub := range c.Conds {
+		f.Exprs = append(f.Exprs, sub.dataFilter())
+	}
+	return f
+}
+
+// score rates a step as the start of the matching, the fewer candidates the higher
+func (s Step) score() int {
+	switch {
+	case s.Name != "":
+		return 3
+	case s.Collection != "" && len(s.Push.Exprs) > 0:
+		return 2
+	case s.Collection != "":
+		return 1
+	}
+	return 0
+}
+
+// NewPlan splits the conditions by variable and picks the end of the pattern to start from
+func NewPlan(q *Query) (*Plan, error) {
+	p := &Plan{Return: q.Return, Limit: q.Limit}
+	conds := make([][]Cond, len(q.Nodes))
+	index := map[string]int{}
+	for i, n := range q.Nodes {
+		s := Step{Var: n.Var, Collection: n.Collection}
+		if s.Var == "" {
+			s.Var = fmt.Sprintf("#%d", i)
+		}
+		index[s.Var] = i
+		if n.Collection != "" {
+			conds[i] = append(conds[i], Cond{Op: filter.OpEq, Var: s.Var, Path: "Collection", Value: n.Collection})
+		}
+		keys := make([]string, 0, len(n.Props))
+		for k := range n.Props {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := n.Props[k]
+			path := "Data." + k
+			if k == "Name" {
+				path = "Name"
+			}
+			conds[i] = append(conds[i], Cond{Op: filter.OpEq, Var: s.Var, Path: path, Value: v})
+		}
+		p.Steps = append(p.Steps, s)
+	}
+	p.Hops = append(p.Hops, q.Rels...)
+
+	// # the conditions on one variable go to its step, the others are checked on the rows
+	var residual []Cond
+	if q.Where != nil {
+		for _, c := range q.Where.conjuncts() {
+			vars := c.vars()
+			if len(vars) != 1 {
+				residual = append(residual, c)
+				continue
+			}
+			for v := range vars {
+				conds[index[v]] = append(conds[index[v]], c)
+			}
+		}
+	}
+	p.Residual = and(residual)
+
+	for i := range p.Steps {
+		s := &p.Steps[i]
+		s.Cond = and(conds[i])
+		var push []filter.Expr
+		for _, c := range conds[i] {
+			if c.Path == "Name" && c.Op == filter.OpEq {
+				if name, ok := c.Value.(string); ok {
+					s.Name = name
+				}
+			}
+			if c.onData() {
+				push = append(push, c.dataFilter())
+			}
+		}
+		s.Push = filter.And(push...)
+		if err := s.Push.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.Steps[len(p.Steps)-1].score() > p.Steps[0].score() {
+		p.reverse()
+	}
+	return p, nil
+}
+
+// reverse turns the pattern around, the relationships keep their meaning
+func (p *Plan) reverse() {
+	n := len(p.Steps)
+	for i := 0; i < n/2; i++ {
+		p.Steps[i], p.Steps[n-1-i] = p.Steps[n-1-i], p.Steps[i]
+	}
+	m := len(p.Hops)
+	for i := 0; i < m/2; i++ {
+		p.Hops[i], p.Hops[m-1-i] = p.Hops[m-1-i], p.Hops[i]
+	}
+	for i := range p.Hops {
+		switch p.Hops[i].Direction {
+		case Out:
+			p.Hops[i].Direction = In
+		case In:
+			p.Hops[i].Direction = Out
+		}
+	}
+	p.Reversed = !p.Reversed
+}
+
+// String describes the plan, for the logs and the tests
+func (p *Plan) String() string {
+	var b strings.Builder
+	for i, s := range p.Steps {
+		if i > 0 {
+			h := p.Hops[i-1]
+			arrow := map[Direction][2]string{Out: {"-", "->"}, In: {"<-", "-"}, Both: {"-", "-"}}[h.Direction]
+			fmt.Fprintf(&b, "%s[%s*%d..%d]%s", arrow[0], strings.Join(h.Types, "|"), h.Min, h.Max, arrow[1])
+		}
+		fmt.Fprintf(&b, "(%s", s.Var)
+		if s.Collection != "" {
+			fmt.Fprintf(&b, ":%s", s.Collection)
+		}
+		if s.Name != "" {
+			fmt.Fprintf(&b, " name=%q", s.Name)
+		}
+		if len(s.Push.Exprs) > 0 {
+			fmt.Fprintf(&b, " push=%s", s.Push)
+		}
+		b.WriteString(")")
+	}
+	if p.Residual != nil {
+		fmt.Fprintf(&b, " residual=%d", len(p.Residual.conjuncts()))
+	}
+	return b.String()
+}
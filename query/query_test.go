@@ -0,0 +1,67 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/filter"
+)
+
+func TestParse(t *testing.T) {
+	q, err := Parse(`MATCH (a:company {Name:"600001"})-[:invest*1..3]->(b)<-[:hold|own]-(:fund)
+		WHERE b.Data.companyCountry = 'USA' AND (b.Data.revenue >= 1e6 OR NOT EXISTS(b.Data.revenue))
+		RETURN a, b LIMIT 5`)
+	assert.NoError(t, err)
+	assert.Equal(t, []NodePattern{
+		{Var: "a", Collection: "company", Props: map[string]interface{}{"Name": "600001"}},
+		{Var: "b", Props: map[string]interface{}{}},
+		{Collection: "fund", Props: map[string]interface{}{}},
+	}, q.Nodes)
+	assert.Equal(t, []RelPattern{
+		{Types: []string{"invest"}, Direction: Out, Min: 1, Max: 3},
+		{Types: []string{"hold", "own"}, Direction: In, Min: 1, Max: 1},
+	}, q.Rels)
+	assert.Equal(t, &Cond{Op: filter.OpAnd, Conds: []Cond{
+		{Op: filter.OpEq, Var: "b", Path: "Data.companyCountry", Value: "USA"},
+		{Op: filter.OpOr, Conds: []Cond{
+			{Op: filter.OpGte, Var: "b", Path: "Data.revenue", Value: 1e6},
+			{Op: filter.OpNot, Conds: []Cond{{Op: filter.OpExists, Var: "b", Path: "Data.revenue"}}},
+		}},
+	}}, q.Where)
+	assert.Equal(t, []string{"a", "b"}, q.Return)
+	assert.Equal(t, 5, q.Limit)
+
+	q, err = Parse(`match (a)-[*]-(b) where a.Name in ["x", "y"] and b.Data.n < -2 return b`)
+	assert.NoError(t, err)
+	assert.Equal(t, RelPattern{Direction: Both, Min: 1, Max: DefaultMaxHops}, q.Rels[0])
+	assert.Equal(t, []interface{}{"x", "y"}, q.Where.Conds[0].Value)
+	assert.Equal(t, -2.0, q.Where.Conds[1].Value)
+
+	for _, bad := range []string{
+		`MATCH (a) RETURN b`,
+		`MATCH (a)-[r:invest]->(b) RETURN a`,
+		`MATCH (a)<-[:x]->(b) RETURN a`,
+		`MATCH (a)-[*3..1]->(b) RETURN a`,
+		`MATCH (a)-->(b) RETURN a`,
+		`MATCH (a), (b) RETURN a`,
+		`MATCH (a)-[]->(a) RETURN a`,
+		`MATCH (a) WHERE a.Data.x > true RETURN a`,
+		`MATCH (a) WHERE a.Size = 1 RETURN a`,
+		`MATCH (a {Name: "x) RETURN a`,
+	} {
+		_, err := Parse(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	q, err := Parse(`MATCH (a)-[:invest*1..3]->(b:company {Name:"600001"})
+		WHERE a.Data.country = "USA" AND (a.Data.size > 10 OR b.Data.size > 10) RETURN a`)
+	assert.NoError(t, err)
+	p, err := NewPlan(q)
+	assert.NoError(t, err)
+	// the named node is the start, the relationship turns around
+	assert.True(t, p.Reversed)
+	assert.Equal(t, `(b:company name="600001")<-[invest*1..3]-(a push=(country == "USA")) residual=1`, p.String())
+	assert.Equal(t, filter.And(filter.Eq("country", "USA")), p.Steps[1].Push)
+}
@@ -0,0 +1,177 @@
+package resolve
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/wonderstone/chainstorm/history"
+)
+
+// AliasIndex maps the aliases of the nodes to their names
+// the aliases are stored in the handler.AliasesKey of the Data, the backends feed the index
+// with the current version of every write and rebuild it on Connect
+// the zero value is ready to use and safe for concurrent use
+type AliasIndex struct {
+	m sync.RWMutex
+	// items is item ID -> name and aliases of the node
+	items map[string]aliasEntry
+	// owners is alias -> item ID
+	owners map[string]string
+}
+
+type aliasEntry struct {
+	name    string
+	aliases []string
+}
+
+// Track follows a write: v is the current version of the item, nil once deleted
+// only the node versions are indexed, an alias already owned by another node stays with it
+// key is the Data key of the aliases
+func (idx *AliasIndex) Track(itemID string, v *history.Version, key string) {
+	idx.m.Lock()
+	defer idx.m.Unlock()
+
+	if v == nil || v.Kind != history.NodeKind {
+		idx.drop(itemID)
+		return
+	}
+	if idx.items == nil {
+		idx.items = make(map[string]aliasEntry)
+		idx.owners = make(map[string]string)
+	}
+	old := idx.items[itemID]
+	entry := aliasEntry{name: v.Name, aliases: Aliases(v.Data, key)}
+	idx.items[itemID] = entry
+	for _, a := range old.aliases {
+		if !contains(entry.aliases, a) {
+			idx.release(itemID, a)
+		}
+	}
+	for _, a := range entry.aliases {
+		if _, taken := idx.owners[a]; !taken {
+			idx.owners[a] = itemID
+		}
+	}
+}
+
+// drop removes the item, the caller holds the lock
+func (idx *AliasIndex) drop(itemID string) {
+	entry, ok := idx.items[itemID]
+	if !ok {
+		return
+	}
+	delete(idx.items, itemID)
+	for _, a := range entry.aliases {
+		idx.release(itemID, a)
+	}
+}
+
+// release frees an alias of the item, the caller holds the lock
+// it goes to another node holding it, as the survivor of a merge
+func (idx *AliasIndex) release(itemID, alias string) {
+	if idx.owners[alias] != itemID {
+		return
+	}
+	delete(idx.owners, alias)
+	for id, other := range idx.items {
+		if id != itemID && contains(other.aliases, alias) {
+			idx.owners[alias] = id
+			return
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset empties the index before it is rebuilt
+func (idx *AliasIndex) Reset() {
+	idx.m.Lock()
+	defer idx.m.Unlock()
+
+	idx.items = nil
+	idx.owners = nil
+}
+
+// Resolve returns the name of the node owning the alias
+func (idx *AliasIndex) Resolve(alias string) (string, bool) {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	id, ok := idx.owners[alias]
+	if !ok {
+		return "", false
+	}
+	return idx.items[id].name, true
+}
+
+// Aliases returns the aliases of the node
+func (idx *AliasIndex) Aliases(itemID string) []string {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	return append([]string{}, idx.items[itemID].aliases...)
+}
+
+// Check returns an error if an alias is empty or owned by another node
+// the node names are checked by the backends
+func (idx *AliasIndex) Check(itemID string, aliases ...string) error {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	for _, a := range aliases {
+		if a == "" {
+			return fmt.Errorf("alias is empty")
+		}
+		if owner, ok := idx.owners[a]; ok && owner != itemID {
+			return fmt.Errorf("alias %s belongs to node %s", a, idx.items[owner].name)
+		}
+	}
+	return nil
+}
+
+// Match returns the names of the nodes with an alias matching the regex, sorted
+func (idx *AliasIndex) Match(re *regexp.Regexp) []string {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	seen := make(map[string]bool)
+	var res []string
+	for a, id := range idx.owners {
+		name := idx.items[id].name
+		if !seen[name] && re.MatchString(a) {
+			seen[name] = true
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// RemoveAliases returns a copy of the data without the names in the list in key
+func RemoveAliases(data map[string]interface{}, key string, names ...string) map[string]interface{} {
+	res := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		res[k] = v
+	}
+	removed := make(map[string]bool, len(names))
+	for _, n := range names {
+		removed[n] = true
+	}
+	aliases := []interface{}{}
+	for _, a := range Aliases(data, key) {
+		if !removed[a] {
+			aliases = append(aliases, a)
+		}
+	}
+	res[key] = aliases
+	return res
+}
@@ -0,0 +1,116 @@
+// Package resolve finds nodes that stand for the same entity.
+//
+// The same company shows up as "600001", "SH600001", "600001.SH" and its full
+// legal name. Three rules flag a pair of nodes of one collection:
+//   - name: the normalised names are equal
+//   - alias: a normalised name or alias of one is a name or alias of the other
+//   - neighbours: they share enough neighbours through GetFromNodes and GetToNodes
+//
+// The candidates are only reported, the caller decides and merges them with
+// handler.ResolvingGraph.MergeNodesInto.
+package resolve
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// exchanges are the stock code prefixes and suffixes, SH600001 and 600001.SH are 600001
+var exchangeCode = regexp.MustCompile(`^(?:SH|SZ|SS|BJ|HK)?(\d{4,6})(?:SH|SZ|SS|BJ|HK)?$`)
+
+// legalSuffixes are removed from the end of a normalised name, the longest first
+var legalSuffixes = []string{
+	"股份有限公司", "有限责任公司", "集团有限公司", "有限公司", "股份公司", "集团公司", "公司",
+	"COMPANYLIMITED", "CORPORATION", "INCORPORATED", "LIMITED", "COLTD", "GROUP", "CORP", "LTD", "INC", "PLC", "CO",
+}
+
+// Normalize returns the comparison key of a name:
+// full-width characters become half-width, letters are upper case,
+// spaces and punctuation are dropped, then the exchange of a stock code
+// and the legal form of a company name are removed
+func Normalize(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		// % full-width ASCII and the ideographic space
+		switch {
+		case r >= 0xFF01 && r <= 0xFF5E:
+			r -= 0xFEE0
+		case r == 0x3000:
+			r = ' '
+		}
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	key := b.String()
+
+	if m := exchangeCode.FindStringSubmatch(key); m != nil {
+		return m[1]
+	}
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for _, s := range legalSuffixes {
+			if len(key) > len(s) && strings.HasSuffix(key, s) {
+				key = strings.TrimSuffix(key, s)
+				trimmed = true
+				break
+			}
+		}
+	}
+	return key
+}
+
+// Aliases returns the names held by the keys of the data, strings or lists of strings
+func Aliases(data map[string]interface{}, keys ...string) []string {
+	var res []string
+	for _, k := range keys {
+		switch v := data[k].(type) {
+		case string:
+			if v != "" {
+				res = append(res, v)
+			}
+		case []string:
+			res = append(res, v...)
+		default:
+			// % []interface{} and the list types of the drivers, such as primitive.A
+			rv := reflect.ValueOf(v)
+			if rv.Kind() != reflect.Slice {
+				continue
+			}
+			for i := 0; i < rv.Len(); i++ {
+				if s, ok := rv.Index(i).Interface().(string); ok && s != "" {
+					res = append(res, s)
+				}
+			}
+		}
+	}
+	return res
+}
+
+// AddAliases returns a copy of the data with the names added to the list in key, without duplicates
+func AddAliases(data map[string]interface{}, key string, names ...string) map[string]interface{} {
+	res := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		res[k] = v
+	}
+	list := Aliases(data, key)
+	seen := make(map[string]bool, len(list))
+	for _, a := range list {
+		seen[a] = true
+	}
+	for _, n := range names {
+		if n != "" && !seen[n] {
+			seen[n] = true
+			list = append(list, n)
+		}
+	}
+	aliases := make([]interface{}, len(list))
+	for i, a := range list {
+		aliases[i] = a
+	}
+	res[key] = aliases
+	return res
+}
@@ -0,0 +1,201 @@
+package resolve
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+// Options tunes FindDuplicates
+type Options struct {
+	// AliasKeys are the Data keys holding other names of a node, handler.AliasesKey by default
+	AliasKeys []string
+	// MinShared is the number of shared neighbours flagging a pair, 0 disables the rule
+	MinShared int
+}
+
+// DefaultOptions reads the aliases of MergeNodesInto and needs two shared neighbours
+func DefaultOptions() Options {
+	return Options{AliasKeys: []string{handler.AliasesKey}, MinShared: 2}
+}
+
+// the reasons of a Candidate
+const (
+	ByName       = "name"
+	ByAlias      = "alias"
+	ByNeighbours = "neighbours"
+)
+
+// Candidate is a pair of nodes of one collection that may be the same entity, A sorts before B
+type Candidate struct {
+	Collection string
+	A, B       string
+	// Score is in [0, 1], the best of the rules that matched
+	Score   float64
+	Reasons []string
+}
+
+// node is what FindDuplicates needs from a handler.Node of any backend
+type node struct {
+	name, collection string
+	keys             map[string]bool // the normalised name and aliases
+}
+
+// view reads the Export of the local, arango and mongo nodes:
+// the local one flattens the Data with Name and Collection, the others nest it in data
+func view(n handler.Node, aliasKeys []string) (node, bool) {
+	exp := n.Export()
+	res := node{keys: map[string]bool{}}
+	var data map[string]interface{}
+	if name, ok := exp["Name"].(string); ok {
+		res.name = name
+		res.collection, _ = exp["Collection"].(string)
+		data = exp
+	} else {
+		res.name, _ = exp["name"].(string)
+		res.collection, _ = exp["collection"].(string)
+		data = toMap(exp["data"])
+	}
+	if res.name == "" {
+		return res, false
+	}
+	if k := Normalize(res.name); k != "" {
+		res.keys[k] = true
+	}
+	for _, a := range Aliases(data, aliasKeys...) {
+		if k := Normalize(a); k != "" {
+			res.keys[k] = true
+		}
+	}
+	return res, true
+}
+
+// toMap accepts the map types of the drivers, such as primitive.M
+func toMap(v interface{}) map[string]interface{} {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+	res := make(map[string]interface{}, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		res[iter.Key().String()] = iter.Value().Interface()
+	}
+	return res
+}
+
+// FindDuplicates returns the candidate duplicates of the graph, the best first
+func FindDuplicates(g handler.GraphDB, opts Options) ([]Candidate, error) {
+	if len(opts.AliasKeys) == 0 {
+		opts.AliasKeys = []string{handler.AliasesKey}
+	}
+	all, err := g.GetNodesByRegex(".*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the nodes: %v", err)
+	}
+	byCollection := make(map[string][]node)
+	for _, n := range all {
+		if v, ok := view(n, opts.AliasKeys); ok {
+			byCollection[v.collection] = append(byCollection[v.collection], v)
+		}
+	}
+
+	var neighbours map[string]map[string]bool
+	if opts.MinShared > 0 {
+		if neighbours, err = neighbourSets(g, byCollection, opts.AliasKeys); err != nil {
+			return nil, err
+		}
+	}
+
+	var res []Candidate
+	for collection, nodes := range byCollection {
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].name < nodes[j].name })
+		for i := range nodes {
+			for j := i + 1; j < len(nodes); j++ {
+				if c, ok := compare(nodes[i], nodes[j], neighbours, opts.MinShared); ok {
+					c.Collection = collection
+					res = append(res, c)
+				}
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		if res[i].Collection != res[j].Collection {
+			return res[i].Collection < res[j].Collection
+		}
+		if res[i].A != res[j].A {
+			return res[i].A < res[j].A
+		}
+		return res[i].B < res[j].B
+	})
+	return res, nil
+}
+
+// neighbourSets returns the names of the nodes linked to each node in either direction
+func neighbourSets(g handler.GraphDB, byCollection map[string][]node, aliasKeys []string) (map[string]map[string]bool, error) {
+	res := make(map[string]map[string]bool)
+	for _, nodes := range byCollection {
+		for _, n := range nodes {
+			set := make(map[string]bool)
+			from, err := g.GetFromNodes(n.name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get the from nodes of %s: %v", n.name, err)
+			}
+			to, err := g.GetToNodes(n.name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get the to nodes of %s: %v", n.name, err)
+			}
+			for _, m := range append(from, to...) {
+				if v, ok := view(m, aliasKeys); ok && v.name != n.name {
+					set[v.name] = true
+				}
+			}
+			res[n.name] = set
+		}
+	}
+	return res, nil
+}
+
+// compare applies the rules to a pair: equal names score 1, a shared alias 0.9
+// and shared neighbours 0.8 times their Jaccard index
+func compare(a, b node, neighbours map[string]map[string]bool, minShared int) (Candidate, bool) {
+	c := Candidate{A: a.name, B: b.name}
+	if Normalize(a.name) == Normalize(b.name) {
+		c.Score = 1
+		c.Reasons = append(c.Reasons, ByName)
+	} else {
+		for k := range a.keys {
+			if b.keys[k] {
+				c.Score = 0.9
+				c.Reasons = append(c.Reasons, ByAlias)
+				break
+			}
+		}
+	}
+	if minShared > 0 {
+		na, nb := neighbours[a.name], neighbours[b.name]
+		shared := 0
+		for k := range na {
+			if nb[k] {
+				shared++
+			}
+		}
+		if shared >= minShared {
+			// % the pair itself is not a shared neighbour but counts in the union when linked
+			score := 0.8 * float64(shared) / float64(len(na)+len(nb)-shared)
+			if score > c.Score {
+				c.Score = score
+			}
+			c.Reasons = append(c.Reasons, ByNeighbours)
+		}
+	}
+	return c, len(c.Reasons) > 0
+}
@@ -0,0 +1,62 @@
+package resolve
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/history"
+)
+
+func TestNormalize(t *testing.T) {
+	// the stock codes lose their exchange
+	for _, name := range []string{"600001", "SH600001", "600001.SH", "sh600001", "６００００１．ＳＨ"} {
+		assert.Equal(t, "600001", Normalize(name), name)
+	}
+	// the legal forms and the punctuation go
+	assert.Equal(t, "平安银行", Normalize("平安银行股份有限公司"))
+	assert.Equal(t, "平安银行", Normalize("平安银行 (集团) 有限公司"))
+	assert.Equal(t, "ACME", Normalize("Acme Co., Ltd."))
+	assert.Equal(t, "ACME", Normalize("ACME Corporation"))
+	// a name that is only a legal form is kept
+	assert.Equal(t, "公司", Normalize("公司"))
+}
+
+func TestAliases(t *testing.T) {
+	data := map[string]interface{}{"aliases": []interface{}{"a", "b"}, "ticker": "c"}
+	assert.Equal(t, []string{"a", "b", "c"}, Aliases(data, "aliases", "ticker"))
+
+	res := AddAliases(data, "aliases", "b", "d")
+	assert.Equal(t, []interface{}{"a", "b", "d"}, res["aliases"])
+	// the input is not touched
+	assert.Len(t, data["aliases"], 2)
+}
+
+func TestAliasIndex(t *testing.T) {
+	var idx AliasIndex
+	node := func(id, name string, aliases ...interface{}) *history.Version {
+		return history.NodeVersion(history.Create, id, "company", name, map[string]interface{}{"aliases": aliases})
+	}
+	idx.Track("a", node("a", "600001", "PAB"), "aliases")
+	idx.Track("b", node("b", "SH600001", "X"), "aliases")
+	name, ok := idx.Resolve("PAB")
+	assert.True(t, ok)
+	assert.Equal(t, "600001", name)
+	assert.Error(t, idx.Check("b", "PAB"))
+	assert.NoError(t, idx.Check("a", "PAB", "new"))
+	assert.Equal(t, []string{"600001", "SH600001"}, idx.Match(regexp.MustCompile(".")))
+
+	// a merge: the survivor takes the aliases of the duplicate, they move once it is deleted
+	idx.Track("a", node("a", "600001", "PAB", "SH600001", "X"), "aliases")
+	name, _ = idx.Resolve("X")
+	assert.Equal(t, "SH600001", name)
+	idx.Track("b", nil, "aliases")
+	name, _ = idx.Resolve("X")
+	assert.Equal(t, "600001", name)
+
+	// an alias no longer in the data is released
+	idx.Track("a", node("a", "600001"), "aliases")
+	_, ok = idx.Resolve("PAB")
+	assert.False(t, ok)
+	assert.Empty(t, idx.Aliases("a"))
+}
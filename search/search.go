@@ -0,0 +1,294 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// the weights of the indexed texts, a hit in the name counts more than one in a field
+const (
+	NameWeight  = 3.0
+	AliasWeight = 2.0
+	FieldWeight = 1.0
+)
+
+// Config tells which string Data fields are indexed along with the name and the aliases
+//
+//	search:
+//	  fields: [industry, description]
+type Config struct {
+	Fields []string `yaml:"fields"`
+}
+
+// Default indexes the names and the aliases only
+func Default() *Config {
+	return &Config{}
+}
+
+// Parse builds a Config from the untyped "search" section of a backend yaml file
+// nil gives the Default config
+func Parse(raw interface{}) (*Config, error) {
+	if raw == nil {
+		return Default(), nil
+	}
+	b, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("invalid search config: %v", err)
+	}
+	for _, f := range c.Fields {
+		if f == "" || strings.ContainsAny(f, ".$`") {
+			return nil, fmt.Errorf("invalid search field %q", f)
+		}
+	}
+	return c, nil
+}
+
+// Options tunes a search
+type Options struct {
+	// Prefix lets a query token match the indexed tokens it starts, for search as you type
+	Prefix bool
+	// Collections restricts the search, all the node collections if empty
+	Collections []string
+	// Limit is the number of hits, 0 for all
+	Limit int
+}
+
+// Hit is a node found by a search
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+// Doc is what is indexed of a node
+type Doc struct {
+	ID         string
+	Collection string
+	Name       string
+	Aliases    []string
+	Data       map[string]interface{}
+}
+
+// Texts returns the indexed texts of the document with their weights
+func (c *Config) Texts(d Doc) map[string]float64 {
+	texts := map[string]float64{d.Name: NameWeight}
+	for _, a := range d.Aliases {
+		if texts[a] < AliasWeight {
+			texts[a] = AliasWeight
+		}
+	}
+	for _, f := range c.Fields {
+		if s, ok := d.Data[f].(string); ok && texts[s] < FieldWeight {
+			texts[s] = FieldWeight
+		}
+	}
+	delete(texts, "")
+	return texts
+}
+
+// Terms returns the unique tokens of the document, for the backends keeping them for a text index
+func (c *Config) Terms(d Doc) []string {
+	seen := make(map[string]bool)
+	var res []string
+	for text := range c.Texts(d) {
+		for _, t := range Tokenize(text) {
+			if !seen[t] {
+				seen[t] = true
+				res = append(res, t)
+			}
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+type docInfo struct {
+	collection string
+	length     float64
+	terms      []string
+}
+
+// Index is the in-process inverted index ranking with BM25
+// safe for concurrent use
+type Index struct {
+	m   sync.RWMutex
+	cfg *Config
+	// postings is token -> node ID -> weighted term frequency
+	postings map[string]map[string]float64
+	docs     map[string]docInfo
+	totalLen float64
+	// sorted are the tokens for the prefix search, nil once a write changed them
+	sorted []string
+}
+
+// NewIndex returns an empty index of the config, nil for the Default one
+func NewIndex(cfg *Config) *Index {
+	if cfg == nil {
+		cfg = Default()
+	}
+	return &Index{
+		cfg:      cfg,
+		postings: make(map[string]map[string]float64),
+		docs:     make(map[string]docInfo),
+	}
+}
+
+// Config returns the config of the index
+func (idx *Index) Config() *Config {
+	return idx.cfg
+}
+
+// Put indexes the document, replacing its former version
+func (idx *Index) Put(d Doc) {
+	idx.m.Lock()
+	defer idx.m.Unlock()
+
+	idx.remove(d.ID)
+	info := docInfo{collection: d.Collection}
+	tf := make(map[string]float64)
+	for text, w := range idx.cfg.Texts(d) {
+		for _, t := range Tokenize(text) {
+			tf[t] += w
+			info.length++
+		}
+	}
+	for t, f := range tf {
+		if idx.postings[t] == nil {
+			idx.postings[t] = make(map[string]float64)
+			idx.sorted = nil
+		}
+		idx.postings[t][d.ID] = f
+		info.terms = append(info.terms, t)
+	}
+	idx.docs[d.ID] = info
+	idx.totalLen += info.length
+}
+
+// Remove drops the document
+func (idx *Index) Remove(id string) {
+	idx.m.Lock()
+	defer idx.m.Unlock()
+
+	idx.remove(id)
+}
+
+// remove drops the document, the caller holds the lock
+func (idx *Index) remove(id string) {
+	info, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for _, t := range info.terms {
+		delete(idx.postings[t], id)
+		if len(idx.postings[t]) == 0 {
+			delete(idx.postings, t)
+			idx.sorted = nil
+		}
+	}
+	idx.totalLen -= info.length
+	delete(idx.docs, id)
+}
+
+// Len returns the number of indexed documents
+func (idx *Index) Len() int {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	return len(idx.docs)
+}
+
+// expand returns the indexed tokens a query token matches, the caller holds the lock
+func (idx *Index) expand(token string, prefix bool) []string {
+	if !prefix {
+		if _, ok := idx.postings[token]; ok {
+			return []string{token}
+		}
+		return nil
+	}
+	if idx.sorted == nil {
+		idx.sorted = make([]string, 0, len(idx.postings))
+		for t := range idx.postings {
+			idx.sorted = append(idx.sorted, t)
+		}
+		sort.Strings(idx.sorted)
+	}
+	var res []string
+	for i := sort.SearchStrings(idx.sorted, token); i < len(idx.sorted) && strings.HasPrefix(idx.sorted[i], token); i++ {
+		res = append(res, idx.sorted[i])
+	}
+	return res
+}
+
+// BM25 parameters
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Search returns the nodes matching any token of the query, the best first
+// a query token matching several tokens by prefix counts its best one
+func (idx *Index) Search(query string, opts Options) []Hit {
+	// % the prefix search sorts the tokens once, under the write lock
+	idx.m.Lock()
+	defer idx.m.Unlock()
+
+	if len(idx.docs) == 0 {
+		return nil
+	}
+	collections := make(map[string]bool, len(opts.Collections))
+	for _, c := range opts.Collections {
+		collections[c] = true
+	}
+	n := float64(len(idx.docs))
+	avgLen := idx.totalLen / n
+	scores := make(map[string]float64)
+	for _, qt := range QueryTokens(query) {
+		best := make(map[string]float64)
+		for _, t := range idx.expand(qt, opts.Prefix) {
+			posting := idx.postings[t]
+			df := float64(len(posting))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			for id, tf := range posting {
+				info := idx.docs[id]
+				if len(collections) > 0 && !collections[info.collection] {
+					continue
+				}
+				s := idf * tf * (k1 + 1) / (tf + k1*(1-b+b*info.length/avgLen))
+				if s > best[id] {
+					best[id] = s
+				}
+			}
+		}
+		for id, s := range best {
+			scores[id] += s
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, s := range scores {
+		hits = append(hits, Hit{ID: id, Score: s})
+	}
+	Sort(hits)
+	if opts.Limit > 0 && len(hits) > opts.Limit {
+		hits = hits[:opts.Limit]
+	}
+	return hits
+}
+
+// Sort orders the hits by score, then by ID
+func Sort(hits []Hit) {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+}
@@ -0,0 +1,56 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"pab", "600001", "平", "平安", "安", "安银", "银", "银行", "行"}, Tokenize("PAB-600001 平安银行"))
+	// full-width letters and digits are folded
+	assert.Equal(t, []string{"ab12"}, Tokenize("ＡＢ１２"))
+	// a query keeps the bigrams, once each
+	assert.Equal(t, []string{"bank", "平安", "安银", "银行"}, QueryTokens("平安银行 Bank bank"))
+	assert.Equal(t, []string{"平"}, QueryTokens("平"))
+}
+
+func TestIndex(t *testing.T) {
+	idx := NewIndex(&Config{Fields: []string{"industry"}})
+	idx.Put(Doc{ID: "a", Collection: "company", Name: "平安银行", Aliases: []string{"PAB"}})
+	idx.Put(Doc{ID: "b", Collection: "company", Name: "招商银行", Data: map[string]interface{}{"industry": "平安保险"}})
+	idx.Put(Doc{ID: "c", Collection: "person", Name: "平安", Data: map[string]interface{}{"industry": 1}})
+	assert.Equal(t, 3, idx.Len())
+
+	// the names holding all the bigrams come first
+	hits := idx.Search("平安银行", Options{})
+	assert.Len(t, hits, 3)
+	assert.Equal(t, "a", hits[0].ID)
+	// a name counts more than a field
+	hits = idx.Search("平安", Options{Collections: []string{"company"}})
+	assert.Equal(t, []string{"a", "b"}, []string{hits[0].ID, hits[1].ID})
+	assert.Len(t, idx.Search("平安", Options{Limit: 1}), 1)
+
+	// the aliases are indexed, the prefix search completes the tokens
+	assert.Len(t, idx.Search("pab", Options{}), 1)
+	assert.Empty(t, idx.Search("pa", Options{}))
+	assert.Equal(t, "a", idx.Search("pa", Options{Prefix: true})[0].ID)
+
+	// a new version replaces the old one
+	idx.Put(Doc{ID: "a", Collection: "company", Name: "平安银行"})
+	assert.Empty(t, idx.Search("pab", Options{}))
+	idx.Remove("b")
+	assert.Empty(t, idx.Search("招商", Options{}))
+	assert.Empty(t, idx.Search("保险", Options{Prefix: true}))
+}
+
+func TestParse(t *testing.T) {
+	c, err := Parse(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, c.Fields)
+	c, err = Parse(map[string]interface{}{"fields": []interface{}{"industry"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"industry"}, c.Fields)
+	_, err = Parse(map[string]interface{}{"fields": []interface{}{"a.b"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,106 @@
+// Package search is the full-text search over the node names, aliases and selected Data fields.
+//
+// The names are mostly Chinese, written without spaces, so the text is cut in two kinds of runs:
+// letters and digits make one token per word, Chinese, Japanese and Korean characters make
+// one token per character and one per pair of neighbours (bigrams). A query keeps only the
+// bigrams of a run longer than one character, so "平安银行" finds the names holding
+// "平安", "安银" and "银行" and ranks first the ones holding all of them.
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// isCJK tells the characters written without spaces
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// normalize folds full-width characters to half-width and letters to lower case
+func normalize(r rune) rune {
+	switch {
+	case r >= 0xFF01 && r <= 0xFF5E:
+		r -= 0xFEE0
+	case r == 0x3000:
+		r = ' '
+	}
+	return unicode.ToLower(r)
+}
+
+// runs cuts the text in words and CJK runs
+func runs(text string) (words []string, cjk [][]rune) {
+	var word strings.Builder
+	var run []rune
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+		if len(run) > 0 {
+			cjk = append(cjk, run)
+			run = nil
+		}
+	}
+	for _, r := range text {
+		r = normalize(r)
+		switch {
+		case isCJK(r):
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			run = append(run, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if len(run) > 0 {
+				cjk = append(cjk, run)
+				run = nil
+			}
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words, cjk
+}
+
+// Tokenize returns the tokens of a text to index: the words, the CJK characters and their bigrams
+func Tokenize(text string) []string {
+	words, cjk := runs(text)
+	tokens := words
+	for _, run := range cjk {
+		for i := range run {
+			tokens = append(tokens, string(run[i]))
+			if i+1 < len(run) {
+				tokens = append(tokens, string(run[i:i+2]))
+			}
+		}
+	}
+	return tokens
+}
+
+// QueryTokens returns the tokens of a query, the bigrams stand for the CJK runs longer than a character
+// the tokens are unique, the words first
+func QueryTokens(query string) []string {
+	words, cjk := runs(query)
+	tokens := words
+	for _, run := range cjk {
+		if len(run) == 1 {
+			tokens = append(tokens, string(run))
+			continue
+		}
+		for i := 0; i+1 < len(run); i++ {
+			tokens = append(tokens, string(run[i:i+2]))
+		}
+	}
+	seen := make(map[string]bool, len(tokens))
+	res := tokens[:0]
+	for _, t := range tokens {
+		if !seen[t] {
+			seen[t] = true
+			res = append(res, t)
+		}
+	}
+	return res
+}
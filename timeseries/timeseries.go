@@ -0,0 +1,137 @@
+// Package timeseries holds the point operations behind the time series
+// properties of nodes and edges, e.g. companyRevenue by quarter.
+// The backends store the points natively, this package keeps the
+// semantics (ordering, ranges, as-of lookups and aggregates) the same.
+package timeseries
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrNoPoints is returned when a lookup or an aggregate finds no point
+var ErrNoPoints = errors.New("no points in range")
+
+// Point is one (timestamp, value) observation of a metric
+type Point struct {
+	Time  time.Time `json:"t" bson:"t"`
+	Value float64   `json:"v" bson:"v"`
+}
+
+// Aggregation names an aggregate over the points of a range
+type Aggregation string
+
+const (
+	Count Aggregation = "count"
+	Sum   Aggregation = "sum"
+	Avg   Aggregation = "avg"
+	Min   Aggregation = "min"
+	Max   Aggregation = "max"
+	First Aggregation = "first"
+	Last  Aggregation = "last"
+)
+
+// Valid checks if the aggregation is one of the known ones
+func (a Aggregation) Valid() bool {
+	switch a {
+	case Count, Sum, Avg, Min, Max, First, Last:
+		return true
+	}
+	return false
+}
+
+// Insert adds the points to a sorted series and returns the sorted result
+// a point with an existing timestamp replaces the old value
+func Insert(series []Point, points ...Point) []Point {
+	for _, p := range points {
+		i := sort.Search(len(series), func(i int) bool { return !series[i].Time.Before(p.Time) })
+		if i < len(series) && series[i].Time.Equal(p.Time) {
+			series[i].Value = p.Value
+			continue
+		}
+		series = append(series, Point{})
+		copy(series[i+1:], series[i:])
+		series[i] = p
+	}
+	return series
+}
+
+// InRange reports if t is in [from, to], a zero bound is open
+func InRange(t, from, to time.Time) bool {
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+	if !to.IsZero() && t.After(to) {
+		return false
+	}
+	return true
+}
+
+// Range returns a copy of the points of a sorted series in [from, to]
+func Range(series []Point, from, to time.Time) []Point {
+	res := []Point{}
+	for _, p := range series {
+		if InRange(p.Time, from, to) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// AsOf returns the last point of a sorted series at or before t
+func AsOf(series []Point, t time.Time) (Point, error) {
+	i := sort.Search(len(series), func(i int) bool { return series[i].Time.After(t) })
+	if i == 0 {
+		return Point{}, ErrNoPoints
+	}
+	return series[i-1], nil
+}
+
+// Aggregate computes the aggregate over sorted points
+// Count of no points is 0, every other aggregate returns ErrNoPoints
+func Aggregate(points []Point, agg Aggregation) (float64, error) {
+	if !agg.Valid() {
+		return 0, fmt.Errorf("unknown aggregation %q", agg)
+	}
+	if agg == Count {
+		return float64(len(points)), nil
+	}
+	if len(points) == 0 {
+		return 0, ErrNoPoints
+	}
+	res := points[0].Value
+	switch agg {
+	case First:
+		return res, nil
+	case Last:
+		return points[len(points)-1].Value, nil
+	}
+	for _, p := range points[1:] {
+		switch agg {
+		case Sum, Avg:
+			res += p.Value
+		case Min:
+			if p.Value < res {
+				res = p.Value
+			}
+		case Max:
+			if p.Value > res {
+				res = p.Value
+			}
+		}
+	}
+	if agg == Avg {
+		res /= float64(len(points))
+	}
+	return res, nil
+}
+
+// ValidMetric checks the metric name used as a key in the backends
+func ValidMetric(metric string) error {
+	if metric == "" {
+		return fmt.Errorf("metric is mandatory")
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package timeseries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func quarter(year int, q int) time.Time {
+	return time.Date(year, time.Month(3*q), 30, 0, 0, 0, 0, time.UTC)
+}
+
+func TestInsertRangeAsOf(t *testing.T) {
+	var s []Point
+	s = Insert(s, Point{quarter(2023, 3), 30}, Point{quarter(2023, 1), 10})
+	s = Insert(s, Point{quarter(2023, 2), 20}, Point{quarter(2023, 3), 33})
+
+	assert.Equal(t, []Point{{quarter(2023, 1), 10}, {quarter(2023, 2), 20}, {quarter(2023, 3), 33}}, s)
+
+	assert.Equal(t, []Point{{quarter(2023, 2), 20}, {quarter(2023, 3), 33}}, Range(s, quarter(2023, 2), time.Time{}))
+	assert.Equal(t, []Point{{quarter(2023, 1), 10}}, Range(s, time.Time{}, quarter(2023, 1)))
+	assert.Empty(t, Range(s, quarter(2024, 1), time.Time{}))
+
+	p, err := AsOf(s, quarter(2023, 2).Add(24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 20.0, p.Value)
+
+	_, err = AsOf(s, quarter(2022, 4))
+	assert.ErrorIs(t, err, ErrNoPoints)
+}
+
+func TestAggregate(t *testing.T) {
+	pts := []Point{{quarter(2023, 1), 10}, {quarter(2023, 2), 40}, {quarter(2023, 3), 25}}
+	tests := []struct {
+		agg  Aggregation
+		want float64
+	}{
+		{Count, 3}, {Sum, 75}, {Avg, 25}, {Min, 10}, {Max, 40}, {First, 10}, {Last, 25},
+	}
+	for _, tt := range tests {
+		got, err := Aggregate(pts, tt.agg)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got, string(tt.agg))
+	}
+
+	n, err := Aggregate(nil, Count)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, n)
+	_, err = Aggregate(nil, Sum)
+	assert.ErrorIs(t, err, ErrNoPoints)
+	_, err = Aggregate(pts, "median")
+	assert.Error(t, err)
+}
@@ -1,76 +1,145 @@
 package tools
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog"
 )
 
-// NewLogger creates a new multilevelWriter loger with level
-// and can be turned off and set output to os.Stdout or file
-func NewLogger(pars map[string]interface{}) zerolog.Logger {
-	// whether the logger is enabled
-	enabled := pars["enabled"].(bool)
-	// level of the logger
-	level := pars["level"].(string)
-	// output of the logger, if stdout or file path, or both with "," separated
-	outputs := pars["output"].(string)
+// LogConfig is the "logger" section of a backend yaml file
+type LogConfig struct {
+	// Enabled turns the logger on, a disabled logger writes nothing
+	Enabled bool `yaml:"enabled"`
+	// Level is the lowest level written, info if empty
+	Level string `yaml:"level"`
+	// Output is "stdout", "stderr" or a file path, or several of them "," separated
+	Output string `yaml:"output"`
+	// Format is "json" or "console", empty for console on stdout and stderr and json in the files
+	Format string `yaml:"format"`
 
-	var logger zerolog.Logger
-	if !enabled {
-		logger = zerolog.New(io.Discard).With().Timestamp().Logger()
-	} else {
-		// set the log level 
-		lvl , err := zerolog.ParseLevel(level)
-		if err != nil {
-			panic(err)
-		}
-		zerolog.SetGlobalLevel(lvl)
+	// MaxSize in megabytes rotates a file before it grows larger, 0 never
+	MaxSize int `yaml:"maxSize"`
+	// MaxAge rotates a file opened for longer, like "24h", 0 never
+	MaxAge time.Duration `yaml:"maxAge"`
+	// MaxBackups is the number of rotated files kept, 0 keeps them all
+	MaxBackups int `yaml:"maxBackups"`
 
-		// set the output
-		// if the output string has "," separated values, then it is a multiwriter
-		outputVals :=strings.Split(outputs, ",")
-		// if outputVals has more than one value, then it is a multiwriter
-		if len(outputVals) > 1 {
-			var writers []io.Writer
-			for _, output := range outputVals {
-				if output == "stdout" {
-					writers = append(writers, zerolog.ConsoleWriter{Out: os.Stdout})
-				} else {
-					// check the output path has a directory,
-					// if not, create the directory
-					dir := filepath.Dir(output)
-					if _, err := os.Stat(dir); os.IsNotExist(err) {
-						os.MkdirAll(dir, os.ModePerm)
-					}		
+	// Sampling keeps 1 of Sampling debug and info messages, 0 or 1 keeps them all
+	// the warnings and the errors are always kept
+	Sampling uint32 `yaml:"sampling"`
+}
 
-					file, err := os.OpenFile(output, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-					if err != nil {
-						panic(err)
-					}
-					writers = append(writers, file)
-				}
-			}
-			logger = zerolog.New(io.MultiWriter(writers...)).With().Timestamp().Logger()
-		} else {
-			// if outputVals has only one value, then it is a single writer
-			output := outputVals[0]
-			if output == "stdout" {
-				logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout}).With().Timestamp().Logger()
-			} else {
-				file, err := os.OpenFile(output, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-				if err != nil {
-					panic(err)
-				}
+// Validate checks the level, the format and the rotation
+func (c LogConfig) Validate() error {
+	if _, err := c.level(); err != nil {
+		return err
+	}
+	switch c.Format {
+	case "", "json", "console":
+	default:
+		return fmt.Errorf("invalid logger format %q", c.Format)
+	}
+	if c.MaxSize < 0 || c.MaxAge < 0 || c.MaxBackups < 0 {
+		return fmt.Errorf("invalid logger rotation: maxSize, maxAge and maxBackups cannot be negative")
+	}
+	return nil
+}
+
+// level parses the level, info if empty
+func (c LogConfig) level() (zerolog.Level, error) {
+	if c.Level == "" {
+		return zerolog.InfoLevel, nil
+	}
+	lvl, err := zerolog.ParseLevel(strings.ToLower(c.Level))
+	if err != nil {
+		return lvl, fmt.Errorf("invalid logger level %q", c.Level)
+	}
+	return lvl, nil
+}
+
+// Logger is a zerolog.Logger owning its files
+type Logger struct {
+	zerolog.Logger
+
+	files []*rotatingFile
+}
 
-				logger = zerolog.New(file).With().Timestamp().Logger()
+// NopLogger writes nothing
+func NopLogger() *Logger {
+	return &Logger{Logger: zerolog.Nop()}
+}
+
+// NewLogger creates a logger writing to the outputs of c at its own level
+// the files are created with their directories, Close releases them
+func NewLogger(c LogConfig) (*Logger, error) {
+	if !c.Enabled {
+		return NopLogger(), nil
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	level, _ := c.level()
+
+	l := &Logger{}
+	var writers []io.Writer
+	outputs := c.Output
+	if outputs == "" {
+		outputs = "stdout"
+	}
+	for _, output := range strings.Split(outputs, ",") {
+		output = strings.TrimSpace(output)
+		var w io.Writer
+		console := c.Format == "console"
+		switch output {
+		case "":
+			continue
+		case "stdout", "stderr":
+			w = os.Stdout
+			if output == "stderr" {
+				w = os.Stderr
 			}
+			console = c.Format != "json"
+		default:
+			f, err := openRotating(output, int64(c.MaxSize)<<20, c.MaxAge, c.MaxBackups)
+			if err != nil {
+				// ~ the files opened so far are not leaked
+				l.Close()
+				return nil, err
+			}
+			l.files = append(l.files, f)
+			w = f
+		}
+		if console {
+			_, isFile := w.(*rotatingFile)
+			w = zerolog.ConsoleWriter{Out: w, NoColor: isFile}
 		}
+		writers = append(writers, w)
+	}
+
+	logger := zerolog.New(zerolog.MultiLevelWriter(writers...)).Level(level).With().Timestamp().Logger()
+	if c.Sampling > 1 {
+		sampler := &zerolog.BasicSampler{N: c.Sampling}
+		logger = logger.Sample(zerolog.LevelSampler{TraceSampler: sampler, DebugSampler: sampler, InfoSampler: sampler})
 	}
-	
-	return logger
+	l.Logger = logger
+	return l, nil
 }
 
+// Close closes the files of the logger, the later messages are not written to them
+// a nil Logger has nothing to close
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	var errs []error
+	for _, f := range l.files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
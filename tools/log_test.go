@@ -1,32 +1,216 @@
 package tools
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewLogger(t *testing.T) {
+	dir := t.TempDir()
 	// test cases
 	tests := []struct {
-		name string
-		pars map[string]interface{}
+		name    string
+		c       LogConfig
+		wantErr bool
 	}{
-
 		{
 			name: "Test case 01",
-			pars: map[string]interface{}{
-				"enabled": true,
-				"level":   "debug",
-				"output":  "stdout,./tmp/test.log",
-			},
+			c:    LogConfig{Enabled: true, Level: "DEBUG", Output: "stdout," + filepath.Join(dir, "new", "test.log")},
+		},
+		{
+			name: "disabled",
+			c:    LogConfig{Output: filepath.Join(dir, "never.log")},
+		},
+		{
+			name:    "bad level",
+			c:       LogConfig{Enabled: true, Level: "loud"},
+			wantErr: true,
+		},
+		{
+			name:    "bad format",
+			c:       LogConfig{Enabled: true, Format: "xml"},
+			wantErr: true,
+		},
+		{
+			name:    "directory is a file",
+			c:       LogConfig{Enabled: true, Output: filepath.Join(dir, "new", "test.log", "x.log")},
+			wantErr: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			logger := NewLogger(tt.pars)
+			logger, err := NewLogger(tt.c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewLogger() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer logger.Close()
 
 			// log some info
 			logger.Info().Msg("Test info")
 		})
 	}
+	if _, err := os.Stat(filepath.Join(dir, "never.log")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file for a disabled logger, got %v", err)
+	}
+}
+
+func TestLoggerLevels(t *testing.T) {
+	dir := t.TempDir()
+	debug, err := NewLogger(LogConfig{Enabled: true, Level: "debug", Output: filepath.Join(dir, "debug.log")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	warn, err := NewLogger(LogConfig{Enabled: true, Level: "warn", Output: filepath.Join(dir, "warn.log")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	debug.Debug().Msg("details")
+	warn.Debug().Msg("details")
+	warn.Warn().Msg("careful")
+	if err := debug.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := warn.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := read(t, filepath.Join(dir, "debug.log")); !strings.Contains(got, `"message":"details"`) {
+		t.Errorf("Expected the debug message in json, got %q", got)
+	}
+	if got := read(t, filepath.Join(dir, "warn.log")); strings.Contains(got, "details") || !strings.Contains(got, "careful") {
+		t.Errorf("Expected only the warning, got %q", got)
+	}
+
+	// a closed logger drops its messages, its file stays closed
+	warn.Error().Msg("again")
+	if got := read(t, filepath.Join(dir, "warn.log")); strings.Contains(got, "again") {
+		t.Errorf("Expected no message after Close, got %q", got)
+	}
+	if f := warn.files[0]; f.f != nil {
+		t.Errorf("Expected the file closed, got %v", f.f.Name())
+	}
+}
+
+func TestLoggerSampling(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sampled.log")
+	logger, err := NewLogger(LogConfig{Enabled: true, Output: path, Format: "console", Sampling: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	for i := 0; i < 100; i++ {
+		logger.Info().Msg("tick")
+		logger.Error().Msg("boom")
+	}
+	got := read(t, path)
+	if n := strings.Count(got, "tick"); n != 10 {
+		t.Errorf("Expected 10 info messages, got %v", n)
+	}
+	if n := strings.Count(got, "boom"); n != 100 {
+		t.Errorf("Expected 100 error messages, got %v", n)
+	}
+	if strings.Contains(got, "{") {
+		t.Errorf("Expected the console format, got %q", got)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	r, err := openRotating(path, 10, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, line := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := read(t, path); got != "dddddd\n" {
+		t.Errorf("Expected the last line in the file, got %q", got)
+	}
+	backups, err := r.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %v", backups)
+	}
+	if got := read(t, backups[0]); got != "bbbbbb\n" {
+		t.Errorf("Expected the oldest backup kept to hold bbbbbb, got %q", got)
+	}
+
+	// a file opened for longer than maxAge is rotated
+	r.maxSize, r.maxAge = 0, 1
+	if _, err := r.Write([]byte("eeeeee\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, path); got != "eeeeee\n" {
+		t.Errorf("Expected a new file, got %q", got)
+	}
+}
+
+func TestRotatingFileAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	day := time.Now().Add(-24 * time.Hour)
+	if err := os.Chtimes(path, day, day); err != nil {
+		t.Fatal(err)
+	}
+
+	// a file of an earlier run is as old as its last write, not as the process
+	r, err := openRotating(path, 0, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("new\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, path); got != "new\n" {
+		t.Errorf("Expected the old file rotated, got %q", got)
+	}
+
+	// written since, it is as old as its rotation
+	backups, err := r.backups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %v %v", backups, err)
+	}
+	rotated := time.Now().Add(-2 * time.Hour)
+	prefix, ext := r.split()
+	if err := os.Rename(backups[0], prefix+"-"+rotated.Format(backupLayout)+ext); err != nil {
+		t.Fatal(err)
+	}
+	r, err = openRotating(path, 0, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if !r.started.Equal(rotated.Truncate(0)) {
+		t.Errorf("Expected the file started at %v, got %v", rotated, r.started)
+	}
+	if !r.due(1) {
+		t.Errorf("Expected the file due for rotation")
+	}
+}
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
 }
@@ -0,0 +1,178 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupLayout timestamps the rotated files, it sorts in time order
+const backupLayout = "20060102T150405.000000000"
+
+// rotatingFile is a log file moved aside once too large or too old
+// path.log becomes path-<time>.log
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	// f is nil while a rotation failed to open the next file, the next write tries again
+	// once closed the writes are dropped
+	f       *os.File
+	closed  bool
+	size    int64
+	started time.Time
+}
+
+// openRotating opens the file, maxSize is in bytes
+func openRotating(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxAge: maxAge, maxBackups: maxBackups}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the file for appending, the caller holds the lock
+// the age of a file kept from an earlier run starts at its rotation, or at its last write before any
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size, r.started = f, info.Size(), time.Now()
+	if r.size > 0 {
+		r.started = info.ModTime()
+		if rotated, ok := r.rotated(); ok && rotated.Before(r.started) {
+			r.started = rotated
+		}
+	}
+	return nil
+}
+
+// rotated returns the time of the last rotation, the stamp of the newest backup
+func (r *rotatingFile) rotated() (time.Time, bool) {
+	backups, err := r.backups()
+	if err != nil || len(backups) == 0 {
+		return time.Time{}, false
+	}
+	prefix, ext := r.split()
+	stamp := strings.TrimSuffix(strings.TrimPrefix(backups[len(backups)-1], prefix+"-"), ext)
+	at, err := time.ParseInLocation(backupLayout, stamp, time.Local)
+	return at, err == nil
+}
+
+// Write(p []byte) (int, error)
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return len(p), nil
+	}
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.due(len(p)) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// due tells if the file is rotated before writing n bytes, an empty file never is
+func (r *rotatingFile) due(n int) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.maxSize > 0 && r.size+int64(n) > r.maxSize {
+		return true
+	}
+	return r.maxAge > 0 && time.Since(r.started) >= r.maxAge
+}
+
+// rotate moves the file aside, opens a new one and removes the backups beyond maxBackups
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+	prefix, ext := r.split()
+	if err := os.Rename(r.path, prefix+"-"+time.Now().Format(backupLayout)+ext); err != nil {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	return r.prune()
+}
+
+func (r *rotatingFile) split() (prefix, ext string) {
+	ext = filepath.Ext(r.path)
+	return strings.TrimSuffix(r.path, ext), ext
+}
+
+// backups returns the rotated files, oldest first
+func (r *rotatingFile) backups() ([]string, error) {
+	prefix, ext := r.split()
+	matches, err := filepath.Glob(prefix + "-*" + ext)
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, m := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(m, prefix+"-"), ext)
+		if _, err := time.Parse(backupLayout, stamp); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+func (r *rotatingFile) prune() error {
+	if r.maxBackups <= 0 {
+		return nil
+	}
+	backups, err := r.backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > r.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// Close() error
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
@@ -0,0 +1,55 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// JSONExporter writes each span as a line of JSON
+type JSONExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+var _ Exporter = (*JSONExporter)(nil)
+
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{enc: json.NewEncoder(w)}
+}
+
+// NewStdoutExporter writes the spans to the standard output, for local debugging
+func NewStdoutExporter() *JSONExporter {
+	return NewJSONExporter(os.Stdout)
+}
+
+// Export(s SpanData)
+func (e *JSONExporter) Export(s SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// ~ an attribute JSON cannot encode loses the span, the debugging output goes on
+	_ = e.enc.Encode(s)
+}
+
+// Recorder keeps the spans in memory, for the tests
+type Recorder struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+var _ Exporter = (*Recorder)(nil)
+
+// Export(s SpanData)
+func (r *Recorder) Export(s SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+// Spans returns the spans in the order they ended
+func (r *Recorder) Spans() []SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]SpanData(nil), r.spans...)
+}
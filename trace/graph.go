@@ -0,0 +1,218 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wonderstone/chainstorm/handler"
+)
+
+// Graph is a handler.GraphDB starting a span for each call to the wrapped one,
+// the backend round trips are its children when the wrapped one is a handler.ContextGraph
+type Graph struct {
+	handler.GraphDB
+
+	// ctx is the caller context of a WithContext view, nil for the graph itself
+	ctx context.Context
+}
+
+var _ handler.GraphDB = (*Graph)(nil)
+var _ handler.TxGraph = (*Graph)(nil)
+var _ handler.ContextGraph = (*Graph)(nil)
+
+// Wrap returns g traced, wrap the backend itself so the spans reach its round trips
+func Wrap(g handler.GraphDB) *Graph {
+	return &Graph{GraphDB: g}
+}
+
+// Unwrap returns the backend, for its other interfaces
+func (g *Graph) Unwrap() handler.GraphDB {
+	return g.GraphDB
+}
+
+// WithContext(ctx context.Context) handler.GraphDB
+// the spans of the view are the children of the one carried by ctx
+func (g *Graph) WithContext(ctx context.Context) handler.GraphDB {
+	view := *g
+	view.ctx = ctx
+	return &view
+}
+
+// start starts the span of a method, it returns the graph to call in its context
+func (g *Graph) start(method string) (handler.GraphDB, *Span) {
+	ctx := g.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := Start(ctx, "GraphDB."+method)
+	cg, ok := g.GraphDB.(handler.ContextGraph)
+	// ~ without a span nor a caller context the backend is called as it is
+	if !ok || (span == nil && g.ctx == nil) {
+		return g.GraphDB, span
+	}
+	return cg.WithContext(ctx), span
+}
+
+// finish ends a span, deferred with the address of its error
+func finish(span *Span, err *error) {
+	span.Fail(*err)
+	span.End()
+}
+
+// - Init operations
+// # Init, Connect and Disconnect set up the graph itself, not a view of it
+func (g *Graph) Init(yamlPath string) (err error) {
+	_, span := g.start("Init")
+	defer finish(span, &err)
+	return g.GraphDB.Init(yamlPath)
+}
+
+// - Connection operations
+func (g *Graph) Connect() (err error) {
+	_, span := g.start("Connect")
+	defer finish(span, &err)
+	return g.GraphDB.Connect()
+}
+
+func (g *Graph) Disconnect() (err error) {
+	_, span := g.start("Disconnect")
+	defer finish(span, &err)
+	return g.GraphDB.Disconnect()
+}
+
+// + Create operations
+func (g *Graph) AddNode(n handler.Node) (id interface{}, err error) {
+	db, span := g.start("AddNode")
+	defer finish(span, &err)
+	return db.AddNode(n)
+}
+
+func (g *Graph) AddEdge(e handler.Edge) (id interface{}, err error) {
+	db, span := g.start("AddEdge")
+	defer finish(span, &err)
+	return db.AddEdge(e)
+}
+
+// + Update operations
+func (g *Graph) ReplaceNode(n handler.Node) (err error) {
+	db, span := g.start("ReplaceNode")
+	defer finish(span, &err)
+	return db.ReplaceNode(n)
+}
+
+func (g *Graph) ReplaceEdge(e handler.Edge) (err error) {
+	db, span := g.start("ReplaceEdge")
+	defer finish(span, &err)
+	return db.ReplaceEdge(e)
+}
+
+func (g *Graph) UpdateNode(n handler.Node) (err error) {
+	db, span := g.start("UpdateNode")
+	defer finish(span, &err)
+	return db.UpdateNode(n)
+}
+
+func (g *Graph) UpdateEdge(e handler.Edge) (err error) {
+	db, span := g.start("UpdateEdge")
+	defer finish(span, &err)
+	return db.UpdateEdge(e)
+}
+
+func (g *Graph) MergeNode(n handler.Node) (err error) {
+	db, span := g.start("MergeNode")
+	defer finish(span, &err)
+	return db.MergeNode(n)
+}
+
+func (g *Graph) MergeEdge(e handler.Edge) (err error) {
+	db, span := g.start("MergeEdge")
+	defer finish(span, &err)
+	return db.MergeEdge(e)
+}
+
+// + Delete operations
+func (g *Graph) DeleteNode(name interface{}) (err error) {
+	db, span := g.start("DeleteNode")
+	defer finish(span, &err)
+	return db.DeleteNode(name)
+}
+
+func (g *Graph) DeleteItemByID(id interface{}) (err error) {
+	db, span := g.start("DeleteItemByID")
+	defer finish(span, &err)
+	return db.DeleteItemByID(id)
+}
+
+// + Query operations
+func (g *Graph) GetItemByID(id interface{}) (item interface{}, err error) {
+	db, span := g.start("GetItemByID")
+	defer finish(span, &err)
+	return db.GetItemByID(id)
+}
+
+func (g *Graph) GetNode(name interface{}) (n handler.Node, err error) {
+	db, span := g.start("GetNode")
+	defer finish(span, &err)
+	return db.GetNode(name)
+}
+
+func (g *Graph) GetNodesByRegex(regex string) (nodes []handler.Node, err error) {
+	db, span := g.start("GetNodesByRegex")
+	defer finish(span, &err)
+	return db.GetNodesByRegex(regex)
+}
+
+func (g *Graph) GetEdgesByRegex(regex string) (edges []handler.Edge, err error) {
+	db, span := g.start("GetEdgesByRegex")
+	defer finish(span, &err)
+	return db.GetEdgesByRegex(regex)
+}
+
+func (g *Graph) GetFromNodes(name interface{}) (nodes []handler.Node, err error) {
+	db, span := g.start("GetFromNodes")
+	defer finish(span, &err)
+	return db.GetFromNodes(name)
+}
+
+func (g *Graph) GetToNodes(name interface{}) (nodes []handler.Node, err error) {
+	db, span := g.start("GetToNodes")
+	defer finish(span, &err)
+	return db.GetToNodes(name)
+}
+
+func (g *Graph) GetInEdges(name interface{}) (edges []handler.Edge, err error) {
+	db, span := g.start("GetInEdges")
+	defer finish(span, &err)
+	return db.GetInEdges(name)
+}
+
+func (g *Graph) GetOutEdges(name interface{}) (edges []handler.Edge, err error) {
+	db, span := g.start("GetOutEdges")
+	defer finish(span, &err)
+	return db.GetOutEdges(name)
+}
+
+// + Graph operations
+func (g *Graph) GetAllRelatedNodes(name interface{}) (nodes [][]handler.Node, err error) {
+	db, span := g.start("GetAllRelatedNodes")
+	defer finish(span, &err)
+	return db.GetAllRelatedNodes(name)
+}
+
+func (g *Graph) GetAllRelatedNodesInEdgeSlice(name interface{}, EdgeSlice ...handler.Edge) (nodes [][]handler.Node, err error) {
+	db, span := g.start("GetAllRelatedNodesInEdgeSlice")
+	defer finish(span, &err)
+	return db.GetAllRelatedNodesInEdgeSlice(name, EdgeSlice...)
+}
+
+// Update(fn func(tx handler.Tx) error) error
+// the transaction is a span, the round trips of its operations are the children
+func (g *Graph) Update(fn func(tx handler.Tx) error) (err error) {
+	db, span := g.start("Update")
+	defer finish(span, &err)
+	txg, ok := db.(handler.TxGraph)
+	if !ok {
+		return fmt.Errorf("%T has no transactions", g.GraphDB)
+	}
+	return txg.Update(fn)
+}
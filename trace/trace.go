@@ -0,0 +1,135 @@
+// Package trace records spans of the graph operations, in the way of OpenTelemetry
+// without its dependencies.
+//
+// A span starts from a context and is carried by the returned one, so the spans
+// started from it are its children: trace.Wrap starts one per public method of a
+// handler.GraphDB, the backends one per round trip. Nothing is recorded until
+// SetExporter gives an Exporter; JSONExporter writes the spans as JSON lines.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// SpanData is a finished span as the exporters get it
+type SpanData struct {
+	TraceID  string                 `json:"traceId"`
+	SpanID   string                 `json:"spanId"`
+	ParentID string                 `json:"parentId,omitempty"`
+	Name     string                 `json:"name"`
+	Start    time.Time              `json:"start"`
+	End      time.Time              `json:"end"`
+	Attrs    map[string]interface{} `json:"attrs,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// Duration is the time between the start and the end
+func (d SpanData) Duration() time.Duration {
+	return d.End.Sub(d.Start)
+}
+
+// Exporter ships the finished spans, it is called by the goroutine ending them
+type Exporter interface {
+	Export(s SpanData)
+}
+
+var (
+	mu       sync.RWMutex
+	exporter Exporter
+)
+
+// SetExporter makes the spans recorded and sent to e, nil stops the recording
+func SetExporter(e Exporter) {
+	mu.Lock()
+	defer mu.Unlock()
+	exporter = e
+}
+
+func currentExporter() Exporter {
+	mu.RLock()
+	defer mu.RUnlock()
+	return exporter
+}
+
+// Span is a running span, the methods of a nil Span do nothing
+type Span struct {
+	mu   sync.Mutex
+	data SpanData
+	done bool
+}
+
+type spanKey struct{}
+
+// FromContext returns the span carried by ctx, nil if none
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Start starts a span, the child of the one carried by ctx, and returns the context carrying it
+// without an exporter it returns ctx and a nil Span
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	if currentExporter() == nil {
+		return ctx, nil
+	}
+	s := &Span{data: SpanData{SpanID: newID(8), Name: name, Start: time.Now()}}
+	if parent := FromContext(ctx); parent != nil {
+		s.data.TraceID, s.data.ParentID = parent.data.TraceID, parent.data.SpanID
+	} else {
+		s.data.TraceID = newID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Set adds an attribute
+func (s *Span) Set(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Attrs == nil {
+		s.data.Attrs = make(map[string]interface{})
+	}
+	s.data.Attrs[key] = value
+}
+
+// Fail records the error of the span, nil does nothing
+func (s *Span) Fail(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Error = err.Error()
+}
+
+// End ends the span and exports it, once
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.done {
+		s.mu.Unlock()
+		return
+	}
+	s.done = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	if e := currentExporter(); e != nil {
+		e.Export(data)
+	}
+}
+
+// newID returns n random bytes in hex
+func newID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,113 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wonderstone/chainstorm/handler"
+	"github.com/wonderstone/chainstorm/local"
+)
+
+func TestSpans(t *testing.T) {
+	// without an exporter nothing is recorded
+	ctx, span := Start(context.Background(), "off")
+	assert.Nil(t, span)
+	assert.Nil(t, FromContext(ctx))
+	span.Set("k", "v")
+	span.End()
+
+	rec := &Recorder{}
+	SetExporter(rec)
+	defer SetExporter(nil)
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	child.Set("collection", "company")
+	child.Fail(errors.New("boom"))
+	child.End()
+	child.End()
+	parent.End()
+
+	spans := rec.Spans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "child", spans[0].Name)
+	assert.Equal(t, "parent", spans[1].Name)
+	assert.Equal(t, spans[1].TraceID, spans[0].TraceID)
+	assert.Equal(t, spans[1].SpanID, spans[0].ParentID)
+	assert.Empty(t, spans[1].ParentID)
+	assert.Equal(t, "boom", spans[0].Error)
+	assert.Equal(t, "company", spans[0].Attrs["collection"])
+	assert.True(t, spans[0].Duration() >= 0)
+}
+
+func TestJSONExporter(t *testing.T) {
+	var b strings.Builder
+	SetExporter(NewJSONExporter(&b))
+	defer SetExporter(nil)
+
+	_, span := Start(context.Background(), "GraphDB.GetNode")
+	span.Set("name", "600001")
+	span.End()
+
+	var got SpanData
+	assert.NoError(t, json.Unmarshal([]byte(b.String()), &got))
+	assert.Equal(t, "GraphDB.GetNode", got.Name)
+	assert.Len(t, got.TraceID, 32)
+	assert.Len(t, got.SpanID, 16)
+	assert.Equal(t, "600001", got.Attrs["name"])
+	assert.NotContains(t, b.String(), "parentId")
+}
+
+// ctxGraph records the span carried by the context of each view
+type ctxGraph struct {
+	handler.GraphDB
+	parents *[]*Span
+	ctx     context.Context
+}
+
+func (g *ctxGraph) WithContext(ctx context.Context) handler.GraphDB {
+	return &ctxGraph{GraphDB: g.GraphDB, parents: g.parents, ctx: ctx}
+}
+
+func (g *ctxGraph) GetNode(name interface{}) (handler.Node, error) {
+	*g.parents = append(*g.parents, FromContext(g.ctx))
+	return g.GraphDB.GetNode(name)
+}
+
+func TestGraph(t *testing.T) {
+	db, err := local.NewInMemoryDB()
+	assert.NoError(t, err)
+	var parents []*Span
+	g := Wrap(&ctxGraph{GraphDB: db, parents: &parents})
+
+	rec := &Recorder{}
+	SetExporter(rec)
+	defer SetExporter(nil)
+
+	n, _ := local.NewNode(local.WithNID("a"), local.WithNCollection("company"), local.WithNName("600001"))
+	_, err = g.AddNode(n)
+	assert.NoError(t, err)
+	_, err = g.GetNode("missing")
+	assert.Error(t, err)
+
+	ctx, root := Start(context.Background(), "request")
+	_, err = g.WithContext(ctx).GetNode("600001")
+	assert.NoError(t, err)
+	root.End()
+
+	spans := rec.Spans()
+	assert.Len(t, spans, 4)
+	assert.Equal(t, "GraphDB.AddNode", spans[0].Name)
+	assert.Equal(t, "GraphDB.GetNode", spans[1].Name)
+	assert.NotEmpty(t, spans[1].Error)
+	assert.Equal(t, spans[3].SpanID, spans[2].ParentID)
+	assert.Equal(t, spans[3].TraceID, spans[2].TraceID)
+
+	// the backend is called in the context of the span of the method
+	assert.Len(t, parents, 2)
+	assert.Equal(t, spans[2].SpanID, parents[1].data.SpanID)
+}